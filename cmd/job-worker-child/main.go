@@ -0,0 +1,19 @@
+// job-worker-child is a minimal helper binary that can be used as the
+// worker.Config.ReexecCommand instead of reexecuting the server itself, so
+// that only a small amount of code runs with the privileges needed to create
+// namespaces. It takes no arguments, the command of a job, and its args, are
+// read from job.SpecFD.
+package main
+
+import (
+	"os"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/worker"
+)
+
+func main() {
+	if err := worker.StartChild(); err != nil {
+		// the error has already been reported to the parent
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,163 @@
+// Package audit implements a bounded, in-memory log of the actions users take
+// on jobs that can be queried by administrators.
+package audit
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+//go:generate stringer -type=Action -trimprefix=Action
+
+// Action is the enum representing the operation a user performed
+type Action int
+
+// NOTE: keep this synced with jobworker.proto:AuditAction
+const (
+	ActionUnspecified Action = iota
+	ActionStartJob
+	ActionStopJob
+	ActionJobStatus
+	ActionJobOutput
+	ActionGrantJobAccess
+	ActionRevokeJobAccess
+	ActionRemoveJob
+	ActionUpdateJobDeadline
+	ActionUpdateJobLimits
+)
+
+// Event is a single entry in the audit log
+type Event struct {
+	Seq    uint64 // monotonically increasing sequence number of the event
+	Time   time.Time
+	Tenant job.TenantID // the tenant of the user
+	UserID job.UserID   // the user that performed the action
+	JobID  job.ID       // the job the action was performed on, may be empty for failed starts
+	Action Action
+	Error  string // the error returned to the user, empty on success
+
+	// CorrelationID ties the job to an external system, if it was started
+	// with one
+	CorrelationID string
+}
+
+// DefaultMaxEvents is the number of events retained if New is called with
+// maxEvents <= 0
+const DefaultMaxEvents = 10000
+
+// Log is a goroutine safe audit log that retains a fixed number of the most
+// recent events
+type Log struct {
+	mu        sync.RWMutex
+	events    []Event // a ring buffer once it holds maxEvents
+	head      int     // the index of the oldest event
+	maxEvents int
+	seq       uint64
+}
+
+// New returns a new Log that retains up to maxEvents events
+func New(maxEvents int) *Log {
+	if maxEvents <= 0 {
+		maxEvents = DefaultMaxEvents
+	}
+	return &Log{maxEvents: maxEvents}
+}
+
+// Record adds an event to the log. Its Seq is assigned by the log and its Time
+// is set to now if it is zero. The oldest event is discarded if the log is
+// full.
+func (l *Log) Record(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	e.Seq = l.seq
+
+	if len(l.events) < l.maxEvents {
+		l.events = append(l.events, e)
+		return
+	}
+
+	// the oldest event is overwritten
+	l.events[l.head] = e
+	l.head = (l.head + 1) % len(l.events)
+}
+
+// Query filters the events in the log. Zero valued fields match all events.
+type Query struct {
+	Tenant job.TenantID
+	UserID job.UserID
+	JobID  job.ID
+	Action Action
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+
+	CorrelationID string
+
+	PageSize  int    // the maximum number of events to return, DefaultPageSize if <= 0
+	PageToken string // the NextPageToken from a previous query with the same filters
+}
+
+// DefaultPageSize is the number of events returned if Query.PageSize is <= 0
+const DefaultPageSize = 100
+
+// ErrInvalidPageToken is returned by Query if the page token is malformed
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// matches returns true if e is matched by the filters in q
+func (q *Query) matches(e *Event) bool {
+	switch {
+	case q.Tenant != "" && e.Tenant != q.Tenant,
+		q.UserID != "" && e.UserID != q.UserID,
+		q.JobID != (job.ID{}) && e.JobID != q.JobID,
+		q.Action != ActionUnspecified && e.Action != q.Action,
+		q.CorrelationID != "" && e.CorrelationID != q.CorrelationID,
+		!q.Since.IsZero() && e.Time.Before(q.Since),
+		!q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// Query returns, oldest first, up to q.PageSize events matching q. If more
+// events may match, nextPageToken will be non-empty and can be used to get the
+// next page.
+func (l *Log) Query(q *Query) (events []Event, nextPageToken string, err error) {
+	var after uint64
+	if q.PageToken != "" {
+		if after, err = strconv.ParseUint(q.PageToken, 10, 64); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+
+	pageSize := q.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for i := range l.events {
+		e := &l.events[(l.head+i)%len(l.events)]
+		if e.Seq <= after || !q.matches(e) {
+			continue
+		}
+
+		if len(events) == pageSize {
+			return events, strconv.FormatUint(events[len(events)-1].Seq, 10), nil
+		}
+
+		events = append(events, *e)
+	}
+
+	return events, "", nil
+}
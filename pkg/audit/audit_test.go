@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+
+	jobID, err := job.NewID()
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	t.Run("query", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		l := New(0)
+		l.Record(Event{Time: now, UserID: "a", JobID: jobID, Action: ActionStartJob})
+		l.Record(Event{Time: now.Add(time.Second), UserID: "b", Action: ActionJobStatus, Error: "job not found"})
+		l.Record(Event{Time: now.Add(2 * time.Second), UserID: "a", JobID: jobID, Action: ActionStopJob})
+
+		events, next, err := l.Query(&Query{UserID: "a"})
+		require.NoError(err)
+		assert.Empty(next)
+		require.Len(events, 2)
+		assert.Equal(ActionStartJob, events[0].Action)
+		assert.Equal(uint64(1), events[0].Seq)
+		assert.Equal(ActionStopJob, events[1].Action)
+
+		events, _, err = l.Query(&Query{Action: ActionJobStatus})
+		require.NoError(err)
+		require.Len(events, 1)
+		assert.Equal(job.UserID("b"), events[0].UserID)
+
+		events, _, err = l.Query(&Query{JobID: jobID, Since: now.Add(time.Second)})
+		require.NoError(err)
+		require.Len(events, 1)
+		assert.Equal(ActionStopJob, events[0].Action)
+
+		events, _, err = l.Query(&Query{Until: now.Add(time.Second)})
+		require.NoError(err)
+		require.Len(events, 1)
+		assert.Equal(ActionStartJob, events[0].Action)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		l := New(0)
+		for range 5 {
+			l.Record(Event{UserID: "a", Action: ActionJobStatus})
+		}
+
+		var seqs []uint64
+		q := Query{PageSize: 2}
+		for {
+			events, next, err := l.Query(&q)
+			require.NoError(err)
+			for _, e := range events {
+				seqs = append(seqs, e.Seq)
+			}
+			if next == "" {
+				break
+			}
+			q.PageToken = next
+		}
+		assert.Equal([]uint64{1, 2, 3, 4, 5}, seqs)
+
+		_, _, err := l.Query(&Query{PageToken: "foo"})
+		require.ErrorIs(err, ErrInvalidPageToken)
+	})
+
+	t.Run("max-events", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		l := New(2)
+		for range 3 {
+			l.Record(Event{UserID: "a", Action: ActionJobStatus})
+		}
+
+		events, _, err := l.Query(&Query{})
+		require.NoError(err)
+		require.Len(events, 2)
+		assert.Equal(uint64(2), events[0].Seq)
+		assert.Equal(uint64(3), events[1].Seq)
+
+		// the oldest events keep being discarded as the log wraps around
+		for range 5 {
+			l.Record(Event{UserID: "a", Action: ActionJobStatus})
+		}
+
+		events, _, err = l.Query(&Query{PageSize: 1})
+		require.NoError(err)
+		require.Len(events, 1)
+		assert.Equal(uint64(7), events[0].Seq)
+
+		events, _, err = l.Query(&Query{})
+		require.NoError(err)
+		require.Len(events, 2)
+		assert.Equal(uint64(7), events[0].Seq)
+		assert.Equal(uint64(8), events[1].Seq)
+	})
+}
+
+func BenchmarkRecord(b *testing.B) {
+	// every event discards the oldest one
+	l := New(DefaultMaxEvents)
+	for range DefaultMaxEvents {
+		l.Record(Event{UserID: "a", Action: ActionJobStatus})
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Record(Event{UserID: "a", Action: ActionJobStatus})
+		}
+	})
+}
@@ -0,0 +1,105 @@
+// Package clock abstracts the passage of time so that the timeouts, retention
+// and scheduling of jobs can be tested without waiting for real time to pass.
+// See workertest.Clock for a fake implementation.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and runs functions after a duration has passed
+type Clock interface {
+	Now() time.Time
+
+	// AfterFunc waits for d to pass and then calls f in its own goroutine
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by Clock.AfterFunc. *time.Timer implements it.
+type Timer interface {
+	// Stop prevents the timer from firing. It returns false if it already
+	// fired or was stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after d. It returns false if it had
+	// already fired or been stopped.
+	Reset(d time.Duration) bool
+}
+
+// Real is the Clock of the system
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Since returns the time elapsed on c since t
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Until returns the duration on c until t
+func Until(c Clock, t time.Time) time.Duration {
+	return t.Sub(c.Now())
+}
+
+// Ticker delivers the time of a Clock on C every d, like a time.Ticker. Like a
+// time.Ticker, ticks are dropped if they aren't received before the next one.
+type Ticker struct {
+	C <-chan time.Time
+
+	c     chan time.Time
+	clock Clock
+	d     time.Duration
+
+	mu      sync.Mutex
+	timer   Timer
+	stopped bool
+}
+
+// NewTicker returns a Ticker that ticks every d on c. d must be greater than
+// zero.
+func NewTicker(c Clock, d time.Duration) *Ticker {
+	ch := make(chan time.Time, 1)
+	t := Ticker{C: ch, c: ch, clock: c, d: d}
+
+	t.mu.Lock()
+	t.timer = c.AfterFunc(d, t.tick)
+	t.mu.Unlock()
+
+	return &t
+}
+
+// tick delivers the time, unless the last tick hasn't been received, and sets
+// the timer for the next one
+func (t *Ticker) tick() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped {
+		return
+	}
+
+	select {
+	case t.c <- t.clock.Now():
+	default:
+	}
+
+	t.timer = t.clock.AfterFunc(t.d, t.tick)
+}
+
+// Stop turns off the ticker. Like time.Ticker.Stop, it doesn't close C.
+func (t *Ticker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopped = true
+	t.timer.Stop()
+}
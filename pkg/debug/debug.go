@@ -0,0 +1,117 @@
+// Package debug provides an optional HTTP server exposing net/http/pprof and
+// expvar so that operators can profile the job worker under load. It must
+// either be bound to a loopback address or be protected by mTLS with only
+// administrators authorized.
+package debug
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Config configures the debug server
+type Config struct {
+	// Addr is the address to listen on. It must be a loopback address (e.g.
+	// "localhost:6060") unless TLSConfig is set.
+	Addr string
+
+	// TLSConfig is optional and, if set, must require and verify client
+	// certificates
+	TLSConfig *tls.Config
+
+	// Authorize reports whether the verified client certificate belongs to an
+	// administrator. It is required when TLSConfig is set.
+	Authorize func(cert *x509.Certificate) bool
+}
+
+var (
+	// ErrLoopbackRequired is returned by NewServer if Addr is not a loopback
+	// address and TLSConfig is not set
+	ErrLoopbackRequired = errors.New("debug server must listen on a loopback address unless tls is configured")
+
+	// ErrClientAuthRequired is returned by NewServer if TLSConfig doesn't
+	// require and verify client certificates
+	ErrClientAuthRequired = errors.New("debug server tls config must require and verify client certificates")
+
+	// ErrAuthorizeRequired is returned by NewServer if TLSConfig is set but
+	// Authorize is not
+	ErrAuthorizeRequired = errors.New("debug server requires an authorize func when tls is configured")
+)
+
+// readHeaderTimeout protects the debug server from slowloris attacks
+const readHeaderTimeout = 10 * time.Second
+
+// Handler returns an http.Handler that serves the pprof endpoints under
+// /debug/pprof/ and expvar at /debug/vars
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// NewServer returns an http.Server for the debug endpoints. When TLS is
+// configured, the caller must use ListenAndServeTLS("", "") to start it.
+func NewServer(cfg *Config) (*http.Server, error) {
+	srv := http.Server{
+		Addr:              cfg.Addr,
+		Handler:           Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	if cfg.TLSConfig == nil {
+		if !isLoopback(cfg.Addr) {
+			return nil, ErrLoopbackRequired
+		}
+		return &srv, nil
+	}
+
+	if cfg.TLSConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		return nil, ErrClientAuthRequired
+	}
+
+	if cfg.Authorize == nil {
+		return nil, ErrAuthorizeRequired
+	}
+
+	srv.TLSConfig = cfg.TLSConfig
+	srv.Handler = authorize(cfg.Authorize, srv.Handler)
+
+	return &srv, nil
+}
+
+// isLoopback returns true if the host of addr is a loopback address
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorize only permits requests with a client certificate accepted by fn
+func authorize(fn func(*x509.Certificate) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || !fn(r.TLS.PeerCertificates[0]) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,75 @@
+package debug
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("loopback", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+
+		for _, addr := range []string{"localhost:6060", "127.0.0.1:6060", "[::1]:6060"} {
+			_, err := NewServer(&Config{Addr: addr})
+			require.NoError(err, addr)
+		}
+
+		for _, addr := range []string{":6060", "0.0.0.0:6060", "10.0.0.1:6060"} {
+			_, err := NewServer(&Config{Addr: addr})
+			require.ErrorIs(err, ErrLoopbackRequired, addr)
+		}
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		_, err := NewServer(&Config{Addr: ":6060", TLSConfig: &tls.Config{}}) //nolint:gosec
+		require.ErrorIs(err, ErrClientAuthRequired)
+
+		tlsConfig := tls.Config{ClientAuth: tls.RequireAndVerifyClientCert} //nolint:gosec
+		_, err = NewServer(&Config{Addr: ":6060", TLSConfig: &tlsConfig})
+		require.ErrorIs(err, ErrAuthorizeRequired)
+
+		srv, err := NewServer(&Config{
+			Addr:      ":6060",
+			TLSConfig: &tlsConfig,
+			Authorize: func(cert *x509.Certificate) bool {
+				return cert.Subject.CommonName == "admin"
+			},
+		})
+		require.NoError(err)
+
+		for name, want := range map[string]int{"admin": http.StatusOK, "user": http.StatusForbidden} {
+			r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: name}}},
+			}
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, r)
+			assert.Equal(want, w.Code, name)
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	for _, path := range []string{"/debug/vars", "/debug/pprof/", "/debug/pprof/goroutine"} {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(http.StatusOK, w.Code, path)
+	}
+}
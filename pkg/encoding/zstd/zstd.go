@@ -0,0 +1,91 @@
+// Package zstd implements and registers a zstd compressor for gRPC. Importing
+// this package, on both the client and the server, makes the compressor
+// available. Clients opt in per call with grpc.UseCompressor(zstd.Name) and
+// the server responds using the same compressor the request was sent with.
+package zstd
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the name registered for the zstd compressor
+const Name = "zstd"
+
+func init() {
+	encoding.RegisterCompressor(&compressor{})
+}
+
+// compressor implements encoding.Compressor and pools encoders and decoders
+// since they are relatively expensive to create
+type compressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+// writer returns its encoder to the pool once closed
+type writer struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+// Close flushes any buffered data and returns the encoder to the pool
+func (w *writer) Close() error {
+	defer w.pool.Put(w.Encoder)
+	return w.Encoder.Close()
+}
+
+// reader returns its decoder to the pool once all data has been read
+type reader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+// Read decompresses data into p and returns the decoder to the pool after the
+// entire message has been read
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.Decoder.Read(p)
+	if errors.Is(err, io.EOF) {
+		r.pool.Put(r.Decoder)
+	}
+	return n, err
+}
+
+// Compress implements encoding.Compressor
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, ok := c.encoders.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		if enc, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	} else {
+		enc.Reset(w)
+	}
+
+	return &writer{Encoder: enc, pool: &c.encoders}, nil
+}
+
+// Decompress implements encoding.Compressor
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, ok := c.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		if dec, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	} else if err := dec.Reset(r); err != nil {
+		return nil, err
+	}
+
+	return &reader{Decoder: dec, pool: &c.decoders}, nil
+}
+
+// Name implements encoding.Compressor
+func (c *compressor) Name() string {
+	return Name
+}
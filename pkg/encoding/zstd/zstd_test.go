@@ -0,0 +1,40 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+)
+
+func TestCompressor(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	c := encoding.GetCompressor(Name)
+	require.NotNil(c)
+
+	msg := strings.Repeat("y\n", 64<<10)
+
+	// run more than once to exercise the pooled encoders and decoders
+	for range 3 {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		require.NoError(err)
+		_, err = io.WriteString(w, msg)
+		require.NoError(err)
+		require.NoError(w.Close())
+		assert.Less(buf.Len(), len(msg)/10)
+
+		r, err := c.Decompress(&buf)
+		require.NoError(err)
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		assert.Equal(msg, string(data))
+	}
+}
@@ -0,0 +1,111 @@
+// Package event defines the job lifecycle events that are delivered to
+// external systems when jobs change state.
+package event
+
+import (
+	"errors"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// Type is the kind of job lifecycle event
+type Type string
+
+const (
+	TypeStarted    Type = "job.started"     // the job was started
+	TypeCompleted  Type = "job.completed"   // the job exited on its own with a 0 exit code
+	TypeFailed     Type = "job.failed"      // the job exited on its own with an error or non-zero exit code
+	TypeStopped    Type = "job.stopped"     // the job was stopped by a user
+	TypeStartError Type = "job.start_error" // the job failed to start
+	TypeQueued     Type = "job.queued"      // the job is waiting for room to start
+
+	// TypeStartDeadlineExceeded means the job was queued, but there was no
+	// room to start it by its start-by time
+	TypeStartDeadlineExceeded Type = "job.start_deadline_exceeded"
+
+	TypeThrottled   Type = "job.throttled"    // the running job is being heavily cpu throttled
+	TypeMemoryLimit Type = "job.memory_limit" // the running job hit its memory limit
+	TypeReady       Type = "job.ready"        // the running job passed its readiness probe
+	TypeRestarting  Type = "job.restarting"   // the job exited and will be restarted
+)
+
+// Terminal returns true if the event type describes a job that will not
+// change state again
+func (t Type) Terminal() bool {
+	switch t {
+	case TypeStarted, TypeQueued, TypeThrottled, TypeMemoryLimit, TypeReady, TypeRestarting:
+		return false
+	default:
+		return true
+	}
+}
+
+// Event describes a job that changed state
+type Event struct {
+	Type          Type      `json:"type"`
+	Time          time.Time `json:"time"`
+	JobID         string    `json:"job_id"`
+	UserID        string    `json:"user_id"`
+	Tenant        string    `json:"tenant,omitempty"`
+	Status        string    `json:"status"`
+	StopReason    string    `json:"stop_reason,omitempty"`
+	ExitCode      *int      `json:"exit_code,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Message       string    `json:"message,omitempty"` // describes alerts, like TypeThrottled
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+// ForJob returns the event describing the current state of j. Jobs that have
+// not been started are described as queued.
+func ForJob(j *job.Job) *Event {
+	e := Event{
+		Time:          time.Now(),
+		JobID:         j.ID().String(),
+		UserID:        j.UserID().String(),
+		Tenant:        j.TenantID().String(),
+		Status:        j.Status().String(),
+		CorrelationID: j.CorrelationID(),
+	}
+
+	if r := j.StopReason(); r != job.StopReasonNone {
+		e.StopReason = r.String()
+	}
+
+	if ec, ok := j.ExitCode(); ok {
+		v := ec.Int()
+		e.ExitCode = &v
+	}
+
+	if err := j.Error(); err != nil {
+		e.Error = err.Error()
+	}
+
+	switch {
+	case j.Status() == job.StatusNotStarted:
+		e.Type = TypeQueued
+	case j.Status() == job.StatusRunning:
+		e.Type = TypeStarted
+	case j.Status() == job.StatusStartError && errors.Is(j.Error(), job.ErrStartDeadlineExceeded):
+		e.Type = TypeStartDeadlineExceeded
+	case j.Status() == job.StatusStartError:
+		e.Type = TypeStartError
+	case j.Status() == job.StatusStopped:
+		e.Type = TypeStopped
+	case e.Error == "" && e.ExitCode != nil && *e.ExitCode == 0:
+		e.Type = TypeCompleted
+	default:
+		e.Type = TypeFailed
+	}
+
+	return &e
+}
+
+// Alert returns an event of type t, with message describing it, about the
+// running job j
+func Alert(j *job.Job, t Type, message string) *Event {
+	e := ForJob(j)
+	e.Type = t
+	e.Message = message
+	return e
+}
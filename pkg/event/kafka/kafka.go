@@ -0,0 +1,78 @@
+// Package kafka implements an event.Sink that produces job lifecycle events to
+// a Kafka topic.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+)
+
+// DefaultTopic is used if Config.Topic is empty
+const DefaultTopic = "job-worker.events"
+
+// Config configures a Sink
+type Config struct {
+	Brokers []string // e.g. []string{"127.0.0.1:9092"}
+	Topic   string
+}
+
+// Sink produces events, encoded as json, to a Kafka topic. Messages are keyed
+// by job id so that all events for a job land on the same partition, in order.
+type Sink struct {
+	w *kafka.Writer
+}
+
+// ensure Sink implements the event.Sink interface
+var _ event.Sink = (*Sink)(nil)
+
+// ErrBrokersRequired is returned by New if no brokers are configured
+var ErrBrokersRequired = errors.New("kafka brokers are required")
+
+// New returns a new Sink. Connections to the brokers are made lazily. Close
+// must be called to flush and release resources.
+func New(cfg *Config) (*Sink, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, ErrBrokersRequired
+	}
+
+	topic := cfg.Topic
+	if topic == "" {
+		topic = DefaultTopic
+	}
+
+	return &Sink{
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+		},
+	}, nil
+}
+
+// Notify produces e and waits for it to be acknowledged by the broker
+func (s *Sink) Notify(ctx context.Context, e *event.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return s.w.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(e.JobID),
+		Value: data,
+		Headers: []kafka.Header{{
+			Key:   "type",
+			Value: []byte(e.Type),
+		}},
+	})
+}
+
+// Close flushes any pending events and closes the writer
+func (s *Sink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,84 @@
+// Package nats implements an event.Sink that publishes job lifecycle events to
+// NATS.
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+)
+
+// DefaultSubject is used if Config.Subject is empty
+const DefaultSubject = "job-worker.events"
+
+// Config configures a Sink
+type Config struct {
+	URL string // e.g. "nats://127.0.0.1:4222"
+
+	// Subject is the prefix of the subject events are published to. Each
+	// event is published to "<Subject>.<event.Type>", e.g.
+	// "job-worker.events.job.completed", so subscribers can filter by type.
+	Subject string
+
+	Options []nats.Option // optional, additional connection options (e.g. credentials, tls)
+}
+
+// Sink publishes events, encoded as json, to NATS
+type Sink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// ensure Sink implements the event.Sink interface
+var _ event.Sink = (*Sink)(nil)
+
+// ErrURLRequired is returned by New if the url is empty
+var ErrURLRequired = errors.New("nats url is required")
+
+// New connects to NATS and returns a new Sink. Close must be called to release
+// the connection.
+func New(cfg *Config) (*Sink, error) {
+	if cfg.URL == "" {
+		return nil, ErrURLRequired
+	}
+
+	conn, err := nats.Connect(cfg.URL, cfg.Options...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := Sink{
+		conn:    conn,
+		subject: cfg.Subject,
+	}
+
+	if s.subject == "" {
+		s.subject = DefaultSubject
+	}
+
+	return &s, nil
+}
+
+// Notify publishes e and waits for the server to acknowledge it has been
+// received
+func (s *Sink) Notify(ctx context.Context, e *event.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err = s.conn.Publish(s.subject+"."+string(e.Type), data); err != nil {
+		return err
+	}
+
+	return s.conn.FlushWithContext(ctx)
+}
+
+// Close drains any buffered events and closes the connection
+func (s *Sink) Close() error {
+	return s.conn.Drain()
+}
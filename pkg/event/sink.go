@@ -0,0 +1,10 @@
+package event
+
+import "context"
+
+// Sink receives job lifecycle events. Implementations must be goroutine safe.
+type Sink interface {
+	// Notify delivers e to the sink. It may block until e has been delivered
+	// or ctx is done.
+	Notify(ctx context.Context, e *Event) error
+}
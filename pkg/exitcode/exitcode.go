@@ -0,0 +1,71 @@
+// Package exitcode defines the exit codes of the job-worker CLI. They are a
+// stable contract so that scripts and automation can tell why a command
+// failed without parsing its output. New codes may be added, but existing
+// codes never change meaning.
+package exitcode
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+const (
+	OK               = 0  // the command succeeded
+	Error            = 1  // the command failed for a reason not covered below
+	Usage            = 2  // the command was invoked incorrectly
+	NotFound         = 3  // the job does not exist or the user has no access to it
+	PermissionDenied = 4  // the user is not permitted to perform the operation
+	Unavailable      = 5  // the server could not be reached or timed out
+	JobBase          = 10 // a job that exited with code n > 0 is reported as JobBase+n
+
+	// Max is the largest exit code, job exit codes that would exceed it are
+	// reported as Max
+	Max = 255
+)
+
+// ErrUsage should be wrapped by errors caused by invalid flags or arguments so
+// that FromError returns Usage for them
+var ErrUsage = errors.New("usage error")
+
+// FromError returns the exit code for err, which may have been returned from a
+// gRPC call. A nil error is OK.
+func FromError(err error) int {
+	if err == nil {
+		return OK
+	}
+
+	if errors.Is(err, ErrUsage) {
+		return Usage
+	}
+
+	switch status.Code(err) {
+	case codes.NotFound:
+		return NotFound
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return PermissionDenied
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return Unavailable
+	case codes.InvalidArgument:
+		return Usage
+	default:
+		return Error
+	}
+}
+
+// FromJob returns the exit code for a command that waited for a job that exited
+// with code. A job that succeeded is OK, otherwise it is JobBase+code, up to
+// Max, so that it never collides with the other exit codes.
+func FromJob(code job.ExitCode) int {
+	switch {
+	case code.Int() == 0:
+		return OK
+	case code.Int() < 0:
+		return Error
+	default:
+		return min(JobBase+code.Int(), Max)
+	}
+}
@@ -0,0 +1,50 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+func TestFromError(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "nil", err: nil, code: OK},
+		{name: "usage", err: fmt.Errorf("%w: job id is required", ErrUsage), code: Usage},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "bad"), code: Usage},
+		{name: "not found", err: status.Error(codes.NotFound, "job not found"), code: NotFound},
+		{name: "permission denied", err: status.Error(codes.PermissionDenied, "no"), code: PermissionDenied},
+		{name: "unauthenticated", err: status.Error(codes.Unauthenticated, "no"), code: PermissionDenied},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "down"), code: Unavailable},
+		{name: "deadline", err: status.Error(codes.DeadlineExceeded, "slow"), code: Unavailable},
+		{name: "internal", err: status.Error(codes.Internal, "oops"), code: Error},
+		{name: "other", err: errors.New("other"), code: Error},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.code, FromError(tc.err))
+		})
+	}
+}
+
+func TestFromJob(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	assert.Equal(OK, FromJob(0))
+	assert.Equal(JobBase+1, FromJob(1))
+	assert.Equal(Max, FromJob(Max-JobBase))
+	assert.Equal(Max, FromJob(255))
+	assert.Equal(Error, FromJob(job.ExitCode(-1)))
+}
@@ -0,0 +1,154 @@
+// Package handoff passes a listening socket from a running server to a newly
+// executed, possibly upgraded, copy of it so that the server can be restarted
+// without refusing connections. The old process starts the new one with
+// Upgrade, waits for it to call Ready and then drains its own connections.
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	// listenerEnv is set, in the new process, to the fd of the inherited
+	// listener
+	listenerEnv = "JOB_WORKER_LISTENER_FD"
+
+	// readyEnv is set, in the new process, to the fd that Ready closes
+	readyEnv = "JOB_WORKER_READY_FD"
+)
+
+var (
+	// ErrUnsupportedListener is returned by Upgrade if the listener's file
+	// descriptor can't be passed to another process
+	ErrUnsupportedListener = errors.New("listener does not support handoff")
+
+	// ErrNotReady is returned by Upgrade if the new process exits without
+	// calling Ready
+	ErrNotReady = errors.New("new process exited before it was ready")
+)
+
+// filer is implemented by listeners, like *net.TCPListener and
+// *net.UnixListener, whose file descriptor can be passed to another process
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Listen returns the listener inherited from the process that called Upgrade
+// or, if there isn't one, a new listener on network and addr
+func Listen(network, addr string) (net.Listener, error) {
+	fd, ok := inheritedFD(listenerEnv)
+	if !ok {
+		return net.Listen(network, addr)
+	}
+
+	f := os.NewFile(fd, "listener")
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("error using inherited listener: %w", err)
+	}
+
+	return ln, nil
+}
+
+// inheritedFD returns the fd set in the environment variable key, and unsets
+// it so that it isn't inherited by further children, like jobs
+func inheritedFD(key string) (uintptr, bool) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return 0, false
+	}
+	_ = os.Unsetenv(key)
+
+	fd, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return uintptr(fd), true
+}
+
+// Ready tells the process that called Upgrade that this process is ready to
+// accept connections. It does nothing if this process was not started by
+// Upgrade.
+func Ready() error {
+	fd, ok := inheritedFD(readyEnv)
+	if !ok {
+		return nil
+	}
+
+	f := os.NewFile(fd, "ready")
+	defer f.Close()
+
+	_, err := f.Write([]byte{1})
+	return err
+}
+
+// Upgrade executes command, with args, passing it ln. It returns once the new
+// process calls Ready, at which point the caller should stop accepting
+// connections on ln and gracefully drain its existing ones. If the new process
+// exits before it is ready, ErrNotReady is returned, and if ctx is done first
+// the new process is killed.
+func Upgrade(ctx context.Context, ln net.Listener, command string, args ...string) (*os.Process, error) {
+	fl, ok := ln.(filer)
+	if !ok {
+		return nil, ErrUnsupportedListener
+	}
+
+	lnFile, err := fl.File()
+	if err != nil {
+		return nil, err
+	}
+	defer lnFile.Close()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(command, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{lnFile, readyW} // fds 3 and 4
+	cmd.Env = append(os.Environ(), listenerEnv+"=3", readyEnv+"=4")
+
+	err = cmd.Start()
+	_ = readyW.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Ready writes a byte to the pipe, if the process exits first the pipe
+	// reaches EOF without one
+	ready := make(chan error, 1)
+	go func() {
+		_, err := readyR.Read(make([]byte, 1))
+		if errors.Is(err, io.EOF) {
+			err = ErrNotReady
+		}
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = context.Cause(ctx)
+	}
+
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+
+	return cmd.Process, nil
+}
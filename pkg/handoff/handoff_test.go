@@ -0,0 +1,99 @@
+package handoff
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const modeEnv = "HANDOFF_TEST_MODE"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(modeEnv) {
+	case "serve":
+		os.Exit(serve())
+	case "fail":
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// serve is run by the upgraded process, it answers a single connection on the
+// inherited listener
+func serve() int {
+	ln, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 1
+	}
+	defer ln.Close()
+
+	if err = Ready(); err != nil {
+		return 1
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		return 1
+	}
+	defer conn.Close()
+
+	_, _ = conn.Write([]byte("upgraded\n"))
+	return 0
+}
+
+func TestUpgrade(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("handoff", func(t *testing.T) {
+		ln, err := Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+
+		t.Setenv(modeEnv, "serve")
+		p, err := Upgrade(ctx, ln, os.Args[0])
+		require.NoError(t, err)
+
+		// the old process stops accepting, the new one keeps the socket open
+		require.NoError(t, ln.Close())
+
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "upgraded\n", line)
+
+		state, err := p.Wait()
+		require.NoError(t, err)
+		assert.True(t, state.Success())
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		ln, err := Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		t.Setenv(modeEnv, "fail")
+		_, err = Upgrade(ctx, ln, os.Args[0])
+		require.ErrorIs(t, err, ErrNotReady)
+	})
+
+	t.Run("unsupported listener", func(t *testing.T) {
+		_, err := Upgrade(ctx, fakeListener{}, os.Args[0])
+		require.ErrorIs(t, err, ErrUnsupportedListener)
+	})
+
+	t.Run("no inherited listener", func(t *testing.T) {
+		require.NoError(t, Ready())
+	})
+}
+
+type fakeListener struct{ net.Listener }
@@ -0,0 +1,155 @@
+package interceptor
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DrainingKey is set, in the trailer of a stream, to "true" if the stream
+	// was ended because the server is draining
+	DrainingKey = "job-worker-draining"
+
+	// ResumeOffsetKey is set, in the trailer of an output stream ended because
+	// the server is draining, to the offset that the client should resume
+	// streaming from on the replacement server
+	ResumeOffsetKey = "job-worker-resume-offset"
+)
+
+// errDraining is returned to clients whose stream was ended because the
+// server is draining. codes.Unavailable tells them it is safe to retry.
+var errDraining = status.Error(codes.Unavailable, "server is draining")
+
+// Drainer ends long lived streams, like output streams and watches, when the
+// server shuts down. grpc.Server.GracefulStop sends GOAWAY so that clients
+// reconnect elsewhere, but it then waits for all streams to end on their own,
+// which streams that follow running jobs may never do.
+//
+// Streams are given a grace period to end on their own once Drain is called,
+// then their context is canceled. Handlers must return when their context is
+// done and should call SetResumeOffset before doing so.
+type Drainer struct {
+	grace time.Duration
+
+	once     sync.Once
+	draining chan struct{}
+}
+
+// NewDrainer returns a Drainer that cancels streams grace after Drain is
+// called
+func NewDrainer(grace time.Duration) *Drainer {
+	return &Drainer{
+		grace:    grace,
+		draining: make(chan struct{}),
+	}
+}
+
+// Drain starts draining. It should be called just before GracefulStop. It is
+// safe to call more than once.
+func (d *Drainer) Drain() {
+	d.once.Do(func() { close(d.draining) })
+}
+
+// Draining returns a channel that is closed when Drain is called
+func (d *Drainer) Draining() <-chan struct{} {
+	return d.draining
+}
+
+// isDraining returns true if Drain has been called
+func (d *Drainer) isDraining() bool {
+	select {
+	case <-d.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// drainContext returns a context that is canceled grace after Drain is called
+func (d *Drainer) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.draining:
+		}
+
+		t := time.NewTimer(d.grace)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+			cancel()
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// drainStream is a grpc.ServerStream whose context is canceled when the
+// server is drained
+type drainStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *drainStream) Context() context.Context {
+	return s.ctx
+}
+
+// Interceptor returns an interceptor that ends streams when the server is
+// drained. Streams that are still running when the server starts draining, and
+// end with an error, or are canceled by the Drainer, return codes.Unavailable
+// with DrainingKey set in their trailer. Unary rpcs are left to GracefulStop.
+func (d *Drainer) Interceptor() Interceptor {
+	return Interceptor{
+		Name: "drain",
+		Stream: func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, cancel := d.drainContext(ss.Context())
+			defer cancel()
+
+			err := handler(srv, &drainStream{ServerStream: ss, ctx: ctx})
+			if !d.isDraining() || (err == nil && ctx.Err() == nil) {
+				return err
+			}
+
+			ss.SetTrailer(metadata.Pairs(DrainingKey, "true"))
+			return errDraining
+		},
+	}
+}
+
+// SetResumeOffset sets ResumeOffsetKey in the trailer of ss. Output stream
+// handlers should call it with the offset of the next byte they would have
+// sent when their stream is ended by a Drainer.
+func SetResumeOffset(ss grpc.ServerStream, offset int64) {
+	ss.SetTrailer(metadata.Pairs(ResumeOffsetKey, strconv.FormatInt(offset, 10)))
+}
+
+// Drained reports whether a stream with the trailer md was ended because the
+// server was draining and, if so, the offset to resume output from. offset is
+// -1 if the trailer doesn't include one.
+func Drained(md metadata.MD) (drained bool, offset int64) {
+	if v := md.Get(DrainingKey); len(v) == 0 || v[0] != "true" {
+		return false, -1
+	}
+
+	offset = -1
+	if v := md.Get(ResumeOffsetKey); len(v) > 0 {
+		if n, err := strconv.ParseInt(v[0], 10, 64); err == nil && n >= 0 {
+			offset = n
+		}
+	}
+
+	return true, offset
+}
@@ -0,0 +1,222 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errInjected is returned by calls that fail with an injected fault. like the
+// errors of a real outage, codes.Unavailable tells clients to retry.
+var errInjected = status.Error(codes.Unavailable, "injected fault")
+
+// errDropped ends streams as if their connection was dropped
+var errDropped = status.Error(codes.Unavailable, "injected fault: connection dropped")
+
+// ErrInvalidFault is returned by ParseFaults if the spec is malformed
+var ErrInvalidFault = errors.New("invalid fault")
+
+// Fault describes faults to inject into the calls to a method so that the
+// retry logic of clients can be tested deterministically against a real
+// server. Faults must never be enabled in production, servers should only
+// configure them with a hidden flag.
+type Fault struct {
+	// Method is the name of the method, e.g. "StartJob", or the full method,
+	// e.g. "/jobworker.v1.JobWorkerService/StartJob", that the faults are
+	// injected into. If empty, they are injected into all methods.
+	Method string
+
+	// Unavailable fails every Nth call with codes.Unavailable, before it is
+	// handled. 1 fails all calls and 0 none.
+	Unavailable int
+
+	// Delay delays the response of unary calls, and each message sent on
+	// streams, by this long
+	Delay time.Duration
+
+	// DropAfter ends streams with codes.Unavailable, as if their connection
+	// was dropped, once they have sent this many messages. 0 never drops
+	// them.
+	DropAfter int
+}
+
+// matches returns true if the faults apply to fullMethod
+func (f *Fault) matches(fullMethod string) bool {
+	return f.Method == "" ||
+		f.Method == fullMethod ||
+		strings.HasSuffix(fullMethod, "/"+f.Method)
+}
+
+// fault is a Fault with a count of the calls it was applied to
+type fault struct {
+	Fault
+	calls atomic.Int64
+}
+
+// fail returns true if the call to fullMethod should fail with errInjected
+func (f *fault) fail(fullMethod string) bool {
+	if !f.matches(fullMethod) {
+		return false
+	}
+
+	n := f.calls.Add(1)
+	return f.Unavailable > 0 && n%int64(f.Unavailable) == 0
+}
+
+// delay waits for f.Delay or until ctx is done, whichever comes first
+func (f *fault) delay(ctx context.Context) {
+	if f.Delay <= 0 {
+		return
+	}
+
+	t := time.NewTimer(f.Delay)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// faultStream is a grpc.ServerStream that delays, and drops, the messages it
+// sends according to faults
+type faultStream struct {
+	grpc.ServerStream
+	faults  []*fault
+	sent    int
+	dropped bool
+}
+
+func (s *faultStream) SendMsg(m any) error {
+	for _, f := range s.faults {
+		if f.DropAfter > 0 && s.sent >= f.DropAfter {
+			s.dropped = true
+			return errDropped
+		}
+	}
+
+	for _, f := range s.faults {
+		f.delay(s.Context())
+	}
+
+	s.sent++
+	return s.ServerStream.SendMsg(m)
+}
+
+// Faults returns an interceptor that injects faults into calls. Each call is
+// subject to all of the faults that match its method.
+func Faults(faults ...Fault) Interceptor {
+	all := make([]*fault, len(faults))
+	for i := range faults {
+		all[i] = &fault{Fault: faults[i]}
+	}
+
+	// match returns the faults that apply to fullMethod, or errInjected if
+	// the call should fail
+	match := func(fullMethod string) ([]*fault, error) {
+		var ret []*fault
+		for _, f := range all {
+			if f.fail(fullMethod) {
+				return nil, errInjected
+			}
+			if f.matches(fullMethod) {
+				ret = append(ret, f)
+			}
+		}
+		return ret, nil
+	}
+
+	return Interceptor{
+		Name: "fault",
+		Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			matched, err := match(info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := handler(ctx, req)
+			for _, f := range matched {
+				f.delay(ctx)
+			}
+			return resp, err
+		},
+		Stream: func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			matched, err := match(info.FullMethod)
+			if err != nil {
+				return err
+			}
+
+			if len(matched) == 0 {
+				return handler(srv, ss)
+			}
+
+			fs := faultStream{ServerStream: ss, faults: matched}
+			err = handler(srv, &fs)
+
+			// handlers may not return the error of SendMsg as is
+			if fs.dropped {
+				return errDropped
+			}
+			return err
+		},
+	}
+}
+
+// ParseFaults parses faults from spec so that they can be configured with a
+// single flag. Faults are separated by ";" and each is a "," separated list
+// of key=value pairs of its fields, e.g.
+// "method=StartJob,unavailable=3;method=StreamJobOutput,delay=100ms,drop=10".
+// The keys are method, unavailable, delay and drop.
+func ParseFaults(spec string) ([]Fault, error) {
+	var faults []Fault
+
+	for _, rule := range strings.Split(spec, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		var (
+			f   Fault
+			err error
+		)
+		for _, field := range strings.Split(rule, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				return nil, fmt.Errorf("%w: %q is not key=value", ErrInvalidFault, field)
+			}
+
+			switch key {
+			case "method":
+				f.Method = value
+			case "unavailable":
+				f.Unavailable, err = strconv.Atoi(value)
+			case "delay":
+				f.Delay, err = time.ParseDuration(value)
+			case "drop":
+				f.DropAfter, err = strconv.Atoi(value)
+			default:
+				return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidFault, key)
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrInvalidFault, key, err)
+			}
+		}
+
+		if f.Unavailable < 0 || f.Delay < 0 || f.DropAfter < 0 {
+			return nil, fmt.Errorf("%w: %q has negative values", ErrInvalidFault, rule)
+		}
+
+		faults = append(faults, f)
+	}
+
+	return faults, nil
+}
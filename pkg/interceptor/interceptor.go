@@ -0,0 +1,81 @@
+// Package interceptor provides an ordered, configurable chain of gRPC server
+// interceptors, such as auth, audit, rate limiting and metrics, along with a
+// recovery interceptor that keeps handler panics from crashing the server.
+package interceptor
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Interceptor is a named pair of unary and stream server interceptors. Either
+// may be nil if the interceptor only applies to one kind of rpc.
+type Interceptor struct {
+	Name   string
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// Chain is an ordered list of interceptors. The first interceptor is the
+// outermost, it sees each request first and each response last.
+type Chain []Interceptor
+
+// ServerOptions returns the options that install the chain on a grpc.Server
+func (c Chain) ServerOptions() []grpc.ServerOption {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+
+	for _, i := range c {
+		if i.Unary != nil {
+			unary = append(unary, i.Unary)
+		}
+		if i.Stream != nil {
+			stream = append(stream, i.Stream)
+		}
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+}
+
+// WithRecovery returns the chain with Recovery prepended so that panics in any
+// of the other interceptors, or in the handlers, are recovered
+func (c Chain) WithRecovery() Chain {
+	return append(Chain{Recovery()}, c...)
+}
+
+// errInternal is returned to clients when a handler panics. the panic itself
+// is logged, but not returned, since it may contain sensitive information.
+var errInternal = status.Error(codes.Internal, "internal error")
+
+// Recovery returns an interceptor that converts panics into codes.Internal
+// errors instead of crashing the server
+func Recovery() Interceptor {
+	return Interceptor{
+		Name: "recovery",
+		Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ any, err error) {
+			defer recoverPanic(info.FullMethod, &err)
+			return handler(ctx, req)
+		},
+		Stream: func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			defer recoverPanic(info.FullMethod, &err)
+			return handler(srv, ss)
+		},
+	}
+}
+
+// recoverPanic must be deferred. if there was a panic it is logged and err is
+// set to errInternal.
+func recoverPanic(method string, err *error) {
+	if r := recover(); r != nil {
+		slog.Error("panic in grpc handler", "method", method, "panic", r, "stack", string(debug.Stack()))
+		*err = errInternal
+	}
+}
@@ -0,0 +1,224 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// panicServer is a health server whose Check handler panics
+type panicServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (panicServer) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	panic("boom")
+}
+
+func (panicServer) Watch(*healthpb.HealthCheckRequest, healthpb.Health_WatchServer) error {
+	panic("boom")
+}
+
+// recorder returns an interceptor that appends name to calls when it is
+// invoked
+func recorder(name string, mu *sync.Mutex, calls *[]string) Interceptor {
+	record := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		*calls = append(*calls, name)
+	}
+
+	return Interceptor{
+		Name: name,
+		Unary: func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			record()
+			return handler(ctx, req)
+		},
+		Stream: func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			record()
+			return handler(srv, ss)
+		},
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var calls []string
+
+	chain := Chain{
+		recorder("auth", &mu, &calls),
+		recorder("audit", &mu, &calls),
+		{Name: "unary-only", Unary: recorder("unary-only", &mu, &calls).Unary},
+	}.WithRecovery()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(chain.ServerOptions()...)
+	healthpb.RegisterHealthServer(srv, panicServer{})
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(err)
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	// the panic is converted to an internal error and the server keeps going
+	for range 2 {
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.Equal(codes.Internal, status.Code(err))
+	}
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(err)
+	_, err = stream.Recv()
+	assert.Equal(codes.Internal, status.Code(err))
+
+	assert.Equal([]string{
+		"auth", "audit", "unary-only",
+		"auth", "audit", "unary-only",
+		"auth", "audit",
+	}, calls)
+}
+
+func TestDrainer(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	drainer := NewDrainer(10 * time.Millisecond)
+	chain := Chain{
+		drainer.Interceptor(),
+		{
+			Name: "resume",
+			Stream: func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				err := handler(srv, ss)
+				SetResumeOffset(ss, 42)
+				return err
+			},
+		},
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(chain.ServerOptions()...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(err)
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	// the health watch stream never ends on its own
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(err)
+	_, err = stream.Recv()
+	require.NoError(err)
+
+	drainer.Drain()
+	drainer.Drain()
+	srv.GracefulStop()
+
+	_, err = stream.Recv()
+	assert.Equal(codes.Unavailable, status.Code(err))
+
+	drained, offset := Drained(stream.Trailer())
+	assert.True(drained)
+	assert.Equal(int64(42), offset)
+
+	drained, offset = Drained(metadata.Pairs(DrainingKey, "true"))
+	assert.True(drained)
+	assert.Equal(int64(-1), offset)
+
+	drained, _ = Drained(metadata.MD{})
+	assert.False(drained)
+}
+
+func TestFaults(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	faults, err := ParseFaults("method=Check,unavailable=2,delay=20ms; method=/grpc.health.v1.Health/Watch,drop=1")
+	require.NoError(err)
+	assert.Equal([]Fault{
+		{Method: "Check", Unavailable: 2, Delay: 20 * time.Millisecond},
+		{Method: "/grpc.health.v1.Health/Watch", DropAfter: 1},
+	}, faults)
+
+	for _, spec := range []string{"method", "color=red", "drop=-1", "delay=soon"} {
+		_, err = ParseFaults(spec)
+		require.ErrorIs(err, ErrInvalidFault, spec)
+	}
+
+	hs := health.NewServer()
+	chain := Chain{Faults(faults...)}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(chain.ServerOptions()...)
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(err)
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	// every second call fails, and the others are delayed
+	for i := range 4 {
+		start := time.Now()
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if i%2 == 1 {
+			assert.Equal(codes.Unavailable, status.Code(err))
+			continue
+		}
+		require.NoError(err)
+		assert.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+	}
+
+	// the stream is dropped after its first message
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(err)
+	_, err = stream.Recv()
+	require.NoError(err)
+
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	_, err = stream.Recv()
+	assert.Equal(codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,88 @@
+// Package retry retries operations that fail with transient errors, with
+// exponential backoff. It is shared by the packages that deliver to http
+// endpoints, like webhook and loki, so that they retry the same way.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is used if Policy.MaxAttempts is <= 0
+	DefaultMaxAttempts = 5
+
+	// DefaultInitialBackoff is used if Policy.InitialBackoff is <= 0
+	DefaultInitialBackoff = time.Second
+
+	// DefaultTimeout is the default timeout of each attempt
+	DefaultTimeout = 10 * time.Second
+)
+
+// Policy determines how often, and how long after each other, attempts are
+// made
+type Policy struct {
+	MaxAttempts    int           // the maximum number of attempts
+	InitialBackoff time.Duration // the delay before the first retry, doubled after each attempt
+}
+
+// retryable wraps errors that should cause the operation to be retried
+type retryable struct {
+	error
+}
+
+func (e retryable) Unwrap() error {
+	return e.error
+}
+
+// Retryable marks err as transient, so that Do makes another attempt
+func Retryable(err error) error {
+	return retryable{err}
+}
+
+// Do calls f until it succeeds, it returns an error that isn't Retryable,
+// p.MaxAttempts were made or ctx is done, and returns the error of the last
+// attempt, or that of ctx
+func Do(ctx context.Context, p Policy, f func(context.Context) error) error {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultInitialBackoff
+	}
+
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := f(ctx)
+
+		var rerr retryable
+		if err == nil || !errors.As(err, &rerr) || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// Status returns nil for a 2xx status code of a response from name, a
+// Retryable error for 429 and 5xx and an error for any other
+func Status(name string, code int) error {
+	switch {
+	case code >= 200 && code < 300:
+		return nil
+	case code == http.StatusTooManyRequests || code >= 500:
+		return Retryable(fmt.Errorf("%s returned status %d", name, code))
+	default:
+		return fmt.Errorf("%s returned status %d", name, code)
+	}
+}
@@ -0,0 +1,97 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+
+	p := Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	t.Run("retry", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		var attempts int
+		err := Do(context.Background(), p, func(context.Context) error {
+			if attempts++; attempts == 1 {
+				return Retryable(errTransient)
+			}
+			return nil
+		})
+		require.NoError(err)
+		assert.Equal(2, attempts)
+	})
+
+	t.Run("not-retryable", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		var attempts int
+		err := Do(context.Background(), p, func(context.Context) error {
+			attempts++
+			return errPermanent
+		})
+		require.ErrorIs(err, errPermanent)
+		assert.Equal(1, attempts)
+	})
+
+	t.Run("max-attempts", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		var attempts int
+		err := Do(context.Background(), p, func(context.Context) error {
+			attempts++
+			return Retryable(errTransient)
+		})
+		require.ErrorIs(err, errTransient)
+		assert.Equal(3, attempts)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var attempts int
+		err := Do(ctx, Policy{MaxAttempts: 3, InitialBackoff: time.Hour}, func(context.Context) error {
+			attempts++
+			cancel()
+			return Retryable(errTransient)
+		})
+		require.ErrorIs(err, context.Canceled)
+		assert.Equal(1, attempts)
+	})
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var rerr retryable
+
+	assert.NoError(Status("test", http.StatusNoContent))
+	assert.ErrorAs(Status("test", http.StatusTooManyRequests), &rerr)
+	assert.ErrorAs(Status("test", http.StatusBadGateway), &rerr)
+
+	err := Status("test", http.StatusBadRequest)
+	assert.EqualError(err, "test returned status 400")
+	assert.False(errors.As(err, &rerr))
+}
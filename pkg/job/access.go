@@ -0,0 +1,51 @@
+package job
+
+//go:generate stringer -type=Access -trimprefix=Access
+
+// Access is the level of access a user has to a job
+type Access int
+
+// NOTE: keep this synced with jobworker.proto:JobAccess
+const (
+	AccessNone Access = iota // the user may not access the job
+	AccessRead               // the user may get the status and output of the job
+	AccessFull               // the user may also stop the job
+)
+
+// Grant gives userID access to the job. It can't be used to change the access
+// of the owner of the job.
+func (j *Job) Grant(userID UserID, access Access) {
+	if userID == j.userID {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if access == AccessNone {
+		delete(j.grants, userID)
+		return
+	}
+
+	if j.grants == nil {
+		j.grants = map[UserID]Access{}
+	}
+	j.grants[userID] = access
+}
+
+// Revoke removes any access previously granted to userID
+func (j *Job) Revoke(userID UserID) {
+	j.Grant(userID, AccessNone)
+}
+
+// Access returns the access that userID has to the job. The owner of the job
+// always has AccessFull.
+func (j *Job) Access(userID UserID) Access {
+	if userID == j.userID {
+		return AccessFull
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.grants[userID]
+}
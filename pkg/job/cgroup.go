@@ -0,0 +1,47 @@
+package job
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// SetCGroup sets the path of the cgroup that the job runs in. When the job is
+// stopped, every process in the cgroup is killed, not just the job's own
+// process. It must be called before Start.
+func (j *Job) SetCGroup(path string) {
+	j.cgroup = path
+}
+
+// SetCGroupFD starts the command inside the cgroup that is open as fd, with
+// clone3(2) and CLONE_INTO_CGROUP, so that it is limited from the moment it is
+// created. fd must remain open until Start returns. It is ignored on platforms
+// other than linux and it must be called before Start.
+func (j *Job) SetCGroupFD(fd int) {
+	setCGroupFD(j.cmd.SysProcAttr, fd)
+}
+
+// killCGroup kills every process in the job's cgroup, if it has one, by
+// writing to cgroup.kill. it does nothing if cgroup.kill doesn't exist, which
+// it doesn't before linux 5.14.
+func (j *Job) killCGroup() error {
+	if j.cgroup == "" {
+		return nil
+	}
+
+	// the file must not be created if it doesn't exist
+	f, err := os.OpenFile(filepath.Join(j.cgroup, "cgroup.kill"), os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.WriteString("1"); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,145 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/wal"
+)
+
+// ErrInterrupted is the error of a recovered job that was still running when
+// the worker stopped
+var ErrInterrupted = errors.New("job was interrupted")
+
+// Metadata identifies the job that a write-ahead log belongs to
+type Metadata struct {
+	ID            ID                `json:"id"`
+	UserID        UserID            `json:"user_id"`
+	TenantID      TenantID          `json:"tenant_id,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Queue         string            `json:"queue,omitempty"`
+	GPUs          []string          `json:"gpus,omitempty"`
+}
+
+// Outcome describes how a job completed. It is the final record of the job's
+// write-ahead log.
+type Outcome struct {
+	Status     Status         `json:"status"`
+	StopReason StopReason     `json:"stop_reason,omitempty"`
+	ExitCode   *ExitCode      `json:"exit_code,omitempty"`
+	Signal     syscall.Signal `json:"signal,omitempty"`
+	Error      string         `json:"error,omitempty"`
+
+	// the monotonic clock isn't meaningful after a restart, so the runtime
+	// is recorded rather than recomputed from the wall clock times
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Runtime   time.Duration `json:"runtime,omitempty"`
+}
+
+// Metadata returns the metadata identifying the job
+func (j *Job) Metadata() *Metadata {
+	return &Metadata{
+		ID:            j.id,
+		UserID:        j.userID,
+		TenantID:      j.tenantID,
+		Description:   j.description,
+		Annotations:   maps.Clone(j.annotations),
+		CorrelationID: j.correlation,
+		Queue:         j.queue,
+		GPUs:          slices.Clone(j.gpus),
+	}
+}
+
+// SetWAL makes the job's output durable. Output is written to l, and fsync'd,
+// before it is made available to readers. When the job completes, its Outcome
+// is written as the final record and l is closed. It must be called before
+// Start.
+func (j *Job) SetWAL(l *wal.Log) {
+	j.wal = l
+	j.out.wal = l
+}
+
+// finishWAL writes the outcome of the job to its write-ahead log, if it has
+// one. it must be called after the final status is set and, for jobs that
+// started, before done is closed.
+func (j *Job) finishWAL() {
+	if j.wal == nil {
+		return
+	}
+
+	outcome := Outcome{
+		Status:     j.Status(),
+		StopReason: j.StopReason(),
+		ExitCode:   j.exitCode,
+		Signal:     j.signal,
+		StartTime:  j.StartTime(),
+		EndTime:    j.endTime.Round(0),
+		Runtime:    j.runtime,
+	}
+
+	if j.cmdErr != nil {
+		outcome.Error = j.cmdErr.Error()
+	}
+
+	data, err := json.Marshal(&outcome)
+	if err == nil {
+		err = j.wal.Finish(data)
+	}
+
+	if err != nil {
+		slog.Error("error finishing job wal", "job_id", j.id, "err", err)
+	}
+}
+
+// Recover returns a completed job, described by meta, that was recovered from
+// its write-ahead log. If outcome is nil, the job was still running when the
+// worker stopped and it has StatusInterrupted.
+func Recover(meta *Metadata, output []byte, outcome *Outcome) *Job {
+	j := Job{
+		id:          meta.ID,
+		userID:      meta.UserID,
+		tenantID:    meta.TenantID,
+		description: meta.Description,
+		annotations: maps.Clone(meta.Annotations),
+		correlation: meta.CorrelationID,
+		queue:       meta.Queue,
+		gpus:        slices.Clone(meta.GPUs),
+		done:        make(chan struct{}),
+		clock:       clock.Real,
+		cmdErr:      ErrInterrupted,
+	}
+	j.status.Store(int32(StatusInterrupted))
+
+	j.out.done = j.done
+	j.out.clock = j.clock
+	if len(output) > 0 {
+		_, _ = j.out.Write(output)
+	}
+
+	if outcome != nil {
+		j.status.Store(int32(outcome.Status))
+		j.stopReason.Store(int32(outcome.StopReason))
+		j.exitCode = outcome.ExitCode
+		j.signal = outcome.Signal
+		j.startTime = outcome.StartTime
+		j.endTime = outcome.EndTime
+		j.runtime = outcome.Runtime
+		j.cmdErr = nil
+		if outcome.Error != "" {
+			j.cmdErr = errors.New(outcome.Error)
+		}
+	}
+
+	close(j.done)
+
+	return &j
+}
@@ -1,6 +1,12 @@
 package job
 
-import "go.jetify.com/typeid"
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"go.jetify.com/typeid"
+)
 
 // Prefix is used to define the job typeid prefix
 type Prefix struct{}
@@ -17,3 +23,28 @@ type ID struct {
 func NewID() (ID, error) {
 	return typeid.New[ID]()
 }
+
+// NewIDFrom returns a new ID, like NewID, but its time is now and its random
+// bits are read from rand, so that tests can generate the same ids each time
+func NewIDFrom(now time.Time, rand io.Reader) (ID, error) {
+	// a uuidv7 is a 48 bit unix timestamp in milliseconds followed by the
+	// version, random bits, the variant and more random bits
+	var uuid [16]byte
+	if _, err := io.ReadFull(rand, uuid[6:]); err != nil {
+		return ID{}, err
+	}
+
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(now.UnixMilli()))
+	copy(uuid[:6], ms[2:])
+
+	uuid[6] = uuid[6]&0x0f | 0x70
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	return typeid.FromUUIDBytes[ID](uuid[:])
+}
+
+// ParseID parses the string form of an ID
+func ParseID(s string) (ID, error) {
+	return typeid.Parse[ID](s)
+}
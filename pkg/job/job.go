@@ -1,26 +1,75 @@
 package job
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
 	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer"
+	"github.com/joshuarubin/teleport-job-worker/pkg/wal"
 )
 
 // Job represents a system process
 type Job struct {
-	id     ID
-	userID UserID
-	cmd    *exec.Cmd
-	buf    *safebuffer.Buffer
-	status Status
-	done   chan struct{}
+	id          ID
+	userID      UserID
+	tenantID    TenantID
+	description string
+	annotations map[string]string
+	correlation string
+	queue       string
+	gpus        []string
+	cmd         *exec.Cmd
+	out         output
+	clock       clock.Clock
+	status      atomic.Int32 // a Status, it is read while the job is being waited on
+	started     chan struct{}
+	done        chan struct{}
+
+	mu     sync.RWMutex
+	grants map[UserID]Access // access granted to users other than the owner
+
+	stopReason  atomic.Int32 // set by stop so the final status is known before done closes
+	progress    progress
+	ready       ready
+	setup       setup
+	spec        spec
+	cgroup      string    // the path of the cgroup the job runs in, if any
+	jobObject   jobObject // the job object the job runs in, on windows
+	timeout     time.Duration
+	idleTimeout time.Duration
+	ttl         time.Duration // how long the job is kept once it is done
+
+	startTime time.Time // includes a monotonic clock reading, set by Start
+
+	deadlineMu    sync.Mutex
+	deadline      time.Time
+	givenDeadline time.Time // the deadline without the timeout applied, see Restart
+	timers        timers    // guarded by deadlineMu
+
+	restarts int
+	lastExit *Exit
 
 	// these values are only safe to read after done has closed
 	cmdErr   error
 	exitCode *ExitCode
+	signal   syscall.Signal
+	result   result
+	endTime  time.Time
+	runtime  time.Duration
+
+	wal *wal.Log // optional, makes the output durable
 }
 
 var (
@@ -31,6 +80,10 @@ var (
 	// ErrCommandRequired is returned by New if command is
 	// empty
 	ErrCommandRequired = errors.New("command is required")
+
+	// ErrStartDeadlineExceeded is the error of a job that could not be
+	// started by its latest acceptable start time
+	ErrStartDeadlineExceeded = errors.New("job could not be started by its start deadline")
 )
 
 // New creates, but does not start a new job
@@ -39,6 +92,23 @@ func New(
 	command string,
 	args []string,
 	env []string,
+) (*Job, error) {
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithID(id, userID, command, args, env)
+}
+
+// NewWithID is like New, but the job is identified by id, e.g. one from
+// NewIDFrom
+func NewWithID(
+	id ID,
+	userID UserID,
+	command string,
+	args []string,
+	env []string,
 ) (*Job, error) {
 	if userID == "" {
 		return nil, ErrUserIDRequired
@@ -47,21 +117,22 @@ func New(
 		return nil, ErrCommandRequired
 	}
 
-	id, err := NewID()
-	if err != nil {
-		return nil, err
-	}
-
 	j := Job{
-		id:     id,
-		userID: userID,
-		done:   make(chan struct{}),
-		cmd:    exec.Command(command, args...),
+		id:      id,
+		userID:  userID,
+		started: make(chan struct{}),
+		done:    make(chan struct{}),
+		cmd:     exec.Command(command, args...),
+		clock:   clock.Real,
 	}
 
-	j.buf = safebuffer.New(j.done)
-	j.cmd.Stdout = j.buf
-	j.cmd.Stderr = j.buf
+	// since stdout and stderr are the same writer, the command is given a
+	// single pipe for both of them, so the output is in exactly the order it
+	// was written, even when it is interleaved
+	j.out.done = j.done
+	j.out.clock = j.clock
+	j.cmd.Stdout = &j.out
+	j.cmd.Stderr = &j.out
 
 	j.cmd.SysProcAttr = sysProcAttr()
 
@@ -71,23 +142,132 @@ func New(
 	return &j, nil
 }
 
+// SetClock sets the clock that the job's times, deadline and idle timeout, and
+// how long its output readers have stalled, are measured with, by default it
+// is clock.Real. It must be called before Start.
+func (j *Job) SetClock(c clock.Clock) {
+	j.clock = c
+	j.out.clock = c
+}
+
+// SetSlowReaderPolicy sets the policy for handling output readers that stop
+// reading. It must be called before Start.
+func (j *Job) SetSlowReaderPolicy(policy safebuffer.SlowReaderPolicy) {
+	j.out.policy = policy
+}
+
+// SetMaxResultSize sets the maximum size of the result the job may write to
+// ResultFD. If <= 0, DefaultMaxResultSize is used. It must be called before
+// Start.
+func (j *Job) SetMaxResultSize(size int) {
+	j.result.maxSize = size
+}
+
+// SetEnv replaces the environment of the job's command, which otherwise
+// inherits the environment of the current process. It must be called before
+// Start.
+func (j *Job) SetEnv(env []string) {
+	j.cmd.Env = slices.Clone(env)
+}
+
 // Start the job process
 func (j *Job) Start() error {
-	if err := j.cmd.Start(); err != nil {
-		j.setStatus(StatusStartError)
+	if err := j.result.start(j.cmd); err != nil {
+		j.startError(err)
+		return err
+	}
+
+	if err := j.progress.start(j.cmd); err != nil {
+		j.result.started(err)
+		j.startError(err)
+		return err
+	}
+
+	if err := j.setup.start(j.cmd); err != nil {
+		j.result.started(err)
+		j.progress.started(err)
+		j.startError(err)
 		return err
 	}
+
+	if err := j.spec.start(j.cmd); err != nil {
+		j.result.started(err)
+		j.progress.started(err)
+		j.setup.started(err)
+		j.startError(err)
+		return err
+	}
+
+	err := j.cmd.Start()
+	if err == nil {
+		// on windows, the command is suspended until it is in its job object
+		if err = j.jobObject.assign(j.cmd.Process); err != nil {
+			_ = j.cmd.Process.Kill()
+			_ = j.cmd.Wait()
+		}
+	}
+	j.result.started(err)
+	j.progress.started(err)
+	j.setup.started(err)
+	j.spec.started(j.cmd, err)
+	if err != nil {
+		j.startError(err)
+		return err
+	}
+	j.startTime = j.clock.Now()
 	j.setStatus(StatusRunning)
+	close(j.started)
 	go j.wait()
+
+	j.startTimeouts(j.startTime)
+	if j.ready.check != nil {
+		go j.checkReadiness()
+	}
+
 	return nil
 }
 
+// startError completes a job that failed to start with err
+func (j *Job) startError(err error) {
+	j.jobObject.close()
+	j.cmdErr = err
+	j.setStatus(StatusStartError)
+	j.setEndTime()
+	j.finishWAL()
+	close(j.done)
+}
+
+// Cancel completes a job that will never be started, e.g. one that was queued
+// and never had room to start. If reason is StopReasonNone, the job has
+// StatusStartError with err as its error, otherwise it has StatusStopped with
+// reason as the reason it was stopped. It must only be called once, and never
+// after Start.
+func (j *Job) Cancel(reason StopReason, err error) {
+	if reason == StopReasonNone {
+		j.startError(err)
+		return
+	}
+
+	j.stopReason.Store(int32(reason))
+	j.setStatus(StatusStopped)
+	j.setEndTime()
+	j.finishWAL()
+	close(j.done)
+}
+
 // wait for the command to finish. sets the error returned by the command, if
-// any, extracts any exit code, sets status to completed and closes the done
-// channel
+// any, extracts any exit code, sets status to completed, stopped, or start error
+// if its wrapper failed to set it up, and closes the done channel
 func (j *Job) wait() {
 	defer func() {
-		j.setStatus(StatusCompleted)
+		j.stopTimeouts()
+		if j.StopReason() != StopReasonNone {
+			j.setStatus(StatusStopped)
+		} else {
+			j.setStatus(StatusCompleted)
+		}
+		j.setEndTime()
+		j.finishWAL()
 		close(j.done)
 	}()
 
@@ -95,6 +275,27 @@ func (j *Job) wait() {
 	// races
 
 	j.cmdErr = j.cmd.Wait()
+	j.jobObject.close()
+	j.result.wait()
+	j.progress.wait()
+	j.spec.wait()
+
+	// the command was never executed, so there is no exit code
+	if err := j.setup.wait(); err != nil {
+		j.cmdErr = err
+		j.setStatus(StatusStartError)
+		return
+	}
+
+	// output that couldn't be made durable is why the job failed, even if it
+	// was then killed by SIGPIPE
+	if err := j.out.walError(); err != nil && !errors.Is(j.cmdErr, err) {
+		if j.cmdErr == nil {
+			j.cmdErr = err
+		} else {
+			j.cmdErr = fmt.Errorf("%w: %w", err, j.cmdErr)
+		}
+	}
 
 	var ec ExitCode
 	if j.cmdErr == nil {
@@ -104,10 +305,18 @@ func (j *Job) wait() {
 	}
 
 	var eerr *exec.ExitError
-	if errors.As(j.cmdErr, &eerr) {
-		ec = ExitCode(eerr.ExitCode())
-		j.exitCode = &ec
+	if !errors.As(j.cmdErr, &eerr) {
+		return
 	}
+
+	// a process that was terminated by a signal has no exit code
+	if ws, ok := eerr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		j.signal = ws.Signal()
+		return
+	}
+
+	ec = ExitCode(eerr.ExitCode())
+	j.exitCode = &ec
 }
 
 // ID returns the job ID
@@ -120,11 +329,135 @@ func (j *Job) UserID() UserID {
 	return j.userID
 }
 
+// SetTenantID sets the tenant the job belongs to. It must be called before
+// Start.
+func (j *Job) SetTenantID(tenantID TenantID) {
+	j.tenantID = tenantID
+}
+
+// TenantID returns the tenant the job belongs to
+func (j *Job) TenantID() TenantID {
+	return j.tenantID
+}
+
+// SetDescription sets the free-form description and annotations that describe
+// what the job is for. It must be called before Start.
+func (j *Job) SetDescription(description string, annotations map[string]string) {
+	j.description = description
+	j.annotations = maps.Clone(annotations)
+}
+
+// Description returns the description of the job
+func (j *Job) Description() string {
+	return j.description
+}
+
+// Annotations returns a copy of the annotations of the job
+func (j *Job) Annotations() map[string]string {
+	return maps.Clone(j.annotations)
+}
+
+// SetCorrelationID sets the id, like a trace id or ticket number, that ties the
+// job to an external system. It must be called before Start.
+func (j *Job) SetCorrelationID(id string) {
+	j.correlation = id
+}
+
+// CorrelationID returns the id that ties the job to an external system
+func (j *Job) CorrelationID() string {
+	return j.correlation
+}
+
+// SetQueue sets the name of the queue the job was scheduled in. It must be
+// called before Start.
+func (j *Job) SetQueue(queue string) {
+	j.queue = queue
+}
+
+// Queue returns the name of the queue the job was scheduled in
+func (j *Job) Queue() string {
+	return j.queue
+}
+
+// SetGPUs sets the names of the gpus the job was granted access to. It must be
+// called before Start.
+func (j *Job) SetGPUs(gpus []string) {
+	j.gpus = slices.Clone(gpus)
+}
+
+// GPUs returns the names of the gpus the job was granted access to
+func (j *Job) GPUs() []string {
+	return slices.Clone(j.gpus)
+}
+
+// SetTTLAfterFinished sets how long the job is kept once it is done before it
+// is removed, 0 means it is kept until it is removed explicitly. It must be
+// called before Start.
+func (j *Job) SetTTLAfterFinished(ttl time.Duration) {
+	j.ttl = ttl
+}
+
+// TTLAfterFinished returns how long the job is kept once it is done
+func (j *Job) TTLAfterFinished() time.Duration {
+	return j.ttl
+}
+
 // NewOutputReader returns an io.ReadCloser that can be used to stream the
 // output of the job from the time it started. It is the caller's responsibility
 // to close it to free allocated resources.
 func (j *Job) NewOutputReader() io.ReadCloser {
-	return j.buf.NewReader()
+	return j.out.buffer().NewReader()
+}
+
+// NewOutputReaderAt is like NewOutputReader, but the reader begins at offset
+// bytes into the output
+func (j *Job) NewOutputReaderAt(offset int) io.ReadCloser {
+	return j.out.buffer().NewReaderAt(offset)
+}
+
+// Close releases the job's output resources. All open output readers are
+// closed, and any created afterwards will be closed already. It must only be
+// called once the job is done.
+func (j *Job) Close() error {
+	var err error
+	if j.wal != nil {
+		err = j.wal.Close()
+	}
+	return errors.Join(err, j.out.buffer().Close())
+}
+
+// CloseOutputReaders closes all open output readers, and any created
+// afterwards, but, unlike Close, the job may continue to write output
+func (j *Job) CloseOutputReaders() {
+	j.out.buffer().Readers.Close()
+}
+
+// OutputStats returns statistics about the job's output buffer, such as its
+// size and the number of open readers
+func (j *Job) OutputStats() safebuffer.Stats {
+	if buf := j.out.allocated(); buf != nil {
+		return buf.Stats()
+	}
+	return safebuffer.Stats{}
+}
+
+// OutputDigest returns the SHA-256 digest of the job's complete output. It
+// returns nil while the job is still running since the output isn't final.
+func (j *Job) OutputDigest() []byte {
+	if !j.isDone() {
+		return nil
+	}
+	if buf := j.out.allocated(); buf != nil {
+		return buf.Digest()
+	}
+	var empty safebuffer.ByteBuffer
+	return empty.Digest()
+}
+
+// Started returns a channel that will be closed when the job has started. It
+// is never closed for jobs that fail to start or are never started.
+func (j *Job) Started() <-chan struct{} {
+	return j.started
 }
 
 // Done returns a channel that will be closed when the job completes
@@ -135,46 +468,79 @@ func (j *Job) Done() <-chan struct{} {
 // setStatus sets the job status. status can only move to higher values:
 // not_started -> running -> completed -> stopped
 func (j *Job) setStatus(st Status) {
-	if st <= j.status {
-		return
+	for {
+		cur := j.status.Load()
+		if int32(st) <= cur || j.status.CompareAndSwap(cur, int32(st)) {
+			return
+		}
 	}
-	j.status = st
 }
 
 // Status returns the job's status
 func (j *Job) Status() Status {
-	return j.status
+	return Status(j.status.Load())
 }
 
-// isDone returns whether or not the job process is still running
+// isDone returns whether or not the job process has completed
 func (j *Job) isDone() bool {
 	select {
 	case <-j.done:
-		return false
-	default:
 		return true
+	default:
+		return false
 	}
 }
 
 // Error returns the error returned by exec.Command. It will return nil if the
 // command is still running.
 func (j *Job) Error() error {
-	if j.isDone() {
+	if !j.isDone() {
 		return nil
 	}
 	return j.cmdErr
 }
 
-// ExitCode returns the process's exit code. If the process is still running,
-// the exit code is nil. If the process completed successfully, the exit code
-// is assumed to be 0. If the process returned any exit code, it will be used.
-// In the case the process was stopped or signaled in some other way, it may
-// have completed without a valid exit code and may be nil.
-func (j *Job) ExitCode() *ExitCode {
-	if j.isDone() {
-		return nil
+// ExitCode returns the process's exit code and true if the process exited on
+// its own. It returns false if the process is still running, if it never
+// started or if it was terminated by a signal, in which case Signal returns
+// the signal.
+func (j *Job) ExitCode() (ExitCode, bool) {
+	if !j.isDone() || j.exitCode == nil {
+		return 0, false
+	}
+	return *j.exitCode, true
+}
+
+// Signal returns the signal that terminated the process. It is 0 if the
+// process is still running or exited on its own.
+func (j *Job) Signal() syscall.Signal {
+	if !j.isDone() {
+		return 0
+	}
+	return j.signal
+}
+
+// Result returns the JSON value the job wrote to ResultFD. It returns nil if
+// the job is still running or didn't write a result. If the result was too
+// large, ErrResultTooLarge is returned, and if it was not valid JSON,
+// ErrInvalidResult is returned.
+func (j *Job) Result() (json.RawMessage, error) {
+	if !j.isDone() {
+		return nil, nil
 	}
-	return j.exitCode
+	return j.result.data, j.result.err
+}
+
+// StopReason returns why the job was stopped. It returns StopReasonNone if the
+// job has not been stopped.
+func (j *Job) StopReason() StopReason {
+	return StopReason(j.stopReason.Load())
+}
+
+// Progress returns the most recent progress the job reported on ProgressFD. It
+// returns nil if the job hasn't reported any progress.
+func (j *Job) Progress() *Progress {
+	return j.progress.get()
 }
 
 // Stop the process. Returns any error from the signaling of the process, not
@@ -183,10 +549,72 @@ func (j *Job) ExitCode() *ExitCode {
 // value. If the process had already completed when first called, Stop() does
 // nothing.
 func (j *Job) Stop() error {
-	if err := j.cmd.Process.Kill(); err != nil {
+	return j.stop(StopReasonRequested)
+}
+
+// StopForShutdown is like Stop, but records StopReasonShutdown as the reason
+// the job was stopped
+func (j *Job) StopForShutdown() error {
+	return j.stop(StopReasonShutdown)
+}
+
+// Preempt stops the job to make room for another. Unlike Stop, the job is
+// first sent SIGTERM and given grace to exit on its own before it is killed.
+// StopReasonPreempted is recorded as the reason the job was stopped. It
+// returns once the job is done.
+func (j *Job) Preempt(grace time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	return j.Terminate(ctx, StopReasonPreempted)
+}
+
+// Terminate stops the job gracefully. Unlike Stop, the job is first sent
+// SIGTERM and is only killed if it hasn't exited by the time ctx is done.
+// reason is recorded as the reason the job was stopped. It returns once the
+// job is done.
+func (j *Job) Terminate(ctx context.Context, reason StopReason) error {
+	// recovered jobs, and jobs that never started, have no process
+	if j.cmd == nil || j.cmd.Process == nil || j.isDone() {
+		return nil
+	}
+
+	if !j.stopReason.CompareAndSwap(int32(StopReasonNone), int32(reason)) {
+		// the job is already being stopped
+		<-j.done
+		return nil
+	}
+
+	if err := j.cmd.Process.Signal(syscall.SIGTERM); err == nil {
+		select {
+		case <-j.done:
+			return nil
+		case <-ctx.Done():
+		}
+	}
+
+	return j.stop(reason)
+}
+
+// stop the process, recording reason as the reason it was stopped if it is the
+// first time the job is stopped
+func (j *Job) stop(reason StopReason) error {
+	// recovered jobs, and jobs that never started, have no process. jobs that
+	// are done keep the reason they ended with.
+	if j.cmd == nil || j.cmd.Process == nil || j.isDone() {
+		return nil
+	}
+
+	j.stopReason.CompareAndSwap(int32(StopReasonNone), int32(reason))
+
+	// the job's descendants are killed along with it, even if they aren't in
+	// its pid namespace
+	cgErr := errors.Join(j.killCGroup(), j.jobObject.kill(), j.killProcessGroup())
+
+	// the process may have exited since it was checked
+	if err := j.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
 		return err
 	}
 	<-j.done
-	j.setStatus(StatusStopped)
-	return nil
+	return cgErr
 }
@@ -3,10 +3,30 @@ package job
 import "syscall"
 
 func sysProcAttr() *syscall.SysProcAttr {
-	return &syscall.SysProcAttr{
-		Cloneflags: syscall.CLONE_NEWPID | // New pid namespace
-			syscall.CLONE_NEWNS | // New mount namespace group
-			syscall.CLONE_NEWNET, // New network namespace
-		Unshareflags: syscall.CLONE_NEWNS, // Isolate process mounts from host
+	var attr syscall.SysProcAttr
+	setNamespaces(&attr, DefaultNamespaces)
+	return &attr
+}
+
+func setNamespaces(attr *syscall.SysProcAttr, ns Namespace) {
+	attr.Cloneflags = 0
+	attr.Unshareflags = 0
+
+	if ns&NamespacePID != 0 {
+		attr.Cloneflags |= syscall.CLONE_NEWPID // New pid namespace
+	}
+
+	if ns&NamespaceMount != 0 {
+		attr.Cloneflags |= syscall.CLONE_NEWNS   // New mount namespace group
+		attr.Unshareflags |= syscall.CLONE_NEWNS // Isolate process mounts from host
 	}
+
+	if ns&NamespaceNetwork != 0 {
+		attr.Cloneflags |= syscall.CLONE_NEWNET // New network namespace
+	}
+}
+
+func setCGroupFD(attr *syscall.SysProcAttr, fd int) {
+	attr.UseCgroupFD = true
+	attr.CgroupFD = fd
 }
@@ -1,4 +1,4 @@
-//go:build !linux
+//go:build !linux && !windows
 
 package job
 
@@ -7,3 +7,7 @@ import "syscall"
 func sysProcAttr() *syscall.SysProcAttr {
 	return nil
 }
+
+func setCGroupFD(*syscall.SysProcAttr, int) {}
+
+func setNamespaces(*syscall.SysProcAttr, Namespace) {}
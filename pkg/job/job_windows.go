@@ -0,0 +1,13 @@
+package job
+
+import "syscall"
+
+// sysProcAttr returns nil since there are no namespaces on windows, jobs are
+// isolated with job objects instead, see SetJobObject
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func setCGroupFD(*syscall.SysProcAttr, int) {}
+
+func setNamespaces(*syscall.SysProcAttr, Namespace) {}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package job
+
+import "os"
+
+// jobObject only exists on windows, see jobobject_windows.go
+type jobObject struct{}
+
+func (*jobObject) assign(*os.Process) error { return nil }
+
+func (*jobObject) kill() error { return nil }
+
+func (*jobObject) close() {}
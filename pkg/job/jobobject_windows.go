@@ -0,0 +1,87 @@
+package job
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// jobObject is the windows job object that the job's process, and all of its
+// descendants, run in
+type jobObject struct {
+	mu sync.Mutex
+	h  windows.Handle
+}
+
+// SetJobObject runs the command in the job object h, which the job takes
+// ownership of. The command is created suspended and only resumed once it has
+// been assigned to h, so that neither it, nor any process it creates, runs
+// outside of it. When the job is stopped, every process in h is terminated and
+// h is closed once the job has exited. It must be called before Start.
+func (j *Job) SetJobObject(h windows.Handle) {
+	j.jobObject.h = h
+	if j.cmd.SysProcAttr == nil {
+		j.cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	j.cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+}
+
+// ntResumeProcess resumes every thread of a suspended process. it is used
+// since os.Process doesn't expose the handle of the process's main thread.
+var ntResumeProcess = windows.NewLazySystemDLL("ntdll.dll").NewProc("NtResumeProcess")
+
+// assign adds p, which was created suspended, to the job object and then
+// resumes it
+func (o *jobObject) assign(p *os.Process) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.h == 0 {
+		return nil
+	}
+
+	const access = windows.PROCESS_SET_QUOTA | windows.PROCESS_TERMINATE | windows.PROCESS_SUSPEND_RESUME
+	ph, err := windows.OpenProcess(access, false, uint32(p.Pid))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = windows.CloseHandle(ph) }()
+
+	if err = windows.AssignProcessToJobObject(o.h, ph); err != nil {
+		return err
+	}
+
+	if status, _, _ := ntResumeProcess.Call(uintptr(ph)); status != 0 {
+		return windows.NTStatus(status)
+	}
+
+	return nil
+}
+
+// kill terminates every process in the job object
+func (o *jobObject) kill() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.h == 0 {
+		return nil
+	}
+
+	return windows.TerminateJobObject(o.h, 1)
+}
+
+// close closes the job object. it is created to kill any processes that are
+// still in it once it is closed.
+func (o *jobObject) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.h == 0 {
+		return
+	}
+
+	_ = windows.CloseHandle(o.h)
+	o.h = 0
+}
@@ -0,0 +1,22 @@
+package job
+
+// Namespace is a set of the linux namespaces that a job's process is created
+// in
+type Namespace uint
+
+const (
+	NamespacePID     Namespace = 1 << iota // a new pid namespace
+	NamespaceMount                         // a new mount namespace, isolated from the host's mounts
+	NamespaceNetwork                       // a new network namespace
+)
+
+// DefaultNamespaces are the namespaces that jobs are created in unless
+// SetNamespaces is called
+const DefaultNamespaces = NamespacePID | NamespaceMount | NamespaceNetwork
+
+// SetNamespaces replaces the namespaces that the job's process is created in.
+// It is ignored on platforms other than linux and it must be called before
+// Start.
+func (j *Job) SetNamespaces(ns Namespace) {
+	setNamespaces(j.cmd.SysProcAttr, ns)
+}
@@ -0,0 +1,74 @@
+package job
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer"
+)
+
+// output is the output of a job. Its buffer is only allocated once the job
+// writes output, or it is read, so that the many jobs that never do cost
+// little.
+type output struct {
+	done   <-chan struct{}
+	policy safebuffer.SlowReaderPolicy
+	wal    io.Writer
+	clock  clock.Clock
+
+	once sync.Once
+	buf  atomic.Pointer[safebuffer.Buffer]
+	last atomic.Int64 // the time of the most recent write, in unix nanoseconds
+}
+
+// ensure output implements the io.Writer interface
+var _ io.Writer = (*output)(nil)
+
+// buffer returns the buffer, allocating it if necessary
+func (o *output) buffer() *safebuffer.Buffer {
+	o.once.Do(func() {
+		buf := safebuffer.New(o.done)
+		buf.SetSlowReaderPolicy(o.policy)
+		buf.SetClock(o.clock)
+		if o.wal != nil {
+			buf.SetWAL(o.wal)
+		}
+		o.buf.Store(buf)
+	})
+	return o.buf.Load()
+}
+
+// allocated returns the buffer, or nil if it hasn't been allocated
+func (o *output) allocated() *safebuffer.Buffer {
+	return o.buf.Load()
+}
+
+// Write is the io.Writer interface that the job's command writes to
+func (o *output) Write(p []byte) (int, error) {
+	n, err := o.buffer().Write(p)
+	if n > 0 {
+		o.last.Store(o.clock.Now().UnixNano())
+	}
+	return n, err
+}
+
+// lastWrite returns the time, on the job's clock, of the most recent write, or
+// the zero time
+func (o *output) lastWrite() time.Time {
+	ns := o.last.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// walError returns the first error returned by the write-ahead log, if any
+func (o *output) walError() error {
+	if buf := o.allocated(); buf != nil {
+		return buf.WALError()
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package job
+
+import (
+	"errors"
+	"syscall"
+)
+
+// killProcessGroup kills every process in the job's process group. there are
+// no pid namespaces, or cgroups, on darwin so this is the best that can be done
+// to kill the job's descendants. the child makes itself the leader of a new
+// process group, so its pid is the group's id. processes that have since moved
+// to another process group, or session, are not killed.
+func (j *Job) killProcessGroup() error {
+	err := syscall.Kill(-j.cmd.Process.Pid, syscall.SIGKILL)
+
+	// the child may not have created its process group yet, in which case it
+	// has no descendants
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
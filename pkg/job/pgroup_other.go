@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package job
+
+// killProcessGroup is here for all non-darwin builds but does nothing and
+// exists only to make builds work, the job's descendants are killed along
+// with its pid namespace, cgroup or job object instead
+func (*Job) killProcessGroup() error {
+	return nil
+}
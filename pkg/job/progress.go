@@ -0,0 +1,139 @@
+package job
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ProgressFD is the file descriptor that a job can write progress reports
+	// to. Each report is a line in the form of "@@progress <percent> [message]",
+	// e.g. "@@progress 42.5 compiling". Other lines are ignored.
+	ProgressFD = 4
+
+	// progressPrefix starts each progress report line
+	progressPrefix = "@@progress"
+)
+
+// Progress is the most recent progress reported by a job
+type Progress struct {
+	Percent float64 // 0 <= Percent <= 100
+	Message string  // optional
+}
+
+// parseProgress parses a single progress report line
+func parseProgress(line string) (*Progress, bool) {
+	rest, ok := strings.CutPrefix(line, progressPrefix+" ")
+	if !ok {
+		return nil, false
+	}
+
+	value, message, _ := strings.Cut(strings.TrimSpace(rest), " ")
+
+	percent, err := strconv.ParseFloat(value, 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return nil, false
+	}
+
+	return &Progress{
+		Percent: percent,
+		Message: strings.TrimSpace(message),
+	}, true
+}
+
+// progress tracks what a job writes to ProgressFD
+type progress struct {
+	r    *os.File
+	w    *os.File
+	done chan struct{}
+
+	mu     sync.RWMutex
+	latest *Progress
+}
+
+// start creates the pipe that is passed to cmd as ProgressFD. it must be
+// called after result.start and before cmd is started.
+func (p *progress) start(cmd *exec.Cmd) error {
+	// extra files are not supported on windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	var err error
+	if p.r, p.w, err = os.Pipe(); err != nil {
+		return err
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, p.w) // ExtraFiles[1] is fd 4
+	return nil
+}
+
+// started must be called after cmd.Start returns. it closes the parent's copy
+// of the write side of the pipe and, if cmd started, starts reading progress.
+func (p *progress) started(err error) {
+	if p.r == nil {
+		return
+	}
+
+	_ = p.w.Close()
+
+	if err != nil {
+		_ = p.r.Close()
+		p.r = nil
+		return
+	}
+
+	p.done = make(chan struct{})
+	go p.read()
+}
+
+// read parses progress reports until the pipe is closed. anything that can't
+// be parsed, like overly long lines, is discarded so that the job doesn't
+// block writing to a full pipe.
+func (p *progress) read() {
+	defer close(p.done)
+
+	s := bufio.NewScanner(p.r)
+	for s.Scan() {
+		if latest, ok := parseProgress(s.Text()); ok {
+			p.mu.Lock()
+			p.latest = latest
+			p.mu.Unlock()
+		}
+	}
+
+	if s.Err() == bufio.ErrTooLong {
+		_, _ = io.Copy(io.Discard, p.r)
+	}
+}
+
+// wait waits for all progress to be read after the job has exited
+func (p *progress) wait() {
+	if p.r == nil {
+		return
+	}
+
+	_ = p.r.SetReadDeadline(time.Now().Add(pipeGracePeriod))
+	<-p.done
+	_ = p.r.Close()
+}
+
+// get returns a copy of the latest progress, if any
+func (p *progress) get() *Progress {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.latest == nil {
+		return nil
+	}
+
+	ret := *p.latest
+	return &ret
+}
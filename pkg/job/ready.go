@@ -0,0 +1,68 @@
+package job
+
+import "time"
+
+// ReadinessCheck reports whether a job that starts a service, e.g. a server,
+// is ready. It is called once the job has started and must return true once
+// the job is ready, or false once done is closed.
+type ReadinessCheck func(j *Job, done <-chan struct{}) bool
+
+// ready tracks whether a job has passed its ReadinessCheck
+type ready struct {
+	check ReadinessCheck
+	ch    chan struct{} // nil if there is no check
+	time  time.Time     // only safe to read after ch has closed
+}
+
+// SetReadinessCheck sets the check that decides when the job is ready. Jobs
+// without one are never ready. It must be called before Start.
+func (j *Job) SetReadinessCheck(check ReadinessCheck) {
+	j.ready.check = check
+	j.ready.ch = make(chan struct{})
+}
+
+// Ready returns a channel that is closed once the job passes its readiness
+// check, or never if it doesn't pass it. It is nil if the job has no check.
+func (j *Job) Ready() <-chan struct{} {
+	return j.ready.ch
+}
+
+// ReadyTime returns the wall clock time the job became ready at. It is the
+// zero time if the job hasn't become ready.
+func (j *Job) ReadyTime() time.Time {
+	select {
+	case <-j.ready.ch:
+		return j.ready.time
+	default:
+		return time.Time{}
+	}
+}
+
+// Pid returns the process id of the job. It is 0 if the job hasn't started.
+func (j *Job) Pid() int {
+	select {
+	case <-j.started:
+		return j.cmd.Process.Pid
+	default:
+		return 0
+	}
+}
+
+// checkReadiness runs the job's readiness check, if it has one. it returns
+// when the job is ready or done.
+func (j *Job) checkReadiness() {
+	if j.ready.check == nil || !j.ready.check(j, j.done) {
+		return
+	}
+
+	select {
+	case <-j.done:
+		// a job that has completed is never ready, even if, e.g., its output
+		// is only matched afterwards
+		return
+	default:
+	}
+
+	j.ready.time = j.clock.Now().Round(0)
+	close(j.ready.ch)
+}
@@ -0,0 +1,102 @@
+package job
+
+import (
+	"maps"
+	"os/exec"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// Exit describes how the attempt of a job before it was restarted ended
+type Exit struct {
+	Status   Status
+	ExitCode *ExitCode // nil if it was terminated by a signal or never started
+	Signal   syscall.Signal
+	Error    error
+	EndTime  time.Time
+}
+
+// Restart returns a new job, that has not been started, which runs the same
+// command as j with the same id, owner, settings and grants. j must be done.
+// The new job has its own output, its Restarts is one more than that of j and
+// its LastExit describes how j ended. Its cgroup, and job object, must be set
+// again before it is started. Its timeout applies to it alone, but its
+// deadline is the one j was given.
+func (j *Job) Restart() *Job {
+	next := Job{
+		id:          j.id,
+		userID:      j.userID,
+		tenantID:    j.tenantID,
+		description: j.description,
+		annotations: maps.Clone(j.annotations),
+		correlation: j.correlation,
+		queue:       j.queue,
+		gpus:        slices.Clone(j.gpus),
+		started:     make(chan struct{}),
+		done:        make(chan struct{}),
+		cmd: &exec.Cmd{
+			Path: j.cmd.Path,
+			Args: slices.Clone(j.cmd.Args),
+			Env:  slices.Clone(j.cmd.Env),
+		},
+
+		setup:       setup{enabled: j.setup.enabled, log: j.setup.log},
+		spec:        spec{data: j.spec.data},
+		result:      result{maxSize: j.result.maxSize},
+		timeout:     j.timeout,
+		idleTimeout: j.idleTimeout,
+		ttl:         j.ttl,
+		clock:       j.clock,
+
+		restarts: j.restarts + 1,
+		lastExit: &Exit{
+			Status:   j.Status(),
+			ExitCode: j.exitCode,
+			Signal:   j.signal,
+			Error:    j.cmdErr,
+			EndTime:  j.EndTime(),
+		},
+	}
+
+	// the namespaces are kept. a cgroup fd, if any, is replaced when the
+	// cgroup is set again.
+	if j.cmd.SysProcAttr != nil {
+		attr := *j.cmd.SysProcAttr
+		next.cmd.SysProcAttr = &attr
+	}
+
+	j.deadlineMu.Lock()
+	next.deadline = j.givenDeadline
+	next.givenDeadline = j.givenDeadline
+	j.deadlineMu.Unlock()
+
+	j.mu.RLock()
+	next.grants = maps.Clone(j.grants)
+	j.mu.RUnlock()
+
+	if j.ready.check != nil {
+		next.SetReadinessCheck(j.ready.check)
+	}
+
+	next.out.done = next.done
+	next.out.policy = j.out.policy
+	next.out.clock = j.clock
+	next.cmd.Stdout = &next.out
+	next.cmd.Stderr = &next.out
+
+	next.setStatus(StatusNotStarted)
+
+	return &next
+}
+
+// Restarts returns the number of times the job has been restarted
+func (j *Job) Restarts() int {
+	return j.restarts
+}
+
+// LastExit describes how the attempt of the job before it was last restarted
+// ended. It is nil if the job hasn't been restarted.
+func (j *Job) LastExit() *Exit {
+	return j.lastExit
+}
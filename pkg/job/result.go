@@ -0,0 +1,131 @@
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+const (
+	// ResultFD is the file descriptor that a job can write a JSON result to,
+	// either directly or via /dev/fd/3, so that callers get a machine-readable
+	// outcome separate from its output
+	ResultFD = 3
+
+	// DefaultMaxResultSize is the default maximum size of a job's result
+	DefaultMaxResultSize = 64 << 10 // 64KiB
+
+	// pipeGracePeriod is how long to wait for the result, or progress, after
+	// the job exits. it only matters if the job left a background process
+	// behind that still holds the fd open.
+	pipeGracePeriod = 100 * time.Millisecond
+)
+
+var (
+	// ErrResultTooLarge is returned by Job.Result if the job wrote more than
+	// the maximum result size
+	ErrResultTooLarge = errors.New("result is too large")
+
+	// ErrInvalidResult is returned by Job.Result if the job wrote a result
+	// that is not valid JSON
+	ErrInvalidResult = errors.New("result is not valid json")
+)
+
+// result captures what a job writes to ResultFD
+type result struct {
+	maxSize int
+	r       *os.File
+	w       *os.File
+	done    chan struct{}
+	data    []byte
+	err     error
+}
+
+// start creates the pipe that is passed to cmd as ResultFD. it must be called
+// before cmd is started.
+func (r *result) start(cmd *exec.Cmd) error {
+	// extra files are not supported on windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	if r.maxSize <= 0 {
+		r.maxSize = DefaultMaxResultSize
+	}
+
+	var err error
+	if r.r, r.w, err = os.Pipe(); err != nil {
+		return err
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r.w) // ExtraFiles[0] is fd 3
+	return nil
+}
+
+// started must be called after cmd.Start returns. it closes the parent's copy
+// of the write side of the pipe and, if cmd started, starts reading the result.
+func (r *result) started(err error) {
+	if r.r == nil {
+		return
+	}
+
+	_ = r.w.Close()
+
+	if err != nil {
+		_ = r.r.Close()
+		r.r = nil
+		return
+	}
+
+	r.done = make(chan struct{})
+	go r.read()
+}
+
+// read reads the result, keeping at most one byte more than maxSize so that
+// oversized results can be detected. the rest is discarded so that the job
+// doesn't block writing to a full pipe.
+func (r *result) read() {
+	defer close(r.done)
+
+	data, err := io.ReadAll(io.LimitReader(r.r, int64(r.maxSize)+1))
+	if err == nil {
+		_, err = io.Copy(io.Discard, r.r)
+	}
+
+	r.data = data
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		r.err = err
+	}
+}
+
+// wait waits for the result to be read after the job has exited
+func (r *result) wait() {
+	if r.r == nil {
+		return
+	}
+
+	_ = r.r.SetReadDeadline(time.Now().Add(pipeGracePeriod))
+	<-r.done
+	_ = r.r.Close()
+
+	switch data := bytes.TrimSpace(r.data); {
+	case r.err != nil:
+	case len(r.data) > r.maxSize:
+		r.err = ErrResultTooLarge
+	case len(data) == 0:
+		r.data = nil
+	case !json.Valid(data):
+		r.err = ErrInvalidResult
+	default:
+		r.data = data
+	}
+
+	if r.err != nil {
+		r.data = nil
+	}
+}
@@ -0,0 +1,152 @@
+package job
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+const (
+	// SetupFD is the file descriptor that a wrapper, like the worker's
+	// reexecuted child, writes an error to if it fails to set up the job before
+	// executing the job's command. The wrapper must mark it close-on-exec so
+	// that it is closed, without anything having been written, once the
+	// command is executed.
+	SetupFD = 5
+
+	// SetupLogFD is the file descriptor that a wrapper writes its own
+	// diagnostics to, one per line, so that they are not mixed in with the
+	// job's output. Like SetupFD, the wrapper must mark it close-on-exec.
+	SetupLogFD = 6
+
+	// maxSetupErrorSize is the maximum length of a setup error message, the
+	// rest is discarded
+	maxSetupErrorSize = 4 << 10 // 4KiB
+)
+
+// ErrSetupFailed is the error of a job whose wrapper reported a setup error to
+// SetupFD. It wraps the message written by the wrapper.
+var ErrSetupFailed = errors.New("job setup failed")
+
+// setup captures what a wrapper writes to SetupFD and SetupLogFD
+type setup struct {
+	enabled bool
+	log     io.Writer
+	r, w    *os.File // SetupFD
+	logR    *os.File
+	logW    *os.File
+	done    chan struct{}
+	logDone chan struct{}
+	msg     []byte
+}
+
+// EnableSetup passes SetupFD and SetupLogFD to the command. If the command
+// writes to SetupFD the job fails with StatusStartError and ErrSetupFailed
+// instead of completing. Each line written to SetupLogFD is written to log,
+// which may be nil to discard them, with a single call to Write. It must only
+// be used when the command is a wrapper that supports these fds and it must be
+// called before Start.
+func (j *Job) EnableSetup(log io.Writer) {
+	j.setup.enabled = true
+	j.setup.log = log
+}
+
+// start creates the pipes that are passed to cmd as SetupFD and SetupLogFD. it
+// must be called after progress.start and before cmd is started.
+func (s *setup) start(cmd *exec.Cmd) error {
+	// extra files are not supported on windows
+	if !s.enabled || runtime.GOOS == "windows" {
+		return nil
+	}
+
+	var err error
+	if s.r, s.w, err = os.Pipe(); err != nil {
+		return err
+	}
+
+	if s.logR, s.logW, err = os.Pipe(); err != nil {
+		_ = s.r.Close()
+		_ = s.w.Close()
+		s.r = nil
+		return err
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, s.w, s.logW) // ExtraFiles[2] and [3] are fds 5 and 6
+	return nil
+}
+
+// started must be called after cmd.Start returns. it closes the parent's copy
+// of the write side of the pipes and, if cmd started, starts reading them.
+func (s *setup) started(err error) {
+	if s.r == nil {
+		return
+	}
+
+	_ = s.w.Close()
+	_ = s.logW.Close()
+
+	if err != nil {
+		_ = s.r.Close()
+		_ = s.logR.Close()
+		s.r = nil
+		return
+	}
+
+	s.done = make(chan struct{})
+	s.logDone = make(chan struct{})
+	go s.read()
+	go s.readLog()
+}
+
+// read reads the setup error, if any. anything beyond maxSetupErrorSize is
+// discarded.
+func (s *setup) read() {
+	defer close(s.done)
+
+	s.msg, _ = io.ReadAll(io.LimitReader(s.r, maxSetupErrorSize))
+	_, _ = io.Copy(io.Discard, s.r)
+}
+
+// readLog copies each line the wrapper logs to log. lines that are too long
+// are discarded so that the wrapper doesn't block writing to a full pipe.
+func (s *setup) readLog() {
+	defer close(s.logDone)
+
+	sc := bufio.NewScanner(s.logR)
+	for sc.Scan() {
+		if s.log != nil {
+			_, _ = s.log.Write(sc.Bytes())
+		}
+	}
+
+	if sc.Err() == bufio.ErrTooLong {
+		_, _ = io.Copy(io.Discard, s.logR)
+	}
+}
+
+// wait waits for the setup error and logs to be read after the job has exited
+// and returns the error
+func (s *setup) wait() error {
+	if s.r == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(pipeGracePeriod)
+	_ = s.r.SetReadDeadline(deadline)
+	_ = s.logR.SetReadDeadline(deadline)
+	<-s.done
+	<-s.logDone
+	_ = s.r.Close()
+	_ = s.logR.Close()
+
+	if len(s.msg) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrSetupFailed, s.msg)
+}
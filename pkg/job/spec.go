@@ -0,0 +1,85 @@
+package job
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// SpecFD is the file descriptor that a wrapper, like the worker's reexecuted
+// child, reads the spec of the job from until EOF. The spec is passed through
+// a pipe, rather than argv or the environment, so that it isn't visible to
+// other users, or limited in size, before the job's command is executed. The
+// wrapper must mark it close-on-exec.
+const SpecFD = 7
+
+// spec is the data written to SpecFD
+type spec struct {
+	data []byte
+	w    *os.File
+	done chan struct{}
+}
+
+// SetSpec passes spec to the command on SpecFD. It must only be used when the
+// command is a wrapper that reads it and it must be called before Start. Extra
+// files are not supported on windows, so spec isn't passed there.
+func (j *Job) SetSpec(spec []byte) {
+	j.spec.data = spec
+}
+
+// start creates the pipe that is passed to cmd as SpecFD. it must be called
+// after setup.start and before cmd is started.
+func (s *spec) start(cmd *exec.Cmd) error {
+	// extra files are not supported on windows
+	if s.data == nil || runtime.GOOS == "windows" {
+		return nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	// the fds before SpecFD are closed in the wrapper if they aren't used
+	for len(cmd.ExtraFiles) < SpecFD-3 {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, nil)
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, r) // ExtraFiles[4] is fd 7
+	s.w = w
+	return nil
+}
+
+// started must be called after cmd.Start returns. it closes the parent's copy
+// of the read side of the pipe and, if cmd started, writes the spec to it.
+// the spec may be larger than the pipe's buffer, so it is written
+// concurrently with the wrapper reading it.
+func (s *spec) started(cmd *exec.Cmd, err error) {
+	if s.w == nil {
+		return
+	}
+
+	_ = cmd.ExtraFiles[SpecFD-3].Close()
+
+	if err != nil {
+		_ = s.w.Close()
+		s.w = nil
+		return
+	}
+
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		// if the wrapper exits without reading it all, the write fails
+		_, _ = s.w.Write(s.data)
+		_ = s.w.Close()
+	}()
+}
+
+// wait waits for the spec to be written, or to fail to be written, after the
+// job has exited
+func (s *spec) wait() {
+	if s.done != nil {
+		<-s.done
+	}
+}
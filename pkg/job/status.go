@@ -11,7 +11,7 @@ func (c ExitCode) Int() int {
 //go:generate stringer -type=Status -trimprefix=Status
 
 // Status is the enum representing the state of a job
-type Status int
+type Status int32
 
 // NOTE: keep this synced with jobworker.proto:JobStatus
 const (
@@ -21,4 +21,5 @@ const (
 	StatusCompleted          // the job completed successfully on its own
 	StatusStopped            // the job completed after being manually signaled to stop
 	StatusStartError         // the job failed to start successfully
+	StatusInterrupted        // the worker stopped while the job was running, only its output was recovered
 )
@@ -14,11 +14,12 @@ func _() {
 	_ = x[StatusCompleted-3]
 	_ = x[StatusStopped-4]
 	_ = x[StatusStartError-5]
+	_ = x[StatusInterrupted-6]
 }
 
-const _Status_name = "UnspecifiedNotStartedRunningCompletedStoppedStartError"
+const _Status_name = "UnspecifiedNotStartedRunningCompletedStoppedStartErrorInterrupted"
 
-var _Status_index = [...]uint8{0, 11, 21, 28, 37, 44, 54}
+var _Status_index = [...]uint8{0, 11, 21, 28, 37, 44, 54, 65}
 
 func (i Status) String() string {
 	if i < 0 || i >= Status(len(_Status_index)-1) {
@@ -0,0 +1,16 @@
+package job
+
+//go:generate stringer -type=StopReason -trimprefix=StopReason
+
+// StopReason describes why a job with StatusStopped was stopped
+type StopReason int32
+
+// NOTE: keep this synced with jobworker.proto:JobStopReason
+const (
+	StopReasonNone        StopReason = iota // the job has not been stopped
+	StopReasonRequested                     // the job was stopped by a user
+	StopReasonTimeout                       // the job ran past its timeout or deadline
+	StopReasonIdleTimeout                   // the job produced no output for longer than its idle timeout
+	StopReasonShutdown                      // the job was stopped because the worker was shut down
+	StopReasonPreempted                     // the job was stopped to make room for a higher priority job
+)
@@ -0,0 +1,140 @@
+package job
+
+import (
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+)
+
+// SetTimeouts sets the maximum amount of time the job may run for, and the
+// maximum amount of time it may run without producing any output, before it
+// is stopped. A value of 0 disables the respective timeout. It must be called
+// before Start.
+func (j *Job) SetTimeouts(timeout, idleTimeout time.Duration) {
+	j.timeout = timeout
+	j.idleTimeout = idleTimeout
+}
+
+// SetDeadline sets the time at which the job will be stopped. If the job also
+// has a timeout, whichever comes first is used. The zero time means no
+// deadline. It must be called before Start, use UpdateDeadline afterwards.
+func (j *Job) SetDeadline(deadline time.Time) {
+	j.deadline = deadline
+	j.givenDeadline = deadline
+}
+
+// timers enforce the deadline and idle timeout of a running job. They are
+// timers of the job's clock, rather than a goroutine per job, so that jobs
+// waiting on them cost nothing until they fire.
+type timers struct {
+	armed    bool // true from when the job starts until it is done
+	deadline clock.Timer
+	idle     clock.Timer
+}
+
+// UpdateDeadline replaces the time at which the running job will be stopped,
+// extending or shortening its allowed lifetime. This replaces any deadline
+// derived from the job's timeout. The zero time removes the deadline.
+func (j *Job) UpdateDeadline(deadline time.Time) {
+	j.deadlineMu.Lock()
+	defer j.deadlineMu.Unlock()
+
+	j.deadline = deadline
+	j.givenDeadline = deadline
+	if j.timers.armed {
+		j.resetDeadlineLocked()
+	}
+}
+
+// Deadline returns the time at which the job will be stopped. It returns the
+// zero time if the job has no deadline.
+func (j *Job) Deadline() time.Time {
+	j.deadlineMu.Lock()
+	defer j.deadlineMu.Unlock()
+	return j.deadline
+}
+
+// startTimeouts combines the job's timeout with its deadline once it has
+// started and arms the timers that stop it once it passes its deadline or
+// exceeds its idle timeout. they are armed even if there is no deadline since
+// it may be set later.
+func (j *Job) startTimeouts(started time.Time) {
+	j.deadlineMu.Lock()
+	defer j.deadlineMu.Unlock()
+
+	if j.timeout > 0 {
+		deadline := started.Add(j.timeout)
+		if j.deadline.IsZero() || deadline.Before(j.deadline) {
+			j.deadline = deadline
+		}
+	}
+
+	j.timers.armed = true
+	j.resetDeadlineLocked()
+
+	if j.idleTimeout > 0 {
+		j.timers.idle = j.clock.AfterFunc(j.idleTimeout, func() { j.checkIdle(started) })
+	}
+}
+
+// stopTimeouts disarms the timers once the job is done
+func (j *Job) stopTimeouts() {
+	j.deadlineMu.Lock()
+	defer j.deadlineMu.Unlock()
+
+	j.timers.armed = false
+	if j.timers.deadline != nil {
+		j.timers.deadline.Stop()
+	}
+	if j.timers.idle != nil {
+		j.timers.idle.Stop()
+	}
+}
+
+// resetDeadlineLocked replaces the deadline timer with one for the current
+// deadline. deadlineMu must be held.
+func (j *Job) resetDeadlineLocked() {
+	if j.timers.deadline != nil {
+		j.timers.deadline.Stop()
+		j.timers.deadline = nil
+	}
+
+	if j.deadline.IsZero() {
+		return
+	}
+
+	var t clock.Timer
+	t = j.clock.AfterFunc(clock.Until(j.clock, j.deadline), func() {
+		// the timer may have been replaced while it was firing
+		j.deadlineMu.Lock()
+		current := j.timers.armed && j.timers.deadline == t
+		j.deadlineMu.Unlock()
+
+		if current {
+			_ = j.stop(StopReasonTimeout)
+		}
+	})
+	j.timers.deadline = t
+}
+
+// checkIdle stops the job if it hasn't produced any output, since started, for
+// longer than its idle timeout. Otherwise it checks again once it could have.
+func (j *Job) checkIdle(started time.Time) {
+	last := j.out.lastWrite()
+	if last.Before(started) {
+		last = started
+	}
+
+	if remaining := j.idleTimeout - clock.Since(j.clock, last); remaining > 0 {
+		j.deadlineMu.Lock()
+		if j.timers.armed {
+			j.timers.idle.Reset(remaining)
+		}
+		j.deadlineMu.Unlock()
+		return
+	}
+
+	if !j.isDone() {
+		_ = j.stop(StopReasonIdleTimeout)
+	}
+}
@@ -0,0 +1,48 @@
+package job
+
+import (
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+)
+
+// setEndTime records when the job completed. the runtime is computed from the
+// monotonic clock so that it is not affected by changes to the wall clock. jobs
+// that never started have no runtime. it must be called before done is closed.
+func (j *Job) setEndTime() {
+	j.endTime = j.clock.Now()
+	if !j.startTime.IsZero() {
+		j.runtime = j.endTime.Sub(j.startTime)
+	}
+}
+
+// StartTime returns the wall clock time the job was started at. It is the zero
+// time if the job has not been started.
+func (j *Job) StartTime() time.Time {
+	// strip the monotonic clock reading, it is meaningless outside of this
+	// process
+	return j.startTime.Round(0)
+}
+
+// EndTime returns the wall clock time the job completed at. It is the zero
+// time if the job is still running.
+func (j *Job) EndTime() time.Time {
+	if !j.isDone() {
+		return time.Time{}
+	}
+	return j.endTime.Round(0)
+}
+
+// Runtime returns how long the job has been running, or how long it ran for
+// if it has completed. It is measured with the monotonic clock so, unlike the
+// difference between EndTime and StartTime, it is correct even if the wall
+// clock jumps while the job is running.
+func (j *Job) Runtime() time.Duration {
+	if j.startTime.IsZero() {
+		return 0
+	}
+	if !j.isDone() {
+		return clock.Since(j.clock, j.startTime)
+	}
+	return j.runtime
+}
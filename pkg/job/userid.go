@@ -7,3 +7,14 @@ type UserID string
 func (id UserID) String() string {
 	return string(id)
 }
+
+// TenantID identifies the organization, or team, that a user belongs to. Jobs
+// are partitioned by tenant: users only see the jobs of their own tenant, and
+// administrative operations can be scoped to a single tenant. The empty
+// TenantID is the default tenant.
+type TenantID string
+
+// String returns the tenant id as a string
+func (id TenantID) String() string {
+	return string(id)
+}
@@ -0,0 +1,231 @@
+// Package fluent implements a logship.Shipper that sends the output of jobs to
+// Fluentd or Fluent Bit using the Fluent Forward protocol. Each batch of lines
+// is sent as a single Forward mode message of records with the line, job,
+// user and tenant.
+package fluent
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+const (
+	// DefaultAddr is used if Config.Addr is empty, it is the default address
+	// of the forward input
+	DefaultAddr = "localhost:24224"
+
+	// DefaultTag is used if Config.Tag is empty
+	DefaultTag = "job-worker.output"
+)
+
+// Config configures a Shipper
+type Config struct {
+	Network string // "tcp" if empty, may be "unix"
+	Addr    string // the address of the forward input
+	Tag     string // the tag that records are routed by
+}
+
+// Shipper sends lines to Fluentd or Fluent Bit
+type Shipper struct {
+	cfg Config
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+	buf    []byte
+}
+
+// ensure Shipper implements the logship.Shipper interface
+var _ logship.Shipper = (*Shipper)(nil)
+
+// New connects to the forward input and returns a new Shipper. Close must be
+// called to release the connection.
+func New(cfg *Config) (*Shipper, error) {
+	s := Shipper{cfg: *cfg}
+
+	if s.cfg.Network == "" {
+		s.cfg.Network = "tcp"
+	}
+
+	if s.cfg.Addr == "" {
+		s.cfg.Addr = DefaultAddr
+	}
+
+	if s.cfg.Tag == "" {
+		s.cfg.Tag = DefaultTag
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// connect (re)connects to the forward input. s.mu must be held, or s not yet
+// shared.
+func (s *Shipper) connect() error {
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+
+	conn, err := net.Dial(s.cfg.Network, s.cfg.Addr)
+	if err != nil {
+		s.conn = nil
+		return fmt.Errorf("error connecting to fluent forward input: %w", err)
+	}
+
+	s.conn = conn
+	return nil
+}
+
+// Ship sends lines as a single Forward mode message. If sending fails, it
+// reconnects and tries again once.
+func (s *Shipper) Ship(ctx context.Context, lines []logship.Line) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return net.ErrClosed
+	}
+
+	s.encode(lines)
+
+	err := s.write(ctx)
+	if err != nil && ctx.Err() == nil {
+		if err = s.connect(); err == nil {
+			err = s.write(ctx)
+		}
+	}
+
+	return err
+}
+
+// write writes s.buf with the deadline of ctx, if any. s.mu must be held.
+func (s *Shipper) write(ctx context.Context) error {
+	if s.conn == nil {
+		return net.ErrClosed
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Write(s.buf)
+	return err
+}
+
+// encode encodes lines into s.buf as the msgpack Forward mode message
+// [tag, [[time, record], ...]]. s.mu must be held.
+func (s *Shipper) encode(lines []logship.Line) {
+	b := s.buf[:0]
+
+	b = appendArrayHeader(b, 2)
+	b = appendString(b, s.cfg.Tag)
+	b = appendArrayHeader(b, len(lines))
+
+	for _, l := range lines {
+		b = appendArrayHeader(b, 2)
+		b = appendEventTime(b, l.Time.Unix(), l.Time.Nanosecond())
+
+		fields := 3
+		if l.Tenant != "" {
+			fields++
+		}
+		if l.Truncated {
+			fields++
+		}
+
+		b = appendMapHeader(b, fields)
+		b = appendString(b, "message")
+		b = appendString(b, l.Message)
+		b = appendString(b, "job_id")
+		b = appendString(b, l.JobID.String())
+		b = appendString(b, "user_id")
+		b = appendString(b, l.UserID.String())
+
+		if l.Tenant != "" {
+			b = appendString(b, "tenant")
+			b = appendString(b, l.Tenant.String())
+		}
+
+		if l.Truncated {
+			b = appendString(b, "truncated")
+			b = append(b, 0xc3) // true
+		}
+	}
+
+	s.buf = b
+}
+
+// Close closes the connection to the forward input
+func (s *Shipper) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// the following implement the small subset of msgpack the protocol needs
+
+func appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+func appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= 0xff:
+		b = append(b, 0xd9, byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendEventTime appends the EventTime extension, fixext 8 of type 0 with
+// the seconds and nanoseconds as big endian uint32s
+func appendEventTime(b []byte, sec int64, nsec int) []byte {
+	b = append(b, 0xd7, 0x00)
+	b = binary.BigEndian.AppendUint32(b, uint32(sec))
+	return binary.BigEndian.AppendUint32(b, uint32(nsec))
+}
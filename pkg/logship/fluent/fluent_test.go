@@ -0,0 +1,75 @@
+package fluent
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+func TestShipper(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer lis.Close()
+
+	s, err := New(&Config{Addr: lis.Addr().String(), Tag: "t"})
+	require.NoError(err)
+	defer s.Close()
+
+	conn, err := lis.Accept()
+	require.NoError(err)
+	defer conn.Close()
+
+	jobID, err := job.ParseID("job_01hzy7c3k5f4qbx8g5z6h4n2m1")
+	require.NoError(err)
+
+	require.NoError(s.Ship(context.Background(), []logship.Line{
+		{Time: time.Unix(1, 2), JobID: jobID, UserID: "u", Tenant: "a", Message: "hi", Truncated: true},
+	}))
+
+	want := []byte{
+		0x92, 0xa1, 't', // [tag, entries]
+		0x91, 0x92, // entries, [time, record]
+		0xd7, 0x00, 0, 0, 0, 1, 0, 0, 0, 2, // time
+		0x85, // record
+		0xa7, 'm', 'e', 's', 's', 'a', 'g', 'e', 0xa2, 'h', 'i',
+		0xa6, 'j', 'o', 'b', '_', 'i', 'd', 0xa0 | byte(len(jobID.String())),
+	}
+	want = append(want, jobID.String()...)
+	want = append(want,
+		0xa7, 'u', 's', 'e', 'r', '_', 'i', 'd', 0xa1, 'u',
+		0xa6, 't', 'e', 'n', 'a', 'n', 't', 0xa1, 'a',
+		0xa9, 't', 'r', 'u', 'n', 'c', 'a', 't', 'e', 'd', 0xc3,
+	)
+
+	got := make([]byte, len(want))
+	require.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	_, err = io.ReadFull(conn, got)
+	require.NoError(err)
+	require.Equal(want, got)
+
+	require.NoError(s.Close())
+	require.ErrorIs(s.Ship(context.Background(), []logship.Line{{}}), net.ErrClosed)
+}
+
+func TestAppendString(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	assert.Equal([]byte{0xd9, 32}, appendString(nil, strings.Repeat("x", 32))[:2])
+	assert.Equal([]byte{0xda, 0x01, 0x00}, appendString(nil, strings.Repeat("x", 256))[:3])
+	assert.Equal([]byte{0xdb, 0x00, 0x01, 0x00, 0x00}, appendString(nil, strings.Repeat("x", 1<<16))[:5])
+	assert.Equal([]byte{0xdc, 0x00, 0x10}, appendArrayHeader(nil, 16))
+	assert.Equal([]byte{0xde, 0x00, 0x10}, appendMapHeader(nil, 16))
+}
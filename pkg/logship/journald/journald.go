@@ -0,0 +1,186 @@
+// Package journald implements a logship.Shipper that sends the output of jobs
+// to the systemd journal using its native protocol. The job, user and tenant
+// of each line are included as journal fields so that they can be filtered
+// on, e.g. "journalctl JOB_ID=job_...".
+package journald
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+const (
+	// DefaultAddr is used if Config.Addr is empty, it is the journal's native
+	// protocol socket
+	DefaultAddr = "/run/systemd/journal/socket"
+
+	// DefaultIdentifier is used if Config.Identifier is empty
+	DefaultIdentifier = "job-worker"
+
+	// priorityInfo is the priority of all lines. the worker can't tell errors
+	// from other output, even stderr is commonly used for informational
+	// output.
+	priorityInfo = "6"
+)
+
+// Config configures a Shipper
+type Config struct {
+	Addr       string // the path of the journal's socket
+	Identifier string // the SYSLOG_IDENTIFIER of entries
+}
+
+// Shipper sends lines to the systemd journal. Each line is sent as a single
+// datagram, lines must therefore be smaller than the socket's maximum
+// datagram size, which is the case for lines read with the default maximum
+// line length.
+type Shipper struct {
+	cfg Config
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+	buf    bytes.Buffer
+}
+
+// ensure Shipper implements the logship.Shipper interface
+var _ logship.Shipper = (*Shipper)(nil)
+
+// New connects to the journal and returns a new Shipper. Close must be called
+// to release the connection.
+func New(cfg *Config) (*Shipper, error) {
+	s := Shipper{cfg: *cfg}
+
+	if s.cfg.Addr == "" {
+		s.cfg.Addr = DefaultAddr
+	}
+
+	if s.cfg.Identifier == "" {
+		s.cfg.Identifier = DefaultIdentifier
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// connect (re)connects to the journal. s.mu must be held, or s not yet
+// shared.
+func (s *Shipper) connect() error {
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+
+	conn, err := net.Dial("unixgram", s.cfg.Addr)
+	if err != nil {
+		s.conn = nil
+		return fmt.Errorf("error connecting to journald: %w", err)
+	}
+
+	s.conn = conn
+	return nil
+}
+
+// Ship sends each line as a journal entry. If sending fails, e.g. because
+// journald was restarted, it reconnects and tries again once.
+func (s *Shipper) Ship(ctx context.Context, lines []logship.Line) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return net.ErrClosed
+	}
+
+	for i := range lines {
+		s.encode(&lines[i])
+
+		err := s.write(ctx)
+		if err != nil && ctx.Err() == nil {
+			if err = s.connect(); err == nil {
+				err = s.write(ctx)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write writes s.buf with the deadline of ctx, if any. s.mu must be held.
+func (s *Shipper) write(ctx context.Context) error {
+	if s.conn == nil {
+		return net.ErrClosed
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Write(s.buf.Bytes())
+	return err
+}
+
+// encode encodes l into s.buf as a journal entry. s.mu must be held.
+func (s *Shipper) encode(l *logship.Line) {
+	s.buf.Reset()
+
+	s.field("MESSAGE", l.Message)
+	s.field("PRIORITY", priorityInfo)
+	s.field("SYSLOG_IDENTIFIER", s.cfg.Identifier)
+	s.field("JOB_ID", l.JobID.String())
+	s.field("JOB_USER_ID", l.UserID.String())
+
+	if l.Tenant != "" {
+		s.field("JOB_TENANT", l.Tenant.String())
+	}
+
+	if l.Truncated {
+		s.field("JOB_OUTPUT_TRUNCATED", "1")
+	}
+}
+
+// field appends a field to s.buf. values containing newlines use the binary
+// form, the length of the value as a little endian uint64 followed by the
+// value.
+func (s *Shipper) field(name, value string) {
+	s.buf.WriteString(name)
+
+	if !strings.Contains(value, "\n") {
+		s.buf.WriteByte('=')
+		s.buf.WriteString(value)
+		s.buf.WriteByte('\n')
+		return
+	}
+
+	s.buf.WriteByte('\n')
+	_ = binary.Write(&s.buf, binary.LittleEndian, uint64(len(value)))
+	s.buf.WriteString(value)
+	s.buf.WriteByte('\n')
+}
+
+// Close closes the connection to the journal
+func (s *Shipper) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
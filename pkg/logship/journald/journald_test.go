@@ -0,0 +1,49 @@
+package journald
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+func TestShipper(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	addr := filepath.Join(t.TempDir(), "socket")
+	journal, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	require.NoError(err)
+	defer journal.Close()
+
+	s, err := New(&Config{Addr: addr})
+	require.NoError(err)
+	defer s.Close()
+
+	jobID, err := job.ParseID("job_01hzy7c3k5f4qbx8g5z6h4n2m1")
+	require.NoError(err)
+
+	require.NoError(s.Ship(context.Background(), []logship.Line{
+		{JobID: jobID, UserID: "alice", Message: "hello"},
+		{JobID: jobID, UserID: "alice", Tenant: "acme", Message: "multi\nline", Truncated: true},
+	}))
+
+	for _, want := range []string{
+		"MESSAGE=hello\nPRIORITY=6\nSYSLOG_IDENTIFIER=job-worker\n" +
+			"JOB_ID=job_01hzy7c3k5f4qbx8g5z6h4n2m1\nJOB_USER_ID=alice\n",
+		"MESSAGE\n\x0a\x00\x00\x00\x00\x00\x00\x00multi\nline\nPRIORITY=6\nSYSLOG_IDENTIFIER=job-worker\n" +
+			"JOB_ID=job_01hzy7c3k5f4qbx8g5z6h4n2m1\nJOB_USER_ID=alice\nJOB_TENANT=acme\nJOB_OUTPUT_TRUNCATED=1\n",
+	} {
+		buf := make([]byte, 1024)
+		require.NoError(journal.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		n, err := journal.Read(buf)
+		require.NoError(err)
+		require.Equal(want, string(buf[:n]))
+	}
+}
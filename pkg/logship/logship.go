@@ -0,0 +1,30 @@
+// Package logship defines how the output of jobs is shipped, a line at a time,
+// to logging systems outside of the worker, like syslog or journald, so that
+// it appears in the host's standard logging pipeline in addition to being
+// streamed to clients.
+package logship
+
+import (
+	"context"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// Line is a single line of the output of a job
+type Line struct {
+	Time      time.Time // when the line was read from the job's output
+	JobID     job.ID
+	UserID    job.UserID
+	Tenant    job.TenantID
+	Message   string // the line, without its trailing newline
+	Truncated bool   // true if the line was too long and only its beginning is included
+}
+
+// Shipper receives lines of job output. Implementations must be goroutine
+// safe.
+type Shipper interface {
+	// Ship delivers lines, which are all from the same job and in order. It
+	// may block until they have been delivered or ctx is done.
+	Ship(ctx context.Context, lines []Line) error
+}
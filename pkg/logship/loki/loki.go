@@ -0,0 +1,187 @@
+// Package loki implements a logship.Shipper that pushes the output of jobs to
+// Grafana Loki. Lines are pushed to a single stream, identified by
+// Config.Labels, with the job, user and tenant of each line attached as
+// structured metadata. Job ids are unbounded so they would make poor labels.
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/internal/retry"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+const (
+	// PushPath is the path of Loki's push api, it is appended to Config.URL
+	PushPath = "/loki/api/v1/push"
+
+	// TenantHeader is set to Config.TenantID, it selects the Loki tenant in
+	// multi-tenant deployments
+	TenantHeader = "X-Scope-OrgID"
+)
+
+const (
+	// DefaultMaxAttempts is used if Config.MaxAttempts is <= 0
+	DefaultMaxAttempts = retry.DefaultMaxAttempts
+
+	// DefaultInitialBackoff is used if Config.InitialBackoff is <= 0
+	DefaultInitialBackoff = retry.DefaultInitialBackoff
+
+	// DefaultTimeout is used if Config.Timeout is <= 0
+	DefaultTimeout = retry.DefaultTimeout
+)
+
+// DefaultLabels is used if Config.Labels is empty
+var DefaultLabels = map[string]string{"service_name": "job-worker"}
+
+// Config configures a Shipper
+type Config struct {
+	URL            string            // the base url of Loki, e.g. "http://loki:3100"
+	TenantID       string            // optional, the Loki tenant to push to
+	Labels         map[string]string // the labels of the stream lines are pushed to
+	MaxAttempts    int               // the maximum number of attempts per push
+	InitialBackoff time.Duration     // the delay before the first retry, doubled after each attempt
+	Timeout        time.Duration     // the timeout for each attempt
+	Client         *http.Client      // optional, http.DefaultClient is used if nil
+}
+
+// Shipper pushes lines to Loki
+type Shipper struct {
+	cfg Config
+}
+
+// ensure Shipper implements the logship.Shipper interface
+var _ logship.Shipper = (*Shipper)(nil)
+
+// ErrURLRequired is returned by New if the url is empty
+var ErrURLRequired = errors.New("loki url is required")
+
+// New returns a new Shipper
+func New(cfg *Config) (*Shipper, error) {
+	if cfg.URL == "" {
+		return nil, ErrURLRequired
+	}
+
+	s := Shipper{cfg: *cfg}
+	s.cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	s.cfg.Labels = maps.Clone(cfg.Labels)
+	if len(s.cfg.Labels) == 0 {
+		s.cfg.Labels = maps.Clone(DefaultLabels)
+	}
+
+	if s.cfg.MaxAttempts <= 0 {
+		s.cfg.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if s.cfg.InitialBackoff <= 0 {
+		s.cfg.InitialBackoff = DefaultInitialBackoff
+	}
+
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = DefaultTimeout
+	}
+
+	if s.cfg.Client == nil {
+		s.cfg.Client = http.DefaultClient
+	}
+
+	return &s, nil
+}
+
+// pushRequest is the json body of a push
+type pushRequest struct {
+	Streams []stream `json:"streams"`
+}
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+
+	// Values are [timestamp, line, structured metadata] tuples. the timestamp
+	// is a string of unix nanoseconds.
+	Values [][3]any `json:"values"`
+}
+
+// Ship pushes lines to Loki, retrying with exponential backoff on network
+// errors, 429 and 5xx responses. It blocks until the lines were pushed, all
+// attempts were exhausted or ctx is done.
+func (s *Shipper) Ship(ctx context.Context, lines []logship.Line) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(s.request(lines))
+	if err != nil {
+		return err
+	}
+
+	p := retry.Policy{MaxAttempts: s.cfg.MaxAttempts, InitialBackoff: s.cfg.InitialBackoff}
+	return retry.Do(ctx, p, func(ctx context.Context) error {
+		return s.push(ctx, body)
+	})
+}
+
+// request returns the push request for lines
+func (s *Shipper) request(lines []logship.Line) *pushRequest {
+	st := stream{
+		Stream: s.cfg.Labels,
+		Values: make([][3]any, 0, len(lines)),
+	}
+
+	for _, l := range lines {
+		meta := map[string]string{
+			"job_id":  l.JobID.String(),
+			"user_id": l.UserID.String(),
+		}
+
+		if l.Tenant != "" {
+			meta["tenant"] = l.Tenant.String()
+		}
+
+		if l.Truncated {
+			meta["truncated"] = "true"
+		}
+
+		st.Values = append(st.Values, [3]any{
+			strconv.FormatInt(l.Time.UnixNano(), 10),
+			l.Message,
+			meta,
+		})
+	}
+
+	return &pushRequest{Streams: []stream{st}}
+}
+
+// push makes a single attempt to push body to Loki
+func (s *Shipper) push(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL+PushPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.TenantID != "" {
+		req.Header.Set(TenantHeader, s.cfg.TenantID)
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return retry.Retryable(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return retry.Status("loki", resp.StatusCode)
+}
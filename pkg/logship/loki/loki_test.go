@@ -0,0 +1,70 @@
+package loki
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+func TestShipper(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	var attempts atomic.Int32
+	received := make(chan string, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		assert.Equal(PushPath, r.URL.Path)
+		assert.Equal("acme", r.Header.Get(TenantHeader))
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(err)
+		received <- string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s, err := New(&Config{
+		URL:            srv.URL + "/",
+		TenantID:       "acme",
+		InitialBackoff: time.Millisecond,
+	})
+	require.NoError(err)
+
+	jobID, err := job.ParseID("job_01hzy7c3k5f4qbx8g5z6h4n2m1")
+	require.NoError(err)
+
+	err = s.Ship(context.Background(), []logship.Line{
+		{Time: time.Unix(1, 5), JobID: jobID, UserID: "alice", Message: "hello"},
+		{Time: time.Unix(2, 0), JobID: jobID, UserID: "alice", Tenant: "acme", Message: "world", Truncated: true},
+	})
+	require.NoError(err)
+
+	assert.JSONEq(`{"streams": [{
+		"stream": {"service_name": "job-worker"},
+		"values": [
+			["1000000005", "hello", {"job_id": "job_01hzy7c3k5f4qbx8g5z6h4n2m1", "user_id": "alice"}],
+			["2000000000", "world", {"job_id": "job_01hzy7c3k5f4qbx8g5z6h4n2m1", "user_id": "alice", "tenant": "acme", "truncated": "true"}]
+		]
+	}]}`, <-received)
+	assert.Equal(int32(2), attempts.Load())
+
+	_, err = New(&Config{})
+	require.ErrorIs(err, ErrURLRequired)
+}
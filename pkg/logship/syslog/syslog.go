@@ -0,0 +1,238 @@
+// Package syslog implements a logship.Shipper that sends the output of jobs to
+// a syslog daemon as RFC 5424 messages. The job, user and tenant of each line
+// are included as structured data.
+package syslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+const (
+	// DefaultNetwork and DefaultAddr are used if Config.Addr is empty, they
+	// are the local syslog daemon's socket
+	DefaultNetwork = "unixgram"
+	DefaultAddr    = "/dev/log"
+
+	// DefaultTag is used if Config.Tag is empty
+	DefaultTag = "job-worker"
+
+	// timeFormat is RFC 3339 with at most the 6 fractional digits that syslog
+	// allows
+	timeFormat = "2006-01-02T15:04:05.999999Z07:00"
+
+	// sdID is the id of the structured data element that identifies the job.
+	// 32473 is the private enterprise number reserved for documentation.
+	sdID = "job@32473"
+)
+
+// Facility is a syslog facility
+type Facility int
+
+const (
+	FacilityUser   Facility = 1
+	FacilityDaemon Facility = 3
+	FacilityLocal0 Facility = 16
+	FacilityLocal1 Facility = 17
+	FacilityLocal2 Facility = 18
+	FacilityLocal3 Facility = 19
+	FacilityLocal4 Facility = 20
+	FacilityLocal5 Facility = 21
+	FacilityLocal6 Facility = 22
+	FacilityLocal7 Facility = 23
+)
+
+// severityInfo is the severity of all lines. the worker can't tell errors
+// from other output, even stderr is commonly used for informational output.
+const severityInfo = 6
+
+// Config configures a Shipper
+type Config struct {
+	// Network and Addr are the address of the syslog daemon, e.g. "udp" and
+	// "logs.example.com:514". Stream networks, like "tcp" and "unix", use
+	// octet counting framing (RFC 6587).
+	Network string
+	Addr    string
+
+	Tag      string   // the APP-NAME of messages
+	Facility Facility // FacilityUser if 0
+	Hostname string   // os.Hostname() if empty
+}
+
+// Shipper sends lines to syslog
+type Shipper struct {
+	cfg    Config
+	stream bool
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// ensure Shipper implements the logship.Shipper interface
+var _ logship.Shipper = (*Shipper)(nil)
+
+// ErrInvalidFacility is returned by New if the facility is out of range
+var ErrInvalidFacility = errors.New("invalid syslog facility")
+
+// New connects to syslog and returns a new Shipper. Close must be called to
+// release the connection.
+func New(cfg *Config) (*Shipper, error) {
+	s := Shipper{cfg: *cfg}
+
+	if s.cfg.Addr == "" {
+		s.cfg.Network, s.cfg.Addr = DefaultNetwork, DefaultAddr
+	}
+
+	if s.cfg.Tag == "" {
+		s.cfg.Tag = DefaultTag
+	}
+
+	if s.cfg.Facility == 0 {
+		s.cfg.Facility = FacilityUser
+	}
+
+	if s.cfg.Facility < 0 || s.cfg.Facility > FacilityLocal7 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidFacility, s.cfg.Facility)
+	}
+
+	if s.cfg.Hostname == "" {
+		s.cfg.Hostname, _ = os.Hostname()
+	}
+
+	switch s.cfg.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		s.stream = true
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// connect (re)connects to syslog. s.mu must be held, or s not yet shared.
+func (s *Shipper) connect() error {
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+
+	conn, err := net.Dial(s.cfg.Network, s.cfg.Addr)
+	if err != nil {
+		s.conn = nil
+		return fmt.Errorf("error connecting to syslog: %w", err)
+	}
+
+	s.conn = conn
+	return nil
+}
+
+// Ship sends each line as a syslog message. If sending fails, it reconnects
+// and tries again once.
+func (s *Shipper) Ship(ctx context.Context, lines []logship.Line) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return net.ErrClosed
+	}
+
+	for i := range lines {
+		msg := s.format(&lines[i])
+
+		err := s.write(ctx, msg)
+		if err != nil && ctx.Err() == nil {
+			if err = s.connect(); err == nil {
+				err = s.write(ctx, msg)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write writes msg with the deadline of ctx, if any. s.mu must be held.
+func (s *Shipper) write(ctx context.Context, msg []byte) error {
+	if s.conn == nil {
+		return net.ErrClosed
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// format returns l as a syslog message, framed for the network
+func (s *Shipper) format(l *logship.Line) []byte {
+	var sd strings.Builder
+	fmt.Fprintf(&sd, "[%s id=\"%s\" user=\"%s\"", sdID, l.JobID, escapeParam(l.UserID.String()))
+	if l.Tenant != "" {
+		fmt.Fprintf(&sd, " tenant=\"%s\"", escapeParam(l.Tenant.String()))
+	}
+	if l.Truncated {
+		sd.WriteString(` truncated="true"`)
+	}
+	sd.WriteByte(']')
+
+	msg := fmt.Sprintf("<%d>1 %s %s %s - - %s %s",
+		int(s.cfg.Facility)*8+severityInfo,
+		l.Time.UTC().Format(timeFormat),
+		nilValue(s.cfg.Hostname),
+		nilValue(s.cfg.Tag),
+		sd.String(),
+		l.Message,
+	)
+
+	if s.stream {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	return []byte(msg)
+}
+
+// nilValue returns "-", the syslog nil value, if v is empty
+func nilValue(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+// paramEscaper escapes the characters that must be escaped in structured data
+// param values
+var paramEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+func escapeParam(v string) string {
+	return paramEscaper.Replace(v)
+}
+
+// Close closes the connection to syslog
+func (s *Shipper) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,119 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+)
+
+var jobID, _ = job.ParseID("job_01hzy7c3k5f4qbx8g5z6h4n2m1")
+
+var lines = []logship.Line{
+	{
+		Time:    time.Date(2024, 6, 1, 10, 0, 0, 123456789, time.UTC),
+		JobID:   jobID,
+		UserID:  job.UserID(`al"ice`),
+		Message: "hello",
+	},
+	{
+		Time:      time.Date(2024, 6, 1, 10, 0, 1, 0, time.UTC),
+		JobID:     jobID,
+		UserID:    job.UserID(`al"ice`),
+		Tenant:    job.TenantID("acme"),
+		Message:   "world",
+		Truncated: true,
+	},
+}
+
+var want = []string{
+	`<134>1 2024-06-01T10:00:00.123456Z host test - - [job@32473 id="job_01hzy7c3k5f4qbx8g5z6h4n2m1" user="al\"ice"] hello`,
+	`<134>1 2024-06-01T10:00:01Z host test - - [job@32473 id="job_01hzy7c3k5f4qbx8g5z6h4n2m1" user="al\"ice" tenant="acme" truncated="true"] world`,
+}
+
+func TestShipperUDP(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer pc.Close()
+
+	s, err := New(&Config{
+		Network:  "udp",
+		Addr:     pc.LocalAddr().String(),
+		Tag:      "test",
+		Facility: FacilityLocal0,
+		Hostname: "host",
+	})
+	require.NoError(err)
+	defer s.Close()
+
+	require.NoError(s.Ship(context.Background(), lines))
+
+	buf := make([]byte, 1024)
+	for _, msg := range want {
+		require.NoError(pc.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		n, _, err := pc.ReadFrom(buf)
+		require.NoError(err)
+		require.Equal(msg, string(buf[:n]))
+	}
+
+	require.NoError(s.Close())
+	require.ErrorIs(s.Ship(context.Background(), lines), net.ErrClosed)
+}
+
+func TestShipperTCP(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer lis.Close()
+
+	s, err := New(&Config{
+		Network:  "tcp",
+		Addr:     lis.Addr().String(),
+		Tag:      "test",
+		Facility: FacilityLocal0,
+		Hostname: "host",
+	})
+	require.NoError(err)
+	defer s.Close()
+
+	conn, err := lis.Accept()
+	require.NoError(err)
+	defer conn.Close()
+
+	require.NoError(s.Ship(context.Background(), lines))
+
+	// messages are framed with their length
+	r := bufio.NewReader(conn)
+	for _, msg := range want {
+		var n int
+		_, err = fmt.Fscanf(r, "%d ", &n)
+		require.NoError(err)
+		require.Equal(len(msg), n)
+
+		p := make([]byte, n)
+		_, err = io.ReadFull(r, p)
+		require.NoError(err)
+		require.Equal(msg, string(p))
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(&Config{Network: "udp", Addr: "127.0.0.1:514", Facility: 24})
+	assert.ErrorIs(t, err, ErrInvalidFacility)
+}
@@ -0,0 +1,282 @@
+// Package mux implements a small framing protocol that multiplexes several
+// streams, like the stdin, stdout and stderr of a job along with terminal
+// resizes and control messages, over a single ordered stream such as a
+// bidirectional gRPC stream or a net.Conn. Each frame is a stream id, flags and
+// a payload. Frames written by concurrent goroutines are never interleaved, so
+// the peer sees them in exactly the order they were written.
+package mux
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//go:generate stringer -type=StreamID -trimprefix=Stream
+
+// StreamID identifies the stream that a frame belongs to
+type StreamID uint8
+
+const (
+	StreamUnspecified StreamID = iota
+	StreamStdin                // input to the job
+	StreamStdout               // standard output of the job
+	StreamStderr               // standard error of the job
+	StreamResize               // terminal size changes, see Resize
+	StreamControl              // control messages, the payload is defined by the application
+)
+
+// Flags modify how a frame is handled
+type Flags uint8
+
+const (
+	// FlagEOF marks the last frame of a stream. Its payload, which may be
+	// empty, is still delivered.
+	FlagEOF Flags = 1 << iota
+)
+
+// Has returns true if all of flag are set
+func (f Flags) Has(flag Flags) bool {
+	return f&flag == flag
+}
+
+const (
+	// HeaderSize is the size of the encoded frame header: the stream id, the
+	// flags and the big endian uint32 length of the payload
+	HeaderSize = 6
+
+	// DefaultMaxPayload is the default maximum size of a frame's payload
+	DefaultMaxPayload = 1 << 20 // 1MiB
+)
+
+var (
+	// ErrPayloadTooLarge is returned when a frame's payload is larger than
+	// the maximum allowed
+	ErrPayloadTooLarge = errors.New("frame payload is too large")
+
+	// ErrInvalidStream is returned when a frame has an unknown stream id
+	ErrInvalidStream = errors.New("invalid stream id")
+
+	// ErrShortFrame is returned by Frame.UnmarshalBinary when the data is
+	// shorter than the frame's header says it is
+	ErrShortFrame = errors.New("frame is truncated")
+)
+
+// Frame is a single message of a stream
+type Frame struct {
+	Stream  StreamID
+	Flags   Flags
+	Payload []byte
+}
+
+// validate returns an error if the frame can't be sent
+func (f Frame) validate(maxPayload int) error {
+	if f.Stream == StreamUnspecified || f.Stream > StreamControl {
+		return fmt.Errorf("%w: %d", ErrInvalidStream, f.Stream)
+	}
+
+	if len(f.Payload) > maxPayload {
+		return fmt.Errorf("%w: %d > %d bytes", ErrPayloadTooLarge, len(f.Payload), maxPayload)
+	}
+
+	return nil
+}
+
+// header returns the encoded header of the frame
+func (f Frame) header() [HeaderSize]byte {
+	var h [HeaderSize]byte
+	h[0] = byte(f.Stream)
+	h[1] = byte(f.Flags)
+	binary.BigEndian.PutUint32(h[2:], uint32(len(f.Payload)))
+	return h
+}
+
+// MarshalBinary encodes the frame. It is intended for transports, like gRPC,
+// that already delimit messages so that each message carries one frame.
+func (f Frame) MarshalBinary() ([]byte, error) {
+	if err := f.validate(DefaultMaxPayload); err != nil {
+		return nil, err
+	}
+
+	h := f.header()
+	return append(h[:], f.Payload...), nil
+}
+
+// UnmarshalBinary decodes a frame encoded by MarshalBinary. The payload
+// references data rather than copying it.
+func (f *Frame) UnmarshalBinary(data []byte) error {
+	if len(data) < HeaderSize {
+		return ErrShortFrame
+	}
+
+	size := binary.BigEndian.Uint32(data[2:HeaderSize])
+	if uint64(len(data)-HeaderSize) < uint64(size) {
+		return ErrShortFrame
+	}
+
+	frame := Frame{
+		Stream:  StreamID(data[0]),
+		Flags:   Flags(data[1]),
+		Payload: data[HeaderSize : HeaderSize+int(size)],
+	}
+
+	if err := frame.validate(DefaultMaxPayload); err != nil {
+		return err
+	}
+
+	*f = frame
+	return nil
+}
+
+// Writer writes frames to an underlying byte stream. It is safe for concurrent
+// use.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter returns a Writer that writes frames to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteFrame writes a single frame. The payload may not be larger than
+// DefaultMaxPayload.
+func (w *Writer) WriteFrame(f Frame) error {
+	if err := f.validate(DefaultMaxPayload); err != nil {
+		return err
+	}
+
+	h := f.header()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.w.Write(h[:]); err != nil {
+		return err
+	}
+
+	if len(f.Payload) == 0 {
+		return nil
+	}
+
+	_, err := w.w.Write(f.Payload)
+	return err
+}
+
+// Stream returns an io.WriteCloser that writes everything written to it as
+// frames of stream id. Close sends an empty frame with FlagEOF.
+func (w *Writer) Stream(id StreamID) io.WriteCloser {
+	return &streamWriter{w: w, id: id}
+}
+
+// streamWriter is returned by Writer.Stream
+type streamWriter struct {
+	w  *Writer
+	id StreamID
+}
+
+// Write splits p into frames of at most DefaultMaxPayload bytes
+func (s *streamWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		size := min(len(p), DefaultMaxPayload)
+		if err := s.w.WriteFrame(Frame{Stream: s.id, Payload: p[:size]}); err != nil {
+			return n, err
+		}
+		n += size
+		p = p[size:]
+	}
+	return n, nil
+}
+
+// Close sends FlagEOF for the stream
+func (s *streamWriter) Close() error {
+	return s.w.WriteFrame(Frame{Stream: s.id, Flags: FlagEOF})
+}
+
+// Reader reads frames from an underlying byte stream. It is not safe for
+// concurrent use.
+type Reader struct {
+	r          io.Reader
+	maxPayload int
+}
+
+// NewReader returns a Reader that reads frames from r. Frames with payloads
+// larger than maxPayload are rejected with ErrPayloadTooLarge so that a peer
+// can't make the reader allocate without bound. If maxPayload <= 0,
+// DefaultMaxPayload is used.
+func NewReader(r io.Reader, maxPayload int) *Reader {
+	if maxPayload <= 0 {
+		maxPayload = DefaultMaxPayload
+	}
+
+	return &Reader{r: r, maxPayload: maxPayload}
+}
+
+// ReadFrame reads the next frame. io.EOF is returned only if the stream ended
+// cleanly between frames, io.ErrUnexpectedEOF if it ended within one.
+func (r *Reader) ReadFrame() (Frame, error) {
+	var h [HeaderSize]byte
+	if _, err := io.ReadFull(r.r, h[:]); err != nil {
+		return Frame{}, err
+	}
+
+	size := binary.BigEndian.Uint32(h[2:])
+	if uint64(size) > uint64(r.maxPayload) {
+		return Frame{}, fmt.Errorf("%w: %d > %d bytes", ErrPayloadTooLarge, size, r.maxPayload)
+	}
+
+	f := Frame{
+		Stream:  StreamID(h[0]),
+		Flags:   Flags(h[1]),
+		Payload: make([]byte, size),
+	}
+
+	if err := f.validate(r.maxPayload); err != nil {
+		return Frame{}, err
+	}
+
+	if _, err := io.ReadFull(r.r, f.Payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Frame{}, err
+	}
+
+	return f, nil
+}
+
+// ResizeSize is the size of an encoded Resize payload
+const ResizeSize = 4
+
+// ErrInvalidResize is returned by DecodeResize if the payload isn't
+// ResizeSize bytes
+var ErrInvalidResize = errors.New("invalid resize payload")
+
+// Resize is the payload of a StreamResize frame
+type Resize struct {
+	Rows, Cols uint16
+}
+
+// Frame returns a StreamResize frame of the size
+func (s Resize) Frame() Frame {
+	p := make([]byte, ResizeSize)
+	binary.BigEndian.PutUint16(p, s.Rows)
+	binary.BigEndian.PutUint16(p[2:], s.Cols)
+	return Frame{Stream: StreamResize, Payload: p}
+}
+
+// DecodeResize decodes the payload of a StreamResize frame
+func DecodeResize(p []byte) (Resize, error) {
+	if len(p) != ResizeSize {
+		return Resize{}, fmt.Errorf("%w: %d bytes", ErrInvalidResize, len(p))
+	}
+
+	return Resize{
+		Rows: binary.BigEndian.Uint16(p),
+		Cols: binary.BigEndian.Uint16(p[2:]),
+	}, nil
+}
@@ -0,0 +1,204 @@
+package mux
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrames(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+
+		stdout := w.Stream(StreamStdout)
+		_, err := io.WriteString(stdout, "foo")
+		require.NoError(err)
+		require.NoError(w.WriteFrame(Resize{Rows: 24, Cols: 80}.Frame()))
+		_, err = io.WriteString(w.Stream(StreamStderr), "bar")
+		require.NoError(err)
+		require.NoError(stdout.Close())
+
+		r := NewReader(&buf, 0)
+
+		f, err := r.ReadFrame()
+		require.NoError(err)
+		assert.Equal(Frame{Stream: StreamStdout, Payload: []byte("foo")}, f)
+
+		f, err = r.ReadFrame()
+		require.NoError(err)
+		assert.Equal(StreamResize, f.Stream)
+		size, err := DecodeResize(f.Payload)
+		require.NoError(err)
+		assert.Equal(Resize{Rows: 24, Cols: 80}, size)
+
+		f, err = r.ReadFrame()
+		require.NoError(err)
+		assert.Equal(Frame{Stream: StreamStderr, Payload: []byte("bar")}, f)
+
+		f, err = r.ReadFrame()
+		require.NoError(err)
+		assert.Equal(StreamStdout, f.Stream)
+		assert.True(f.Flags.Has(FlagEOF))
+		assert.Empty(f.Payload)
+
+		_, err = r.ReadFrame()
+		assert.ErrorIs(err, io.EOF)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+
+		var wg sync.WaitGroup
+		for _, id := range []StreamID{StreamStdout, StreamStderr} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					_ = w.WriteFrame(Frame{Stream: id, Payload: []byte(id.String())})
+				}
+			}()
+		}
+		wg.Wait()
+
+		r := NewReader(&buf, 0)
+		for range 200 {
+			f, err := r.ReadFrame()
+			require.NoError(err)
+			require.Equal(f.Stream.String(), string(f.Payload))
+		}
+	})
+
+	t.Run("large", func(t *testing.T) {
+		t.Parallel()
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+
+		n, err := w.Stream(StreamStdin).Write(make([]byte, DefaultMaxPayload+1))
+		require.NoError(err)
+		assert.Equal(DefaultMaxPayload+1, n)
+
+		r := NewReader(&buf, 0)
+
+		f, err := r.ReadFrame()
+		require.NoError(err)
+		assert.Len(f.Payload, DefaultMaxPayload)
+
+		f, err = r.ReadFrame()
+		require.NoError(err)
+		assert.Len(f.Payload, 1)
+
+		err = w.WriteFrame(Frame{Stream: StreamStdin, Payload: make([]byte, DefaultMaxPayload+1)})
+		assert.ErrorIs(err, ErrPayloadTooLarge)
+
+		require.NoError(w.WriteFrame(Frame{Stream: StreamStdin, Payload: make([]byte, 10)}))
+		_, err = NewReader(&buf, 5).ReadFrame()
+		assert.ErrorIs(err, ErrPayloadTooLarge)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		assert := assert.New(t)
+
+		w := NewWriter(io.Discard)
+		assert.ErrorIs(w.WriteFrame(Frame{}), ErrInvalidStream)
+		assert.ErrorIs(w.WriteFrame(Frame{Stream: StreamControl + 1}), ErrInvalidStream)
+
+		_, err := NewReader(strings.NewReader("\x01\x00\x00\x00\x00\x05foo"), 0).ReadFrame()
+		assert.ErrorIs(err, io.ErrUnexpectedEOF)
+
+		_, err = NewReader(strings.NewReader("\x09\x00\x00\x00\x00\x00"), 0).ReadFrame()
+		assert.ErrorIs(err, ErrInvalidStream)
+
+		_, err = DecodeResize([]byte("foo"))
+		assert.ErrorIs(err, ErrInvalidResize)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		t.Parallel()
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		in := Frame{Stream: StreamControl, Flags: FlagEOF, Payload: []byte("stop")}
+		data, err := in.MarshalBinary()
+		require.NoError(err)
+
+		var out Frame
+		require.NoError(out.UnmarshalBinary(data))
+		assert.Equal(in, out)
+
+		assert.ErrorIs(out.UnmarshalBinary(data[:HeaderSize-1]), ErrShortFrame)
+		assert.ErrorIs(out.UnmarshalBinary(data[:len(data)-1]), ErrShortFrame)
+	})
+}
+
+// FuzzReadFrame checks that frames read from a peer, however malformed, are
+// either rejected or valid, that the stream and binary decodings agree, and
+// that valid frames are written back out the same way
+func FuzzReadFrame(f *testing.F) {
+	f.Add([]byte("\x02\x00\x00\x00\x00\x03foo"), 0)
+	f.Add([]byte("\x04\x01\x00\x00\x00\x04\x00\x18\x00\x50"), 0)
+	f.Add([]byte("\x01\x00\x00\x00\x00\x05foo"), 0)
+	f.Add([]byte("\x09\x00\x00\x00\x00\x00"), 0)
+	f.Add([]byte("\x01\x00\xff\xff\xff\xff"), 4)
+
+	f.Fuzz(func(t *testing.T, data []byte, maxPayload int) {
+		r := NewReader(bytes.NewReader(data), maxPayload)
+		if maxPayload <= 0 {
+			maxPayload = DefaultMaxPayload
+		}
+
+		var bin Frame
+		binErr := bin.UnmarshalBinary(data)
+
+		fr, err := r.ReadFrame()
+		if err != nil {
+			return
+		}
+
+		require.NoError(t, fr.validate(maxPayload))
+		require.LessOrEqual(t, HeaderSize+len(fr.Payload), len(data))
+
+		if maxPayload >= DefaultMaxPayload {
+			require.NoError(t, binErr)
+			require.Equal(t, fr.Stream, bin.Stream)
+			require.Equal(t, fr.Flags, bin.Flags)
+			require.Equal(t, fr.Payload, bin.Payload)
+		}
+
+		if fr.Stream == StreamResize {
+			_, _ = DecodeResize(fr.Payload)
+		}
+
+		if len(fr.Payload) > DefaultMaxPayload {
+			return
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, NewWriter(&buf).WriteFrame(fr))
+		require.Equal(t, data[:HeaderSize+len(fr.Payload)], buf.Bytes())
+	})
+}
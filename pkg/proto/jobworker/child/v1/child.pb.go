@@ -0,0 +1,675 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: jobworker/child/v1/child.proto
+
+// Package jobworker.child.v1 is the protocol between the worker and the child
+// it executes, either the worker's own binary or a helper binary, to start
+// each job. The worker writes a ChildSpec to the child's SpecFD, where the
+// child reads it until EOF, instead of passing the job's command line in argv
+// where it would be visible to other users, or mangled, before the job's
+// command is executed.
+
+package jobworkerchildv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ChildSpec is everything the child needs to set up, and execute, a job
+type ChildSpec struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// args are the command of the job, which is looked up in PATH, followed by
+	// its arguments
+	Args []string `protobuf:"bytes,1,rep,name=args,proto3" json:"args,omitempty"`
+	// env is the environment the job runs with, in the form of "key=value"
+	Env        []string     `protobuf:"bytes,2,rep,name=env,proto3" json:"env,omitempty"`
+	Rlimits    []*Rlimit    `protobuf:"bytes,6,rep,name=rlimits,proto3" json:"rlimits,omitempty"`
+	BindMounts []*BindMount `protobuf:"bytes,7,rep,name=bind_mounts,json=bindMounts,proto3" json:"bind_mounts,omitempty"`
+	Priority   *Priority    `protobuf:"bytes,8,opt,name=priority,proto3" json:"priority,omitempty"`
+	// setup is set when the worker passed SetupFD and SetupLogFD to the child
+	Setup bool  `protobuf:"varint,9,opt,name=setup,proto3" json:"setup,omitempty"`
+	Proc  *Proc `protobuf:"bytes,10,opt,name=proc,proto3" json:"proc,omitempty"`
+	// setsid starts the job in a new session, without a controlling terminal
+	Setsid bool `protobuf:"varint,11,opt,name=setsid,proto3" json:"setsid,omitempty"`
+	// no_new_privs sets PR_SET_NO_NEW_PRIVS so that the job can't gain
+	// privileges, e.g. with setuid binaries
+	NoNewPrivs bool `protobuf:"varint,12,opt,name=no_new_privs,json=noNewPrivs,proto3" json:"no_new_privs,omitempty"`
+	// init keeps the child running as pid 1 of the job's pid namespace, where
+	// it reaps orphaned processes, instead of executing the job's command in
+	// its place
+	Init bool `protobuf:"varint,13,opt,name=init,proto3" json:"init,omitempty"`
+	// sandbox is the path of a sandbox-exec(1) profile that the job's command
+	// is executed with, it is only used on darwin
+	Sandbox string `protobuf:"bytes,14,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	// jail is the name of the jail that the child creates, and attaches to,
+	// before executing the job's command, it is only used on freebsd
+	Jail string `protobuf:"bytes,15,opt,name=jail,proto3" json:"jail,omitempty"`
+	// namespaces are the worker's job.Namespace that the child was created in.
+	// /proc is only remounted, and files bind mounted, in a new mount
+	// namespace.
+	Namespaces uint32 `protobuf:"varint,16,opt,name=namespaces,proto3" json:"namespaces,omitempty"`
+	// seccomp installs a seccomp filter that denies syscalls that jobs have no
+	// business making
+	Seccomp bool `protobuf:"varint,17,opt,name=seccomp,proto3" json:"seccomp,omitempty"`
+	// if drop_capabilities is set, every capability that isn't listed in
+	// capabilities is dropped before the job's command is executed
+	Capabilities     []int32 `protobuf:"varint,18,rep,packed,name=capabilities,proto3" json:"capabilities,omitempty"`
+	DropCapabilities bool    `protobuf:"varint,19,opt,name=drop_capabilities,json=dropCapabilities,proto3" json:"drop_capabilities,omitempty"`
+	// masked_devices are device nodes, e.g. of the gpus that the job wasn't
+	// granted, that /dev/null is mounted over
+	MaskedDevices []string `protobuf:"bytes,20,rep,name=masked_devices,json=maskedDevices,proto3" json:"masked_devices,omitempty"`
+}
+
+func (x *ChildSpec) Reset() {
+	*x = ChildSpec{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_jobworker_child_v1_child_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ChildSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChildSpec) ProtoMessage() {}
+
+func (x *ChildSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_jobworker_child_v1_child_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChildSpec.ProtoReflect.Descriptor instead.
+func (*ChildSpec) Descriptor() ([]byte, []int) {
+	return file_jobworker_child_v1_child_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ChildSpec) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ChildSpec) GetEnv() []string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *ChildSpec) GetRlimits() []*Rlimit {
+	if x != nil {
+		return x.Rlimits
+	}
+	return nil
+}
+
+func (x *ChildSpec) GetBindMounts() []*BindMount {
+	if x != nil {
+		return x.BindMounts
+	}
+	return nil
+}
+
+func (x *ChildSpec) GetPriority() *Priority {
+	if x != nil {
+		return x.Priority
+	}
+	return nil
+}
+
+func (x *ChildSpec) GetSetup() bool {
+	if x != nil {
+		return x.Setup
+	}
+	return false
+}
+
+func (x *ChildSpec) GetProc() *Proc {
+	if x != nil {
+		return x.Proc
+	}
+	return nil
+}
+
+func (x *ChildSpec) GetSetsid() bool {
+	if x != nil {
+		return x.Setsid
+	}
+	return false
+}
+
+func (x *ChildSpec) GetNoNewPrivs() bool {
+	if x != nil {
+		return x.NoNewPrivs
+	}
+	return false
+}
+
+func (x *ChildSpec) GetInit() bool {
+	if x != nil {
+		return x.Init
+	}
+	return false
+}
+
+func (x *ChildSpec) GetSandbox() string {
+	if x != nil {
+		return x.Sandbox
+	}
+	return ""
+}
+
+func (x *ChildSpec) GetJail() string {
+	if x != nil {
+		return x.Jail
+	}
+	return ""
+}
+
+func (x *ChildSpec) GetNamespaces() uint32 {
+	if x != nil {
+		return x.Namespaces
+	}
+	return 0
+}
+
+func (x *ChildSpec) GetSeccomp() bool {
+	if x != nil {
+		return x.Seccomp
+	}
+	return false
+}
+
+func (x *ChildSpec) GetCapabilities() []int32 {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *ChildSpec) GetDropCapabilities() bool {
+	if x != nil {
+		return x.DropCapabilities
+	}
+	return false
+}
+
+func (x *ChildSpec) GetMaskedDevices() []string {
+	if x != nil {
+		return x.MaskedDevices
+	}
+	return nil
+}
+
+// NOTE: keep this synced with worker.Rlimit
+type Rlimit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Resource int32  `protobuf:"varint,1,opt,name=resource,proto3" json:"resource,omitempty"` // a worker.RlimitResource
+	Soft     uint64 `protobuf:"varint,2,opt,name=soft,proto3" json:"soft,omitempty"`
+	Hard     uint64 `protobuf:"varint,3,opt,name=hard,proto3" json:"hard,omitempty"`
+}
+
+func (x *Rlimit) Reset() {
+	*x = Rlimit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_jobworker_child_v1_child_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Rlimit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rlimit) ProtoMessage() {}
+
+func (x *Rlimit) ProtoReflect() protoreflect.Message {
+	mi := &file_jobworker_child_v1_child_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rlimit.ProtoReflect.Descriptor instead.
+func (*Rlimit) Descriptor() ([]byte, []int) {
+	return file_jobworker_child_v1_child_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Rlimit) GetResource() int32 {
+	if x != nil {
+		return x.Resource
+	}
+	return 0
+}
+
+func (x *Rlimit) GetSoft() uint64 {
+	if x != nil {
+		return x.Soft
+	}
+	return 0
+}
+
+func (x *Rlimit) GetHard() uint64 {
+	if x != nil {
+		return x.Hard
+	}
+	return 0
+}
+
+type BindMount struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Source string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Target string `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+}
+
+func (x *BindMount) Reset() {
+	*x = BindMount{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_jobworker_child_v1_child_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BindMount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BindMount) ProtoMessage() {}
+
+func (x *BindMount) ProtoReflect() protoreflect.Message {
+	mi := &file_jobworker_child_v1_child_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BindMount.ProtoReflect.Descriptor instead.
+func (*BindMount) Descriptor() ([]byte, []int) {
+	return file_jobworker_child_v1_child_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BindMount) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *BindMount) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+// NOTE: keep this synced with worker.Priority
+type Priority struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Nice        int32 `protobuf:"varint,1,opt,name=nice,proto3" json:"nice,omitempty"`
+	IoClass     int32 `protobuf:"varint,2,opt,name=io_class,json=ioClass,proto3" json:"io_class,omitempty"` // a worker.IOClass
+	IoLevel     int32 `protobuf:"varint,3,opt,name=io_level,json=ioLevel,proto3" json:"io_level,omitempty"`
+	OomScoreAdj int32 `protobuf:"varint,4,opt,name=oom_score_adj,json=oomScoreAdj,proto3" json:"oom_score_adj,omitempty"`
+}
+
+func (x *Priority) Reset() {
+	*x = Priority{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_jobworker_child_v1_child_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Priority) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Priority) ProtoMessage() {}
+
+func (x *Priority) ProtoReflect() protoreflect.Message {
+	mi := &file_jobworker_child_v1_child_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Priority.ProtoReflect.Descriptor instead.
+func (*Priority) Descriptor() ([]byte, []int) {
+	return file_jobworker_child_v1_child_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Priority) GetNice() int32 {
+	if x != nil {
+		return x.Nice
+	}
+	return 0
+}
+
+func (x *Priority) GetIoClass() int32 {
+	if x != nil {
+		return x.IoClass
+	}
+	return 0
+}
+
+func (x *Priority) GetIoLevel() int32 {
+	if x != nil {
+		return x.IoLevel
+	}
+	return 0
+}
+
+func (x *Priority) GetOomScoreAdj() int32 {
+	if x != nil {
+		return x.OomScoreAdj
+	}
+	return 0
+}
+
+// NOTE: keep this synced with worker.ProcPolicy
+type Proc struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ReadOnly bool `protobuf:"varint,1,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
+	HidePid  bool `protobuf:"varint,2,opt,name=hide_pid,json=hidePid,proto3" json:"hide_pid,omitempty"`
+}
+
+func (x *Proc) Reset() {
+	*x = Proc{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_jobworker_child_v1_child_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Proc) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Proc) ProtoMessage() {}
+
+func (x *Proc) ProtoReflect() protoreflect.Message {
+	mi := &file_jobworker_child_v1_child_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Proc.ProtoReflect.Descriptor instead.
+func (*Proc) Descriptor() ([]byte, []int) {
+	return file_jobworker_child_v1_child_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Proc) GetReadOnly() bool {
+	if x != nil {
+		return x.ReadOnly
+	}
+	return false
+}
+
+func (x *Proc) GetHidePid() bool {
+	if x != nil {
+		return x.HidePid
+	}
+	return false
+}
+
+var File_jobworker_child_v1_child_proto protoreflect.FileDescriptor
+
+var file_jobworker_child_v1_child_proto_rawDesc = []byte{
+	0x0a, 0x1e, 0x6a, 0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2f, 0x63, 0x68, 0x69, 0x6c,
+	0x64, 0x2f, 0x76, 0x31, 0x2f, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x12, 0x6a, 0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2e, 0x63, 0x68, 0x69, 0x6c,
+	0x64, 0x2e, 0x76, 0x31, 0x22, 0xf8, 0x04, 0x0a, 0x09, 0x43, 0x68, 0x69, 0x6c, 0x64, 0x53, 0x70,
+	0x65, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x04, 0x61, 0x72, 0x67, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x76, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x03, 0x65, 0x6e, 0x76, 0x12, 0x34, 0x0a, 0x07, 0x72, 0x6c, 0x69, 0x6d,
+	0x69, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6a, 0x6f, 0x62, 0x77,
+	0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2e, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x52,
+	0x6c, 0x69, 0x6d, 0x69, 0x74, 0x52, 0x07, 0x72, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x73, 0x12, 0x3e,
+	0x0a, 0x0b, 0x62, 0x69, 0x6e, 0x64, 0x5f, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x07, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x6a, 0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2e,
+	0x63, 0x68, 0x69, 0x6c, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x69, 0x6e, 0x64, 0x4d, 0x6f, 0x75,
+	0x6e, 0x74, 0x52, 0x0a, 0x62, 0x69, 0x6e, 0x64, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x12, 0x38,
+	0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1c, 0x2e, 0x6a, 0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2e, 0x63, 0x68, 0x69,
+	0x6c, 0x64, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x52, 0x08,
+	0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x65, 0x74, 0x75,
+	0x70, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x73, 0x65, 0x74, 0x75, 0x70, 0x12, 0x2c,
+	0x0a, 0x04, 0x70, 0x72, 0x6f, 0x63, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x6a,
+	0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2e, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x2e, 0x76,
+	0x31, 0x2e, 0x50, 0x72, 0x6f, 0x63, 0x52, 0x04, 0x70, 0x72, 0x6f, 0x63, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x65, 0x74, 0x73, 0x69, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x65,
+	0x74, 0x73, 0x69, 0x64, 0x12, 0x20, 0x0a, 0x0c, 0x6e, 0x6f, 0x5f, 0x6e, 0x65, 0x77, 0x5f, 0x70,
+	0x72, 0x69, 0x76, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x6e, 0x6f, 0x4e, 0x65,
+	0x77, 0x50, 0x72, 0x69, 0x76, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x69, 0x6e, 0x69, 0x74, 0x18, 0x0d,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x69, 0x6e, 0x69, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x61,
+	0x6e, 0x64, 0x62, 0x6f, 0x78, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x61, 0x6e,
+	0x64, 0x62, 0x6f, 0x78, 0x12, 0x12, 0x0a, 0x04, 0x6a, 0x61, 0x69, 0x6c, 0x18, 0x0f, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x6a, 0x61, 0x69, 0x6c, 0x12, 0x1e, 0x0a, 0x0a, 0x6e, 0x61, 0x6d, 0x65,
+	0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x6e, 0x61,
+	0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x65, 0x63, 0x63,
+	0x6f, 0x6d, 0x70, 0x18, 0x11, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x65, 0x63, 0x63, 0x6f,
+	0x6d, 0x70, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69,
+	0x65, 0x73, 0x18, 0x12, 0x20, 0x03, 0x28, 0x05, 0x52, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x2b, 0x0a, 0x11, 0x64, 0x72, 0x6f, 0x70, 0x5f, 0x63,
+	0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x13, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x10, 0x64, 0x72, 0x6f, 0x70, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x69, 0x65, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x6d, 0x61, 0x73, 0x6b, 0x65, 0x64, 0x5f, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x73, 0x18, 0x14, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0d, 0x6d, 0x61, 0x73,
+	0x6b, 0x65, 0x64, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x73, 0x4a, 0x04, 0x08, 0x03, 0x10, 0x06,
+	0x52, 0x06, 0x63, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x52, 0x06, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x73,
+	0x52, 0x0d, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x73, 0x22,
+	0x4c, 0x0a, 0x06, 0x52, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x72, 0x65, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x6f, 0x66, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x6f, 0x66, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x72,
+	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x68, 0x61, 0x72, 0x64, 0x22, 0x3b, 0x0a,
+	0x09, 0x42, 0x69, 0x6e, 0x64, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x22, 0x78, 0x0a, 0x08, 0x50, 0x72,
+	0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x69, 0x63, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x6e, 0x69, 0x63, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x6f,
+	0x5f, 0x63, 0x6c, 0x61, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x69, 0x6f,
+	0x43, 0x6c, 0x61, 0x73, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x69, 0x6f, 0x5f, 0x6c, 0x65, 0x76, 0x65,
+	0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x69, 0x6f, 0x4c, 0x65, 0x76, 0x65, 0x6c,
+	0x12, 0x22, 0x0a, 0x0d, 0x6f, 0x6f, 0x6d, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x5f, 0x61, 0x64,
+	0x6a, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x6f, 0x6f, 0x6d, 0x53, 0x63, 0x6f, 0x72,
+	0x65, 0x41, 0x64, 0x6a, 0x22, 0x3e, 0x0a, 0x04, 0x50, 0x72, 0x6f, 0x63, 0x12, 0x1b, 0x0a, 0x09,
+	0x72, 0x65, 0x61, 0x64, 0x5f, 0x6f, 0x6e, 0x6c, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x08, 0x72, 0x65, 0x61, 0x64, 0x4f, 0x6e, 0x6c, 0x79, 0x12, 0x19, 0x0a, 0x08, 0x68, 0x69, 0x64,
+	0x65, 0x5f, 0x70, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x68, 0x69, 0x64,
+	0x65, 0x50, 0x69, 0x64, 0x42, 0xce, 0x01, 0x0a, 0x16, 0x63, 0x6f, 0x6d, 0x2e, 0x6a, 0x6f, 0x62,
+	0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2e, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x2e, 0x76, 0x31, 0x42,
+	0x0a, 0x43, 0x68, 0x69, 0x6c, 0x64, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x3e, 0x64,
+	0x6f, 0x65, 0x73, 0x6e, 0x74, 0x6d, 0x61, 0x74, 0x74, 0x65, 0x72, 0x73, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x72, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x76, 0x65, 0x2f, 0x6a, 0x6f, 0x62, 0x77, 0x6f, 0x72,
+	0x6b, 0x65, 0x72, 0x2f, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x2f, 0x76, 0x31, 0x3b, 0x6a, 0x6f, 0x62,
+	0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x63, 0x68, 0x69, 0x6c, 0x64, 0x76, 0x31, 0xa2, 0x02, 0x03,
+	0x4a, 0x58, 0x58, 0xaa, 0x02, 0x12, 0x4a, 0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x2e,
+	0x43, 0x68, 0x69, 0x6c, 0x64, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x12, 0x4a, 0x6f, 0x62, 0x77, 0x6f,
+	0x72, 0x6b, 0x65, 0x72, 0x5c, 0x43, 0x68, 0x69, 0x6c, 0x64, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x1e,
+	0x4a, 0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x5c, 0x43, 0x68, 0x69, 0x6c, 0x64, 0x5c,
+	0x56, 0x31, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02,
+	0x14, 0x4a, 0x6f, 0x62, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x3a, 0x3a, 0x43, 0x68, 0x69, 0x6c,
+	0x64, 0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_jobworker_child_v1_child_proto_rawDescOnce sync.Once
+	file_jobworker_child_v1_child_proto_rawDescData = file_jobworker_child_v1_child_proto_rawDesc
+)
+
+func file_jobworker_child_v1_child_proto_rawDescGZIP() []byte {
+	file_jobworker_child_v1_child_proto_rawDescOnce.Do(func() {
+		file_jobworker_child_v1_child_proto_rawDescData = protoimpl.X.CompressGZIP(file_jobworker_child_v1_child_proto_rawDescData)
+	})
+	return file_jobworker_child_v1_child_proto_rawDescData
+}
+
+var file_jobworker_child_v1_child_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_jobworker_child_v1_child_proto_goTypes = []any{
+	(*ChildSpec)(nil), // 0: jobworker.child.v1.ChildSpec
+	(*Rlimit)(nil),    // 1: jobworker.child.v1.Rlimit
+	(*BindMount)(nil), // 2: jobworker.child.v1.BindMount
+	(*Priority)(nil),  // 3: jobworker.child.v1.Priority
+	(*Proc)(nil),      // 4: jobworker.child.v1.Proc
+}
+var file_jobworker_child_v1_child_proto_depIdxs = []int32{
+	1, // 0: jobworker.child.v1.ChildSpec.rlimits:type_name -> jobworker.child.v1.Rlimit
+	2, // 1: jobworker.child.v1.ChildSpec.bind_mounts:type_name -> jobworker.child.v1.BindMount
+	3, // 2: jobworker.child.v1.ChildSpec.priority:type_name -> jobworker.child.v1.Priority
+	4, // 3: jobworker.child.v1.ChildSpec.proc:type_name -> jobworker.child.v1.Proc
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_jobworker_child_v1_child_proto_init() }
+func file_jobworker_child_v1_child_proto_init() {
+	if File_jobworker_child_v1_child_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_jobworker_child_v1_child_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ChildSpec); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_jobworker_child_v1_child_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*Rlimit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_jobworker_child_v1_child_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*BindMount); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_jobworker_child_v1_child_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*Priority); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_jobworker_child_v1_child_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*Proc); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_jobworker_child_v1_child_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_jobworker_child_v1_child_proto_goTypes,
+		DependencyIndexes: file_jobworker_child_v1_child_proto_depIdxs,
+		MessageInfos:      file_jobworker_child_v1_child_proto_msgTypes,
+	}.Build()
+	File_jobworker_child_v1_child_proto = out.File
+	file_jobworker_child_v1_child_proto_rawDesc = nil
+	file_jobworker_child_v1_child_proto_goTypes = nil
+	file_jobworker_child_v1_child_proto_depIdxs = nil
+}
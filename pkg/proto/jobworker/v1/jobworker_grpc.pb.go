@@ -19,10 +19,25 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	JobWorkerService_StartJob_FullMethodName        = "/jobworker.v1.JobWorkerService/StartJob"
-	JobWorkerService_StopJob_FullMethodName         = "/jobworker.v1.JobWorkerService/StopJob"
-	JobWorkerService_JobStatus_FullMethodName       = "/jobworker.v1.JobWorkerService/JobStatus"
-	JobWorkerService_StreamJobOutput_FullMethodName = "/jobworker.v1.JobWorkerService/StreamJobOutput"
+	JobWorkerService_StartJob_FullMethodName          = "/jobworker.v1.JobWorkerService/StartJob"
+	JobWorkerService_StopJob_FullMethodName           = "/jobworker.v1.JobWorkerService/StopJob"
+	JobWorkerService_StopJobs_FullMethodName          = "/jobworker.v1.JobWorkerService/StopJobs"
+	JobWorkerService_JobStatus_FullMethodName         = "/jobworker.v1.JobWorkerService/JobStatus"
+	JobWorkerService_StreamJobOutput_FullMethodName   = "/jobworker.v1.JobWorkerService/StreamJobOutput"
+	JobWorkerService_WatchJobs_FullMethodName         = "/jobworker.v1.JobWorkerService/WatchJobs"
+	JobWorkerService_GrantJobAccess_FullMethodName    = "/jobworker.v1.JobWorkerService/GrantJobAccess"
+	JobWorkerService_RevokeJobAccess_FullMethodName   = "/jobworker.v1.JobWorkerService/RevokeJobAccess"
+	JobWorkerService_QueryAuditLog_FullMethodName     = "/jobworker.v1.JobWorkerService/QueryAuditLog"
+	JobWorkerService_RemoveJob_FullMethodName         = "/jobworker.v1.JobWorkerService/RemoveJob"
+	JobWorkerService_ListJobs_FullMethodName          = "/jobworker.v1.JobWorkerService/ListJobs"
+	JobWorkerService_ExportJobs_FullMethodName        = "/jobworker.v1.JobWorkerService/ExportJobs"
+	JobWorkerService_GetUsageReport_FullMethodName    = "/jobworker.v1.JobWorkerService/GetUsageReport"
+	JobWorkerService_UpdateJobDeadline_FullMethodName = "/jobworker.v1.JobWorkerService/UpdateJobDeadline"
+	JobWorkerService_UpdateJobLimits_FullMethodName   = "/jobworker.v1.JobWorkerService/UpdateJobLimits"
+	JobWorkerService_GetVersion_FullMethodName        = "/jobworker.v1.JobWorkerService/GetVersion"
+	JobWorkerService_CordonWorker_FullMethodName      = "/jobworker.v1.JobWorkerService/CordonWorker"
+	JobWorkerService_UncordonWorker_FullMethodName    = "/jobworker.v1.JobWorkerService/UncordonWorker"
+	JobWorkerService_DrainWorker_FullMethodName       = "/jobworker.v1.JobWorkerService/DrainWorker"
 )
 
 // JobWorkerServiceClient is the client API for JobWorkerService service.
@@ -31,8 +46,23 @@ const (
 type JobWorkerServiceClient interface {
 	StartJob(ctx context.Context, in *StartJobRequest, opts ...grpc.CallOption) (*StartJobResponse, error)
 	StopJob(ctx context.Context, in *StopJobRequest, opts ...grpc.CallOption) (*StopJobResponse, error)
+	StopJobs(ctx context.Context, in *StopJobsRequest, opts ...grpc.CallOption) (*StopJobsResponse, error)
 	JobStatus(ctx context.Context, in *JobStatusRequest, opts ...grpc.CallOption) (*JobStatusResponse, error)
 	StreamJobOutput(ctx context.Context, in *StreamJobOutputRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamJobOutputResponse], error)
+	WatchJobs(ctx context.Context, in *WatchJobsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchJobsResponse], error)
+	GrantJobAccess(ctx context.Context, in *GrantJobAccessRequest, opts ...grpc.CallOption) (*GrantJobAccessResponse, error)
+	RevokeJobAccess(ctx context.Context, in *RevokeJobAccessRequest, opts ...grpc.CallOption) (*RevokeJobAccessResponse, error)
+	QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (*QueryAuditLogResponse, error)
+	RemoveJob(ctx context.Context, in *RemoveJobRequest, opts ...grpc.CallOption) (*RemoveJobResponse, error)
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	ExportJobs(ctx context.Context, in *ExportJobsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportJobsResponse], error)
+	GetUsageReport(ctx context.Context, in *GetUsageReportRequest, opts ...grpc.CallOption) (*GetUsageReportResponse, error)
+	UpdateJobDeadline(ctx context.Context, in *UpdateJobDeadlineRequest, opts ...grpc.CallOption) (*UpdateJobDeadlineResponse, error)
+	UpdateJobLimits(ctx context.Context, in *UpdateJobLimitsRequest, opts ...grpc.CallOption) (*UpdateJobLimitsResponse, error)
+	GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetVersionResponse, error)
+	CordonWorker(ctx context.Context, in *CordonWorkerRequest, opts ...grpc.CallOption) (*CordonWorkerResponse, error)
+	UncordonWorker(ctx context.Context, in *UncordonWorkerRequest, opts ...grpc.CallOption) (*UncordonWorkerResponse, error)
+	DrainWorker(ctx context.Context, in *DrainWorkerRequest, opts ...grpc.CallOption) (*DrainWorkerResponse, error)
 }
 
 type jobWorkerServiceClient struct {
@@ -63,6 +93,16 @@ func (c *jobWorkerServiceClient) StopJob(ctx context.Context, in *StopJobRequest
 	return out, nil
 }
 
+func (c *jobWorkerServiceClient) StopJobs(ctx context.Context, in *StopJobsRequest, opts ...grpc.CallOption) (*StopJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StopJobsResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_StopJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *jobWorkerServiceClient) JobStatus(ctx context.Context, in *JobStatusRequest, opts ...grpc.CallOption) (*JobStatusResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(JobStatusResponse)
@@ -92,14 +132,187 @@ func (c *jobWorkerServiceClient) StreamJobOutput(ctx context.Context, in *Stream
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type JobWorkerService_StreamJobOutputClient = grpc.ServerStreamingClient[StreamJobOutputResponse]
 
+func (c *jobWorkerServiceClient) WatchJobs(ctx context.Context, in *WatchJobsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchJobsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JobWorkerService_ServiceDesc.Streams[1], JobWorkerService_WatchJobs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchJobsRequest, WatchJobsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobWorkerService_WatchJobsClient = grpc.ServerStreamingClient[WatchJobsResponse]
+
+func (c *jobWorkerServiceClient) GrantJobAccess(ctx context.Context, in *GrantJobAccessRequest, opts ...grpc.CallOption) (*GrantJobAccessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GrantJobAccessResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_GrantJobAccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) RevokeJobAccess(ctx context.Context, in *RevokeJobAccessRequest, opts ...grpc.CallOption) (*RevokeJobAccessResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeJobAccessResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_RevokeJobAccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (*QueryAuditLogResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryAuditLogResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_QueryAuditLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) RemoveJob(ctx context.Context, in *RemoveJobRequest, opts ...grpc.CallOption) (*RemoveJobResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveJobResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_RemoveJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) ExportJobs(ctx context.Context, in *ExportJobsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportJobsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JobWorkerService_ServiceDesc.Streams[2], JobWorkerService_ExportJobs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportJobsRequest, ExportJobsResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobWorkerService_ExportJobsClient = grpc.ServerStreamingClient[ExportJobsResponse]
+
+func (c *jobWorkerServiceClient) GetUsageReport(ctx context.Context, in *GetUsageReportRequest, opts ...grpc.CallOption) (*GetUsageReportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUsageReportResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_GetUsageReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) UpdateJobDeadline(ctx context.Context, in *UpdateJobDeadlineRequest, opts ...grpc.CallOption) (*UpdateJobDeadlineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateJobDeadlineResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_UpdateJobDeadline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) UpdateJobLimits(ctx context.Context, in *UpdateJobLimitsRequest, opts ...grpc.CallOption) (*UpdateJobLimitsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateJobLimitsResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_UpdateJobLimits_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetVersionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetVersionResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_GetVersion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) CordonWorker(ctx context.Context, in *CordonWorkerRequest, opts ...grpc.CallOption) (*CordonWorkerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CordonWorkerResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_CordonWorker_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) UncordonWorker(ctx context.Context, in *UncordonWorkerRequest, opts ...grpc.CallOption) (*UncordonWorkerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UncordonWorkerResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_UncordonWorker_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobWorkerServiceClient) DrainWorker(ctx context.Context, in *DrainWorkerRequest, opts ...grpc.CallOption) (*DrainWorkerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DrainWorkerResponse)
+	err := c.cc.Invoke(ctx, JobWorkerService_DrainWorker_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // JobWorkerServiceServer is the server API for JobWorkerService service.
 // All implementations must embed UnimplementedJobWorkerServiceServer
 // for forward compatibility.
 type JobWorkerServiceServer interface {
 	StartJob(context.Context, *StartJobRequest) (*StartJobResponse, error)
 	StopJob(context.Context, *StopJobRequest) (*StopJobResponse, error)
+	StopJobs(context.Context, *StopJobsRequest) (*StopJobsResponse, error)
 	JobStatus(context.Context, *JobStatusRequest) (*JobStatusResponse, error)
 	StreamJobOutput(*StreamJobOutputRequest, grpc.ServerStreamingServer[StreamJobOutputResponse]) error
+	WatchJobs(*WatchJobsRequest, grpc.ServerStreamingServer[WatchJobsResponse]) error
+	GrantJobAccess(context.Context, *GrantJobAccessRequest) (*GrantJobAccessResponse, error)
+	RevokeJobAccess(context.Context, *RevokeJobAccessRequest) (*RevokeJobAccessResponse, error)
+	QueryAuditLog(context.Context, *QueryAuditLogRequest) (*QueryAuditLogResponse, error)
+	RemoveJob(context.Context, *RemoveJobRequest) (*RemoveJobResponse, error)
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	ExportJobs(*ExportJobsRequest, grpc.ServerStreamingServer[ExportJobsResponse]) error
+	GetUsageReport(context.Context, *GetUsageReportRequest) (*GetUsageReportResponse, error)
+	UpdateJobDeadline(context.Context, *UpdateJobDeadlineRequest) (*UpdateJobDeadlineResponse, error)
+	UpdateJobLimits(context.Context, *UpdateJobLimitsRequest) (*UpdateJobLimitsResponse, error)
+	GetVersion(context.Context, *GetVersionRequest) (*GetVersionResponse, error)
+	CordonWorker(context.Context, *CordonWorkerRequest) (*CordonWorkerResponse, error)
+	UncordonWorker(context.Context, *UncordonWorkerRequest) (*UncordonWorkerResponse, error)
+	DrainWorker(context.Context, *DrainWorkerRequest) (*DrainWorkerResponse, error)
 	mustEmbedUnimplementedJobWorkerServiceServer()
 }
 
@@ -116,12 +329,57 @@ func (UnimplementedJobWorkerServiceServer) StartJob(context.Context, *StartJobRe
 func (UnimplementedJobWorkerServiceServer) StopJob(context.Context, *StopJobRequest) (*StopJobResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method StopJob not implemented")
 }
+func (UnimplementedJobWorkerServiceServer) StopJobs(context.Context, *StopJobsRequest) (*StopJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopJobs not implemented")
+}
 func (UnimplementedJobWorkerServiceServer) JobStatus(context.Context, *JobStatusRequest) (*JobStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method JobStatus not implemented")
 }
 func (UnimplementedJobWorkerServiceServer) StreamJobOutput(*StreamJobOutputRequest, grpc.ServerStreamingServer[StreamJobOutputResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamJobOutput not implemented")
 }
+func (UnimplementedJobWorkerServiceServer) WatchJobs(*WatchJobsRequest, grpc.ServerStreamingServer[WatchJobsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchJobs not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) GrantJobAccess(context.Context, *GrantJobAccessRequest) (*GrantJobAccessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GrantJobAccess not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) RevokeJobAccess(context.Context, *RevokeJobAccessRequest) (*RevokeJobAccessResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeJobAccess not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) QueryAuditLog(context.Context, *QueryAuditLogRequest) (*QueryAuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryAuditLog not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) RemoveJob(context.Context, *RemoveJobRequest) (*RemoveJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveJob not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) ExportJobs(*ExportJobsRequest, grpc.ServerStreamingServer[ExportJobsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportJobs not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) GetUsageReport(context.Context, *GetUsageReportRequest) (*GetUsageReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsageReport not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) UpdateJobDeadline(context.Context, *UpdateJobDeadlineRequest) (*UpdateJobDeadlineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateJobDeadline not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) UpdateJobLimits(context.Context, *UpdateJobLimitsRequest) (*UpdateJobLimitsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateJobLimits not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) GetVersion(context.Context, *GetVersionRequest) (*GetVersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) CordonWorker(context.Context, *CordonWorkerRequest) (*CordonWorkerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CordonWorker not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) UncordonWorker(context.Context, *UncordonWorkerRequest) (*UncordonWorkerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UncordonWorker not implemented")
+}
+func (UnimplementedJobWorkerServiceServer) DrainWorker(context.Context, *DrainWorkerRequest) (*DrainWorkerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DrainWorker not implemented")
+}
 func (UnimplementedJobWorkerServiceServer) mustEmbedUnimplementedJobWorkerServiceServer() {}
 func (UnimplementedJobWorkerServiceServer) testEmbeddedByValue()                          {}
 
@@ -179,6 +437,24 @@ func _JobWorkerService_StopJob_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _JobWorkerService_StopJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).StopJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_StopJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).StopJobs(ctx, req.(*StopJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _JobWorkerService_JobStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(JobStatusRequest)
 	if err := dec(in); err != nil {
@@ -208,6 +484,244 @@ func _JobWorkerService_StreamJobOutput_Handler(srv interface{}, stream grpc.Serv
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type JobWorkerService_StreamJobOutputServer = grpc.ServerStreamingServer[StreamJobOutputResponse]
 
+func _JobWorkerService_WatchJobs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchJobsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobWorkerServiceServer).WatchJobs(m, &grpc.GenericServerStream[WatchJobsRequest, WatchJobsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobWorkerService_WatchJobsServer = grpc.ServerStreamingServer[WatchJobsResponse]
+
+func _JobWorkerService_GrantJobAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GrantJobAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).GrantJobAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_GrantJobAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).GrantJobAccess(ctx, req.(*GrantJobAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_RevokeJobAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeJobAccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).RevokeJobAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_RevokeJobAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).RevokeJobAccess(ctx, req.(*RevokeJobAccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_QueryAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryAuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).QueryAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_QueryAuditLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).QueryAuditLog(ctx, req.(*QueryAuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_RemoveJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).RemoveJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_RemoveJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).RemoveJob(ctx, req.(*RemoveJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_ExportJobs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportJobsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobWorkerServiceServer).ExportJobs(m, &grpc.GenericServerStream[ExportJobsRequest, ExportJobsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JobWorkerService_ExportJobsServer = grpc.ServerStreamingServer[ExportJobsResponse]
+
+func _JobWorkerService_GetUsageReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsageReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).GetUsageReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_GetUsageReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).GetUsageReport(ctx, req.(*GetUsageReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_UpdateJobDeadline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateJobDeadlineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).UpdateJobDeadline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_UpdateJobDeadline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).UpdateJobDeadline(ctx, req.(*UpdateJobDeadlineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_UpdateJobLimits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateJobLimitsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).UpdateJobLimits(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_UpdateJobLimits_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).UpdateJobLimits(ctx, req.(*UpdateJobLimitsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_GetVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).GetVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_GetVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).GetVersion(ctx, req.(*GetVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_CordonWorker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CordonWorkerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).CordonWorker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_CordonWorker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).CordonWorker(ctx, req.(*CordonWorkerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_UncordonWorker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UncordonWorkerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).UncordonWorker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_UncordonWorker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).UncordonWorker(ctx, req.(*UncordonWorkerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JobWorkerService_DrainWorker_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainWorkerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JobWorkerServiceServer).DrainWorker(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JobWorkerService_DrainWorker_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JobWorkerServiceServer).DrainWorker(ctx, req.(*DrainWorkerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // JobWorkerService_ServiceDesc is the grpc.ServiceDesc for JobWorkerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -223,10 +737,62 @@ var JobWorkerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "StopJob",
 			Handler:    _JobWorkerService_StopJob_Handler,
 		},
+		{
+			MethodName: "StopJobs",
+			Handler:    _JobWorkerService_StopJobs_Handler,
+		},
 		{
 			MethodName: "JobStatus",
 			Handler:    _JobWorkerService_JobStatus_Handler,
 		},
+		{
+			MethodName: "GrantJobAccess",
+			Handler:    _JobWorkerService_GrantJobAccess_Handler,
+		},
+		{
+			MethodName: "RevokeJobAccess",
+			Handler:    _JobWorkerService_RevokeJobAccess_Handler,
+		},
+		{
+			MethodName: "QueryAuditLog",
+			Handler:    _JobWorkerService_QueryAuditLog_Handler,
+		},
+		{
+			MethodName: "RemoveJob",
+			Handler:    _JobWorkerService_RemoveJob_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _JobWorkerService_ListJobs_Handler,
+		},
+		{
+			MethodName: "GetUsageReport",
+			Handler:    _JobWorkerService_GetUsageReport_Handler,
+		},
+		{
+			MethodName: "UpdateJobDeadline",
+			Handler:    _JobWorkerService_UpdateJobDeadline_Handler,
+		},
+		{
+			MethodName: "UpdateJobLimits",
+			Handler:    _JobWorkerService_UpdateJobLimits_Handler,
+		},
+		{
+			MethodName: "GetVersion",
+			Handler:    _JobWorkerService_GetVersion_Handler,
+		},
+		{
+			MethodName: "CordonWorker",
+			Handler:    _JobWorkerService_CordonWorker_Handler,
+		},
+		{
+			MethodName: "UncordonWorker",
+			Handler:    _JobWorkerService_UncordonWorker_Handler,
+		},
+		{
+			MethodName: "DrainWorker",
+			Handler:    _JobWorkerService_DrainWorker_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -234,6 +800,16 @@ var JobWorkerService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _JobWorkerService_StreamJobOutput_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchJobs",
+			Handler:       _JobWorkerService_WatchJobs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportJobs",
+			Handler:       _JobWorkerService_ExportJobs_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "jobworker/v1/jobworker.proto",
 }
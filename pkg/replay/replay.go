@@ -0,0 +1,367 @@
+// Package replay records the rpcs handled by a gRPC server, including each of
+// the messages sent on their streams, to a file and serves them back from a
+// replay server. Clients, like the cli or a ui, can then be developed against
+// deterministic responses without a Linux host to run jobs on.
+//
+// A recording is a sequence of json objects, one per line, each of which is a
+// Call. Messages are stored in their protobuf wire format so that recordings
+// don't depend on the generated code of the services they were recorded from.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/interceptor"
+)
+
+// ErrNotProto is returned by Recorder.Err if a message could not be recorded
+// because it isn't a protobuf message
+var ErrNotProto = errors.New("message is not a protobuf message")
+
+// Frame is a single message of a Call
+type Frame struct {
+	// Elapsed is the time since the start of the call that the message was
+	// received, or sent, at
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Message is the message in the protobuf wire format
+	Message []byte `json:"message"`
+}
+
+// Call is a recorded rpc
+type Call struct {
+	Method    string        `json:"method"` // the full method, e.g. "/jobworker.v1.JobWorkerService/StartJob"
+	Requests  []Frame       `json:"requests,omitempty"`
+	Responses []Frame       `json:"responses,omitempty"`
+	Code      codes.Code    `json:"code"`            // the status code the call ended with
+	Error     string        `json:"error,omitempty"` // the status message, if Code isn't codes.OK
+	Duration  time.Duration `json:"duration"`        // how long the call took
+}
+
+// Recorder writes each rpc handled by a server to a recording once it has
+// completed. It is safe for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a Recorder that writes the recording to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error that occurred while recording, if any. Calls
+// aren't affected by recording errors.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// fail sets the error returned by Err, if it isn't already set
+func (r *Recorder) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// write appends c to the recording
+func (r *Recorder) write(c *Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	if err := r.enc.Encode(c); err != nil {
+		r.err = fmt.Errorf("error writing call: %w", err)
+	}
+}
+
+// frame returns m as a Frame received, or sent, at elapsed
+func (r *Recorder) frame(m any, elapsed time.Duration) (Frame, bool) {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		r.fail(fmt.Errorf("%w: %T", ErrNotProto, m))
+		return Frame{}, false
+	}
+
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		r.fail(fmt.Errorf("error marshaling %T: %w", m, err))
+		return Frame{}, false
+	}
+
+	return Frame{Elapsed: elapsed, Message: b}, true
+}
+
+// end completes c with err and writes it
+func (r *Recorder) end(c *Call, start time.Time, err error) {
+	st := status.Convert(err)
+	c.Code = st.Code()
+	if c.Code != codes.OK {
+		c.Error = st.Message()
+	}
+	c.Duration = time.Since(start)
+	r.write(c)
+}
+
+// recordingStream is a grpc.ServerStream that records the messages it
+// receives and sends to call
+type recordingStream struct {
+	grpc.ServerStream
+	r     *Recorder
+	call  *Call
+	start time.Time
+}
+
+func (s *recordingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if f, ok := s.r.frame(m, time.Since(s.start)); ok {
+		s.call.Requests = append(s.call.Requests, f)
+	}
+
+	return nil
+}
+
+func (s *recordingStream) SendMsg(m any) error {
+	if f, ok := s.r.frame(m, time.Since(s.start)); ok {
+		s.call.Responses = append(s.call.Responses, f)
+	}
+
+	return s.ServerStream.SendMsg(m)
+}
+
+// Interceptor returns an interceptor that records calls. It should be the
+// innermost interceptor of the chain so that the recording contains the
+// responses of the handlers, not those of other interceptors.
+func (r *Recorder) Interceptor() interceptor.Interceptor {
+	return interceptor.Interceptor{
+		Name: "record",
+		Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			start := time.Now()
+			call := Call{Method: info.FullMethod}
+
+			if f, ok := r.frame(req, 0); ok {
+				call.Requests = append(call.Requests, f)
+			}
+
+			resp, err := handler(ctx, req)
+			if err == nil {
+				if f, ok := r.frame(resp, time.Since(start)); ok {
+					call.Responses = append(call.Responses, f)
+				}
+			}
+
+			r.end(&call, start, err)
+			return resp, err
+		},
+		Stream: func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			rs := recordingStream{
+				ServerStream: ss,
+				r:            r,
+				call:         &Call{Method: info.FullMethod},
+				start:        time.Now(),
+			}
+
+			err := handler(srv, &rs)
+			r.end(rs.call, rs.start, err)
+			return err
+		},
+	}
+}
+
+// Load reads the calls of a recording written by a Recorder
+func Load(r io.Reader) ([]*Call, error) {
+	var calls []*Call
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var c Call
+		if err := dec.Decode(&c); err != nil {
+			if errors.Is(err, io.EOF) {
+				return calls, nil
+			}
+			return nil, fmt.Errorf("error reading call %d: %w", len(calls)+1, err)
+		}
+		calls = append(calls, &c)
+	}
+}
+
+// rawMessage is a message in the protobuf wire format that is passed through
+// rawCodec as is
+type rawMessage []byte
+
+// rawCodec lets the replay server send and receive messages without knowing
+// their types
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(*rawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *m, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(*rawMessage)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// Server serves the calls of a recording back to clients. Calls of each method
+// are served in the order they were recorded, but a call whose first request
+// is identical to the request of the client is preferred, so that e.g. the
+// status of a particular job is served. Once all of the calls of a method were
+// served, they are served again from the start. It is safe for concurrent use.
+type Server struct {
+	realtime bool
+
+	mu    sync.Mutex
+	calls map[string][]*Call
+	next  map[string]int
+}
+
+// NewServer returns a Server that serves calls. If realtime is true, the
+// messages of calls are sent with the same delays they were recorded with,
+// otherwise they are sent as quickly as possible.
+func NewServer(calls []*Call, realtime bool) *Server {
+	s := Server{
+		realtime: realtime,
+		calls:    map[string][]*Call{},
+		next:     map[string]int{},
+	}
+
+	for _, c := range calls {
+		s.calls[c.Method] = append(s.calls[c.Method], c)
+	}
+
+	return &s
+}
+
+// ServerOptions returns the options that make a grpc.Server serve the calls.
+// No services should be registered with the grpc.Server.
+func (s *Server) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(s.handle),
+	}
+}
+
+// match returns the call to serve for a request to method
+func (s *Server) match(method string, req []byte) *Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := s.calls[method]
+	if len(calls) == 0 {
+		return nil
+	}
+
+	next := s.next[method]
+	for i := range len(calls) {
+		idx := (next + i) % len(calls)
+		c := calls[idx]
+		if len(c.Requests) > 0 && bytes.Equal(c.Requests[0].Message, req) {
+			next = idx
+			break
+		}
+	}
+
+	s.next[method] = (next + 1) % len(calls)
+	return calls[next]
+}
+
+// handle serves a recorded call of the method of stream. all of the methods
+// of the job worker take a single request, so only one is read.
+func (s *Server) handle(_ any, stream grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "unknown method")
+	}
+
+	var req rawMessage
+	if err := stream.RecvMsg(&req); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	call := s.match(method, req)
+	if call == nil {
+		return status.Errorf(codes.Unimplemented, "no recorded calls of %s", method)
+	}
+
+	ctx := stream.Context()
+	start := time.Now()
+
+	for _, f := range call.Responses {
+		if s.realtime {
+			if err := sleep(ctx, f.Elapsed-time.Since(start)); err != nil {
+				return status.FromContextError(err).Err()
+			}
+		}
+
+		msg := rawMessage(f.Message)
+		if err := stream.SendMsg(&msg); err != nil {
+			return err
+		}
+	}
+
+	if s.realtime {
+		if err := sleep(ctx, call.Duration-time.Since(start)); err != nil {
+			return status.FromContextError(err).Err()
+		}
+	}
+
+	if call.Code == codes.OK {
+		return nil
+	}
+
+	return status.Error(call.Code, call.Error)
+}
+
+// sleep waits for d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
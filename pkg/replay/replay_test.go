@@ -0,0 +1,185 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/interceptor"
+)
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent use
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+// serve starts a grpc.Server with opts, and registered services, and returns a
+// health client connected to it
+func serve(t *testing.T, opts []grpc.ServerOption, register func(*grpc.Server)) healthpb.HealthClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	if register != nil {
+		register(srv)
+	}
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestRecordReplay(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// record
+
+	var buf lockedBuffer
+	rec := NewRecorder(&buf)
+
+	hs := health.NewServer()
+	hs.SetServingStatus("jobs", healthpb.HealthCheckResponse_SERVING)
+
+	client := serve(t, interceptor.Chain{rec.Interceptor()}.ServerOptions(), func(srv *grpc.Server) {
+		healthpb.RegisterHealthServer(srv, hs)
+	})
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "jobs"})
+	require.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	assert.Equal(codes.NotFound, status.Code(err))
+
+	wctx, wcancel := context.WithCancel(ctx)
+	watch, err := client.Watch(wctx, &healthpb.HealthCheckRequest{Service: "jobs"})
+	require.NoError(err)
+
+	resp, err = watch.Recv()
+	require.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	hs.SetServingStatus("jobs", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	resp, err = watch.Recv()
+	require.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+
+	wcancel()
+	_, err = watch.Recv()
+	require.Equal(codes.Canceled, status.Code(err))
+
+	// the watch is only recorded once its handler returns
+	require.Eventually(func() bool {
+		return bytes.Count(buf.Bytes(), []byte("\n")) == 4
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(rec.Err())
+
+	calls, err := Load(bytes.NewReader(buf.Bytes()))
+	require.NoError(err)
+	require.Len(calls, 4)
+	assert.Equal("/grpc.health.v1.Health/Check", calls[0].Method)
+	assert.Equal(codes.NotFound, calls[2].Code)
+	assert.Equal("/grpc.health.v1.Health/Watch", calls[3].Method)
+	assert.Len(calls[3].Requests, 1)
+	assert.Len(calls[3].Responses, 2)
+
+	// replay
+
+	client = serve(t, NewServer(calls, false).ServerOptions(), nil)
+
+	// the call with the same request is preferred over the next one
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "jobs"})
+	require.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	assert.Equal(codes.NotFound, status.Code(err))
+
+	// requests that weren't recorded get the next call, wrapping around
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "other"})
+	require.NoError(err)
+	assert.Equal(healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	watch, err = client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "jobs"})
+	require.NoError(err)
+
+	for _, want := range []healthpb.HealthCheckResponse_ServingStatus{
+		healthpb.HealthCheckResponse_SERVING,
+		healthpb.HealthCheckResponse_NOT_SERVING,
+	} {
+		resp, err = watch.Recv()
+		require.NoError(err)
+		assert.Equal(want, resp.GetStatus())
+	}
+
+	_, err = watch.Recv()
+	assert.Equal(codes.Canceled, status.Code(err))
+
+	// methods that weren't recorded
+	client = serve(t, NewServer(nil, false).ServerOptions(), nil)
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Equal(codes.Unimplemented, status.Code(err))
+}
+
+func TestReplayRealtime(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	calls := []*Call{{
+		Method:    "/grpc.health.v1.Health/Check",
+		Responses: []Frame{{Elapsed: 50 * time.Millisecond}},
+		Duration:  50 * time.Millisecond,
+	}}
+
+	client := serve(t, NewServer(calls, true).ServerOptions(), nil)
+
+	start := time.Now()
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(err)
+	require.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+}
@@ -1,12 +1,16 @@
 package safebuffer
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"sync"
 	"sync/atomic"
 )
 
+// ErrNegativeOffset is returned when reading from an offset < 0
+var ErrNegativeOffset = errors.New("offset is negative")
+
 // byteNode is a linked list node
 type byteNode struct {
 	data []byte
@@ -36,8 +40,19 @@ func (b *ByteBuffer) String() string {
 	return s.String()
 }
 
+// Len returns the number of bytes that have been written to the buffer
+func (b *ByteBuffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.size
+}
+
 // ReadOffset is called by readers to read from a given offset into p
 func (b *ByteBuffer) ReadOffset(offset int, p []byte) (int, error) {
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	}
+
 	if len(p) == 0 {
 		return 0, nil
 	}
@@ -75,6 +90,51 @@ func (b *ByteBuffer) ReadOffset(offset int, p []byte) (int, error) {
 	return 0, io.EOF
 }
 
+// SlicesOffset returns the data starting at offset, up to max bytes, as slices
+// of the underlying buffer rather than copying it. The slices must not be
+// modified. If max <= 0 there is no limit. io.EOF is returned if there is no
+// data at offset.
+func (b *ByteBuffer) SlicesOffset(offset, max int) ([][]byte, error) {
+	if offset < 0 {
+		return nil, ErrNegativeOffset
+	}
+
+	b.mu.RLock()
+	if b.size <= offset {
+		b.mu.RUnlock()
+		return nil, io.EOF
+	}
+	node := b.root
+	b.mu.RUnlock()
+
+	var slices [][]byte
+	for node != nil {
+		if offset >= len(node.data) {
+			offset -= len(node.data)
+			node = node.next.Load()
+			continue
+		}
+
+		data := node.data[offset:]
+		if max > 0 && len(data) >= max {
+			slices = append(slices, data[:max:max])
+			break
+		}
+		if len(data) > 0 {
+			slices = append(slices, data[:len(data):len(data)])
+		}
+		max -= len(data)
+		offset = 0
+		node = node.next.Load()
+	}
+
+	if len(slices) > 0 {
+		return slices, nil
+	}
+
+	return nil, io.EOF
+}
+
 // Write is the io.Writer interface that writes to the buffer
 func (b *ByteBuffer) Write(p []byte) (int, error) {
 	node := byteNode{
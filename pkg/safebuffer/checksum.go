@@ -0,0 +1,33 @@
+package safebuffer
+
+import (
+	"crypto/sha256"
+	"hash/crc32"
+)
+
+// crc32cTable is the Castagnoli table used for rolling output checksums
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// UpdateChecksum returns the CRC-32C of the output that produced crc followed
+// by p. Starting from 0 and calling it with each chunk of output, in order,
+// results in a rolling checksum of the output that can be sent with each chunk
+// so that a client can verify it hasn't missed or corrupted any data.
+func UpdateChecksum(crc uint32, p []byte) uint32 {
+	return crc32.Update(crc, crc32cTable, p)
+}
+
+// Digest returns the SHA-256 digest of all of the data that has been written
+// to the ByteBuffer
+func (b *ByteBuffer) Digest() []byte {
+	h := sha256.New()
+	p := make([]byte, 32<<10)
+	for offset := 0; ; {
+		n, err := b.ReadOffset(offset, p)
+		if err != nil {
+			break
+		}
+		h.Write(p[:n])
+		offset += n
+	}
+	return h.Sum(nil)
+}
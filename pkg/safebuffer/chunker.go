@@ -0,0 +1,183 @@
+package safebuffer
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxChunkSize is the default maximum size of a chunk returned by
+	// Chunker.Next
+	DefaultMaxChunkSize = 64 << 10 // 64KiB
+
+	// DefaultFlushInterval is the default amount of time that Chunker.Next
+	// waits for more data before returning a partial chunk
+	DefaultFlushInterval = 10 * time.Millisecond
+)
+
+// ChunkerConfig configures how a Chunker coalesces reads
+type ChunkerConfig struct {
+	// MaxSize is the maximum number of bytes returned by Next. If <= 0,
+	// DefaultMaxChunkSize is used.
+	MaxSize int
+
+	// FlushInterval is how long Next waits, after receiving the first data of
+	// a chunk, for more data before returning a partial chunk. If 0, data is
+	// returned as soon as it is read. If < 0, DefaultFlushInterval is used.
+	FlushInterval time.Duration
+}
+
+// readResult is the result of a single Read by the Chunker's read loop
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// Chunker coalesces many small reads from an io.Reader into fewer, larger
+// chunks. It is intended to be used when streaming job output so that a job
+// making many tiny writes doesn't result in a message being sent per write.
+type Chunker struct {
+	r   io.Reader
+	cfg ChunkerConfig
+
+	results   chan readResult
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	// these are only accessed by Next and Checksum
+	pending  []byte
+	err      error
+	checksum uint32
+}
+
+// NewChunker returns a Chunker that reads from r. Close must be called when the
+// Chunker is no longer needed to free its resources.
+func NewChunker(r io.Reader, cfg ChunkerConfig) *Chunker {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxChunkSize
+	}
+
+	if cfg.FlushInterval < 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+
+	c := Chunker{
+		r:       r,
+		cfg:     cfg,
+		results: make(chan readResult),
+		stop:    make(chan struct{}),
+	}
+
+	go c.readLoop()
+
+	return &c
+}
+
+// readLoop reads from r until an error is returned or the Chunker is closed.
+// the read buffer is reused, so only the data that was read is sent.
+func (c *Chunker) readLoop() {
+	p := make([]byte, c.cfg.MaxSize)
+	for {
+		n, err := c.r.Read(p)
+
+		select {
+		case c.results <- readResult{data: bytes.Clone(p[:n]), err: err}:
+		case <-c.stop:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Next returns the next chunk of data of at most MaxSize bytes. It blocks until
+// some data is available, then waits up to FlushInterval for more data to fill
+// the chunk. Any error returned by the underlying reader, including io.EOF, is
+// returned only after all data read before it has been returned.
+func (c *Chunker) Next() ([]byte, error) {
+	if len(c.pending) == 0 && c.err != nil {
+		return nil, c.err
+	}
+
+	// the chunk only grows as large as the data that fills it
+	chunk := c.fill(nil)
+
+	// wait until there is at least some data
+	for len(chunk) == 0 {
+		if c.err != nil {
+			return nil, c.err
+		}
+		c.receive(<-c.results)
+		chunk = c.fill(chunk)
+	}
+
+	if c.cfg.FlushInterval == 0 {
+		return c.sum(chunk), nil
+	}
+
+	timer := time.NewTimer(c.cfg.FlushInterval)
+	defer timer.Stop()
+
+	for len(chunk) < c.cfg.MaxSize && c.err == nil {
+		select {
+		case res := <-c.results:
+			c.receive(res)
+			chunk = c.fill(chunk)
+		case <-timer.C:
+			return c.sum(chunk), nil
+		}
+	}
+
+	return c.sum(chunk), nil
+}
+
+// sum adds chunk to the rolling checksum and returns it
+func (c *Chunker) sum(chunk []byte) []byte {
+	c.checksum = UpdateChecksum(c.checksum, chunk)
+	return chunk
+}
+
+// Checksum returns the rolling CRC-32C, see UpdateChecksum, of all of the
+// chunks returned by Next so far. It is intended to be sent along with each
+// chunk.
+func (c *Chunker) Checksum() uint32 {
+	return c.checksum
+}
+
+// receive stores the result of a read to be added to the next chunk. the data
+// is owned by the Chunker, so it is kept as is if nothing else is pending.
+func (c *Chunker) receive(res readResult) {
+	if len(c.pending) == 0 {
+		c.pending = res.data
+	} else {
+		c.pending = append(c.pending, res.data...)
+	}
+	if res.err != nil {
+		c.err = res.err
+	}
+}
+
+// fill moves as much pending data as will fit into chunk
+func (c *Chunker) fill(chunk []byte) []byte {
+	n := min(len(c.pending), c.cfg.MaxSize-len(chunk))
+	chunk = append(chunk, c.pending[:n]...)
+	c.pending = c.pending[n:]
+	return chunk
+}
+
+// Close stops the Chunker from reading any more data. If the underlying reader
+// is an io.Closer, it is also closed. Next must not be called after Close.
+func (c *Chunker) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		if closer, ok := c.r.(io.Closer); ok {
+			err = closer.Close()
+		}
+	})
+	return err
+}
@@ -1,90 +1,64 @@
 package safebuffer
 
 import (
+	"slices"
 	"sync"
 
 	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer/safereader"
 )
 
-// Readers manages a list of safereader.Readers
+// Readers manages a list of safereader.Readers. Readers remove themselves from
+// the list when they are closed, so it only ever holds the open ones.
 type Readers struct {
-	mu   sync.RWMutex
-	list []*safereader.Reader
+	mu     sync.RWMutex
+	list   []*safereader.Reader
+	closed bool
 }
 
-// Add a Reader to the list
-func (c *Readers) Add(chans *safereader.Reader) {
+// Add a Reader to the list. If the list has been closed, the Reader is closed
+// instead.
+func (c *Readers) Add(r *safereader.Reader) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.list = append(c.list, chans)
-}
-
-// ReadersIterator is an iterator used for looping throuth the list
-type ReadersIterator struct {
-	pos int
-	*Readers
-}
-
-// Iterator returns an iterator
-func (c *Readers) Iterator() *ReadersIterator {
-	return &ReadersIterator{
-		Readers: c,
-		pos:     -1,
+	closed := c.closed
+	if !closed {
+		c.list = append(c.list, r)
 	}
-}
-
-// Next returns true if there is an available reader. It should be called
-// before any other iterator method.
-func (i *ReadersIterator) Next() bool {
-	i.mu.Lock()
-	defer i.mu.Unlock()
-	return i.nextNoLock()
-}
+	c.mu.Unlock()
 
-func (i *ReadersIterator) nextNoLock() bool {
-	for i.pos+1 < len(i.list) {
-		i.pos++
-		if i.list[i.pos].IsClosed() {
-			i.deleteNoLock()
-			continue
-		}
-		return true
+	// closing it may remove it from the list, so the lock must not be held
+	if closed {
+		_ = r.Close()
 	}
-
-	return false
 }
 
-// Reader returns the current reader
-func (i *ReadersIterator) Reader() *safereader.Reader {
-	for {
-		i.mu.RLock()
-		if i.pos >= len(i.list) {
-			i.mu.RUnlock()
-			return nil
-		}
-		r := i.list[i.pos]
-		i.mu.RUnlock()
+// Remove a Reader from the list. It does nothing if the Reader isn't in the
+// list.
+func (c *Readers) Remove(r *safereader.Reader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		if !r.IsClosed() {
-			return r
-		}
-		i.Delete()
+	if i := slices.Index(c.list, r); i >= 0 {
+		c.list = slices.Delete(c.list, i, i+1)
 	}
 }
 
-// Delete the current iterator from the list
-func (i *ReadersIterator) Delete() {
-	i.mu.Lock()
-	defer i.mu.Unlock()
-
-	i.deleteNoLock()
-}
+// Close closes all of the Readers in the list and any that are added
+// afterwards
+func (c *Readers) Close() {
+	c.mu.Lock()
+	list := c.list
+	c.list = nil
+	c.closed = true
+	c.mu.Unlock()
 
-func (i *ReadersIterator) deleteNoLock() {
-	if i.pos >= len(i.list) {
-		return
+	for _, r := range list {
+		_ = r.Close()
 	}
+}
 
-	i.list = append(i.list[:i.pos], i.list[i.pos+1:]...)
-	i.pos--
+// Len returns the number of readers in the list
+func (c *Readers) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.list)
 }
@@ -1,8 +1,12 @@
 package safebuffer
 
 import (
+	"errors"
 	"io"
+	"sync/atomic"
+	"time"
 
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
 	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer/safereader"
 )
 
@@ -11,36 +15,128 @@ import (
 type Buffer struct {
 	Readers
 	ByteBuffer
-	done <-chan struct{}
+	done       <-chan struct{}
+	slowReader SlowReaderPolicy
+	closed     atomic.Bool
+	clock      clock.Clock
+	evicted    atomic.Int64 // the number of readers evicted by slowReader
+	wal        io.Writer
+	walErr     atomic.Pointer[error] // the first error returned by wal
 }
 
-// ensure Buffer implements the io.Writer interface
-var _ io.Writer = (*Buffer)(nil)
+// ErrBufferClosed is returned by Write after the Buffer has been closed
+var ErrBufferClosed = errors.New("buffer is closed")
+
+// SlowReaderAction is the action taken against a reader that is detected as
+// stalled by a SlowReaderPolicy
+type SlowReaderAction int
+
+const (
+	SlowReaderIgnore     SlowReaderAction = iota // stalled readers are left alone
+	SlowReaderDisconnect                         // stalled readers are evicted and their Reads return safereader.ErrReaderEvicted
+	SlowReaderSkipAhead                          // stalled readers skip any unread output and continue with the next write
+)
+
+// SlowReaderPolicy determines how readers that have unread output, but have not
+// read for longer than Threshold, are handled. Stalled readers are detected
+// when the buffer is written to.
+type SlowReaderPolicy struct {
+	Action    SlowReaderAction
+	Threshold time.Duration
+}
+
+// ensure Buffer implements the io.WriteCloser interface
+var _ io.WriteCloser = (*Buffer)(nil)
 
 // New creates a new Buffer
 func New(done <-chan struct{}) *Buffer {
-	return &Buffer{done: done}
+	return &Buffer{done: done, clock: clock.Real}
+}
+
+// SetClock sets the clock that measures how long readers have stalled for the
+// SlowReaderPolicy, by default it is clock.Real. It must be called before the
+// buffer is used.
+func (b *Buffer) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
+// SetSlowReaderPolicy sets the policy for handling stalled readers. It must be
+// called before the buffer is used.
+func (b *Buffer) SetSlowReaderPolicy(policy SlowReaderPolicy) {
+	b.slowReader = policy
+}
+
+// SetWAL sets a write-ahead log that all writes are written to before they
+// are added to the buffer and made available to readers. It must be called
+// before the buffer is used.
+func (b *Buffer) SetWAL(wal io.Writer) {
+	b.wal = wal
 }
 
 // Write is the io.Writer interface that writes to the buffer and notifies
 // readers that more data is available
 func (b *Buffer) Write(p []byte) (int, error) {
+	if b.closed.Load() {
+		return 0, ErrBufferClosed
+	}
+
+	// output is only made available to readers once it is durable
+	if b.wal != nil {
+		if _, err := b.wal.Write(p); err != nil {
+			b.walErr.CompareAndSwap(nil, &err)
+			return 0, err
+		}
+	}
+
+	size := b.ByteBuffer.Len()
 	n, werr := b.ByteBuffer.Write(p)
 
-	for it := b.Iterator(); it.Next(); {
-		reader := it.Reader()
+	var evicted []*safereader.Reader
 
-		if reader.IsClosed() {
-			it.Delete()
-			continue
+	b.Readers.mu.RLock()
+	for _, reader := range b.list {
+		// only output that was available before this write counts towards
+		// determining whether the reader is stalled
+		if b.slowReader.Action != SlowReaderIgnore && reader.Stalled(size, b.slowReader.Threshold) {
+			switch b.slowReader.Action {
+			case SlowReaderDisconnect:
+				reader.Evict()
+				evicted = append(evicted, reader)
+				continue
+			case SlowReaderSkipAhead:
+				reader.SkipTo(size)
+			}
 		}
 
 		reader.Wake()
 	}
+	b.Readers.mu.RUnlock()
+
+	for _, reader := range evicted {
+		b.Remove(reader)
+	}
+	b.evicted.Add(int64(len(evicted)))
 
 	return n, werr
 }
 
+// WALError returns the first error returned by the write-ahead log, if any
+func (b *Buffer) WALError() error {
+	if err := b.walErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Close closes all open readers, and any created afterwards, and causes
+// subsequent writes to fail with ErrBufferClosed. The buffered data is
+// retained until the Buffer is garbage collected.
+func (b *Buffer) Close() error {
+	b.closed.Store(true)
+	b.Readers.Close()
+	return nil
+}
+
 // Done returns a channel that's closed when the done channel passed into New()
 // closes
 func (b *Buffer) Done() <-chan struct{} {
@@ -50,7 +146,31 @@ func (b *Buffer) Done() <-chan struct{} {
 // NewReader creates a new io.ReadCloser that can be used to stream the output
 // of the job. It is the caller's responsibility to close the reader when done.
 func (b *Buffer) NewReader() io.ReadCloser {
-	r := safereader.New(b)
+	return b.NewReaderAt(0)
+}
+
+// NewReaderAt is like NewReader, but the reader begins at offset instead of at
+// the beginning of the output. It is used to resume an interrupted stream.
+func (b *Buffer) NewReaderAt(offset int) io.ReadCloser {
+	r := safereader.New(b, b.clock)
+	r.SkipTo(offset)
+	r.OnClose(func() { b.Remove(r) })
 	b.Add(r)
 	return r
 }
+
+// Stats contains point in time statistics about a Buffer
+type Stats struct {
+	Size    int // the number of bytes held in the buffer
+	Readers int // the number of open readers
+	Evicted int // the number of readers that were evicted by the SlowReaderPolicy
+}
+
+// Stats returns the current statistics of the buffer
+func (b *Buffer) Stats() Stats {
+	return Stats{
+		Size:    b.ByteBuffer.Len(),
+		Readers: b.Readers.Len(),
+		Evicted: int(b.evicted.Load()),
+	}
+}
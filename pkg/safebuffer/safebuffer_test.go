@@ -1,14 +1,36 @@
 package safebuffer
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	jobworkerv1 "github.com/joshuarubin/teleport-job-worker/pkg/proto/jobworker/v1"
+	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer/safereader"
+	"github.com/joshuarubin/teleport-job-worker/pkg/worker/workertest"
 )
 
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
 func bufWrite(buf *Buffer, v string) <-chan error {
 	ch := make(chan error)
 	go func() {
@@ -201,4 +223,812 @@ func TestSafeBuffer(t *testing.T) {
 		assert.False(ok)
 		assert.Equal(times*len(msg), read)
 	})
+	t.Run("stats", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		buf := New(jobDone)
+		assert.Equal(Stats{}, buf.Stats())
+
+		require.NoError(<-bufWrite(buf, "foo"))
+
+		r0 := buf.NewReader()
+		r1 := buf.NewReader()
+		assert.Equal(Stats{Size: 3, Readers: 2}, buf.Stats())
+
+		require.NoError(r0.Close())
+		assert.Equal(Stats{Size: 3, Readers: 1}, buf.Stats())
+
+		require.NoError(r1.Close())
+		close(jobDone)
+		assert.Equal(Stats{Size: 3, Readers: 0}, buf.Stats())
+	})
+
+	t.Run("closed-readers-removed", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		defer close(jobDone)
+
+		// readers of a buffer that is never written to are removed as soon
+		// as they are closed
+		buf := New(jobDone)
+		for range 100 {
+			r := buf.NewReader()
+			require.NoError(r.Close())
+			require.NoError(r.Close())
+		}
+
+		buf.Readers.mu.RLock()
+		assert.Empty(buf.list)
+		buf.Readers.mu.RUnlock()
+
+		// as are readers closed while they are blocked reading
+		r := buf.NewReader()
+		errCh := make(chan error)
+		go func() {
+			_, err := io.ReadAll(r)
+			errCh <- err
+		}()
+		require.NoError(r.Close())
+		require.ErrorIs(<-errCh, safereader.ErrReaderClosed)
+		assert.Equal(Stats{}, buf.Stats())
+	})
+
+	t.Run("slow-reader-disconnect", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		defer close(jobDone)
+
+		c := workertest.NewClock(time.Time{})
+
+		buf := New(jobDone)
+		buf.SetClock(c)
+		buf.SetSlowReaderPolicy(SlowReaderPolicy{
+			Action:    SlowReaderDisconnect,
+			Threshold: 10 * time.Millisecond,
+		})
+
+		r := buf.NewReader()
+		require.NoError(<-bufWrite(buf, "foo"))
+
+		c.Advance(20 * time.Millisecond)
+
+		// the reader hasn't read "foo" within the threshold
+		require.NoError(<-bufWrite(buf, "bar"))
+
+		n, err := r.Read(make([]byte, 3))
+		require.ErrorIs(err, safereader.ErrReaderEvicted)
+		assert.Equal(0, n)
+		assert.Equal(Stats{Size: 6, Evicted: 1}, buf.Stats())
+	})
+
+	t.Run("slow-reader-skip-ahead", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		c := workertest.NewClock(time.Time{})
+
+		buf := New(jobDone)
+		buf.SetClock(c)
+		buf.SetSlowReaderPolicy(SlowReaderPolicy{
+			Action:    SlowReaderSkipAhead,
+			Threshold: 10 * time.Millisecond,
+		})
+
+		r := buf.NewReader()
+		require.NoError(<-bufWrite(buf, "foo"))
+
+		c.Advance(20 * time.Millisecond)
+
+		// the reader hasn't read "foo" within the threshold so it will skip
+		// to "bar"
+		require.NoError(<-bufWrite(buf, "bar"))
+		close(jobDone)
+
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		assert.Equal("bar", string(data))
+	})
+
+	t.Run("close", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		defer close(jobDone)
+
+		buf := New(jobDone)
+		require.NoError(<-bufWrite(buf, "foo"))
+
+		r0 := buf.NewReader()
+
+		// a blocked reader is released by Close
+		errCh := make(chan error)
+		go func() {
+			_, err := io.ReadAll(r0)
+			errCh <- err
+		}()
+
+		require.NoError(buf.Close())
+		require.ErrorIs(<-errCh, safereader.ErrReaderClosed)
+
+		// readers created after Close are already closed
+		r1 := buf.NewReader()
+		_, err := r1.Read(make([]byte, 3))
+		require.ErrorIs(err, safereader.ErrReaderClosed)
+
+		require.ErrorIs(<-bufWrite(buf, "bar"), ErrBufferClosed)
+		assert.Equal(Stats{Size: 3}, buf.Stats())
+	})
+}
+
+// streamChunks writes count small messages to a new Buffer, spaced out by
+// delay, while concurrently streaming it with next. it returns all of the data
+// read and the number of chunks it was read in.
+func streamChunks(t testing.TB, count int, delay time.Duration, next func(r io.Reader) func() ([]byte, error)) (string, int) {
+	t.Helper()
+
+	jobDone := make(chan struct{})
+	buf := New(jobDone)
+	read := next(buf.NewReader())
+
+	go func() {
+		defer close(jobDone)
+		for range count {
+			_, _ = buf.Write([]byte("y\n"))
+			time.Sleep(delay)
+		}
+	}()
+
+	var (
+		data   []byte
+		chunks int
+	)
+
+	for {
+		chunk, err := read()
+		if len(chunk) > 0 {
+			chunks++
+			data = append(data, chunk...)
+		}
+		if err == io.EOF {
+			return string(data), chunks
+		}
+		require.NoError(t, err)
+	}
+}
+
+// readChunk returns a function that reads at most DefaultMaxChunkSize bytes at
+// a time from r, without coalescing
+func readChunk(r io.Reader) func() ([]byte, error) {
+	p := make([]byte, DefaultMaxChunkSize)
+	return func() ([]byte, error) {
+		n, err := r.Read(p)
+		return p[:n], err
+	}
+}
+
+// chunkerChunk returns a function that reads chunks from a Chunker using the
+// default configuration
+func chunkerChunk(r io.Reader) func() ([]byte, error) {
+	c := NewChunker(r, ChunkerConfig{FlushInterval: -1})
+	return c.Next
+}
+
+func TestWriteTo(t *testing.T) {
+	t.Parallel()
+
+	t.Run("next", func(t *testing.T) {
+		t.Parallel()
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		buf := New(jobDone)
+		for _, v := range []string{"foo", "bar", "baz"} {
+			_, err := buf.Write([]byte(v))
+			require.NoError(err)
+		}
+
+		r, ok := buf.NewReader().(*safereader.Reader)
+		require.True(ok)
+		defer r.Close()
+
+		slices, err := r.Next(5)
+		require.NoError(err)
+		assert.Equal(net.Buffers{[]byte("foo"), []byte("ba")}, slices)
+
+		slices, err = r.Next(0)
+		require.NoError(err)
+		assert.Equal(net.Buffers{[]byte("r"), []byte("baz")}, slices)
+
+		close(jobDone)
+
+		_, err = r.Next(0)
+		assert.ErrorIs(err, io.EOF)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		t.Parallel()
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		buf := New(jobDone)
+		r := buf.NewReader()
+		defer r.Close()
+
+		_, ok := r.(io.WriterTo)
+		require.True(ok)
+
+		go func() {
+			for _, v := range []string{"foo", "bar", "baz"} {
+				<-bufWrite(buf, v)
+			}
+			close(jobDone)
+		}()
+
+		var out strings.Builder
+		n, err := io.Copy(&out, r)
+		require.NoError(err)
+		assert.Equal(int64(9), n)
+		assert.Equal("foobarbaz", out.String())
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		t.Parallel()
+
+		buf := New(make(chan struct{}))
+		r := buf.NewReader()
+		require.NoError(t, r.Close())
+
+		_, err := io.Copy(io.Discard, r)
+		assert.ErrorIs(t, err, safereader.ErrReaderClosed)
+	})
+}
+
+func TestLineReader(t *testing.T) {
+	t.Parallel()
+
+	type line struct {
+		data      string
+		truncated bool
+	}
+
+	for _, tc := range []struct {
+		name  string
+		input string
+		max   int
+		lines []line
+	}{
+		{
+			name:  "lines",
+			input: "foo\n\nbar\nbaz",
+			max:   3,
+			lines: []line{{data: "foo"}, {data: ""}, {data: "bar"}, {data: "baz"}},
+		},
+		{
+			name:  "truncated",
+			input: "foobarbaz\nqux\n" + strings.Repeat("x", 100),
+			max:   4,
+			lines: []line{{data: "foob", truncated: true}, {data: "qux"}, {data: "xxxx", truncated: true}},
+		},
+		{
+			name:  "long",
+			input: strings.Repeat("x", 100) + "\nfoo\n",
+			max:   20,
+			lines: []line{{data: strings.Repeat("x", 20), truncated: true}, {data: "foo"}},
+		},
+		{
+			name:  "default",
+			input: strings.Repeat("x", DefaultMaxLineLength) + "\n",
+			lines: []line{{data: strings.Repeat("x", DefaultMaxLineLength)}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			lr := NewLineReader(strings.NewReader(tc.input), tc.max)
+
+			var lines []line
+			for {
+				data, truncated, err := lr.Line()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(err)
+				lines = append(lines, line{data: string(data), truncated: truncated})
+			}
+
+			assert.Equal(t, tc.lines, lines)
+		})
+	}
+}
+
+func TestFrame(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		encoding FrameEncoding
+	}{
+		{name: "text", data: []byte("foo ☃\n"), encoding: FrameEncodingUTF8},
+		{name: "binary", data: []byte{0xff, 0x00, 0xfe}, encoding: FrameEncodingBase64},
+		{name: "split rune", data: []byte("☃")[:2], encoding: FrameEncodingBase64},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			f := NewFrame(tc.data)
+			require.Equal(tc.encoding, f.Encoding)
+
+			data, err := f.Bytes()
+			require.NoError(err)
+			require.Equal(tc.data, data)
+		})
+	}
+
+	_, err := Frame{Encoding: "hex"}.Bytes()
+	assert.ErrorIs(t, err, ErrUnknownFrameEncoding)
+}
+
+func TestEscapeWriter(t *testing.T) {
+	t.Parallel()
+
+	snowman := []byte("☃")
+
+	for _, tc := range []struct {
+		name   string
+		writes [][]byte
+		want   string
+	}{
+		{name: "text", writes: [][]byte{[]byte("foo ☃\tbar\n")}, want: "foo ☃\tbar\n"},
+		{name: "escape sequence", writes: [][]byte{[]byte("\x1b[2Jfoo\r\x00\x7f")}, want: `\x1b[2Jfoo\x0d\x00\x7f`},
+		{name: "c1 control", writes: [][]byte{[]byte("\u009b2J")}, want: `\u009b2J`},
+		{name: "invalid utf-8", writes: [][]byte{{'a', 0xff, 'b'}}, want: `a\xffb`},
+		{name: "split rune", writes: [][]byte{snowman[:1], snowman[1:2], snowman[2:]}, want: "☃"},
+		{name: "truncated rune", writes: [][]byte{[]byte("a"), snowman[:2]}, want: `a\xe2\x98`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			var out strings.Builder
+			w := NewEscapeWriter(&out)
+			for _, p := range tc.writes {
+				n, err := w.Write(p)
+				require.NoError(err)
+				require.Equal(len(p), n)
+			}
+			require.NoError(w.Flush())
+			require.Equal(tc.want, out.String())
+		})
+	}
+}
+
+func TestChunker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("coalesce", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		const count = 100
+
+		raw, rawChunks := streamChunks(t, count, time.Millisecond, readChunk)
+		coalesced, chunks := streamChunks(t, count, time.Millisecond, chunkerChunk)
+
+		assert.Equal(strings.Repeat("y\n", count), raw)
+		assert.Equal(raw, coalesced)
+
+		// each write is read on its own without coalescing, while the chunker
+		// groups about 10ms worth of writes together
+		assert.Less(chunks, rawChunks/2)
+	})
+
+	t.Run("max-size", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		buf := New(jobDone)
+		require.NoError(<-bufWrite(buf, "foobarbaz"))
+		close(jobDone)
+
+		c := NewChunker(buf.NewReader(), ChunkerConfig{MaxSize: 4, FlushInterval: time.Second})
+		defer c.Close()
+
+		var chunks []string
+		for {
+			chunk, err := c.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(err)
+			chunks = append(chunks, string(chunk))
+		}
+
+		assert.Equal([]string{"foob", "arba", "z"}, chunks)
+	})
+
+	t.Run("checksum", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		jobDone := make(chan struct{})
+		buf := New(jobDone)
+		require.NoError(<-bufWrite(buf, "foobarbaz"))
+		close(jobDone)
+
+		c := NewChunker(buf.NewReader(), ChunkerConfig{MaxSize: 4, FlushInterval: time.Second})
+		defer c.Close()
+
+		// the client computes the same rolling checksum over what it receives
+		var crc uint32
+		for {
+			chunk, err := c.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(err)
+			crc = UpdateChecksum(crc, chunk)
+			assert.Equal(crc, c.Checksum())
+		}
+
+		assert.Equal(crc32.Checksum([]byte("foobarbaz"), crc32.MakeTable(crc32.Castagnoli)), crc)
+
+		digest := sha256.Sum256([]byte("foobarbaz"))
+		assert.Equal(digest[:], buf.Digest())
+	})
+}
+
+func BenchmarkStreamChunks(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		next func(r io.Reader) func() ([]byte, error)
+	}{
+		{name: "read", next: readChunk},
+		{name: "chunker", next: chunkerChunk},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var chunks int
+			for range b.N {
+				_, n := streamChunks(b, 1000, 10*time.Microsecond, bc.next)
+				chunks += n
+			}
+			b.ReportMetric(float64(chunks)/float64(b.N), "chunks/op")
+		})
+	}
+}
+
+// onlyWriter hides any io.ReaderFrom implementation of the wrapped writer so
+// that io.CopyBuffer copies through its buffer
+type onlyWriter struct{ io.Writer }
+
+func BenchmarkWriteTo(b *testing.B) {
+	jobDone := make(chan struct{})
+	buf := New(jobDone)
+	p := make([]byte, 32<<10)
+	for range 64 { // 2MiB
+		_, _ = buf.Write(p)
+	}
+	close(jobDone)
+
+	for _, bc := range []struct {
+		name string
+		copy func(w io.Writer, r io.Reader) (int64, error)
+	}{
+		{name: "read", copy: func(w io.Writer, r io.Reader) (int64, error) {
+			// io.Reader only, as StreamJobOutput would without WriteTo
+			return io.CopyBuffer(w, struct{ io.Reader }{r}, make([]byte, DefaultMaxChunkSize))
+		}},
+		{name: "writeto", copy: io.Copy},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(buf.ByteBuffer.Len()))
+			for range b.N {
+				r := buf.NewReader()
+				if _, err := bc.copy(onlyWriter{io.Discard}, r); err != nil {
+					b.Fatal(err)
+				}
+				_ = r.Close()
+			}
+		})
+	}
+}
+
+// outputServer streams the output of buf, as StreamJobOutput does for a job
+type outputServer struct {
+	jobworkerv1.UnimplementedJobWorkerServiceServer
+	buf atomic.Pointer[Buffer]
+}
+
+func (s *outputServer) StreamJobOutput(_ *jobworkerv1.StreamJobOutputRequest, stream grpc.ServerStreamingServer[jobworkerv1.StreamJobOutputResponse]) error {
+	r := s.buf.Load().NewReader()
+	defer r.Close()
+
+	c := NewChunker(r, ChunkerConfig{})
+	defer c.Close()
+
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(&jobworkerv1.StreamJobOutputResponse{
+			Data:   chunk,
+			Crc32C: c.Checksum(),
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// outputClient returns a client of s, served over an in memory connection
+func outputClient(b *testing.B, s *outputServer) jobworkerv1.JobWorkerServiceClient {
+	b.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	jobworkerv1.RegisterJobWorkerServiceServer(srv, s)
+	go func() { _ = srv.Serve(lis) }()
+	b.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = conn.Close() })
+
+	return jobworkerv1.NewJobWorkerServiceClient(conn)
+}
+
+// BenchmarkOutputThroughput measures the rate at which output is delivered to
+// readers while a job writes it, to guard the design of the Buffer against
+// regressions. MB/s is the total delivered to all readers.
+func BenchmarkOutputThroughput(b *testing.B) {
+	const (
+		writes = 128
+		size   = 32 << 10 // 4MiB per op
+	)
+
+	p := make([]byte, size)
+
+	// write writes the output of a job to buf while it is being read
+	write := func(buf *Buffer, jobDone chan struct{}) {
+		defer close(jobDone)
+		for range writes {
+			_, _ = buf.Write(p)
+		}
+	}
+
+	for _, readers := range []int{1, 100} {
+		b.Run(fmt.Sprintf("readers=%d", readers), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(writes * size * readers))
+
+			for range b.N {
+				jobDone := make(chan struct{})
+				buf := New(jobDone)
+
+				var wg sync.WaitGroup
+				for range readers {
+					r := buf.NewReader()
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						defer r.Close()
+						if _, err := io.Copy(io.Discard, r); err != nil {
+							b.Error(err)
+						}
+					}()
+				}
+
+				write(buf, jobDone)
+				wg.Wait()
+			}
+		})
+	}
+
+	b.Run("grpc", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(writes * size)
+
+		ctx := context.Background()
+
+		var srv outputServer
+		client := outputClient(b, &srv)
+		b.ResetTimer()
+
+		for range b.N {
+			jobDone := make(chan struct{})
+			buf := New(jobDone)
+			srv.buf.Store(buf)
+
+			stream, err := client.StreamJobOutput(ctx, &jobworkerv1.StreamJobOutputRequest{})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			go write(buf, jobDone)
+
+			var n int
+			for {
+				resp, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+				n += len(resp.GetData())
+			}
+
+			if n != writes*size {
+				b.Fatalf("received %d bytes, expected %d", n, writes*size)
+			}
+		}
+	})
+}
+
+// FuzzReadOffset checks that reads from any offset, into any size buffer,
+// return the same data as the output written, however it was split into
+// writes
+func FuzzReadOffset(f *testing.F) {
+	f.Add([]byte("foobarbaz"), uint8(3), 0, 4, 0)
+	f.Add([]byte("foobarbaz"), uint8(1), 5, 100, 2)
+	f.Add([]byte("foo"), uint8(0), 3, 1, 1)
+	f.Add([]byte{}, uint8(2), -1, 1, 0)
+
+	f.Fuzz(func(t *testing.T, data []byte, split uint8, offset, size, max int) {
+		if size < 0 || size > 1<<16 {
+			t.Skip()
+		}
+
+		var buf ByteBuffer
+		for p := data; len(p) > 0; {
+			n := min(len(p), int(split)+1)
+			_, err := buf.Write(p[:n])
+			require.NoError(t, err)
+			p = p[n:]
+		}
+		require.Equal(t, len(data), buf.Len())
+
+		var want []byte
+		if offset >= 0 && offset < len(data) {
+			want = data[offset:]
+		}
+
+		p := make([]byte, size)
+		n, err := buf.ReadOffset(offset, p)
+		switch {
+		case offset < 0:
+			require.ErrorIs(t, err, ErrNegativeOffset)
+		case size == 0:
+			require.NoError(t, err)
+			require.Zero(t, n)
+		case len(want) == 0:
+			require.ErrorIs(t, err, io.EOF)
+			require.Zero(t, n)
+		default:
+			require.NoError(t, err)
+			require.Equal(t, want[:min(len(want), size)], p[:n])
+		}
+
+		slices, err := buf.SlicesOffset(offset, max)
+		switch {
+		case offset < 0:
+			require.ErrorIs(t, err, ErrNegativeOffset)
+		case len(want) == 0:
+			require.ErrorIs(t, err, io.EOF)
+		default:
+			require.NoError(t, err)
+			if max > 0 {
+				want = want[:min(len(want), max)]
+			}
+			require.Equal(t, want, bytes.Join(slices, nil))
+		}
+	})
+}
+
+// FuzzLineReader checks that lines are never longer than the maximum and that,
+// unless they were truncated, they are the lines of the output
+func FuzzLineReader(f *testing.F) {
+	f.Add("foo\n\nbar\nbaz", 3)
+	f.Add("foobarbaz\nqux\n", 4)
+	f.Add("\n\n\n", 1)
+	f.Add("", 0)
+
+	f.Fuzz(func(t *testing.T, input string, max int) {
+		if max > 1<<16 {
+			t.Skip()
+		}
+
+		lr := NewLineReader(strings.NewReader(input), max)
+		if max <= 0 {
+			max = DefaultMaxLineLength
+		}
+
+		want := strings.Split(strings.TrimSuffix(input, "\n"), "\n")
+		if input == "" {
+			want = nil
+		}
+
+		var n int
+		for {
+			line, truncated, err := lr.Line()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.Less(t, n, len(want))
+			require.LessOrEqual(t, len(line), max)
+
+			if truncated {
+				require.Greater(t, len(want[n]), max)
+				require.Equal(t, want[n][:max], string(line))
+			} else {
+				require.Equal(t, want[n], string(line))
+			}
+			n++
+		}
+
+		require.Equal(t, len(want), n)
+	})
+}
+
+// FuzzFrame checks that frames round trip through json and that decoding
+// frames from clients never panics
+func FuzzFrame(f *testing.F) {
+	f.Add([]byte("foo ☃\n"), []byte(`{"encoding":"utf8","data":"foo"}`))
+	f.Add([]byte{0xff, 0x00, 0xfe}, []byte(`{"encoding":"base64","data":"/wD+"}`))
+	f.Add([]byte("☃")[:2], []byte(`{"encoding":"base64","data":"!"}`))
+
+	f.Fuzz(func(t *testing.T, data, msg []byte) {
+		b, err := json.Marshal(NewFrame(data))
+		require.NoError(t, err)
+
+		var frame Frame
+		require.NoError(t, json.Unmarshal(b, &frame))
+
+		got, err := frame.Bytes()
+		require.NoError(t, err)
+		require.Equal(t, data, append([]byte{}, got...))
+
+		if err := json.Unmarshal(msg, &frame); err == nil {
+			_, _ = frame.Bytes()
+		}
+	})
 }
@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net"
 	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
 )
 
 // Reader is a goroutine safe io.ReadCloser that streams Buffer data from the
@@ -12,12 +16,18 @@ import (
 type Reader struct {
 	Buffer
 
-	cancel func()
+	cancel func(error)
+	cause  func() error // ErrReaderClosed or ErrReaderEvicted once closed
 	closed func() <-chan struct{}
 
-	mu     sync.RWMutex
-	offset int
-	wake   chan struct{}
+	onClose   func()
+	closeOnce sync.Once
+
+	clock    clock.Clock // measures how long the reader has stalled
+	mu       sync.RWMutex
+	offset   int
+	lastRead time.Time
+	wake     chan struct{}
 }
 
 // jobIsDone returns true if the job has completed
@@ -61,22 +71,34 @@ func (r *Reader) Wake() {
 // Buffer is used to prevent an import cycle
 type Buffer interface {
 	ReadOffset(offset int, p []byte) (int, error)
+	SlicesOffset(offset, max int) ([][]byte, error)
 	Done() <-chan struct{}
 }
 
 // New returns a new Reader that will read from the beginning of Buffer until
-// io.EOF is returned after Done() closes.
-func New(b Buffer) *Reader {
-	ctx, cancel := context.WithCancel(context.Background())
+// io.EOF is returned after Done() closes. Whether it has stalled is measured
+// with c.
+func New(b Buffer, c clock.Clock) *Reader {
+	ctx, cancel := context.WithCancelCause(context.Background())
 
 	return &Reader{
-		cancel: cancel,
-		closed: ctx.Done,
-		wake:   make(chan struct{}),
-		Buffer: b,
+		cancel:   cancel,
+		cause:    func() error { return context.Cause(ctx) },
+		closed:   ctx.Done,
+		clock:    c,
+		lastRead: c.Now(),
+		wake:     make(chan struct{}),
+		Buffer:   b,
 	}
 }
 
+// OnClose sets a function that is called the first time the reader is closed,
+// e.g. to remove it from the Buffer's readers. It is not called when the
+// reader is evicted. It must be called before the reader is used.
+func (r *Reader) OnClose(f func()) {
+	r.onClose = f
+}
+
 // readOffset safely reads from the buffer into p and updates the offset by the
 // number of bytes read
 func (r *Reader) readOffset(p []byte) (int, error) {
@@ -87,18 +109,59 @@ func (r *Reader) readOffset(p []byte) (int, error) {
 	if err == nil {
 		r.offset += n
 	}
+	r.lastRead = r.clock.Now()
 
 	return n, err
 }
 
-// ErrReaderClosed is returned by Read() after the Reader.Close() is called
-var ErrReaderClosed = errors.New("reader is closed")
+// slicesOffset safely returns up to max bytes of the buffer, without copying,
+// and updates the offset by the number of bytes returned
+func (r *Reader) slicesOffset(max int) (net.Buffers, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	slices, err := r.SlicesOffset(r.offset, max)
+	for _, s := range slices {
+		r.offset += len(s)
+	}
+	r.lastRead = r.clock.Now()
+
+	return slices, err
+}
+
+// Stalled returns true if the reader has not read any of the size bytes
+// available in the buffer for longer than threshold
+func (r *Reader) Stalled(size int, threshold time.Duration) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.offset < size && clock.Since(r.clock, r.lastRead) > threshold
+}
+
+// SkipTo moves the reader forward to offset, discarding any unread data before
+// it. It does nothing if the reader has already read past offset.
+func (r *Reader) SkipTo(offset int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if offset > r.offset {
+		r.offset = offset
+		r.lastRead = r.clock.Now()
+	}
+}
+
+var (
+	// ErrReaderClosed is returned by Read() after the Reader.Close() is called
+	ErrReaderClosed = errors.New("reader is closed")
+
+	// ErrReaderEvicted is returned by Read() after the Reader was evicted for
+	// not keeping up with the output
+	ErrReaderEvicted = errors.New("reader was evicted for reading too slowly")
+)
 
 // Read is the io.Reader interface and returns up to len(p) data in p. The
 // number of bytes written is returned.
 func (r *Reader) Read(p []byte) (int, error) {
 	if r.IsClosed() {
-		return 0, ErrReaderClosed
+		return 0, r.cause()
 	}
 
 	for {
@@ -107,13 +170,68 @@ func (r *Reader) Read(p []byte) (int, error) {
 			return n, err
 		}
 
-		// got io.EOF and job isn't done yet
-		select {
-		case <-r.Await():
-		case <-r.closed():
-			return n, ErrReaderClosed
-		case <-r.Done():
-			return n, io.EOF
+		if err := r.wait(); err != nil {
+			return n, err
+		}
+	}
+}
+
+// wait blocks, after the reader got io.EOF before the job is done, until more
+// data may be available. It returns an error if the reader is closed or io.EOF
+// if the job finishes.
+func (r *Reader) wait() error {
+	select {
+	case <-r.Await():
+		return nil
+	case <-r.closed():
+		return r.cause()
+	case <-r.Done():
+		return io.EOF
+	}
+}
+
+// Next returns the next up to max bytes of data, blocking like Read until some
+// is available. If max <= 0 all available data is returned. Unlike Read the
+// data is not copied, the returned slices reference the buffer itself and must
+// not be modified. The result can be written with a single writev(2) by
+// net.Buffers.WriteTo when the destination is a net.Conn.
+func (r *Reader) Next(max int) (net.Buffers, error) {
+	if r.IsClosed() {
+		return nil, r.cause()
+	}
+
+	for {
+		slices, err := r.slicesOffset(max)
+		if !errors.Is(err, io.EOF) || r.jobIsDone() {
+			return slices, err
+		}
+
+		if err := r.wait(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// WriteTo is the io.WriterTo interface. It writes all data to w until the job
+// is done, without copying it into an intermediate buffer, and returns the
+// number of bytes written. It is used automatically by io.Copy.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		slices, err := r.Next(0)
+		if len(slices) > 0 {
+			n, werr := slices.WriteTo(w)
+			total += n
+			if werr != nil {
+				return total, werr
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
 		}
 	}
 }
@@ -122,6 +240,16 @@ func (r *Reader) Read(p []byte) (int, error) {
 // from its resources. Any Reads after being closed will return
 // ErrReaderClosed.
 func (r *Reader) Close() error {
-	r.cancel()
+	r.cancel(ErrReaderClosed)
+	r.closeOnce.Do(func() {
+		if r.onClose != nil {
+			r.onClose()
+		}
+	})
 	return nil
 }
+
+// Evict closes the reader such that any Reads will return ErrReaderEvicted
+func (r *Reader) Evict() {
+	r.cancel(ErrReaderEvicted)
+}
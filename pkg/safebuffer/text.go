@@ -0,0 +1,202 @@
+package safebuffer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// DefaultMaxLineLength is the default maximum length of a line returned by
+// LineReader.Line
+const DefaultMaxLineLength = 16 << 10 // 16KiB
+
+// LineReader reads output a line at a time for line-oriented consumers, like
+// tailing a job's output. Jobs can write arbitrarily long lines, or binary
+// data with no newlines at all, so lines longer than the maximum are truncated
+// rather than buffered in full.
+type LineReader struct {
+	r   *bufio.Reader
+	max int
+}
+
+// NewLineReader returns a LineReader that reads lines of at most maxLen bytes
+// from r. If maxLen <= 0, DefaultMaxLineLength is used.
+func NewLineReader(r io.Reader, maxLen int) *LineReader {
+	if maxLen <= 0 {
+		maxLen = DefaultMaxLineLength
+	}
+
+	return &LineReader{
+		// leave room for the newline of a line of exactly maxLen
+		r:   bufio.NewReaderSize(r, maxLen+1),
+		max: maxLen,
+	}
+}
+
+// Line returns the next line, without the trailing newline. If the line was
+// longer than the maximum length, only its beginning is returned, truncated is
+// true and the rest of the line is discarded. A final line without a trailing
+// newline is returned with a nil error, io.EOF is returned after it.
+func (l *LineReader) Line() (line []byte, truncated bool, err error) {
+	p, err := l.r.ReadSlice('\n')
+	full := errors.Is(err, bufio.ErrBufferFull)
+	if !full {
+		p = bytes.TrimSuffix(p, []byte{'\n'})
+	}
+
+	if len(p) > l.max {
+		p = p[:l.max]
+		truncated = true
+	}
+	line = append([]byte(nil), p...)
+
+	if full {
+		// the end of the line wasn't found before the buffer filled up
+		truncated = true
+		err = l.discardLine()
+	}
+
+	if errors.Is(err, io.EOF) && (len(line) > 0 || truncated) {
+		// the last line is returned now and io.EOF on the next call
+		err = nil
+	}
+
+	return line, truncated, err
+}
+
+// discardLine discards data up to, and including, the next newline
+func (l *LineReader) discardLine() error {
+	for {
+		_, err := l.r.ReadSlice('\n')
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return err
+		}
+	}
+}
+
+// FrameEncoding is how the data of a Frame is encoded
+type FrameEncoding string
+
+const (
+	FrameEncodingUTF8   FrameEncoding = "utf8"   // the data is valid UTF-8 text
+	FrameEncodingBase64 FrameEncoding = "base64" // the data is standard base64
+)
+
+// ErrUnknownFrameEncoding is returned by Frame.Bytes when the encoding is not
+// one of the FrameEncodings
+var ErrUnknownFrameEncoding = errors.New("unknown frame encoding")
+
+// Frame is a chunk of output prepared for transports that only carry text,
+// like JSON. Text is sent as is so that it stays readable, any other data is
+// base64 encoded so that it isn't mangled.
+type Frame struct {
+	Encoding FrameEncoding `json:"encoding"`
+	Data     string        `json:"data"`
+}
+
+// NewFrame returns a Frame of p. Chunk boundaries may split a multi-byte
+// character, in which case the chunk isn't valid UTF-8 and is base64 encoded
+// even though the output is text.
+func NewFrame(p []byte) Frame {
+	if utf8.Valid(p) {
+		return Frame{Encoding: FrameEncodingUTF8, Data: string(p)}
+	}
+
+	return Frame{
+		Encoding: FrameEncodingBase64,
+		Data:     base64.StdEncoding.EncodeToString(p),
+	}
+}
+
+// Bytes returns the decoded data of the frame
+func (f Frame) Bytes() ([]byte, error) {
+	switch f.Encoding {
+	case FrameEncodingUTF8:
+		return []byte(f.Data), nil
+	case FrameEncodingBase64:
+		return base64.StdEncoding.DecodeString(f.Data)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFrameEncoding, f.Encoding)
+	}
+}
+
+// EscapeWriter escapes control characters and invalid UTF-8 in the output
+// written to it, for clients that write it straight to a terminal that would
+// otherwise interpret escape sequences from the job. Newlines and tabs are
+// kept as is. The escaping is for display, it isn't reversible since
+// backslashes aren't escaped.
+type EscapeWriter struct {
+	w       io.Writer
+	partial []byte // the beginning of a character split across writes
+	buf     []byte
+}
+
+// NewEscapeWriter returns an EscapeWriter that writes to w
+func NewEscapeWriter(w io.Writer) *EscapeWriter {
+	return &EscapeWriter{w: w}
+}
+
+// Write escapes p and writes it to the underlying writer. A multi-byte
+// character split across writes is held back until the rest of it is written.
+func (e *EscapeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(e.partial) > 0 {
+		p = append(e.partial, p...)
+		e.partial = nil
+	}
+
+	e.buf = e.buf[:0]
+	for len(p) > 0 {
+		if !utf8.FullRune(p) {
+			e.partial = append([]byte(nil), p...)
+			break
+		}
+
+		r, size := utf8.DecodeRune(p)
+		e.buf = appendEscaped(e.buf, r, p[:size])
+		p = p[size:]
+	}
+
+	if _, err := e.w.Write(e.buf); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Flush writes the held back beginning of a character, if any, escaped as
+// invalid UTF-8. It should be called once the output is complete.
+func (e *EscapeWriter) Flush() error {
+	if len(e.partial) == 0 {
+		return nil
+	}
+
+	e.buf = e.buf[:0]
+	for _, c := range e.partial {
+		e.buf = appendEscaped(e.buf, utf8.RuneError, []byte{c})
+	}
+	e.partial = nil
+
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// appendEscaped appends r, decoded from p, to buf escaping it if necessary
+func appendEscaped(buf []byte, r rune, p []byte) []byte {
+	switch {
+	case r == utf8.RuneError && len(p) == 1:
+		return fmt.Appendf(buf, `\x%02x`, p[0])
+	case r == '\n', r == '\t':
+		return append(buf, p...)
+	case r < ' ', r == 0x7f:
+		return fmt.Appendf(buf, `\x%02x`, r)
+	case r >= 0x80 && r <= 0x9f: // C1 controls, e.g. CSI
+		return fmt.Appendf(buf, `\u%04x`, r)
+	default:
+		return append(buf, p...)
+	}
+}
@@ -0,0 +1,147 @@
+// Package transport configures the gRPC transport of the job worker server:
+// message size limits and HTTP/2 flow control. The defaults suit typical
+// deployments; operators can raise them for very large output frames or for
+// thousands of concurrent watchers.
+package transport
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultMaxRecvMsgSize is the largest request the server accepts. Requests
+	// are small, the largest are StartJob requests with long argument lists.
+	DefaultMaxRecvMsgSize = 4 << 20 // 4MiB, the grpc default
+
+	// DefaultMaxSendMsgSize is the largest response the server sends. Output is
+	// streamed in chunks much smaller than this, so it only limits unary
+	// responses like ListJobs and QueryAuditLog.
+	DefaultMaxSendMsgSize = 16 << 20 // 16MiB
+
+	// DefaultMaxConcurrentStreams is the number of concurrent streams allowed
+	// on each client connection. Every StreamJobOutput and WatchJobs call holds
+	// a stream for as long as it runs.
+	DefaultMaxConcurrentStreams = 1000
+
+	// MinWindowSize is the smallest flow control window that can be set. grpc
+	// ignores smaller windows.
+	MinWindowSize = 64 << 10 // 64KiB
+)
+
+// ErrInvalidConfig is returned by Config.Validate when a setting is out of
+// range
+var ErrInvalidConfig = errors.New("invalid transport config")
+
+// Config is the gRPC transport configuration of the server
+type Config struct {
+	MaxRecvMsgSize int // the largest request, in bytes
+	MaxSendMsgSize int // the largest response, in bytes
+
+	// InitialWindowSize and InitialConnWindowSize are the HTTP/2 flow control
+	// windows for each stream and for each connection. If zero, grpc sizes
+	// them dynamically based on the estimated bandwidth-delay product, which
+	// is usually best. Setting either disables the dynamic sizing.
+	InitialWindowSize     int32
+	InitialConnWindowSize int32
+
+	MaxConcurrentStreams uint32 // per client connection
+}
+
+// DefaultConfig returns the default transport configuration
+func DefaultConfig() Config {
+	return Config{
+		MaxRecvMsgSize:       DefaultMaxRecvMsgSize,
+		MaxSendMsgSize:       DefaultMaxSendMsgSize,
+		MaxConcurrentStreams: DefaultMaxConcurrentStreams,
+	}
+}
+
+// RegisterFlags registers flags for each setting on fs. The current values of
+// c are used as the flag defaults, so c should usually be DefaultConfig.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.MaxRecvMsgSize, "grpc-max-recv-msg-size", c.MaxRecvMsgSize,
+		"largest request the server accepts, in bytes")
+	fs.IntVar(&c.MaxSendMsgSize, "grpc-max-send-msg-size", c.MaxSendMsgSize,
+		"largest response the server sends, in bytes")
+	fs.Var((*int32Value)(&c.InitialWindowSize), "grpc-initial-window-size",
+		"HTTP/2 flow control window for each stream, in bytes, 0 sizes it dynamically")
+	fs.Var((*int32Value)(&c.InitialConnWindowSize), "grpc-initial-conn-window-size",
+		"HTTP/2 flow control window for each connection, in bytes, 0 sizes it dynamically")
+	fs.Var((*uint32Value)(&c.MaxConcurrentStreams), "grpc-max-concurrent-streams",
+		"concurrent streams allowed on each client connection")
+}
+
+// Validate returns an error if any of the settings are out of range
+func (c *Config) Validate() error {
+	switch {
+	case c.MaxRecvMsgSize <= 0:
+		return fmt.Errorf("%w: max recv msg size must be positive", ErrInvalidConfig)
+	case c.MaxSendMsgSize <= 0:
+		return fmt.Errorf("%w: max send msg size must be positive", ErrInvalidConfig)
+	case c.InitialWindowSize != 0 && c.InitialWindowSize < MinWindowSize:
+		return fmt.Errorf("%w: initial window size must be at least %d", ErrInvalidConfig, MinWindowSize)
+	case c.InitialConnWindowSize != 0 && c.InitialConnWindowSize < MinWindowSize:
+		return fmt.Errorf("%w: initial conn window size must be at least %d", ErrInvalidConfig, MinWindowSize)
+	case c.MaxConcurrentStreams == 0:
+		return fmt.Errorf("%w: max concurrent streams must be positive", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// ServerOptions returns the options that apply the configuration to a
+// grpc.Server
+func (c *Config) ServerOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(c.MaxRecvMsgSize),
+		grpc.MaxSendMsgSize(c.MaxSendMsgSize),
+		grpc.MaxConcurrentStreams(c.MaxConcurrentStreams),
+	}
+
+	if c.InitialWindowSize != 0 {
+		opts = append(opts, grpc.InitialWindowSize(c.InitialWindowSize))
+	}
+
+	if c.InitialConnWindowSize != 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(c.InitialConnWindowSize))
+	}
+
+	return opts
+}
+
+// int32Value is a flag.Value for an int32
+type int32Value int32
+
+func (v *int32Value) String() string {
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+func (v *int32Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return err
+	}
+	*v = int32Value(n)
+	return nil
+}
+
+// uint32Value is a flag.Value for a uint32
+type uint32Value uint32
+
+func (v *uint32Value) String() string {
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+func (v *uint32Value) Set(s string) error {
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return err
+	}
+	*v = uint32Value(n)
+	return nil
+}
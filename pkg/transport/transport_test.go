@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"context"
+	"flag"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	cfg := DefaultConfig()
+	require.NoError(cfg.Validate())
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+
+	assert.Equal("1000", fs.Lookup("grpc-max-concurrent-streams").DefValue)
+
+	require.NoError(fs.Parse([]string{
+		"-grpc-max-recv-msg-size", "1024",
+		"-grpc-initial-window-size", "1048576",
+		"-grpc-max-concurrent-streams", "5000",
+	}))
+
+	assert.Equal(Config{
+		MaxRecvMsgSize:       1024,
+		MaxSendMsgSize:       DefaultMaxSendMsgSize,
+		InitialWindowSize:    1 << 20,
+		MaxConcurrentStreams: 5000,
+	}, cfg)
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&strings.Builder{})
+	cfg.RegisterFlags(fs)
+	require.Error(fs.Parse([]string{"-grpc-initial-window-size", "4294967296"}))
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, fn := range []func(*Config){
+		func(c *Config) { c.MaxRecvMsgSize = 0 },
+		func(c *Config) { c.MaxSendMsgSize = -1 },
+		func(c *Config) { c.InitialWindowSize = MinWindowSize - 1 },
+		func(c *Config) { c.InitialConnWindowSize = 1 },
+		func(c *Config) { c.MaxConcurrentStreams = 0 },
+	} {
+		cfg := DefaultConfig()
+		fn(&cfg)
+		assert.ErrorIs(t, cfg.Validate(), ErrInvalidConfig)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cfg := DefaultConfig()
+	cfg.MaxRecvMsgSize = 1024
+	cfg.InitialWindowSize = MinWindowSize
+	cfg.InitialConnWindowSize = MinWindowSize
+	require.NoError(cfg.Validate())
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(cfg.ServerOptions()...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(err)
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(err)
+
+	// requests larger than the limit are rejected
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: strings.Repeat("x", 2048),
+	})
+	require.Equal(codes.ResourceExhausted, status.Code(err))
+}
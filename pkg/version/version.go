@@ -0,0 +1,117 @@
+// Package version describes the build of the job worker and the optional
+// features it supports so that clients can detect incompatible servers and
+// gate the use of optional features. Version, Commit and Date are set at build
+// time, e.g.:
+//
+//	go build -ldflags "-X github.com/joshuarubin/teleport-job-worker/pkg/version.Version=v1.2.3"
+package version
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"slices"
+)
+
+// ProtoVersion is the version of the protobuf api package served. Clients and
+// servers with different ProtoVersions are incompatible.
+const ProtoVersion = "jobworker.v1"
+
+// these are set via -ldflags -X at build time
+var (
+	Version = "dev" // the release version, e.g. v1.2.3
+	Commit  = ""    // the vcs revision, if empty it is read from the build info
+	Date    = ""    // the build date, if empty the vcs commit time is used
+)
+
+// Feature is an optional capability of the server. Features are only ever
+// added, a server that doesn't report a feature doesn't support it.
+type Feature string
+
+// NOTE: keep these in sync with the features the worker supports
+const (
+	FeatureDurable     Feature = "durable"      // StartJobRequest.durable
+	FeatureResult      Feature = "result"       // structured job results
+	FeatureProgress    Feature = "progress"     // job progress reporting
+	FeatureTimeouts    Feature = "timeouts"     // job timeouts, idle timeouts and deadlines
+	FeatureUpdateLimit Feature = "update-limit" // UpdateJobLimits
+	FeatureRemoveJob   Feature = "remove-job"   // RemoveJob
+	FeatureChecksums   Feature = "checksums"    // output crc32c and sha256
+)
+
+// features are the features supported by this build
+var features = []Feature{
+	FeatureDurable,
+	FeatureResult,
+	FeatureProgress,
+	FeatureTimeouts,
+	FeatureUpdateLimit,
+	FeatureRemoveJob,
+	FeatureChecksums,
+}
+
+// ErrIncompatible is returned by Info.Compatible if the ProtoVersions differ
+var ErrIncompatible = errors.New("incompatible version")
+
+// Info describes a build of the job worker
+type Info struct {
+	Version      string
+	Commit       string
+	Date         string
+	GoVersion    string
+	ProtoVersion string
+	Features     []Feature
+}
+
+// Get returns the Info of the running binary
+func Get() *Info {
+	info := Info{
+		Version:      Version,
+		Commit:       Commit,
+		Date:         Date,
+		GoVersion:    runtime.Version(),
+		ProtoVersion: ProtoVersion,
+		Features:     slices.Clone(features),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "":
+				info.Date = s.Value
+			}
+		}
+	}
+
+	return &info
+}
+
+// Supports reports whether f is one of the Info's features
+func (i *Info) Supports(f Feature) bool {
+	return slices.Contains(i.Features, f)
+}
+
+// Compatible returns ErrIncompatible if other speaks a different ProtoVersion
+// than i
+func (i *Info) Compatible(other *Info) error {
+	if i.ProtoVersion != other.ProtoVersion {
+		return fmt.Errorf("%w: %s != %s", ErrIncompatible, i.ProtoVersion, other.ProtoVersion)
+	}
+	return nil
+}
+
+// String returns a human readable description of the build, as printed by a
+// version command
+func (i *Info) String() string {
+	s := i.Version
+	if i.Commit != "" {
+		s += " (" + i.Commit + ")"
+	}
+	if i.Date != "" {
+		s += " built " + i.Date
+	}
+	return fmt.Sprintf("%s, %s, %s", s, i.GoVersion, i.ProtoVersion)
+}
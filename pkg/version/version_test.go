@@ -0,0 +1,35 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+
+	info := Get()
+	assert.Equal(t, Version, info.Version)
+	assert.Equal(t, ProtoVersion, info.ProtoVersion)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	assert.Contains(t, info.String(), ProtoVersion)
+
+	t.Run("features", func(t *testing.T) {
+		t.Parallel()
+		assert.True(t, info.Supports(FeatureDurable))
+		assert.False(t, info.Supports(Feature("unknown")))
+
+		// the features returned can't be used to modify this build's features
+		info.Features[0] = "modified"
+		assert.True(t, Get().Supports(FeatureDurable))
+	})
+
+	t.Run("compatible", func(t *testing.T) {
+		t.Parallel()
+		require.NoError(t, Get().Compatible(&Info{ProtoVersion: ProtoVersion}))
+		require.ErrorIs(t, Get().Compatible(&Info{ProtoVersion: "jobworker.v2"}), ErrIncompatible)
+	})
+}
@@ -0,0 +1,267 @@
+// Package wal implements a simple, append-only write-ahead log that is used to
+// persist job output so that it survives a crash of the worker.
+//
+// A log is a sequence of records, each of which is a one byte type, a four byte
+// big endian length, a four byte big endian CRC-32C of the data and the data
+// itself. Every log starts with a metadata record, is followed by any number of
+// data records and, if the job it belongs to completed, ends with a final
+// record. Each record is fsync'd to disk before Write returns.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RecordType identifies the kind of a record in the log
+type RecordType byte
+
+const (
+	RecordMetadata RecordType = iota + 1 // describes what the log belongs to, always the first record
+	RecordData                           // a chunk of output
+	RecordFinal                          // describes how the job completed, always the last record
+)
+
+const (
+	// headerSize is the size of the type, length and checksum that precede
+	// the data of every record
+	headerSize = 9
+
+	// maxRecordSize is the largest record that will be written or read. it
+	// keeps a corrupt length from causing a huge allocation during recovery.
+	maxRecordSize = 1 << 20 // 1MiB
+)
+
+var (
+	// ErrClosed is returned when writing to a Log after it was closed, or
+	// after its final record was written
+	ErrClosed = errors.New("log is closed")
+
+	// ErrCorrupt is returned by Read if the log doesn't start with a valid
+	// metadata record
+	ErrCorrupt = errors.New("log is corrupt")
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Quota limits the disk space used by logs
+type Quota interface {
+	// Reserve is called before a data record of n bytes is appended to the
+	// log. If it returns an error, the record is not written and Write
+	// returns the error.
+	Reserve(n int) error
+
+	// Use is called before a final record of n bytes is appended to the log.
+	// Final records are always written, so that a log is never left without
+	// one, regardless of the quota.
+	Use(n int)
+}
+
+// Log is an open write-ahead log. It is safe for concurrent use.
+type Log struct {
+	mu     sync.Mutex
+	f      *os.File
+	closed bool
+	quota  Quota
+}
+
+// ensure Log implements the io.Writer interface
+var _ io.Writer = (*Log)(nil)
+
+// Create creates a new log at path, which must not exist, and durably writes
+// metadata as its first record. Metadata, and the final record, must not be
+// larger than 1MiB.
+func Create(path string, metadata []byte) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := Log{f: f}
+	if err = l.append(RecordMetadata, metadata); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	// make sure that the new file itself survives a crash
+	if err = syncDir(filepath.Dir(path)); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// syncDir fsyncs the directory at path
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// SetQuota limits the size of the log with q. The metadata record is written by
+// Create, so it is not reserved from q.
+func (l *Log) SetQuota(q Quota) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.quota = q
+}
+
+// Write appends p as a data record. It only returns once the record has been
+// fsync'd to disk.
+func (l *Log) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return 0, ErrClosed
+	}
+
+	var n int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), maxRecordSize)]
+		if err := l.append(RecordData, chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+
+	return n, nil
+}
+
+// Finish durably appends final as the last record and closes the log
+func (l *Log) Finish(final []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	l.closed = true
+	err := l.append(RecordFinal, final)
+	return errors.Join(err, l.f.Close())
+}
+
+// Close closes the log without writing a final record
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+	return l.f.Close()
+}
+
+// append writes a record and fsyncs it. l.mu must be held.
+func (l *Log) append(typ RecordType, data []byte) error {
+	switch {
+	case l.quota == nil:
+	case typ == RecordData:
+		if err := l.quota.Reserve(headerSize + len(data)); err != nil {
+			return err
+		}
+	default:
+		l.quota.Use(headerSize + len(data))
+	}
+
+	record := make([]byte, headerSize+len(data))
+	record[0] = byte(typ)
+	binary.BigEndian.PutUint32(record[1:5], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[5:9], crc32.Checksum(data, crc32cTable))
+	copy(record[headerSize:], data)
+
+	if _, err := l.f.Write(record); err != nil {
+		return err
+	}
+
+	return l.f.Sync()
+}
+
+// Contents are the records recovered from a log
+type Contents struct {
+	Metadata []byte
+	Data     []byte // all of the data records concatenated
+	Final    []byte // nil if the log has no final record
+}
+
+// Read recovers the contents of the log at path. A torn or corrupt record at
+// the end of the log, like one that was being written during a crash, and
+// anything after it is ignored.
+func Read(path string) (*Contents, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var c Contents
+	for first := true; ; first = false {
+		typ, data, ok := readRecord(r)
+		if first && (!ok || typ != RecordMetadata) {
+			return nil, ErrCorrupt
+		}
+
+		if !ok {
+			return &c, nil
+		}
+
+		switch typ {
+		case RecordMetadata:
+			c.Metadata = data
+		case RecordData:
+			c.Data = append(c.Data, data...)
+		case RecordFinal:
+			c.Final = data
+			return &c, nil
+		default:
+			return &c, nil
+		}
+	}
+}
+
+// readRecord reads the next record from r. ok is false if there isn't a
+// complete and valid record.
+func readRecord(r io.Reader) (_ RecordType, _ []byte, ok bool) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, false
+	}
+
+	size := binary.BigEndian.Uint32(header[1:5])
+	if size > maxRecordSize {
+		return 0, nil, false
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, false
+	}
+
+	if crc32.Checksum(data, crc32cTable) != binary.BigEndian.Uint32(header[5:9]) {
+		return 0, nil, false
+	}
+
+	return RecordType(header[0]), data, true
+}
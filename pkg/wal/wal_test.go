@@ -0,0 +1,122 @@
+package wal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// byteQuota is a Quota that allows up to its value in bytes
+type byteQuota int
+
+func (q *byteQuota) Reserve(n int) error {
+	if n > int(*q) {
+		return errQuota
+	}
+	*q -= byteQuota(n)
+	return nil
+}
+
+func (q *byteQuota) Use(n int) {
+	*q -= byteQuota(n)
+}
+
+var errQuota = errors.New("quota exceeded")
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+
+	t.Run("quota", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		path := filepath.Join(t.TempDir(), "job.wal")
+
+		l, err := Create(path, []byte("meta"))
+		require.NoError(err)
+
+		q := byteQuota(headerSize + 3)
+		l.SetQuota(&q)
+
+		_, err = l.Write([]byte("foo"))
+		require.NoError(err)
+		_, err = l.Write([]byte("bar"))
+		require.ErrorIs(err, errQuota)
+
+		// the final record is always written
+		require.NoError(l.Finish([]byte("final")))
+		assert.Equal(byteQuota(-headerSize-5), q)
+
+		c, err := Read(path)
+		require.NoError(err)
+		assert.Equal(&Contents{Metadata: []byte("meta"), Data: []byte("foo"), Final: []byte("final")}, c)
+	})
+
+	t.Run("round-trip", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		path := filepath.Join(t.TempDir(), "job.wal")
+
+		l, err := Create(path, []byte("meta"))
+		require.NoError(err)
+
+		_, err = l.Write([]byte("foo"))
+		require.NoError(err)
+		_, err = l.Write([]byte("bar"))
+		require.NoError(err)
+
+		// the data is readable before the log is finished
+		c, err := Read(path)
+		require.NoError(err)
+		assert.Equal(&Contents{Metadata: []byte("meta"), Data: []byte("foobar")}, c)
+
+		require.NoError(l.Finish([]byte("final")))
+
+		_, err = l.Write([]byte("baz"))
+		require.ErrorIs(err, ErrClosed)
+
+		c, err = Read(path)
+		require.NoError(err)
+		assert.Equal(&Contents{Metadata: []byte("meta"), Data: []byte("foobar"), Final: []byte("final")}, c)
+
+		_, err = Create(path, nil)
+		require.ErrorIs(err, os.ErrExist)
+	})
+
+	t.Run("torn-write", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		path := filepath.Join(t.TempDir(), "job.wal")
+
+		l, err := Create(path, []byte("meta"))
+		require.NoError(err)
+		_, err = l.Write([]byte("foo"))
+		require.NoError(err)
+		_, err = l.Write([]byte("bar"))
+		require.NoError(err)
+		require.NoError(l.Close())
+
+		// simulate a crash in the middle of writing the last record
+		fi, err := os.Stat(path)
+		require.NoError(err)
+		require.NoError(os.Truncate(path, fi.Size()-1))
+
+		c, err := Read(path)
+		require.NoError(err)
+		assert.Equal(&Contents{Metadata: []byte("meta"), Data: []byte("foo")}, c)
+
+		// without a valid metadata record the log can't be recovered
+		require.NoError(os.Truncate(path, 4))
+		_, err = Read(path)
+		require.ErrorIs(err, ErrCorrupt)
+	})
+}
@@ -0,0 +1,141 @@
+// Package webhook delivers job lifecycle events to an HTTP endpoint as HMAC
+// signed JSON payloads.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+	"github.com/joshuarubin/teleport-job-worker/pkg/internal/retry"
+)
+
+const (
+	// SignatureHeader contains the hex encoded HMAC-SHA256 of the request
+	// body, prefixed with "sha256=", using Config.Secret as the key
+	SignatureHeader = "X-Job-Worker-Signature"
+
+	// EventHeader contains the event.Type of the payload
+	EventHeader = "X-Job-Worker-Event"
+)
+
+const (
+	// DefaultMaxAttempts is used if Config.MaxAttempts is <= 0
+	DefaultMaxAttempts = retry.DefaultMaxAttempts
+
+	// DefaultInitialBackoff is used if Config.InitialBackoff is <= 0
+	DefaultInitialBackoff = retry.DefaultInitialBackoff
+
+	// DefaultTimeout is used if Config.Timeout is <= 0
+	DefaultTimeout = retry.DefaultTimeout
+)
+
+// Config configures a Notifier
+type Config struct {
+	URL            string        // the url that events are POSTed to
+	Secret         []byte        // the key used to sign payloads, if empty, payloads are not signed
+	MaxAttempts    int           // the maximum number of delivery attempts per event
+	InitialBackoff time.Duration // the delay before the first retry, doubled after each attempt
+	Timeout        time.Duration // the timeout for each attempt
+	Client         *http.Client  // optional, http.DefaultClient is used if nil
+}
+
+// Notifier delivers events to a webhook
+type Notifier struct {
+	cfg Config
+}
+
+// ErrURLRequired is returned by New if the url is empty
+var ErrURLRequired = errors.New("webhook url is required")
+
+// New returns a new Notifier
+func New(cfg *Config) (*Notifier, error) {
+	if cfg.URL == "" {
+		return nil, ErrURLRequired
+	}
+
+	n := Notifier{cfg: *cfg}
+
+	if n.cfg.MaxAttempts <= 0 {
+		n.cfg.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if n.cfg.InitialBackoff <= 0 {
+		n.cfg.InitialBackoff = DefaultInitialBackoff
+	}
+
+	if n.cfg.Timeout <= 0 {
+		n.cfg.Timeout = DefaultTimeout
+	}
+
+	if n.cfg.Client == nil {
+		n.cfg.Client = http.DefaultClient
+	}
+
+	return &n, nil
+}
+
+// Sign returns the value of SignatureHeader for body signed with secret.
+// Receivers should compute it and compare it using hmac.Equal.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ensure Notifier implements the event.Sink interface
+var _ event.Sink = (*Notifier)(nil)
+
+// Notify delivers e to the webhook, retrying with exponential backoff on
+// network errors, 429 and 5xx responses. It blocks until the event was
+// delivered, all attempts were exhausted or ctx is done. Only terminal events
+// are delivered, others are ignored.
+func (n *Notifier) Notify(ctx context.Context, e *event.Event) error {
+	if !e.Type.Terminal() {
+		return nil
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	p := retry.Policy{MaxAttempts: n.cfg.MaxAttempts, InitialBackoff: n.cfg.InitialBackoff}
+	return retry.Do(ctx, p, func(ctx context.Context) error {
+		return n.deliver(ctx, e.Type, body)
+	})
+}
+
+// deliver makes a single attempt to deliver body to the webhook
+func (n *Notifier) deliver(ctx context.Context, typ event.Type, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, n.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(typ))
+	if len(n.cfg.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.cfg.Secret, body))
+	}
+
+	resp, err := n.cfg.Client.Do(req)
+	if err != nil {
+		return retry.Retryable(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return retry.Status("webhook", resp.StatusCode)
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+
+	t.Run("retry", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		var attempts atomic.Int32
+		received := make(chan *event.Event, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil || r.Header.Get(SignatureHeader) != Sign(secret, body) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			var e event.Event
+			if err = json.Unmarshal(body, &e); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			assert.Equal(string(event.TypeCompleted), r.Header.Get(EventHeader))
+			received <- &e
+		}))
+		defer srv.Close()
+
+		n, err := New(&Config{
+			URL:            srv.URL,
+			Secret:         secret,
+			InitialBackoff: time.Millisecond,
+		})
+		require.NoError(err)
+
+		err = n.Notify(context.Background(), &event.Event{Type: event.TypeCompleted, JobID: "job_123"})
+		require.NoError(err)
+
+		e := <-received
+		assert.Equal("job_123", e.JobID)
+		assert.Equal(int32(2), attempts.Load())
+	})
+
+	t.Run("no-retry", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		var attempts atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		n, err := New(&Config{URL: srv.URL, InitialBackoff: time.Millisecond})
+		require.NoError(err)
+
+		err = n.Notify(context.Background(), &event.Event{Type: event.TypeFailed})
+		require.Error(err)
+		assert.Equal(int32(1), attempts.Load())
+	})
+
+	t.Run("max-attempts", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		var attempts atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		n, err := New(&Config{URL: srv.URL, MaxAttempts: 3, InitialBackoff: time.Millisecond})
+		require.NoError(err)
+
+		err = n.Notify(context.Background(), &event.Event{Type: event.TypeFailed})
+		require.Error(err)
+		assert.Equal(int32(3), attempts.Load())
+	})
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxDescriptionLength is the maximum length, in bytes, of
+	// JobOptions.Description
+	MaxDescriptionLength = 1 << 10 // 1KiB
+
+	// MaxAnnotations is the maximum number of JobOptions.Annotations
+	MaxAnnotations = 32
+
+	// MaxAnnotationKeyLength and MaxAnnotationValueLength are the maximum
+	// lengths, in bytes, of each of the keys and values of
+	// JobOptions.Annotations
+	MaxAnnotationKeyLength   = 64
+	MaxAnnotationValueLength = 256
+
+	// MaxCorrelationIDLength is the maximum length, in bytes, of
+	// JobOptions.CorrelationID
+	MaxCorrelationIDLength = 128
+)
+
+// ErrInvalidAnnotations is returned by StartJob if JobOptions.Description or
+// JobOptions.Annotations exceed their limits or if an annotation has an empty
+// key
+var ErrInvalidAnnotations = errors.New("invalid description or annotations")
+
+// ErrInvalidCorrelationID is returned by StartJob if JobOptions.CorrelationID
+// is longer than MaxCorrelationIDLength or contains anything other than
+// printable, non-space, ASCII characters
+var ErrInvalidCorrelationID = errors.New("invalid correlation id")
+
+// validateCorrelationID ensures that id can safely be logged and searched for
+func validateCorrelationID(id string) error {
+	if len(id) > MaxCorrelationIDLength {
+		return fmt.Errorf("%w: longer than %d bytes", ErrInvalidCorrelationID, MaxCorrelationIDLength)
+	}
+
+	for _, c := range []byte(id) {
+		if c <= ' ' || c > '~' {
+			return fmt.Errorf("%w: %q", ErrInvalidCorrelationID, id)
+		}
+	}
+
+	return nil
+}
+
+// validateAnnotations ensures that the description and annotations of a job
+// are within their limits. they are kept for as long as the job is, so they
+// must not be allowed to grow without bound.
+func validateAnnotations(description string, annotations map[string]string) error {
+	if len(description) > MaxDescriptionLength {
+		return fmt.Errorf("%w: description is longer than %d bytes", ErrInvalidAnnotations, MaxDescriptionLength)
+	}
+
+	if len(annotations) > MaxAnnotations {
+		return fmt.Errorf("%w: more than %d annotations", ErrInvalidAnnotations, MaxAnnotations)
+	}
+
+	for k, v := range annotations {
+		switch {
+		case k == "":
+			return fmt.Errorf("%w: annotation key is empty", ErrInvalidAnnotations)
+		case len(k) > MaxAnnotationKeyLength:
+			return fmt.Errorf("%w: annotation key %q is longer than %d bytes", ErrInvalidAnnotations, k, MaxAnnotationKeyLength)
+		case len(v) > MaxAnnotationValueLength:
+			return fmt.Errorf("%w: value of annotation %q is longer than %d bytes", ErrInvalidAnnotations, k, MaxAnnotationValueLength)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInvalidBindMount is returned by New if a file that should be bind mounted
+// into jobs is not a regular file
+var ErrInvalidBindMount = errors.New("bind mount source must be a regular file")
+
+// mountSpec is a file on the host that is mounted over Target in each job's
+// mount namespace
+type mountSpec struct {
+	Source string
+	Target string
+}
+
+// bindMounts returns the files configured to be bind mounted into jobs
+func (c *Config) bindMounts() []mountSpec {
+	var ret []mountSpec
+
+	if c.ResolvConfPath != "" {
+		ret = append(ret, mountSpec{Source: c.ResolvConfPath, Target: "/etc/resolv.conf"})
+	}
+
+	if c.HostsPath != "" {
+		ret = append(ret, mountSpec{Source: c.HostsPath, Target: "/etc/hosts"})
+	}
+
+	return ret
+}
+
+// validateBindMounts ensures that the source of each mount is a regular file
+func validateBindMounts(mounts []mountSpec) error {
+	for _, m := range mounts {
+		fi, err := os.Stat(m.Source)
+		if err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("%w: %s", ErrInvalidBindMount, m.Source)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// ErrNoBlockDevice is returned when a path is not backed by a block device,
+// e.g. because it is on tmpfs or overlayfs
+var ErrNoBlockDevice = errors.New("path is not backed by a block device")
+
+// majMinRE matches block devices given as MAJOR:MINOR
+var majMinRE = regexp.MustCompile(`^\d+:\d+$`)
+
+// resolveBlockDevices returns the MAJOR:MINOR of the block devices that io
+// limits are applied to. devices may be given as MAJOR:MINOR, as the path of a
+// block device or as any other path, in which case the device backing it is
+// used. Partitions are resolved to the disk they belong to since io.max only
+// accepts whole disks. If devices is empty, the device backing the working
+// directory, which jobs inherit, is used. If that can't be detected, all
+// non-loop block devices are used.
+func resolveBlockDevices(devices []string) ([]string, error) {
+	if runtime.GOOS != linuxOS {
+		return nil, nil
+	}
+
+	if len(devices) == 0 {
+		dev, err := workspaceBlockDevice()
+		if err == nil {
+			return []string{dev}, nil
+		}
+
+		slog.Warn("error detecting the block device of the job workspace, using all block devices", "err", err)
+		return getBlockDevices()
+	}
+
+	ret := make([]string, 0, len(devices))
+	for _, device := range devices {
+		dev, err := resolveBlockDevice(device)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving io device %q: %w", device, err)
+		}
+
+		if !slices.Contains(ret, dev) {
+			ret = append(ret, dev)
+		}
+	}
+
+	return ret, nil
+}
+
+// workspaceBlockDevice returns the MAJOR:MINOR of the disk backing the current
+// working directory
+func workspaceBlockDevice() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return resolveBlockDevice(wd)
+}
+
+// resolveBlockDevice returns the MAJOR:MINOR of the disk identified by device,
+// see resolveBlockDevices
+func resolveBlockDevice(device string) (string, error) {
+	dev := device
+	if !majMinRE.MatchString(device) {
+		var err error
+		if dev, err = deviceOf(device); err != nil {
+			return "", err
+		}
+	}
+
+	return wholeDisk(dev)
+}
+
+// wholeDisk returns the MAJOR:MINOR of the disk that the partition dev belongs
+// to. If dev is already a disk, it is returned as is.
+func wholeDisk(dev string) (string, error) {
+	// /sys/dev/block/MAJOR:MINOR is a symlink into the device tree where
+	// partitions are children of the disk they belong to
+	path, err := filepath.EvalSymlinks(filepath.Join("/sys/dev/block", dev))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoBlockDevice
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = os.Stat(filepath.Join(path, "partition")); errors.Is(err, os.ErrNotExist) {
+		return dev, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "dev"))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// sysBlock lists the top-level block devices, including device-mapper and
+// md-raid devices, but not partitions
+const sysBlock = "/sys/block"
+
+// virtualDevicePrefixes are the block devices that are never io limited
+var virtualDevicePrefixes = []string{"loop", "ram", "zram"}
+
+// getBlockDevices returns a list of MAJOR:MINOR block devices that can be used
+// for setting io limits in io.max for cgroups
+func getBlockDevices() ([]string, error) {
+	return listBlockDevices(sysBlock)
+}
+
+// listBlockDevices walks dir, which is usually /sys/block, and returns the
+// MAJOR:MINOR of each top-level device. virtual devices are skipped, as are
+// devices, or devices with partitions, that are held by another device, like
+// the disks beneath a device-mapper or md-raid device. io to those devices is
+// limited by limiting the device holding them.
+func listBlockDevices(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if slices.ContainsFunc(virtualDevicePrefixes, func(prefix string) bool {
+			return strings.HasPrefix(name, prefix)
+		}) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+
+		held, err := isHeld(path, name)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(path, "dev"))
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, strings.TrimSpace(string(data)))
+	}
+
+	return ret, nil
+}
+
+// isHeld returns true if the device at path, or any of its partitions, is
+// held by another device
+func isHeld(path, name string) (bool, error) {
+	holders, err := os.ReadDir(filepath.Join(path, "holders"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if len(holders) > 0 {
+		return true, nil
+	}
+
+	// partitions are subdirectories named after the device, e.g. sda1 or
+	// nvme0n1p1
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), name) {
+			continue
+		}
+
+		held, err := isHeld(filepath.Join(path, entry.Name()), entry.Name())
+		if err != nil || held {
+			return held, err
+		}
+	}
+
+	return false, nil
+}
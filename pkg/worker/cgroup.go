@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// cgroupMountPoint is where the cgroup v2 unified hierarchy is expected to be
+// mounted
+const cgroupMountPoint = "/sys/fs/cgroup"
+
+// subtreeControllers are the controllers that are enabled for the children of
+// the root cgroup
+const subtreeControllers = "+cpu +memory +io"
+
+// serverCGroupName is the name of the leaf cgroup that processes are moved to
+// when the cgroup the server is running in needs to delegate controllers to
+// its children
+const serverCGroupName = "job-worker-server"
+
+// ErrCGroupV2Required is returned if the current process does not belong to a
+// cgroup v2 hierarchy
+var ErrCGroupV2Required = errors.New("cgroup v2 is required")
+
+// parseCGroupV2Path parses the contents of /proc/<pid>/cgroup and returns the
+// path, relative to the cgroup mount point, of the cgroup v2 entry
+func parseCGroupV2Path(data []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// the cgroup v2 entry always has hierarchy id 0 and no controllers:
+		//  0::/system.slice/job-worker.service
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", ErrCGroupV2Required
+}
+
+// procSelfCGroup lists the cgroups that the current process belongs to
+const procSelfCGroup = "/proc/self/cgroup"
+
+// currentCGroup returns the absolute path, beneath mountPoint, of the cgroup
+// listed in procCGroup, which is usually /proc/self/cgroup. when running
+// inside a container, this is often not the root of the hierarchy.
+func currentCGroup(mountPoint, procCGroup string) (string, error) {
+	data, err := os.ReadFile(procCGroup)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := parseCGroupV2Path(data)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(mountPoint, path), nil
+}
+
+// enableSubtreeControllers makes the cpu, memory and io controllers available
+// to the children of cg. cgroup v2 does not permit a non-root cgroup that
+// contains processes to enable controllers for its children (the "no internal
+// processes" rule), so if cg contains processes, they are first moved to a new
+// leaf cgroup.
+func enableSubtreeControllers(cg string) error {
+	file := filepath.Join(cg, "cgroup.subtree_control")
+
+	err := os.WriteFile(file, []byte(subtreeControllers), cgroupFilePerm)
+	if !errors.Is(err, syscall.EBUSY) {
+		return err
+	}
+
+	if err = moveProcesses(cg, filepath.Join(cg, serverCGroupName)); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, []byte(subtreeControllers), cgroupFilePerm)
+}
+
+// moveProcesses moves all processes from the cgroup src to the cgroup dst,
+// creating dst if necessary
+func moveProcesses(src, dst string) error {
+	if err := os.MkdirAll(dst, 0o700); err != nil { //nolint:mnd
+		return fmt.Errorf("error creating cgroup %q: %w", dst, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(src, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+
+	procs := filepath.Join(dst, "cgroup.procs")
+	for _, pid := range strings.Fields(string(data)) {
+		err = os.WriteFile(procs, []byte(pid), cgroupFilePerm)
+
+		// the process may have exited in the meantime
+		if err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("error moving pid %s to cgroup %q: %w", pid, dst, err)
+		}
+	}
+
+	return nil
+}
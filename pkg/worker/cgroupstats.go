@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// CGroupStats are the cpu throttling and memory limit statistics of a job's
+// cgroup. They help explain why a job is slow or was killed.
+type CGroupStats struct {
+	NrPeriods     uint64 // the number of cpu.max enforcement periods that have elapsed
+	NrThrottled   uint64 // the number of periods in which the job was throttled
+	ThrottledTime time.Duration
+
+	MemoryMax     uint64 // the number of times memory usage hit memory.max
+	MemoryOOM     uint64 // the number of times memory.max was exceeded and the job ran out of memory
+	MemoryOOMKill uint64 // the number of processes killed by the oom killer
+}
+
+// parseFlatKeyed parses the "key value" lines of cgroup files like cpu.stat
+// and memory.events
+func parseFlatKeyed(data []byte) map[string]uint64 {
+	ret := map[string]uint64{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ret[key] = v
+	}
+
+	return ret
+}
+
+// readCGroupStats reads cpu.stat and memory.events from the cgroup at path
+func readCGroupStats(path string) (*CGroupStats, error) {
+	cpu, err := os.ReadFile(filepath.Join(path, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := os.ReadFile(filepath.Join(path, "memory.events"))
+	if err != nil {
+		return nil, err
+	}
+
+	return newCGroupStats(parseFlatKeyed(cpu), parseFlatKeyed(memory)), nil
+}
+
+// newCGroupStats returns the stats from the parsed contents of cpu.stat and
+// memory.events
+func newCGroupStats(cpu, memory map[string]uint64) *CGroupStats {
+	return &CGroupStats{
+		NrPeriods:     cpu["nr_periods"],
+		NrThrottled:   cpu["nr_throttled"],
+		ThrottledTime: time.Duration(cpu["throttled_usec"]) * time.Microsecond,
+		MemoryMax:     memory["max"],
+		MemoryOOM:     memory["oom"],
+		MemoryOOMKill: memory["oom_kill"],
+	}
+}
+
+// throttledRatio returns the fraction of the cpu.max periods between prev and
+// s in which the job was throttled
+func (s *CGroupStats) throttledRatio(prev *CGroupStats) float64 {
+	periods := s.NrPeriods - prev.NrPeriods
+	if periods == 0 {
+		return 0
+	}
+	return float64(s.NrThrottled-prev.NrThrottled) / float64(periods)
+}
+
+// DefaultThrottleThreshold is the default fraction of cpu.max periods that a
+// job must be throttled in for it to be considered heavily throttled
+const DefaultThrottleThreshold = 0.5
+
+// CGroupAlertPolicy determines how often the cgroup statistics of running jobs
+// are checked and when alerts are sent to the Worker's event sinks
+type CGroupAlertPolicy struct {
+	// Interval is how often the statistics are checked. If 0, alerts are
+	// disabled.
+	Interval time.Duration
+
+	// ThrottleThreshold is the fraction of cpu.max periods, 0 < value <= 1,
+	// during an interval that a job must be throttled in to send an
+	// event.TypeThrottled alert. If 0, DefaultThrottleThreshold is used.
+	ThrottleThreshold float64
+}
+
+// monitorCGroup checks the statistics of the cgroup at path each interval
+// until j is done. it sends an event.TypeThrottled alert when the job becomes
+// heavily throttled, and an event.TypeMemoryLimit alert whenever it hits its
+// memory limit.
+func (w *Worker) monitorCGroup(j *job.Job, path string) {
+	threshold := w.cfg.CGroupAlerts.ThrottleThreshold
+	if threshold <= 0 {
+		threshold = DefaultThrottleThreshold
+	}
+
+	ticker := clock.NewTicker(w.clock, w.cfg.CGroupAlerts.Interval)
+	defer ticker.Stop()
+
+	var prev *CGroupStats
+	var throttled bool
+
+	for {
+		select {
+		case <-j.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := readCGroupStats(path)
+		if err != nil {
+			// the child may not have created the cgroup yet
+			continue
+		}
+
+		if prev != nil {
+			// only alert when the job becomes throttled, not every interval
+			// that it stays throttled
+			ratio := stats.throttledRatio(prev)
+			if ratio >= threshold && !throttled {
+				w.notify(event.Alert(j, event.TypeThrottled,
+					fmt.Sprintf("throttled in %.0f%% of cpu periods", ratio*100),
+				))
+			}
+			throttled = ratio >= threshold
+
+			if stats.MemoryMax > prev.MemoryMax || stats.MemoryOOM > prev.MemoryOOM {
+				w.notify(event.Alert(j, event.TypeMemoryLimit,
+					fmt.Sprintf("memory limit hit %d times", stats.MemoryMax-prev.MemoryMax),
+				))
+			}
+		}
+
+		prev = stats
+	}
+}
@@ -0,0 +1,299 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	childv1 "github.com/joshuarubin/teleport-job-worker/pkg/proto/jobworker/child/v1"
+)
+
+// childEnv is the environment variable that marks a process as the
+// reexecuted child, so that it doesn't verify ReexecCommand and its logs are
+// redirected before anything is logged. The job's spec is not passed in the
+// environment, it is read from job.SpecFD.
+const childEnv = "JOB_WORKER_CHILD"
+
+// childSpec contains everything that StartChild needs to set up, and execute,
+// a job. it is passed to the child, as a childv1.ChildSpec, on job.SpecFD so
+// that helper binaries don't need the Worker's Config.
+type childSpec struct {
+	Args       []string // the job's command, which has already been resolved, followed by its arguments
+	Env        []string // the environment the job runs with
+	Rlimits    []Rlimit
+	BindMounts []mountSpec
+	Priority   Priority
+	Proc       ProcPolicy
+	Setsid     bool   // start the job in a new session
+	NoNewPrivs bool   // set PR_SET_NO_NEW_PRIVS before executing the job
+	Init       bool   // start the job under a minimal init instead of executing it
+	Sandbox    string // the sandbox-exec(1) profile the job runs with, on darwin
+	Jail       string // the name of the jail the job runs in, on freebsd
+
+	// Namespaces are the namespaces that the parent created the child in
+	Namespaces job.Namespace
+
+	// Seccomp installs the seccomp filter before executing the job
+	Seccomp bool
+
+	// Capabilities are the only capabilities the job may have, if
+	// DropCapabilities is set
+	Capabilities     []int
+	DropCapabilities bool
+
+	// MaskedDevices are the device nodes that are hidden from the job
+	MaskedDevices []string
+
+	// Setup is set when the parent passed job.SetupFD and job.SetupLogFD to
+	// the child
+	Setup bool
+}
+
+// marshal encodes the spec as a childv1.ChildSpec
+func (s *childSpec) marshal() ([]byte, error) {
+	pb := childv1.ChildSpec{
+		Args: s.Args,
+		Env:  s.Env,
+		Priority: &childv1.Priority{
+			Nice:        int32(s.Priority.Nice),
+			IoClass:     int32(s.Priority.IOClass),
+			IoLevel:     int32(s.Priority.IOLevel),
+			OomScoreAdj: int32(s.Priority.OOMScoreAdj),
+		},
+		Proc: &childv1.Proc{
+			ReadOnly: s.Proc.ReadOnly,
+			HidePid:  s.Proc.HidePID,
+		},
+		Setsid:     s.Setsid,
+		NoNewPrivs: s.NoNewPrivs,
+		Init:       s.Init,
+		Sandbox:    s.Sandbox,
+		Jail:       s.Jail,
+		Setup:      s.Setup,
+
+		Namespaces:       uint32(s.Namespaces),
+		Seccomp:          s.Seccomp,
+		DropCapabilities: s.DropCapabilities,
+		MaskedDevices:    s.MaskedDevices,
+	}
+
+	for _, c := range s.Capabilities {
+		pb.Capabilities = append(pb.Capabilities, int32(c))
+	}
+
+	for _, r := range s.Rlimits {
+		pb.Rlimits = append(pb.Rlimits, &childv1.Rlimit{
+			Resource: int32(r.Resource),
+			Soft:     r.Soft,
+			Hard:     r.Hard,
+		})
+	}
+
+	for _, m := range s.BindMounts {
+		pb.BindMounts = append(pb.BindMounts, &childv1.BindMount{
+			Source: m.Source,
+			Target: m.Target,
+		})
+	}
+
+	return proto.Marshal(&pb)
+}
+
+// unmarshalChildSpec decodes a childv1.ChildSpec written by marshal
+func unmarshalChildSpec(data []byte) (*childSpec, error) {
+	var pb childv1.ChildSpec
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return nil, err
+	}
+
+	if len(pb.GetArgs()) == 0 {
+		return nil, job.ErrCommandRequired
+	}
+
+	p := pb.GetPriority()
+	spec := childSpec{
+		Args: pb.GetArgs(),
+		Env:  pb.GetEnv(),
+		Priority: Priority{
+			Nice:        int(p.GetNice()),
+			IOClass:     IOClass(p.GetIoClass()),
+			IOLevel:     int(p.GetIoLevel()),
+			OOMScoreAdj: int(p.GetOomScoreAdj()),
+		},
+		Proc: ProcPolicy{
+			ReadOnly: pb.GetProc().GetReadOnly(),
+			HidePID:  pb.GetProc().GetHidePid(),
+		},
+		Setsid:     pb.GetSetsid(),
+		NoNewPrivs: pb.GetNoNewPrivs(),
+		Init:       pb.GetInit(),
+		Sandbox:    pb.GetSandbox(),
+		Jail:       pb.GetJail(),
+		Setup:      pb.GetSetup(),
+
+		Namespaces:       job.Namespace(pb.GetNamespaces()),
+		Seccomp:          pb.GetSeccomp(),
+		DropCapabilities: pb.GetDropCapabilities(),
+		MaskedDevices:    pb.GetMaskedDevices(),
+	}
+
+	for _, c := range pb.GetCapabilities() {
+		spec.Capabilities = append(spec.Capabilities, int(c))
+	}
+
+	for _, r := range pb.GetRlimits() {
+		spec.Rlimits = append(spec.Rlimits, Rlimit{
+			Resource: RlimitResource(r.GetResource()),
+			Soft:     r.GetSoft(),
+			Hard:     r.GetHard(),
+		})
+	}
+
+	for _, m := range pb.GetBindMounts() {
+		spec.BindMounts = append(spec.BindMounts, mountSpec{
+			Source: m.GetSource(),
+			Target: m.GetTarget(),
+		})
+	}
+
+	return &spec, nil
+}
+
+// readChildSpec reads the childSpec from job.SpecFD until EOF. the fd is
+// closed once it has been read, so it is never inherited by the job's command.
+// it is only read once, later calls return the same result.
+var readChildSpec = sync.OnceValues(func() (*childSpec, error) {
+	f := os.NewFile(job.SpecFD, "spec")
+	if f == nil {
+		return nil, errors.New("spec fd is not open")
+	}
+	defer func() { _ = f.Close() }()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalChildSpec(data)
+})
+
+// openSetupPipe returns job.SetupFD, if the parent passed it, marked
+// close-on-exec so that the parent sees it close once the job's command has
+// been executed
+func openSetupPipe(spec *childSpec) *os.File {
+	if !spec.Setup {
+		return nil
+	}
+
+	closeOnExec(job.SetupFD)
+	return os.NewFile(job.SetupFD, "setup")
+}
+
+// childError reports an error that prevented the child from executing the
+// job's command. it is written to the setup pipe, if there is one, so that it
+// is reported as the job's error instead of being mixed in with its output.
+func childError(setup *os.File, err error) error {
+	if setup != nil {
+		if _, werr := io.WriteString(setup, err.Error()); werr == nil {
+			return err
+		}
+	}
+
+	slog.Error("error starting child process", "err", err)
+	return err
+}
+
+var (
+	// redirectChildLogOnce keeps job.SetupLogFD from being wrapped in more
+	// than one os.File, each of which would close it once it is garbage
+	// collected
+	redirectChildLogOnce sync.Once
+
+	// childLogFile is job.SetupLogFD once the child's logs are redirected to
+	// it
+	childLogFile *os.File
+)
+
+// redirectChildLog sends the child's logs to job.SetupLogFD, if the parent
+// passed it, so that they aren't written to stderr, which is the job's output.
+// it must be called before anything is logged.
+func redirectChildLog() {
+	redirectChildLogOnce.Do(func() {
+		spec, err := readChildSpec()
+		if err != nil || !spec.Setup {
+			return
+		}
+
+		closeOnExec(job.SetupLogFD)
+		childLogFile = os.NewFile(job.SetupLogFD, "setup-log")
+		slog.SetDefault(slog.New(slog.NewJSONHandler(childLogFile, nil)))
+	})
+}
+
+// closeChildLog closes job.SetupLogFD, if the child's logs were redirected to
+// it, so that the parent stops reading them. anything logged afterwards is
+// discarded.
+func closeChildLog() {
+	if childLogFile == nil {
+		return
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_ = childLogFile.Close()
+	childLogFile = nil
+}
+
+// childLog logs the records a job's child wrote to job.SetupLogFD with the
+// parent's logger
+type childLog struct {
+	jobID job.ID
+}
+
+// Write logs a single JSON log record from the child
+func (l childLog) Write(p []byte) (int, error) {
+	level, msg, attrs := parseChildLog(p)
+	slog.Log(context.Background(), level, msg, append([]any{"job_id", l.jobID}, attrs...)...)
+	return len(p), nil
+}
+
+// parseChildLog parses a record written by slog.JSONHandler. the time is
+// dropped since the record is logged again. anything that isn't a record is
+// logged as a warning, as is.
+func parseChildLog(line []byte) (slog.Level, string, []any) {
+	var rec map[string]any
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return slog.LevelWarn, string(line), nil
+	}
+
+	level := slog.LevelInfo
+	if s, ok := rec[slog.LevelKey].(string); ok {
+		_ = level.UnmarshalText([]byte(s))
+	}
+
+	msg, _ := rec[slog.MessageKey].(string)
+
+	delete(rec, slog.TimeKey)
+	delete(rec, slog.LevelKey)
+	delete(rec, slog.MessageKey)
+
+	keys := make([]string, 0, len(rec))
+	for k := range rec {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	attrs := make([]any, 0, 2*len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, k, rec[k])
+	}
+
+	return level, msg, attrs
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+//go:generate stringer -type=State -trimprefix=State
+
+// State describes whether the Worker is accepting new jobs, so that servers
+// can reflect it in their health status and take it out of rotation during
+// maintenance
+type State int
+
+// NOTE: keep this synced with jobworker.proto:WorkerState, its values are
+// offset by one
+const (
+	StateServing  State = iota // new jobs are accepted
+	StateCordoned              // new jobs are rejected, existing jobs are unaffected
+	StateDraining              // new jobs are rejected and existing jobs are being waited for, or stopped
+	StateDrained               // new jobs are rejected and all existing jobs are done
+)
+
+// DrainPolicy determines what happens to running jobs when the Worker is
+// drained
+type DrainPolicy int
+
+// NOTE: keep this synced with jobworker.proto:DrainPolicy, its values are
+// offset by one
+const (
+	DrainWaitForJobs DrainPolicy = iota // running jobs are allowed to complete until ctx is done, then they are stopped
+	DrainStopJobs                       // running jobs are sent SIGTERM and, if they are still running once ctx is done, killed
+)
+
+// ErrWorkerCordoned is returned when trying to start a job while the Worker is
+// cordoned or drained
+var ErrWorkerCordoned = errors.New("worker is cordoned")
+
+// State returns whether the Worker is accepting new jobs
+func (w *Worker) State() State {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.state
+}
+
+// setState sets the state of the Worker to st and calls Config.OnStateChange,
+// if it changed. if from is not empty, the state is only changed if it is one
+// of them.
+func (w *Worker) setState(st State, from ...State) {
+	w.mu.Lock()
+	prev := w.state
+	if len(from) == 0 || slices.Contains(from, prev) {
+		w.state = st
+	}
+	changed := w.state != prev
+	w.mu.Unlock()
+
+	if changed && w.cfg.OnStateChange != nil {
+		w.cfg.OnStateChange(st)
+	}
+}
+
+// Cordon stops the Worker from accepting new jobs, they are rejected with
+// ErrWorkerCordoned. Running jobs, and jobs queued for their start-by time,
+// are unaffected. It must only be exposed to administrators.
+func (w *Worker) Cordon() {
+	w.setState(StateCordoned, StateServing)
+}
+
+// Uncordon makes the Worker accept new jobs again after it was cordoned or
+// drained. It must only be exposed to administrators.
+func (w *Worker) Uncordon() {
+	w.setState(StateServing)
+}
+
+// Drain cordons the Worker, cancels its queued jobs and then waits for its
+// running jobs to complete, handling them according to policy. Jobs that are
+// still running once ctx is done are stopped. Jobs that are stopped have
+// job.StopReasonShutdown. Once all jobs are done, the Worker is StateDrained
+// and stays cordoned until Uncordon is called. It must only be exposed to
+// administrators.
+func (w *Worker) Drain(ctx context.Context, policy DrainPolicy) error {
+	w.setState(StateDraining)
+
+	// queued jobs would never have room to start
+	w.sched.cancelAll(job.StopReasonShutdown)
+	w.cancelRestarts(job.StopReasonShutdown)
+
+	// no more jobs can be added once those being started have been
+	w.waitForLaunches()
+	jobs := w.jobs.all()
+
+	var errs []error
+
+	if policy == DrainWaitForJobs {
+		if err := waitForJobs(ctx, jobs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := j.Terminate(ctx, job.StopReasonShutdown); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("error stopping job %s: %w", j.ID(), err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the worker may have been uncordoned while it was being drained
+	w.setState(StateDrained, StateDraining)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,87 @@
+package worker
+
+import "errors"
+
+// ErrNotDevice is returned by New if a path in Config.AllowedDevices isn't a
+// device node
+var ErrNotDevice = errors.New("not a device")
+
+// deviceType is the type of device a deviceRule applies to, it matches
+// BPF_DEVCG_DEV_*
+type deviceType uint32
+
+const (
+	deviceBlock deviceType = 1 // BPF_DEVCG_DEV_BLOCK
+	deviceChar  deviceType = 2 // BPF_DEVCG_DEV_CHAR
+)
+
+// deviceAccess is a set of the ways a device may be accessed, it matches
+// BPF_DEVCG_ACC_*
+type deviceAccess uint32
+
+const (
+	deviceMknod deviceAccess = 1 << iota // BPF_DEVCG_ACC_MKNOD
+	deviceRead                           // BPF_DEVCG_ACC_READ
+	deviceWrite                          // BPF_DEVCG_ACC_WRITE
+
+	deviceRWM = deviceRead | deviceWrite | deviceMknod
+)
+
+// anyDevice matches every major, or minor, number in a deviceRule
+const anyDevice = -1
+
+// deviceRule permits access to the devices of typ with the major and minor
+// numbers
+type deviceRule struct {
+	typ    deviceType
+	major  int64
+	minor  int64
+	access deviceAccess
+}
+
+// defaultDeviceRules are the devices that every job may access when the
+// device policy is applied. device nodes may be created, but not opened, for
+// any device so that e.g. package managers work.
+var defaultDeviceRules = []deviceRule{
+	{typ: deviceChar, major: anyDevice, minor: anyDevice, access: deviceMknod},
+	{typ: deviceBlock, major: anyDevice, minor: anyDevice, access: deviceMknod},
+	{typ: deviceChar, major: 1, minor: 3, access: deviceRWM},           // /dev/null
+	{typ: deviceChar, major: 1, minor: 5, access: deviceRWM},           // /dev/zero
+	{typ: deviceChar, major: 1, minor: 7, access: deviceRWM},           // /dev/full
+	{typ: deviceChar, major: 1, minor: 8, access: deviceRWM},           // /dev/random
+	{typ: deviceChar, major: 1, minor: 9, access: deviceRWM},           // /dev/urandom
+	{typ: deviceChar, major: 5, minor: 0, access: deviceRWM},           // /dev/tty
+	{typ: deviceChar, major: 5, minor: 2, access: deviceRWM},           // /dev/ptmx
+	{typ: deviceChar, major: 136, minor: anyDevice, access: deviceRWM}, // /dev/pts/*
+}
+
+// deviceRules returns the rules of the device policy of a job granted the gpus
+// named in gpus. besides defaultDeviceRules, the job may access
+// Config.AllowedDevices and the devices of its gpus.
+func (c *Config) deviceRules(gpus []string) ([]deviceRule, error) {
+	paths := append([]string(nil), c.AllowedDevices...)
+	for _, name := range gpus {
+		paths = append(paths, c.GPUs[name].Devices...)
+	}
+
+	rules := append([]deviceRule(nil), defaultDeviceRules...)
+	for _, path := range paths {
+		rule, err := deviceRuleOf(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// validateAllowedDevices ensures that every path in devices is a device node
+func validateAllowedDevices(devices []string) error {
+	for _, path := range devices {
+		if _, err := deviceRuleOf(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package worker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// deviceRuleOf returns a rule that permits reading and writing the device at
+// path
+func deviceRuleOf(path string) (deviceRule, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return deviceRule{}, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || fi.Mode()&os.ModeDevice == 0 {
+		return deviceRule{}, fmt.Errorf("%w: %s", ErrNotDevice, path)
+	}
+
+	typ := deviceBlock
+	if fi.Mode()&os.ModeCharDevice != 0 {
+		typ = deviceChar
+	}
+
+	return deviceRule{
+		typ:    typ,
+		major:  int64(unix.Major(st.Rdev)),
+		minor:  int64(unix.Minor(st.Rdev)),
+		access: deviceRWM,
+	}, nil
+}
+
+// bpfInsn is struct bpf_insn
+type bpfInsn struct {
+	code uint8
+	regs uint8 // dst_reg in the low nibble, src_reg in the high one
+	off  int16
+	imm  int32
+}
+
+// the registers used by the device program
+const (
+	bpfR0 = iota // the return value
+	bpfR1        // the struct bpf_cgroup_dev_ctx
+	bpfR2        // access_type
+	bpfR3        // the device type
+	bpfR4        // the access requested
+	bpfR5        // major
+	bpfR6        // minor
+	bpfR7        // scratch
+)
+
+// the instructions used by the device program
+const (
+	bpfLoadW   = unix.BPF_LDX | unix.BPF_MEM | unix.BPF_W
+	bpfMovReg  = unix.BPF_ALU64 | unix.BPF_MOV | unix.BPF_X
+	bpfMovImm  = unix.BPF_ALU64 | unix.BPF_MOV | unix.BPF_K
+	bpfAndImm  = unix.BPF_ALU64 | unix.BPF_AND | unix.BPF_K
+	bpfRshImm  = unix.BPF_ALU64 | unix.BPF_RSH | unix.BPF_K
+	bpfJumpNe  = unix.BPF_JMP | unix.BPF_JNE | unix.BPF_K
+	bpfExit    = unix.BPF_JMP | unix.BPF_EXIT
+	bpfToNext  = -1 // the jumps to the next rule are filled in later
+	devCtxSize = 4  // the size of each field of struct bpf_cgroup_dev_ctx
+)
+
+// deviceProgram assembles a BPF_PROG_TYPE_CGROUP_DEVICE program that returns
+// 1, permitting access, if any of rules match the device, and 0 otherwise
+func deviceProgram(rules []deviceRule) []bpfInsn {
+	insn := func(code uint8, dst, src uint8, off int16, imm int32) bpfInsn {
+		return bpfInsn{code: code, regs: dst | src<<4, off: off, imm: imm}
+	}
+
+	prog := []bpfInsn{
+		insn(bpfLoadW, bpfR2, bpfR1, 0, 0),
+		insn(bpfMovReg, bpfR3, bpfR2, 0, 0),
+		insn(bpfAndImm, bpfR3, 0, 0, 0xffff),
+		insn(bpfMovReg, bpfR4, bpfR2, 0, 0),
+		insn(bpfRshImm, bpfR4, 0, 0, 16),
+		insn(bpfLoadW, bpfR5, bpfR1, devCtxSize, 0),
+		insn(bpfLoadW, bpfR6, bpfR1, 2*devCtxSize, 0),
+	}
+
+	for _, r := range rules {
+		block := []bpfInsn{
+			insn(bpfJumpNe, bpfR3, 0, bpfToNext, int32(r.typ)),
+
+			// the access requested must be a subset of the rule's
+			insn(bpfMovReg, bpfR7, bpfR4, 0, 0),
+			insn(bpfAndImm, bpfR7, 0, 0, int32(^r.access&deviceRWM)),
+			insn(bpfJumpNe, bpfR7, 0, bpfToNext, 0),
+		}
+
+		if r.major != anyDevice {
+			block = append(block, insn(bpfJumpNe, bpfR5, 0, bpfToNext, int32(r.major)))
+		}
+
+		if r.minor != anyDevice {
+			block = append(block, insn(bpfJumpNe, bpfR6, 0, bpfToNext, int32(r.minor)))
+		}
+
+		block = append(block,
+			insn(bpfMovImm, bpfR0, 0, 0, 1),
+			insn(bpfExit, 0, 0, 0, 0),
+		)
+
+		for i := range block {
+			if block[i].off == bpfToNext {
+				block[i].off = int16(len(block) - i - 1)
+			}
+		}
+
+		prog = append(prog, block...)
+	}
+
+	return append(prog,
+		insn(bpfMovImm, bpfR0, 0, 0, 0),
+		insn(bpfExit, 0, 0, 0, 0),
+	)
+}
+
+// bpf calls bpf(2) with attr, which must be a pointer to a struct
+func bpf(cmd uintptr, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, cmd, uintptr(attr), size)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// loadDeviceProgram loads the device program for rules into the kernel and
+// returns its fd
+func loadDeviceProgram(rules []deviceRule) (int, error) {
+	prog := deviceProgram(rules)
+
+	insns := make([]byte, 0, 8*len(prog))
+	for _, in := range prog {
+		insns = append(insns, in.code, in.regs)
+		insns = binary.NativeEndian.AppendUint16(insns, uint16(in.off))
+		insns = binary.NativeEndian.AppendUint32(insns, uint32(in.imm))
+	}
+
+	license := []byte("BSD\x00")
+
+	// the prefix of union bpf_attr used by BPF_PROG_LOAD
+	attr := struct {
+		progType    uint32
+		insnCnt     uint32
+		insns       uint64
+		license     uint64
+		logLevel    uint32
+		logSize     uint32
+		logBuf      uint64
+		kernVersion uint32
+		progFlags   uint32
+	}{
+		progType: unix.BPF_PROG_TYPE_CGROUP_DEVICE,
+		insnCnt:  uint32(len(prog)),
+		insns:    uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+	}
+
+	return bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+// setDevicePolicy attaches a device program to the cgroup that is open as dir,
+// so that the processes in it may only access the devices permitted by rules.
+// the program stays attached until the cgroup is removed.
+func setDevicePolicy(dir *os.File, rules []deviceRule) error {
+	prog, err := loadDeviceProgram(rules)
+	if err != nil {
+		return fmt.Errorf("error loading device program: %w", err)
+	}
+	defer func() { _ = unix.Close(prog) }()
+
+	// the prefix of union bpf_attr used by BPF_PROG_ATTACH
+	attr := struct {
+		targetFD    uint32
+		attachBPFFD uint32
+		attachType  uint32
+		attachFlags uint32
+	}{
+		targetFD:    uint32(dir.Fd()),
+		attachBPFFD: uint32(prog),
+		attachType:  unix.BPF_CGROUP_DEVICE,
+	}
+
+	if _, err = bpf(unix.BPF_PROG_ATTACH, unsafe.Pointer(&attr), unsafe.Sizeof(attr)); err != nil {
+		return fmt.Errorf("error attaching device program: %w", err)
+	}
+
+	return nil
+}
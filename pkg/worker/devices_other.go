@@ -0,0 +1,26 @@
+//go:build !linux
+
+package worker
+
+import (
+	"errors"
+	"os"
+)
+
+// deviceRuleOf is here for all non-linux builds but does nothing and exists
+// only to make builds work
+func deviceRuleOf(string) (deviceRule, error) {
+	return deviceRule{}, nil
+}
+
+// setDevicePolicy is here for all non-linux builds but does nothing and exists
+// only to make builds work
+func setDevicePolicy(*os.File, []deviceRule) error {
+	return nil
+}
+
+// loadDeviceProgram is here for all non-linux builds but always fails and
+// exists only to make builds work
+func loadDeviceProgram([]deviceRule) (int, error) {
+	return -1, errors.ErrUnsupported
+}
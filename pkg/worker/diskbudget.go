@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/wal"
+)
+
+// ErrDiskBudgetExceeded is the error of a durable job whose output could not
+// be written because Config.WALDiskBudget was reached and there was no
+// completed job data left to clean up
+var ErrDiskBudgetExceeded = errors.New("wal disk budget exceeded")
+
+// DiskUsage describes the disk space used by the write-ahead logs in WALDir
+type DiskUsage struct {
+	Used   int64 // the total size, in bytes, of the write-ahead logs
+	Budget int64 // Config.WALDiskBudget, 0 means unlimited
+	Logs   int   // the number of write-ahead logs
+}
+
+// walFile is a write-ahead log that counts towards the disk budget
+type walFile struct {
+	job       *job.Job
+	size      int64
+	lastWrite time.Time
+}
+
+// diskBudget tracks the size of the write-ahead logs in WALDir and enforces
+// Config.WALDiskBudget. when a write would exceed the budget, the logs of
+// completed jobs are removed, least recently written first, to make room.
+// their output remains available until the Worker restarts.
+type diskBudget struct {
+	w *Worker
+
+	mu    sync.Mutex
+	used  int64
+	files map[job.ID]*walFile
+}
+
+// add starts tracking the write-ahead log of j, whose size is currently size
+func (b *diskBudget) add(j *job.Job, size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.files == nil {
+		b.files = map[job.ID]*walFile{}
+	}
+
+	b.files[j.ID()] = &walFile{job: j, size: size, lastWrite: b.w.clock.Now()}
+	b.used += size
+}
+
+// remove stops tracking the write-ahead log of jobID, it must be called when
+// the log is removed
+func (b *diskBudget) remove(jobID job.ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f, ok := b.files[jobID]; ok {
+		b.used -= f.size
+		delete(b.files, jobID)
+	}
+}
+
+// reserve accounts n more bytes to the log of jobID. if that exceeds the
+// budget, the logs of completed jobs are removed until it doesn't. if that
+// isn't enough, ErrDiskBudgetExceeded is returned and nothing is reserved.
+func (b *diskBudget) reserve(jobID job.ID, n int) error {
+	budget := b.w.cfg.WALDiskBudget
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, ok := b.files[jobID]
+	if !ok {
+		return nil
+	}
+
+	for budget > 0 && b.used+int64(n) > budget {
+		if !b.evictLocked() {
+			return ErrDiskBudgetExceeded
+		}
+	}
+
+	b.useLocked(f, n)
+	return nil
+}
+
+// use accounts n more bytes to the log of jobID regardless of the budget
+func (b *diskBudget) use(jobID job.ID, n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f, ok := b.files[jobID]; ok {
+		b.useLocked(f, n)
+	}
+}
+
+// useLocked accounts n more bytes to f. b.mu must be held.
+func (b *diskBudget) useLocked(f *walFile, n int) {
+	f.size += int64(n)
+	f.lastWrite = b.w.clock.Now()
+	b.used += int64(n)
+}
+
+// evictLocked removes the least recently written log of a completed job. it
+// returns false if there is none. b.mu must be held.
+func (b *diskBudget) evictLocked() bool {
+	var oldest *walFile
+	for _, f := range b.files {
+		select {
+		case <-f.job.Done():
+		default:
+			continue
+		}
+
+		if oldest == nil || f.lastWrite.Before(oldest.lastWrite) {
+			oldest = f
+		}
+	}
+
+	if oldest == nil {
+		return false
+	}
+
+	path := b.w.walPath(oldest.job.ID())
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("error removing wal", "path", path, "err", err)
+		return false
+	}
+
+	slog.Info("removed wal to stay within disk budget", "job_id", oldest.job.ID())
+
+	b.used -= oldest.size
+	delete(b.files, oldest.job.ID())
+
+	return true
+}
+
+// usage returns the current disk usage
+func (b *diskBudget) usage() DiskUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return DiskUsage{
+		Used:   b.used,
+		Budget: b.w.cfg.WALDiskBudget,
+		Logs:   len(b.files),
+	}
+}
+
+// walQuota is the wal.Quota of a single job
+type walQuota struct {
+	budget *diskBudget
+	jobID  job.ID
+}
+
+// ensure walQuota implements the wal.Quota interface
+var _ wal.Quota = walQuota{}
+
+// Reserve is the wal.Quota interface
+func (q walQuota) Reserve(n int) error {
+	return q.budget.reserve(q.jobID, n)
+}
+
+// Use is the wal.Quota interface
+func (q walQuota) Use(n int) {
+	q.budget.use(q.jobID, n)
+}
+
+// DiskUsage returns the disk space used by the write-ahead logs of durable
+// jobs. It must only be exposed to administrators.
+func (w *Worker) DiskUsage() DiskUsage {
+	return w.disk.usage()
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Environment are the standard variables that are set for every job. Empty
+// fields are not set.
+type Environment struct {
+	Path   string // PATH
+	Home   string // HOME
+	TmpDir string // TMPDIR
+	Lang   string // LANG, the locale
+}
+
+// merge returns e with any fields that are set in o replaced
+func (e Environment) merge(o Environment) Environment {
+	if o.Path != "" {
+		e.Path = o.Path
+	}
+	if o.Home != "" {
+		e.Home = o.Home
+	}
+	if o.TmpDir != "" {
+		e.TmpDir = o.TmpDir
+	}
+	if o.Lang != "" {
+		e.Lang = o.Lang
+	}
+	return e
+}
+
+// vars returns the fields that are set as "key=value" variables
+func (e Environment) vars() []string {
+	var ret []string
+	for _, v := range []struct{ key, value string }{
+		{"PATH", e.Path},
+		{"HOME", e.Home},
+		{"TMPDIR", e.TmpDir},
+		{"LANG", e.Lang},
+	} {
+		if v.value != "" {
+			ret = append(ret, v.key+"="+v.value)
+		}
+	}
+	return ret
+}
+
+// ErrInvalidEnv is returned by StartJob if a variable in JobOptions.Env is not
+// in the form "key=value"
+var ErrInvalidEnv = errors.New("env variables must be in the form key=value")
+
+// envAllowlist returns the names of the variables passed on to jobs
+func (c *Config) envAllowlist() []string {
+	if c.EnvAllowlist == nil {
+		return DefaultEnvAllowlist
+	}
+	return c.EnvAllowlist
+}
+
+// validateEnv ensures that each variable in env is in the form "key=value"
+func validateEnv(env []string) error {
+	for _, kv := range env {
+		if key, _, ok := strings.Cut(kv, "="); !ok || key == "" {
+			return ErrInvalidEnv
+		}
+	}
+	return nil
+}
+
+// jobEnv returns the environment a job runs with. it is the standard
+// variables, followed by the allowlisted variables from the Worker's
+// environment, followed by opts.Env. later variables take precedence. nothing
+// else is inherited from the Worker, so that secrets like credentials aren't
+// leaked to jobs.
+func (w *Worker) jobEnv(opts *JobOptions) []string {
+	allowed := w.cfg.envAllowlist()
+
+	env := DefaultEnvironment.
+		merge(w.cfg.Environment).
+		merge(opts.Environment).
+		vars()
+
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		for _, a := range allowed {
+			if envKey(key) == envKey(a) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+
+	return dedupEnv(append(env, opts.Env...))
+}
+
+// dedupEnv removes all but the last value of each variable in env, like
+// exec.Cmd does. it is needed since the job is started with syscall.Exec and
+// getenv(3) typically returns the first value.
+func dedupEnv(env []string) []string {
+	seen := make(map[string]bool, len(env))
+	ret := make([]string, 0, len(env))
+
+	for i := len(env) - 1; i >= 0; i-- {
+		key, _, _ := strings.Cut(env[i], "=")
+		key = envKey(key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ret = append(ret, env[i])
+	}
+
+	slices.Reverse(ret)
+	return ret
+}
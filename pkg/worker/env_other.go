@@ -0,0 +1,23 @@
+//go:build !windows
+
+package worker
+
+// DefaultEnvironment is the minimal environment jobs run with. Fields that are
+// set in Config.Environment or JobOptions.Environment override it.
+var DefaultEnvironment = Environment{
+	Path:   "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	Home:   "/",
+	TmpDir: "/tmp",
+	Lang:   "C.UTF-8",
+}
+
+// DefaultEnvAllowlist are the variables from the Worker's environment that are
+// passed on to jobs if Config.EnvAllowlist is nil. It is empty so that jobs
+// don't depend on the environment the Worker happened to be started with.
+var DefaultEnvAllowlist = []string{}
+
+// envKey returns key as it is compared to other variable names, which are case
+// sensitive
+func envKey(key string) string {
+	return key
+}
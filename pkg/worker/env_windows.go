@@ -0,0 +1,28 @@
+package worker
+
+import "strings"
+
+// DefaultEnvironment is the minimal environment jobs run with. Fields that are
+// set in Config.Environment or JobOptions.Environment override it.
+var DefaultEnvironment = Environment{
+	Path: `C:\Windows\System32;C:\Windows;C:\Windows\System32\Wbem;C:\Windows\System32\WindowsPowerShell\v1.0`,
+}
+
+// DefaultEnvAllowlist are the variables from the Worker's environment that are
+// passed on to jobs if Config.EnvAllowlist is nil. Unlike other platforms,
+// many programs on windows don't work without these.
+var DefaultEnvAllowlist = []string{
+	"SYSTEMROOT",
+	"SYSTEMDRIVE",
+	"WINDIR",
+	"COMSPEC",
+	"PATHEXT",
+	"TEMP",
+	"TMP",
+}
+
+// envKey returns key as it is compared to other variable names, which are case
+// insensitive on windows
+func envKey(key string) string {
+	return strings.ToUpper(key)
+}
@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// ExportFormat is the format ExportJobs writes job records in
+type ExportFormat int
+
+// NOTE: keep this synced with jobworker.proto:ExportFormat
+const (
+	ExportFormatJSONL ExportFormat = iota // one JSON object per line
+	ExportFormatCSV                       // comma separated values with a header row
+)
+
+// ErrInvalidExportFormat is returned by ExportJobs if the format is not one of
+// the ExportFormats
+var ErrInvalidExportFormat = errors.New("invalid export format")
+
+// exportRecord is the exported form of a JobRecord. it only contains simple
+// values so that it can be written as either JSON or CSV.
+type exportRecord struct {
+	JobID          string            `json:"job_id"`
+	UserID         string            `json:"user_id"`
+	Tenant         string            `json:"tenant,omitempty"`
+	Historical     bool              `json:"historical"`
+	Status         string            `json:"status"`
+	StopReason     string            `json:"stop_reason,omitempty"`
+	Reason         string            `json:"reason,omitempty"`
+	ExitCode       *int              `json:"exit_code,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	StartTime      string            `json:"start_time,omitempty"`
+	EndTime        string            `json:"end_time,omitempty"`
+	RuntimeSeconds float64           `json:"runtime_seconds"`
+	Description    string            `json:"description,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	CorrelationID  string            `json:"correlation_id,omitempty"`
+	Queue          string            `json:"queue,omitempty"`
+}
+
+// exportHeader is the header row of CSV exports. annotations are not included
+// since they don't have a fixed set of columns.
+var exportHeader = []string{
+	"job_id", "user_id", "tenant", "historical", "status", "stop_reason",
+	"reason", "exit_code", "error", "start_time", "end_time",
+	"runtime_seconds", "description", "correlation_id", "queue",
+}
+
+// formatTime returns t in RFC 3339 format, or an empty string if it is zero
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// newExportRecord returns the exported form of r
+func newExportRecord(r *JobRecord) *exportRecord {
+	e := exportRecord{
+		JobID:          r.ID.String(),
+		UserID:         r.UserID.String(),
+		Tenant:         r.Tenant.String(),
+		Historical:     r.Historical,
+		Status:         r.Status.String(),
+		StartTime:      formatTime(r.StartTime),
+		EndTime:        formatTime(r.EndTime),
+		RuntimeSeconds: r.Runtime.Seconds(),
+		Description:    r.Description,
+		Annotations:    r.Annotations,
+		CorrelationID:  r.CorrelationID,
+		Queue:          r.Queue,
+	}
+
+	if r.StopReason != job.StopReasonNone {
+		e.StopReason = r.StopReason.String()
+	}
+
+	if r.Reason != ReasonNone {
+		e.Reason = r.Reason.String()
+	}
+
+	if r.ExitCode != nil {
+		v := r.ExitCode.Int()
+		e.ExitCode = &v
+	}
+
+	if r.Error != nil {
+		e.Error = r.Error.Error()
+	}
+
+	return &e
+}
+
+// row returns the CSV row of e, in the order of exportHeader
+func (e *exportRecord) row() []string {
+	var exitCode string
+	if e.ExitCode != nil {
+		exitCode = strconv.Itoa(*e.ExitCode)
+	}
+
+	return []string{
+		e.JobID, e.UserID, e.Tenant, strconv.FormatBool(e.Historical), e.Status,
+		e.StopReason, e.Reason, exitCode, e.Error, e.StartTime, e.EndTime,
+		strconv.FormatFloat(e.RuntimeSeconds, 'f', -1, 64), e.Description,
+		e.CorrelationID, e.Queue,
+	}
+}
+
+// ExportJobs writes, oldest first, the records of all of the jobs in tenant,
+// regardless of user, that match q, which may be nil, to out in format. Both
+// active and historical jobs are included unless q selects one of them. If
+// tenant is empty, jobs from all tenants are included. It is intended for
+// offline reporting and must only be exposed to administrators of tenant.
+func (w *Worker) ExportJobs(out io.Writer, tenant job.TenantID, format ExportFormat, q *ListJobsQuery) error {
+	var write func(*exportRecord) error
+	var flush func() error
+
+	switch format {
+	case ExportFormatJSONL:
+		enc := json.NewEncoder(out)
+		write = func(e *exportRecord) error { return enc.Encode(e) }
+		flush = func() error { return nil }
+	case ExportFormatCSV:
+		cw := csv.NewWriter(out)
+		if err := cw.Write(exportHeader); err != nil {
+			return err
+		}
+		write = func(e *exportRecord) error { return cw.Write(e.row()) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return ErrInvalidExportFormat
+	}
+
+	records := w.listJobs(q,
+		func(j *job.Job) bool { return tenant == "" || j.TenantID() == tenant },
+		func(r *JobRecord) bool { return tenant == "" || r.Tenant == tenant },
+	)
+
+	for i := range records {
+		if err := write(newExportRecord(&records[i])); err != nil {
+			return err
+		}
+	}
+
+	return flush()
+}
@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// GPU is a gpu, configured in Config.GPUs, that jobs may be granted access to
+// with JobOptions.GPUs. The device nodes of the gpus that a job isn't granted
+// are masked in its mount namespace.
+type GPU struct {
+	// Devices are the device nodes that give access to the gpu, e.g.
+	// /dev/nvidia0. Nodes shared by all gpus, like /dev/nvidiactl, should be
+	// listed for each of them.
+	Devices []string
+
+	// Users are the users that may be granted the gpu, AnyUser permits every
+	// user. Since gpus are scarce, it should normally only list
+	// administrators, who start jobs on behalf of others.
+	Users []job.UserID
+}
+
+var (
+	// ErrGPUNotFound is returned when a job requests a gpu that isn't
+	// configured in Config.GPUs
+	ErrGPUNotFound = errors.New("gpu not found")
+
+	// ErrGPUNotPermitted is returned when a job requests a gpu that its user
+	// isn't listed in
+	ErrGPUNotPermitted = errors.New("gpu not permitted")
+
+	// ErrInvalidGPUDevice is returned by New if a device of a gpu is not a
+	// character device
+	ErrInvalidGPUDevice = errors.New("gpu device must be a character device")
+)
+
+// validateGPUs ensures that every device of every gpu is a character device
+func validateGPUs(gpus map[string]GPU) error {
+	for name, gpu := range gpus {
+		for _, dev := range gpu.Devices {
+			fi, err := os.Stat(dev)
+			if err != nil {
+				return fmt.Errorf("gpu %q: %w", name, err)
+			}
+
+			if fi.Mode()&os.ModeCharDevice == 0 {
+				return fmt.Errorf("%w: gpu %q: %s", ErrInvalidGPUDevice, name, dev)
+			}
+		}
+	}
+	return nil
+}
+
+// gpuDevices returns the device nodes that a job granted the gpus named in
+// names must not have access to. devices shared with a granted gpu are
+// accessible.
+func (c *Config) gpuDevices(userID job.UserID, names []string) (masked []string, err error) {
+	var granted []string
+	for _, name := range names {
+		gpu, ok := c.GPUs[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrGPUNotFound, name)
+		}
+
+		if !slices.Contains(gpu.Users, AnyUser) && !slices.Contains(gpu.Users, userID) {
+			return nil, fmt.Errorf("%w: %q", ErrGPUNotPermitted, name)
+		}
+
+		granted = append(granted, gpu.Devices...)
+	}
+
+	for _, gpu := range c.GPUs {
+		for _, dev := range gpu.Devices {
+			if !slices.Contains(granted, dev) && !slices.Contains(masked, dev) {
+				masked = append(masked, dev)
+			}
+		}
+	}
+
+	// sorted so that the order doesn't depend on the map
+	slices.Sort(masked)
+	return masked, nil
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"io"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/audit"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// Job is a handle to a job for Go programs that embed a Worker, rather than
+// use it through the gRPC api. Like a context.Context, its Done channel is
+// closed once the job is done, and Err then describes how it ended. The handle
+// acts as the user it was obtained for. A job that is restarted by its
+// RestartPolicy is a new attempt, each of which needs its own handle.
+type Job struct {
+	w      *Worker
+	tenant job.TenantID
+	userID job.UserID
+	job    *job.Job
+}
+
+// Job returns a handle to the job identified by jobID. If the job does not
+// exist, or if the user has not been granted at least read access,
+// ErrJobNotFound will be returned.
+func (w *Worker) Job(tenant job.TenantID, userID job.UserID, jobID job.ID) (_ *Job, err error) {
+	defer func() { w.record(audit.ActionJobStatus, tenant, userID, jobID, err) }()
+
+	j, err := w.getJob(tenant, userID, jobID, job.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{w: w, tenant: tenant, userID: userID, job: j}, nil
+}
+
+// WaitJob is like Job, but blocks until the job is done or ctx is done,
+// whichever comes first. If ctx is done first, its error is returned.
+func (w *Worker) WaitJob(ctx context.Context, tenant job.TenantID, userID job.UserID, jobID job.ID) (*Job, error) {
+	h, err := w.Job(tenant, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-h.Done():
+		return h, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ID returns the id of the job
+func (h *Job) ID() job.ID {
+	return h.job.ID()
+}
+
+// Done returns a channel that is closed once the job is done, whether it
+// completed, was stopped or failed to start
+func (h *Job) Done() <-chan struct{} {
+	return h.job.Done()
+}
+
+// Err returns nil until Done is closed. Afterwards it returns the error the job
+// failed with, e.g. an *exec.ExitError for a non-zero exit code, or nil if it
+// succeeded.
+func (h *Job) Err() error {
+	return h.job.Error()
+}
+
+// ExitCode returns the process's exit code and true if the process exited on
+// its own. It returns false until Done is closed, or if the process never
+// started or was terminated by a signal.
+func (h *Job) ExitCode() (job.ExitCode, bool) {
+	return h.job.ExitCode()
+}
+
+// Status returns the current status of the job, like Worker.JobStatus
+func (h *Job) Status() *StatusResponse {
+	return h.w.jobStatus(h.job)
+}
+
+// OutputReader returns an io.ReadCloser that streams the output of the job
+// from the beginning, like Worker.JobOutput. It is the responsibility of the
+// caller to close it. ErrJobNotFound is returned if the user's access to the
+// job has since been revoked.
+func (h *Job) OutputReader() (_ io.ReadCloser, err error) {
+	defer func() { h.w.record(audit.ActionJobOutput, h.tenant, h.userID, h.job.ID(), err) }()
+
+	if h.job.Access(h.userID) == job.AccessNone {
+		return nil, ErrJobNotFound
+	}
+
+	return h.job.NewOutputReader(), nil
+}
@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// DefaultHistorySize is the number of removed jobs retained in the history if
+// Config.HistorySize is 0
+const DefaultHistorySize = 1000
+
+// JobState selects which jobs ListJobs returns
+type JobState int
+
+// NOTE: keep this synced with jobworker.proto:JobState
+const (
+	JobStateAll     JobState = iota // both active and historical jobs
+	JobStateActive                  // jobs that have not been removed, whether or not they are running
+	JobStateHistory                 // jobs that have been removed with RemoveJob
+)
+
+// JobRecord summarizes a job for ListJobs
+type JobRecord struct {
+	ID         job.ID
+	UserID     job.UserID
+	Tenant     job.TenantID
+	Historical bool // the job has been removed and only this record remains
+
+	Status     job.Status
+	StopReason job.StopReason
+	Reason     Reason
+	ExitCode   *job.ExitCode
+	Error      error
+
+	StartTime time.Time
+	EndTime   time.Time
+	Runtime   time.Duration
+
+	Description   string
+	Annotations   map[string]string
+	CorrelationID string
+	Queue         string
+}
+
+// ListJobsQuery filters the jobs returned by ListJobs. Zero valued fields
+// match all jobs.
+type ListJobsQuery struct {
+	State JobState
+
+	// Since and Until select the jobs that were running at any time between
+	// them, e.g. to find what ran last night
+	Since time.Time // inclusive
+	Until time.Time // exclusive
+
+	// CorrelationID selects the jobs started with it
+	CorrelationID string
+
+	// Queue selects the jobs scheduled in it
+	Queue string
+}
+
+// matches returns true if r is matched by the filters in q
+func (q *ListJobsQuery) matches(r *JobRecord) bool {
+	switch {
+	case q.State == JobStateActive && r.Historical,
+		q.State == JobStateHistory && !r.Historical,
+		q.CorrelationID != "" && r.CorrelationID != q.CorrelationID,
+		q.Queue != "" && r.Queue != q.Queue,
+		!q.Until.IsZero() && !r.StartTime.Before(q.Until),
+		!q.Since.IsZero() && !r.EndTime.IsZero() && r.EndTime.Before(q.Since):
+		return false
+	}
+	return true
+}
+
+// history retains the records of a fixed number of the most recently removed
+// jobs
+type history struct {
+	mu         sync.RWMutex
+	records    []JobRecord
+	maxRecords int
+}
+
+// add adds r to the history, discarding the oldest record if it is full
+func (h *history) add(r JobRecord) {
+	maxRecords := h.maxRecords
+	if maxRecords <= 0 {
+		maxRecords = DefaultHistorySize
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.records) >= maxRecords {
+		h.records = append(h.records[:0], h.records[1:]...)
+	}
+	h.records = append(h.records, r)
+}
+
+// list returns the records for which include returns true
+func (h *history) list(include func(*JobRecord) bool) []JobRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var ret []JobRecord
+	for i := range h.records {
+		if r := &h.records[i]; include(r) {
+			ret = append(ret, *r)
+		}
+	}
+	return ret
+}
+
+// jobRecord returns the record describing the current state of j
+func (w *Worker) jobRecord(j *job.Job) JobRecord {
+	st := w.jobStatus(j)
+	return JobRecord{
+		ID:            j.ID(),
+		UserID:        j.UserID(),
+		Tenant:        j.TenantID(),
+		Status:        st.Status,
+		StopReason:    st.StopReason,
+		Reason:        st.Reason,
+		ExitCode:      st.ExitCode,
+		Error:         st.Error,
+		StartTime:     st.StartTime,
+		EndTime:       st.EndTime,
+		Runtime:       st.Runtime,
+		Description:   st.Description,
+		Annotations:   st.Annotations,
+		CorrelationID: st.CorrelationID,
+		Queue:         st.Queue,
+	}
+}
+
+// ListJobs returns, oldest first, the jobs of tenant that userID has access to
+// and that match q, which may be nil. Once a job is removed with RemoveJob, its record
+// moves to a history of the Config.HistorySize most recently removed jobs. Only
+// the owner of a job can list its record once it has been removed.
+func (w *Worker) ListJobs(tenant job.TenantID, userID job.UserID, q *ListJobsQuery) []JobRecord {
+	return w.listJobs(q,
+		func(j *job.Job) bool { return j.TenantID() == tenant && j.Access(userID) != job.AccessNone },
+		func(r *JobRecord) bool { return r.Tenant == tenant && r.UserID == userID },
+	)
+}
+
+// listJobs returns, oldest first, the records matching q, which may be nil, of
+// the active jobs for which active returns true and of the historical jobs for
+// which historical returns true
+func (w *Worker) listJobs(q *ListJobsQuery, active func(*job.Job) bool, historical func(*JobRecord) bool) []JobRecord {
+	if q == nil {
+		q = &ListJobsQuery{}
+	}
+
+	var ret []JobRecord
+
+	if q.State != JobStateHistory {
+		jobs := slices.DeleteFunc(w.jobs.all(), func(j *job.Job) bool {
+			return !active(j)
+		})
+
+		for _, j := range jobs {
+			if r := w.jobRecord(j); q.matches(&r) {
+				ret = append(ret, r)
+			}
+		}
+	}
+
+	if q.State != JobStateActive {
+		ret = append(ret, w.history.list(func(r *JobRecord) bool {
+			return historical(r) && q.matches(r)
+		})...)
+	}
+
+	slices.SortStableFunc(ret, func(a, b JobRecord) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+
+	return ret
+}
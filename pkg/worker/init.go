@@ -0,0 +1,93 @@
+//go:build !windows
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// runInit starts the job's command as a child of the current process, which
+// remains pid 1 of the job's pid namespace. Orphaned processes in the
+// namespace are reparented to it, so it reaps them, as well as forwarding
+// signals to the command, until the command exits. It then exits with the
+// command's exit code, or 128 plus the number of the signal that terminated
+// it, which kills every other process in the namespace. It only returns if the
+// command couldn't be started.
+func runInit(setup *os.File, spec *childSpec) error {
+	// signals are caught before the command is started so that none are lost
+	signals := make(chan os.Signal, 16)
+	signal.Notify(signals)
+
+	cmd := exec.Cmd{
+		Path:   spec.Args[0],
+		Args:   spec.Args,
+		Env:    spec.Env,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		ExtraFiles: []*os.File{
+			os.NewFile(job.ResultFD, "result"),
+			os.NewFile(job.ProgressFD, "progress"),
+		},
+	}
+
+	if err := cmd.Start(); err != nil {
+		signal.Reset()
+		return childError(setup, fmt.Errorf("error starting command: %w", err))
+	}
+
+	// the command has been executed, so the parent must see the setup pipes
+	// close, just as if the command had replaced the current process
+	if setup != nil {
+		_ = setup.Close()
+	}
+	closeChildLog()
+
+	go forwardSignals(signals, cmd.Process.Pid)
+
+	os.Exit(reap(cmd.Process.Pid))
+	return nil
+}
+
+// forwardSignals sends every signal received on signals to pid, except those
+// that are only meaningful to the current process
+func forwardSignals(signals <-chan os.Signal, pid int) {
+	for sig := range signals {
+		s, ok := sig.(syscall.Signal)
+		if !ok || s == syscall.SIGCHLD || s == syscall.SIGURG {
+			// SIGURG is used internally by the go runtime
+			continue
+		}
+		_ = syscall.Kill(pid, s)
+	}
+}
+
+// reap waits for every child of the current process until pid exits and
+// returns the exit code that describes how it exited
+func reap(pid int) int {
+	for {
+		var ws syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			// there are no children left to wait for
+			return 1
+		}
+		if wpid != pid {
+			continue
+		}
+		if ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return ws.ExitStatus()
+	}
+}
@@ -0,0 +1,12 @@
+package worker
+
+import (
+	"errors"
+	"os"
+)
+
+// runInit is not supported on windows, where jobs are run in job objects
+// instead of by a child
+func runInit(*os.File, *childSpec) error {
+	return errors.New("init is not supported on windows")
+}
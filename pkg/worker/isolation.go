@@ -0,0 +1,213 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// The names of the built in isolation profiles, see DefaultIsolationProfiles
+const (
+	IsolationNone   = "none"
+	IsolationBasic  = "basic"
+	IsolationStrict = "strict"
+)
+
+// AnyUser may be listed in IsolationProfile.Users to permit every user to
+// select the profile
+const AnyUser job.UserID = "*"
+
+// IsolationProfile bundles how jobs that use it are isolated from the host.
+// Profiles are configured in Config.IsolationProfiles and selected per job
+// with JobOptions.IsolationProfile. They only apply on linux.
+type IsolationProfile struct {
+	// Namespaces are the namespaces jobs are created in. /proc is only
+	// remounted, and files are only bind mounted, in a new mount namespace.
+	Namespaces job.Namespace
+
+	// Proc hardens /proc in addition to Config.Proc
+	Proc ProcPolicy
+
+	// Seccomp installs a seccomp filter that fails syscalls that jobs have no
+	// business making, e.g. mount(2), ptrace(2) and kexec_load(2), with EPERM
+	Seccomp bool
+
+	// Capabilities, if not nil, are the only capabilities, e.g. "CAP_CHOWN",
+	// that jobs may have, all others are dropped from the bounding set
+	Capabilities []string
+
+	// Users are the users that may select the profile with
+	// JobOptions.IsolationProfile, AnyUser permits every user. Jobs that
+	// don't select a profile use Config.IsolationProfile regardless.
+	Users []job.UserID
+}
+
+// DefaultCapabilities are the capabilities that jobs using the strict profile
+// keep
+var DefaultCapabilities = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_SYS_CHROOT",
+	"CAP_MKNOD",
+	"CAP_AUDIT_WRITE",
+	"CAP_SETFCAP",
+}
+
+// DefaultIsolationProfiles are the built in profiles, which may be overridden
+// in Config.IsolationProfiles. Basic is how jobs have always been isolated.
+// Strict also hides other processes, makes /proc read only, installs a seccomp
+// filter and drops capabilities. None runs jobs in the host's namespaces and
+// may only be selected by users that are listed after overriding it.
+var DefaultIsolationProfiles = map[string]IsolationProfile{
+	IsolationNone: {},
+	IsolationBasic: {
+		Namespaces: job.DefaultNamespaces,
+		Users:      []job.UserID{AnyUser},
+	},
+	IsolationStrict: {
+		Namespaces:   job.DefaultNamespaces,
+		Proc:         ProcPolicy{ReadOnly: true, HidePID: true},
+		Seccomp:      true,
+		Capabilities: DefaultCapabilities,
+		Users:        []job.UserID{AnyUser},
+	},
+}
+
+var (
+	// ErrIsolationProfileNotFound is returned when a job selects an isolation
+	// profile that isn't configured
+	ErrIsolationProfileNotFound = errors.New("isolation profile not found")
+
+	// ErrIsolationProfileNotPermitted is returned when a job selects an
+	// isolation profile that its user isn't listed in
+	ErrIsolationProfileNotPermitted = errors.New("isolation profile not permitted")
+
+	// ErrInvalidCapability is returned by New if an IsolationProfile lists an
+	// unknown capability
+	ErrInvalidCapability = errors.New("invalid capability")
+)
+
+// capabilities maps the names of capabilities to their numbers
+var capabilities = map[string]int{
+	"CAP_CHOWN":              0,
+	"CAP_DAC_OVERRIDE":       1,
+	"CAP_DAC_READ_SEARCH":    2,
+	"CAP_FOWNER":             3,
+	"CAP_FSETID":             4,
+	"CAP_KILL":               5,
+	"CAP_SETGID":             6,
+	"CAP_SETUID":             7,
+	"CAP_SETPCAP":            8,
+	"CAP_LINUX_IMMUTABLE":    9,
+	"CAP_NET_BIND_SERVICE":   10,
+	"CAP_NET_BROADCAST":      11,
+	"CAP_NET_ADMIN":          12,
+	"CAP_NET_RAW":            13,
+	"CAP_IPC_LOCK":           14,
+	"CAP_IPC_OWNER":          15,
+	"CAP_SYS_MODULE":         16,
+	"CAP_SYS_RAWIO":          17,
+	"CAP_SYS_CHROOT":         18,
+	"CAP_SYS_PTRACE":         19,
+	"CAP_SYS_PACCT":          20,
+	"CAP_SYS_ADMIN":          21,
+	"CAP_SYS_BOOT":           22,
+	"CAP_SYS_NICE":           23,
+	"CAP_SYS_RESOURCE":       24,
+	"CAP_SYS_TIME":           25,
+	"CAP_SYS_TTY_CONFIG":     26,
+	"CAP_MKNOD":              27,
+	"CAP_LEASE":              28,
+	"CAP_AUDIT_WRITE":        29,
+	"CAP_AUDIT_CONTROL":      30,
+	"CAP_SETFCAP":            31,
+	"CAP_MAC_OVERRIDE":       32,
+	"CAP_MAC_ADMIN":          33,
+	"CAP_SYSLOG":             34,
+	"CAP_WAKE_ALARM":         35,
+	"CAP_BLOCK_SUSPEND":      36,
+	"CAP_AUDIT_READ":         37,
+	"CAP_PERFMON":            38,
+	"CAP_BPF":                39,
+	"CAP_CHECKPOINT_RESTORE": 40,
+}
+
+// isolationProfiles returns DefaultIsolationProfiles overridden by those
+// configured in Config.IsolationProfiles
+func (c *Config) isolationProfiles() map[string]IsolationProfile {
+	ret := maps.Clone(DefaultIsolationProfiles)
+	maps.Copy(ret, c.IsolationProfiles)
+	return ret
+}
+
+// defaultIsolationProfile returns the name of the profile used by jobs that
+// don't select one
+func (c *Config) defaultIsolationProfile() string {
+	if c.IsolationProfile == "" {
+		return IsolationBasic
+	}
+	return c.IsolationProfile
+}
+
+// validateIsolationProfiles ensures that the default profile exists and that
+// every profile only lists known capabilities
+func (c *Config) validateIsolationProfiles() error {
+	profiles := c.isolationProfiles()
+
+	if _, ok := profiles[c.defaultIsolationProfile()]; !ok {
+		return fmt.Errorf("%w: %q", ErrIsolationProfileNotFound, c.defaultIsolationProfile())
+	}
+
+	for name, p := range profiles {
+		for _, capName := range p.Capabilities {
+			if _, ok := capabilities[capName]; !ok {
+				return fmt.Errorf("%w: %q in profile %q", ErrInvalidCapability, capName, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isolationProfile returns the profile that userID's job, started with opts,
+// uses. it is the one selected by opts, if userID is permitted to use it, or
+// Config.IsolationProfile.
+func (c *Config) isolationProfile(userID job.UserID, opts *JobOptions) (*IsolationProfile, error) {
+	profiles := c.isolationProfiles()
+
+	if opts.IsolationProfile == "" || opts.IsolationProfile == c.defaultIsolationProfile() {
+		p := profiles[c.defaultIsolationProfile()]
+		return &p, nil
+	}
+
+	p, ok := profiles[opts.IsolationProfile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrIsolationProfileNotFound, opts.IsolationProfile)
+	}
+
+	if !slices.Contains(p.Users, AnyUser) && !slices.Contains(p.Users, userID) {
+		return nil, fmt.Errorf("%w: %q", ErrIsolationProfileNotPermitted, opts.IsolationProfile)
+	}
+
+	return &p, nil
+}
+
+// capabilityNumbers returns the numbers of the capabilities named in names,
+// which have already been validated
+func capabilityNumbers(names []string) []int {
+	ret := make([]int, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, capabilities[name])
+	}
+	return ret
+}
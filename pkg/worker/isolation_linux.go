@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccompDenylist are the syscalls that the seccomp filter fails with EPERM.
+// they either affect the whole host or let jobs escape their namespaces.
+var seccompDenylist = []uint32{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_BPF,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PTRACE,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+}
+
+// seccompArches maps runtime.GOARCH to the AUDIT_ARCH that the seccomp filter
+// allows, syscalls made with any other calling convention are killed since
+// their numbers differ
+var seccompArches = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}
+
+// the bpf instructions used by the seccomp filter
+const (
+	bpfLoadAbs    = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+	bpfJumpEq     = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+	bpfJumpGe     = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+	bpfReturn     = unix.BPF_RET | unix.BPF_K
+	x32SyscallBit = 0x40000000 // __X32_SYSCALL_BIT
+)
+
+// setSeccomp installs a seccomp filter, on every thread of the current
+// process, that fails the syscalls in seccompDenylist with EPERM. it is
+// inherited by the job after syscall.Exec.
+func setSeccomp() error {
+	arch, ok := seccompArches[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("seccomp is not supported on %s", runtime.GOARCH)
+	}
+
+	// offsets into struct seccomp_data
+	const (
+		nrOffset   = 0
+		archOffset = 4
+	)
+
+	// the jumps to the final instructions are filled in once the length of
+	// the filter is known
+	const (
+		toDeny = 1 << iota
+		toKill
+	)
+
+	type insn struct {
+		unix.SockFilter
+		jt int
+	}
+
+	prog := []insn{
+		{SockFilter: unix.SockFilter{Code: bpfLoadAbs, K: archOffset}},
+		{SockFilter: unix.SockFilter{Code: bpfJumpEq, Jt: 1, K: arch}},
+		{SockFilter: unix.SockFilter{Code: bpfReturn, K: unix.SECCOMP_RET_KILL_PROCESS}},
+		{SockFilter: unix.SockFilter{Code: bpfLoadAbs, K: nrOffset}},
+	}
+
+	// the x32 abi shares AUDIT_ARCH_X86_64 but has its own syscall numbers
+	if runtime.GOARCH == "amd64" {
+		prog = append(prog, insn{SockFilter: unix.SockFilter{Code: bpfJumpGe, K: x32SyscallBit}, jt: toDeny})
+	}
+
+	for _, nr := range seccompDenylist {
+		prog = append(prog, insn{SockFilter: unix.SockFilter{Code: bpfJumpEq, K: nr}, jt: toDeny})
+	}
+
+	prog = append(prog,
+		insn{SockFilter: unix.SockFilter{Code: bpfReturn, K: unix.SECCOMP_RET_ALLOW}},
+		insn{SockFilter: unix.SockFilter{Code: bpfReturn, K: unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)}},
+	)
+
+	deny := len(prog) - 1
+	filter := make([]unix.SockFilter, len(prog))
+	for i, in := range prog {
+		if in.jt == toDeny {
+			in.Jt = uint8(deny - i - 1)
+		}
+		filter[i] = in.SockFilter
+	}
+
+	fprog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER, unix.SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&fprog)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// lastCapability returns the number of the highest capability the kernel
+// supports
+func lastCapability() int {
+	data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return unix.CAP_LAST_CAP
+	}
+
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return unix.CAP_LAST_CAP
+	}
+
+	return last
+}
+
+// dropCapabilities drops every capability that isn't in keep from the
+// bounding, inheritable and ambient sets of the current process so that the
+// job can't have them after syscall.Exec, even though it runs as root
+func dropCapabilities(keep []int) error {
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("error clearing ambient capabilities: %w", err)
+	}
+
+	var mask [2]uint32
+	for c := 0; c <= lastCapability(); c++ {
+		if slices.Contains(keep, c) {
+			mask[c/32] |= 1 << (c % 32)
+			continue
+		}
+
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			return fmt.Errorf("error dropping capability %d: %w", c, err)
+		}
+	}
+
+	// root's permitted capabilities after exec are the bounding set and the
+	// inheritable set combined
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("error getting capabilities: %w", err)
+	}
+
+	for i := range data {
+		data[i].Inheritable &= mask[i]
+	}
+
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("error setting capabilities: %w", err)
+	}
+
+	return nil
+}
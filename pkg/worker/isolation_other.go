@@ -0,0 +1,15 @@
+//go:build !linux
+
+package worker
+
+// setSeccomp is here for all non-linux builds but does nothing and exists
+// only to make builds work
+func setSeccomp() error {
+	return nil
+}
+
+// dropCapabilities is here for all non-linux builds but does nothing and
+// exists only to make builds work
+func dropCapabilities([]int) error {
+	return nil
+}
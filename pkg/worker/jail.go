@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// freebsdOS is the value expected by runtime.GOOS on freebsd
+const freebsdOS = "freebsd"
+
+// jailName returns the name of the jail that j runs in, it is "" on platforms
+// other than freebsd, where jobs don't run in jails
+func jailName(j *job.Job) string {
+	if runtime.GOOS != freebsdOS {
+		return ""
+	}
+	return j.ID().String()
+}
+
+// rctlRules returns the rctl(8) rules that apply l to the jail named jail.
+// zero values are skipped. like cpu.max, pcpu is a percentage of a single cpu.
+func (l *Limits) rctlRules(jail string) []string {
+	var rules []string
+
+	if v := l.CPUMax; v != 0 {
+		rules = append(rules, fmt.Sprintf("jail:%s:pcpu:deny=%d", jail, max(int(v*100), 1)))
+	}
+
+	if v := l.MemoryMax; v != 0 {
+		rules = append(rules, fmt.Sprintf("jail:%s:memoryuse:deny=%d", jail, v))
+	}
+
+	if v := l.RIOPSMax; v != 0 {
+		rules = append(rules, fmt.Sprintf("jail:%s:readiops:throttle=%d", jail, v))
+	}
+
+	if v := l.WIOPSMax; v != 0 {
+		rules = append(rules, fmt.Sprintf("jail:%s:writeiops:throttle=%d", jail, v))
+	}
+
+	return rules
+}
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// the flags of jail_set(2)
+const (
+	jailCreate = 0x01 // JAIL_CREATE
+	jailAttach = 0x04 // JAIL_ATTACH
+)
+
+// createJail creates a jail named name, which shares the host's filesystem,
+// and attaches the current process to it so that it is inherited by the job
+// after syscall.Exec. the jail isn't persistent, it is removed by the kernel
+// once the job, and all of its descendants, have exited.
+func createJail(name string) error {
+	var iov []unix.Iovec
+	for _, p := range [][2]string{
+		{"name", name},
+		{"host.hostname", name},
+		{"path", "/"},
+	} {
+		for _, s := range p {
+			b, err := unix.ByteSliceFromString(s)
+			if err != nil {
+				return err
+			}
+			v := unix.Iovec{Base: &b[0]}
+			v.SetLen(len(b))
+			iov = append(iov, v)
+		}
+	}
+
+	_, _, errno := unix.Syscall(unix.SYS_JAIL_SET, uintptr(unsafe.Pointer(&iov[0])), uintptr(len(iov)), jailCreate|jailAttach)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// rctl calls one of the rctl(2) syscalls, which take a nul terminated rule, or
+// filter, and write nothing when given no output buffer
+func rctl(trap uintptr, rule string) error {
+	b, err := unix.ByteSliceFromString(rule)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := unix.Syscall6(trap, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), 0, 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// addJailRules applies limits to the jail named jail with rctl rules. they can
+// be added before the jail is created, which it is by the child, so that it is
+// limited from the start. existing rules for the same resource are replaced.
+// racct must be enabled, with the kern.racct.enable tunable, for limits to be
+// applied.
+func addJailRules(jail string, limits *Limits) error {
+	for _, rule := range limits.rctlRules(jail) {
+		// the filter is the rule without its amount
+		filter, _, _ := strings.Cut(rule, "=")
+		if err := rctl(unix.SYS_RCTL_REMOVE_RULE, filter); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+
+		if err := rctl(unix.SYS_RCTL_ADD_RULE, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeJailRules removes every rctl rule for the jail named jail
+func removeJailRules(jail string) error {
+	err := rctl(unix.SYS_RCTL_REMOVE_RULE, "jail:"+jail)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
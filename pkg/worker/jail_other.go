@@ -0,0 +1,21 @@
+//go:build !freebsd
+
+package worker
+
+// createJail is here for all non-freebsd builds but does nothing and exists
+// only to make builds work
+func createJail(string) error {
+	return nil
+}
+
+// addJailRules is here for all non-freebsd builds but does nothing and exists
+// only to make builds work
+func addJailRules(string, *Limits) error {
+	return nil
+}
+
+// removeJailRules is here for all non-freebsd builds but does nothing and
+// exists only to make builds work
+func removeJailRules(string) error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"hash/maphash"
+	"sync"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// jobShards is the number of shards of a jobMap
+const jobShards = 64
+
+// jobSeed seeds the hash that picks the shard of a job
+var jobSeed = maphash.MakeSeed()
+
+// jobEntry is a job and the path of the leaf cgroup it runs in, if any. It is
+// immutable, jobs are replaced with a new entry, e.g. once a queued job is
+// started in its cgroup, rather than being modified.
+type jobEntry struct {
+	job *job.Job
+	cg  string
+}
+
+// jobMap holds the jobs of a Worker by id. It is sharded so that looking up
+// jobs, which every request does, only contends with the jobs that are added
+// and removed in the same shard rather than with every other request. The
+// zero value is ready to use.
+type jobMap struct {
+	shards [jobShards]jobShard
+}
+
+// jobShard is one shard of a jobMap
+type jobShard struct {
+	mu      sync.RWMutex
+	entries map[job.ID]*jobEntry
+}
+
+// shard returns the shard that holds id
+func (m *jobMap) shard(id job.ID) *jobShard {
+	return &m.shards[maphash.String(jobSeed, id.Suffix())%jobShards]
+}
+
+// get returns the entry of the job identified by id
+func (m *jobMap) get(id job.ID) (*jobEntry, bool) {
+	s := m.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[id]
+	return e, ok
+}
+
+// store adds j, which runs in the cgroup at path cg, replacing any job with
+// the same id
+func (m *jobMap) store(j *job.Job, cg string) {
+	s := m.shard(j.ID())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = map[job.ID]*jobEntry{}
+	}
+	s.entries[j.ID()] = &jobEntry{job: j, cg: cg}
+}
+
+// remove removes the job identified by id
+func (m *jobMap) remove(id job.ID) {
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, id)
+}
+
+// removeJob removes j, unless it was already removed or replaced, and returns
+// its entry. It returns false if it wasn't removed.
+func (m *jobMap) removeJob(j *job.Job) (*jobEntry, bool) {
+	s := m.shard(j.ID())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[j.ID()]
+	if !ok || e.job != j {
+		return nil, false
+	}
+
+	delete(s.entries, j.ID())
+	return e, true
+}
+
+// all returns a snapshot of all of the jobs. Jobs that are added, or removed,
+// while it is taken may or may not be included.
+func (m *jobMap) all() []*job.Job {
+	var ret []*job.Job
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for _, e := range s.entries {
+			ret = append(ret, e.job)
+		}
+		s.mu.RUnlock()
+	}
+	return ret
+}
+
+// cgroups returns the paths of the leaf cgroups of all jobs that run in one
+func (m *jobMap) cgroups() []string {
+	var ret []string
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for _, e := range s.entries {
+			if e.cg != "" {
+				ret = append(ret, e.cg)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return ret
+}
+
+// jobCGroup returns the path of the leaf cgroup j runs in, or "" if it doesn't
+// run in one or was removed
+func (w *Worker) jobCGroup(j *job.Job) string {
+	e, ok := w.jobs.get(j.ID())
+	if !ok || e.job != j {
+		return ""
+	}
+	return e.cg
+}
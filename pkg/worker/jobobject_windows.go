@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// jobObjectCPURateControlInformation is JOBOBJECT_CPU_RATE_CONTROL_INFORMATION,
+// which isn't defined by x/sys/windows
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	CPURate      uint32 // in hundredths of a percent of all of the cpus
+}
+
+const (
+	jobObjectCPURateControlEnable  = 0x1 // JOB_OBJECT_CPU_RATE_CONTROL_ENABLE
+	jobObjectCPURateControlHardCap = 0x4 // JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP
+)
+
+// setJobObject creates a job object, with limits applied, and runs j in it.
+// windows has no cgroups, so job objects are used to limit jobs instead. As
+// with cpu.max, CPUMax is a fraction of a single cpu. RIOPSMax and WIOPSMax
+// are not supported.
+func setJobObject(j *job.Job, limits *Limits) error {
+	h, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return err
+	}
+
+	// like the pid namespace on linux, every process in the job object is
+	// killed once the job exits and the job object is closed
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+
+	if limits != nil && limits.MemoryMax > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_MEMORY
+		info.JobMemoryLimit = uintptr(limits.MemoryMax)
+	}
+
+	if _, err = windows.SetInformationJobObject(
+		h,
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+	); err != nil {
+		_ = windows.CloseHandle(h)
+		return err
+	}
+
+	if limits != nil && limits.CPUMax > 0 {
+		cpu := jobObjectCPURateControlInformation{
+			ControlFlags: jobObjectCPURateControlEnable | jobObjectCPURateControlHardCap,
+			CPURate:      max(uint32(float64(limits.CPUMax)*10000/float64(runtime.NumCPU())), 1),
+		}
+
+		if _, err = windows.SetInformationJobObject(
+			h,
+			windows.JobObjectCpuRateControlInformation,
+			uintptr(unsafe.Pointer(&cpu)),
+			uint32(unsafe.Sizeof(cpu)),
+		); err != nil {
+			_ = windows.CloseHandle(h)
+			return err
+		}
+	}
+
+	j.SetJobObject(h)
+	return nil
+}
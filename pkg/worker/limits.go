@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Limits are the cgroup limits applied to a job. When updating a running job,
+// zero values leave the current limit unchanged.
+type Limits struct {
+	CPUMax    float32 // the maximum cpu usage as a decimal, 0 < value <= 1
+	MemoryMax uint32  // the maximum memory usage in bytes
+	RIOPSMax  uint32  // the maximum read io operations per second
+	WIOPSMax  uint32  // the maximum write io operations per second
+}
+
+// ErrLimitExceedsCeiling is returned by UpdateJobLimits if a limit is higher
+// than the one configured on the Worker
+var ErrLimitExceedsCeiling = errors.New("limit exceeds the configured maximum")
+
+// limits returns the limits configured on the Worker. they are applied to
+// every job and are the ceilings for UpdateJobLimits.
+func (c *Config) limits() *Limits {
+	return &Limits{
+		CPUMax:    c.CPUMax,
+		MemoryMax: c.MemoryMax,
+		RIOPSMax:  c.RIOPSMax,
+		WIOPSMax:  c.WIOPSMax,
+	}
+}
+
+// validate ensures that l does not exceed ceiling. ceilings of 0 are
+// unlimited.
+func (l *Limits) validate(ceiling *Limits) error {
+	if l.CPUMax < 0 || l.CPUMax > 1 {
+		return ErrInvalidCPUMax
+	}
+
+	exceeds := func(v, ceiling uint32) bool {
+		return ceiling != 0 && v > ceiling
+	}
+
+	switch {
+	case ceiling.CPUMax != 0 && l.CPUMax > ceiling.CPUMax:
+		return fmt.Errorf("%w: cpu max %v > %v", ErrLimitExceedsCeiling, l.CPUMax, ceiling.CPUMax)
+	case exceeds(l.MemoryMax, ceiling.MemoryMax):
+		return fmt.Errorf("%w: memory max %d > %d", ErrLimitExceedsCeiling, l.MemoryMax, ceiling.MemoryMax)
+	case exceeds(l.RIOPSMax, ceiling.RIOPSMax):
+		return fmt.Errorf("%w: riops max %d > %d", ErrLimitExceedsCeiling, l.RIOPSMax, ceiling.RIOPSMax)
+	case exceeds(l.WIOPSMax, ceiling.WIOPSMax):
+		return fmt.Errorf("%w: wiops max %d > %d", ErrLimitExceedsCeiling, l.WIOPSMax, ceiling.WIOPSMax)
+	}
+
+	return nil
+}
+
+// cgroupValue is a value to be written to a file in a cgroup
+type cgroupValue struct {
+	file  string
+	value string
+}
+
+// cgroupValues returns the values of cpu.max, memory.max and io.max that
+// apply l. zero values are skipped.
+func (l *Limits) cgroupValues(blockDevices []string) []cgroupValue {
+	var values []cgroupValue
+
+	if v := l.CPUMax; v != 0 {
+		const maxCPU = 100000
+		d := int64(v * float32(maxCPU))
+		values = append(values, cgroupValue{
+			file: "cpu.max", value: fmt.Sprintf("%d %d", d, maxCPU),
+		})
+	}
+
+	if v := l.MemoryMax; v != 0 {
+		values = append(values, cgroupValue{
+			file: "memory.max", value: strconv.Itoa(int(v)),
+		})
+	}
+
+	if l.RIOPSMax > 0 || l.WIOPSMax > 0 {
+		for _, v := range blockDevices {
+			s := v
+			if l.RIOPSMax > 0 {
+				s += fmt.Sprintf(" riops=%d", l.RIOPSMax)
+			}
+			if l.WIOPSMax > 0 {
+				s += fmt.Sprintf(" wiops=%d", l.WIOPSMax)
+			}
+			values = append(values, cgroupValue{
+				file: "io.max", value: s,
+			})
+		}
+	}
+
+	return values
+}
+
+// writeCGroupValues writes values to the cgroup at path. devices that reject
+// io.max are skipped since the remaining limits are still useful.
+func writeCGroupValues(path string, values []cgroupValue) error {
+	for _, v := range values {
+		file := filepath.Join(path, v.file)
+		err := os.WriteFile(file, []byte(v.value), cgroupFilePerm)
+		if err != nil && v.file == "io.max" {
+			slog.Warn("skipping device that rejected io limits", "value", v.value, "err", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error writing to cgroup file %q (value: %q): %w", file, v.value, err)
+		}
+	}
+	return nil
+}
+
+// newJobCGroupPath returns a new, unique, path for the leaf cgroup of a job.
+// the cgroup itself is created by createJobCGroup when the job is started.
+func (w *Worker) newJobCGroupPath() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return filepath.Join(w.rootCGroupName, "job-"+hex.EncodeToString(b[:])), nil
+}
+
+// createJobCGroup creates the leaf cgroup of a job at path and applies limits,
+// if any, to it. it returns the cgroup's directory, opened so that the job can
+// be started inside it with clone3(2).
+func createJobCGroup(path string, limits *Limits, blockDevices []string) (*os.File, error) {
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, err
+	}
+
+	if limits != nil {
+		if err := writeCGroupValues(path, limits.cgroupValues(blockDevices)); err != nil {
+			_ = os.Remove(path)
+			return nil, err
+		}
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return dir, nil
+}
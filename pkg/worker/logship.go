@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer"
+	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer/safereader"
+)
+
+const (
+	// maxShipBatch is the maximum number of lines passed to a Shipper at once
+	maxShipBatch = 100
+
+	// shipQueueSize is the number of lines that are read ahead of the
+	// shippers. once it is full, the reader falls behind the output and is
+	// subject to Config.SlowReaderPolicy like any other reader.
+	shipQueueSize = 1024
+)
+
+// shipOutput reads the output of j a line at a time and ships it to each of
+// the configured log shippers until j is done or its output readers are
+// closed. shipping is best effort, errors are logged and the lines dropped.
+func (w *Worker) shipOutput(j *job.Job) {
+	defer w.wg.Done()
+
+	r := j.NewOutputReader()
+	defer r.Close()
+
+	lines := make(chan logship.Line, shipQueueSize)
+
+	go func() {
+		defer close(lines)
+
+		lr := safebuffer.NewLineReader(r, 0)
+		for {
+			line, truncated, err := lr.Line()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, safereader.ErrReaderClosed) {
+					slog.Error("error reading output to ship", "job_id", j.ID(), "err", err)
+				}
+				return
+			}
+
+			lines <- logship.Line{
+				Time:      time.Now(),
+				JobID:     j.ID(),
+				UserID:    j.UserID(),
+				Tenant:    j.TenantID(),
+				Message:   string(line),
+				Truncated: truncated,
+			}
+		}
+	}()
+
+	batch := make([]logship.Line, 0, maxShipBatch)
+	for line := range lines {
+		batch = append(batch[:0], line)
+
+		// ship whatever else is already queued along with it
+	fill:
+		for len(batch) < maxShipBatch {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, line)
+			default:
+				break fill
+			}
+		}
+
+		w.ship(batch)
+	}
+}
+
+// ship delivers batch to all log shippers concurrently and waits for all of
+// the deliveries to complete
+func (w *Worker) ship(batch []logship.Line) {
+	var wg sync.WaitGroup
+	for _, s := range w.cfg.LogShippers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Ship(context.Background(), batch); err != nil {
+				slog.Error("error shipping output", "job_id", batch[0].JobID, "lines", len(batch), "err", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
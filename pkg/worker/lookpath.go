@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCommandNotFound is returned by StartJob if the command can't be found in
+// the job's PATH
+var ErrCommandNotFound = errors.New("command not found")
+
+// lookPath resolves command the way exec.LookPath does, but using the PATH in
+// env, which is what the job will see, instead of the worker's own. like
+// exec.LookPath, relative entries in PATH are ignored so that a job can't be
+// tricked into running a binary from the worker's working directory.
+func lookPath(command string, env []string) (string, error) {
+	if strings.ContainsRune(command, filepath.Separator) || strings.ContainsRune(command, '/') {
+		path, err := exec.LookPath(command)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrCommandNotFound, err)
+		}
+		return path, nil
+	}
+
+	for _, dir := range filepath.SplitList(envValue(env, "PATH")) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+
+		if path, err := exec.LookPath(filepath.Join(dir, command)); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrCommandNotFound, command)
+}
+
+// envValue returns the value of key in env. like the exec package, if key is
+// set more than once, the last value wins.
+func envValue(env []string, key string) string {
+	var value string
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, key+"="); ok {
+			value = v
+		}
+	}
+	return value
+}
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IOClass is the io scheduling class of a job, as set by ionice(1)
+type IOClass int
+
+// NOTE: keep this synced with jobworker.proto:IOClass. the values match
+// IOPRIO_CLASS_* from ioprio_set(2).
+const (
+	IOClassNone       IOClass = iota // the io priority is derived from the nice value
+	IOClassRealtime                  // the job always gets first access to the disk
+	IOClassBestEffort                // the default class, ordered by Priority.IOLevel
+	IOClassIdle                      // the job only gets disk time when nothing else needs it
+)
+
+// Priority controls how a job is scheduled relative to other processes. Zero
+// values are left as inherited from the Worker.
+type Priority struct {
+	Nice        int     // the nice value, -20 (highest priority) to 19 (lowest)
+	IOClass     IOClass // the io scheduling class
+	IOLevel     int     // the priority within IOClassRealtime and IOClassBestEffort, 0 (highest) to 7 (lowest)
+	OOMScoreAdj int     // added to the job's oom score, -1000 (never killed) to 1000 (killed first)
+}
+
+// PriorityPolicy is the ceiling on how favorably jobs may be scheduled. The
+// zero value only permits jobs to lower their priority.
+type PriorityPolicy struct {
+	MinNice         int  // the lowest, most favorable, nice value jobs may use
+	MinOOMScoreAdj  int  // the lowest oom_score_adj jobs may use
+	AllowRealtimeIO bool // whether jobs may use IOClassRealtime
+}
+
+var (
+	// ErrInvalidPriority is returned when a Priority is out of range
+	ErrInvalidPriority = errors.New("invalid priority")
+
+	// ErrPriorityExceedsPolicy is returned when a Priority is more favorable
+	// than Config.PriorityPolicy permits
+	ErrPriorityExceedsPolicy = errors.New("priority exceeds policy")
+)
+
+// validate ensures that p is in range and permitted by policy
+func (p *Priority) validate(policy *PriorityPolicy) error {
+	switch {
+	case p.Nice < -20 || p.Nice > 19:
+		return fmt.Errorf("%w: nice %d is not between -20 and 19", ErrInvalidPriority, p.Nice)
+	case p.IOClass < IOClassNone || p.IOClass > IOClassIdle:
+		return fmt.Errorf("%w: unknown io class %d", ErrInvalidPriority, p.IOClass)
+	case p.IOLevel < 0 || p.IOLevel > 7:
+		return fmt.Errorf("%w: io level %d is not between 0 and 7", ErrInvalidPriority, p.IOLevel)
+	case p.OOMScoreAdj < -1000 || p.OOMScoreAdj > 1000:
+		return fmt.Errorf("%w: oom score adj %d is not between -1000 and 1000", ErrInvalidPriority, p.OOMScoreAdj)
+	case p.Nice < policy.MinNice:
+		return fmt.Errorf("%w: nice %d is less than %d", ErrPriorityExceedsPolicy, p.Nice, policy.MinNice)
+	case p.OOMScoreAdj < policy.MinOOMScoreAdj:
+		return fmt.Errorf("%w: oom score adj %d is less than %d", ErrPriorityExceedsPolicy, p.OOMScoreAdj, policy.MinOOMScoreAdj)
+	case p.IOClass == IOClassRealtime && !policy.AllowRealtimeIO:
+		return fmt.Errorf("%w: realtime io is not allowed", ErrPriorityExceedsPolicy)
+	}
+	return nil
+}
+
+// ioprio returns the value passed to ioprio_set(2)
+func (p *Priority) ioprio() int {
+	const classShift = 13 // IOPRIO_CLASS_SHIFT
+	return int(p.IOClass)<<classShift | p.IOLevel
+}
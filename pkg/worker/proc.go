@@ -0,0 +1,47 @@
+package worker
+
+// ProcPolicy determines how /proc is mounted in each job's mount namespace.
+// Regardless of the policy, /proc is mounted nosuid, nodev and noexec and
+// files that expose details of the host, like /proc/kcore and
+// /proc/sysrq-trigger, are masked.
+type ProcPolicy struct {
+	// ReadOnly mounts /proc read only so that jobs can't change settings,
+	// e.g. in /proc/sys, even if they have the privileges to
+	ReadOnly bool
+
+	// HidePID mounts /proc with hidepid=invisible, so that jobs can only see
+	// their own user's processes, or hidepid=2 on kernels that don't support
+	// it
+	HidePID bool
+}
+
+// merge returns p with the hardening enabled in o added, e.g. by an
+// IsolationProfile
+func (p ProcPolicy) merge(o ProcPolicy) ProcPolicy {
+	p.ReadOnly = p.ReadOnly || o.ReadOnly
+	p.HidePID = p.HidePID || o.HidePID
+	return p
+}
+
+// maskedProcPaths are hidden from jobs by mounting /dev/null, or an empty read
+// only tmpfs for directories, over them. paths that don't exist are skipped.
+var maskedProcPaths = []string{
+	"/proc/acpi",
+	"/proc/kcore",
+	"/proc/keys",
+	"/proc/latency_stats",
+	"/proc/sched_debug",
+	"/proc/scsi",
+	"/proc/sysrq-trigger",
+	"/proc/timer_list",
+	"/proc/timer_stats",
+}
+
+// readOnlyProcPaths are mounted read only in jobs even if ProcPolicy.ReadOnly
+// isn't set. paths that don't exist are skipped.
+var readOnlyProcPaths = []string{
+	"/proc/bus",
+	"/proc/fs",
+	"/proc/irq",
+	"/proc/sys",
+}
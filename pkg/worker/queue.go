@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultQueue is the queue of jobs that don't select one with
+// JobOptions.Queue. It has no limits of its own unless it is configured in
+// Config.Queues.
+const DefaultQueue = "default"
+
+// ErrQueueNotFound is returned when starting a job in a queue that isn't
+// configured in Config.Queues
+var ErrQueueNotFound = errors.New("queue not found")
+
+// QueueConfig limits the jobs of a named queue, e.g. "ci" or "batch", so that
+// they can't take all of the Worker's room from the jobs of other queues. The
+// limits apply in addition to those of the Worker. Zero values are unlimited.
+type QueueConfig struct {
+	// MaxRunningJobs is the maximum number of jobs of the queue that may run
+	// at once
+	MaxRunningJobs int
+
+	// Capacity is the share of the Worker's capacity that the requests of the
+	// queue's running jobs may add up to
+	Capacity Resources
+}
+
+// queueName returns the name of the queue selected by opts, or
+// ErrQueueNotFound if it isn't configured
+func (c *Config) queueName(opts *JobOptions) (string, error) {
+	if opts.Queue == "" || opts.Queue == DefaultQueue {
+		return DefaultQueue, nil
+	}
+
+	if _, ok := c.Queues[opts.Queue]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrQueueNotFound, opts.Queue)
+	}
+
+	return opts.Queue, nil
+}
+
+// usage is the room used by the admitted jobs of the Worker, or of one of its
+// queues
+type usage struct {
+	jobs      int       // the number of admitted jobs, started or not
+	requested Resources // the sum of their requests
+}
+
+// add adds the room used by a job with requests to u
+func (u *usage) add(requests *Resources) {
+	u.jobs++
+	u.requested.add(requests)
+}
+
+// sub subtracts the room used by a job with requests from u
+func (u *usage) sub(requests *Resources) {
+	u.jobs--
+	u.requested.sub(requests)
+}
+
+// fits returns true if a job with requests fits within the limit on the number
+// of jobs and capacity, alongside the jobs using u. zero limits are unlimited.
+func (u usage) fits(requests *Resources, limit int, capacity *Resources) bool {
+	if limit > 0 && u.jobs >= limit {
+		return false
+	}
+
+	u.requested.add(requests)
+	return u.requested.fits(capacity)
+}
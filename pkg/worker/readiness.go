@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// DefaultReadinessInterval is how often a ReadinessProbe's TCPPort or File is
+// checked if its Interval isn't set
+const DefaultReadinessInterval = time.Second
+
+// ReadinessProbe decides when a job that starts a service, e.g. a server, is
+// ready, so that whatever is orchestrating it can wait for it with JobStatus
+// or WatchJobs. Exactly one of TCPPort, File and OutputRegexp
+// must be set.
+type ReadinessProbe struct {
+	// TCPPort is ready once the job is listening on the port in its network
+	// namespace
+	TCPPort int
+
+	// File is ready once the absolute path exists, as the job sees it
+	File string
+
+	// OutputRegexp is ready once a line of the job's output matches the
+	// regular expression
+	OutputRegexp string
+
+	// Interval is how often TCPPort or File is checked. If 0,
+	// DefaultReadinessInterval is used.
+	Interval time.Duration
+}
+
+// ErrInvalidReadinessProbe is returned by StartJob if JobOptions.Readiness
+// doesn't set exactly one valid check
+var ErrInvalidReadinessProbe = errors.New("invalid readiness probe")
+
+// validate ensures that p sets exactly one valid check
+func (p *ReadinessProbe) validate() error {
+	var checks int
+	if p.TCPPort != 0 {
+		checks++
+		if p.TCPPort < 1 || p.TCPPort > 65535 {
+			return fmt.Errorf("%w: invalid tcp port %d", ErrInvalidReadinessProbe, p.TCPPort)
+		}
+	}
+	if p.File != "" {
+		checks++
+		if !filepath.IsAbs(p.File) {
+			return fmt.Errorf("%w: file %q must be absolute", ErrInvalidReadinessProbe, p.File)
+		}
+	}
+	if p.OutputRegexp != "" {
+		checks++
+		if _, err := regexp.Compile(p.OutputRegexp); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidReadinessProbe, err)
+		}
+	}
+	if checks != 1 {
+		return fmt.Errorf("%w: exactly one of a tcp port, file or output regexp must be set", ErrInvalidReadinessProbe)
+	}
+	if p.Interval < 0 {
+		return fmt.Errorf("%w: negative interval", ErrInvalidReadinessProbe)
+	}
+	return nil
+}
+
+// check returns the job.ReadinessCheck that implements p, which has been
+// validated. probes are sent each interval on c.
+func (p *ReadinessProbe) check(c clock.Clock) job.ReadinessCheck {
+	if p.OutputRegexp != "" {
+		re := regexp.MustCompile(p.OutputRegexp)
+		return func(j *job.Job, _ <-chan struct{}) bool {
+			return outputMatches(j, re)
+		}
+	}
+
+	interval := p.Interval
+	if interval == 0 {
+		interval = DefaultReadinessInterval
+	}
+
+	ready := func(pid int) bool { return fileExists(pid, p.File) }
+	if p.TCPPort != 0 {
+		ready = func(pid int) bool { return listening(pid, p.TCPPort) }
+	}
+
+	return func(j *job.Job, done <-chan struct{}) bool {
+		ticker := clock.NewTicker(c, interval)
+		defer ticker.Stop()
+
+		for {
+			if ready(j.Pid()) {
+				return true
+			}
+
+			select {
+			case <-done:
+				return false
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// outputMatches returns true once a line of j's output matches re, or false
+// if j completes without one doing so. lines longer than the reader's buffer
+// are matched in pieces.
+func outputMatches(j *job.Job, re *regexp.Regexp) bool {
+	rc := j.NewOutputReader()
+	defer func() { _ = rc.Close() }()
+
+	r := bufio.NewReader(rc)
+	for {
+		line, _, err := r.ReadLine()
+		if err != nil {
+			return false
+		}
+		if re.Match(line) {
+			return true
+		}
+	}
+}
+
+// notifyReady delivers the job.ready event once j is ready. it returns once
+// the event has been delivered, or j is done without having become ready.
+func (w *Worker) notifyReady(j *job.Job) {
+	select {
+	case <-j.Ready():
+	case <-j.Done():
+		// the job may have become ready just before it completed
+		if j.ReadyTime().IsZero() {
+			return
+		}
+	}
+
+	w.notify(event.Alert(j, event.TypeReady, "passed its readiness probe"))
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the state of listening sockets in /proc/<pid>/net/tcp
+const tcpListen = "0A"
+
+// listening returns true if a socket in the network namespace of pid is
+// listening on the tcp port
+func listening(pid, port int) bool {
+	if pid == 0 {
+		return false
+	}
+
+	for _, name := range []string{"tcp", "tcp6"} {
+		if listeningIn(filepath.Join("/proc", strconv.Itoa(pid), "net", name), port) {
+			return true
+		}
+	}
+	return false
+}
+
+// listeningIn returns true if the /proc/<pid>/net/tcp style table at path has
+// a socket listening on port
+func listeningIn(path string, port int) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+
+	s := bufio.NewScanner(f)
+	s.Scan() // skip the header
+	for s.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(s.Text())
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+
+		if p, err := strconv.ParseUint(hexPort, 16, 16); err == nil && int(p) == port {
+			return true
+		}
+	}
+	return false
+}
+
+// fileExists returns true if path exists in the mount namespace of pid
+func fileExists(pid int, path string) bool {
+	if pid == 0 {
+		return false
+	}
+
+	_, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid), "root", path))
+	return err == nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package worker
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// listening returns true if something is listening on the tcp port. jobs
+// share the host's network, so it is only a best effort check that the job is
+// the one listening.
+func listening(_, port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)), time.Second)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// fileExists returns true if path exists, jobs share the host's filesystem
+func fileExists(_ int, path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
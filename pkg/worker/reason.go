@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"errors"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+//go:generate stringer -type=Reason -trimprefix=Reason
+
+// Reason describes why a job is no longer running so that clients can branch
+// on it without interpreting the status, stop reason, exit code and signal
+// themselves
+type Reason int
+
+// NOTE: keep this synced with jobworker.proto:JobReason
+const (
+	ReasonNone                  Reason = iota // the job is still running, or queued
+	ReasonCompletedOK                         // the job exited with a zero exit code
+	ReasonNonZeroExit                         // the job exited with a non-zero exit code
+	ReasonSignalKilled                        // the job was terminated by a signal the worker didn't send
+	ReasonOOMKilled                           // the job was killed by the oom killer after exceeding its memory limit
+	ReasonTimedOut                            // the job was stopped by its timeout, idle timeout or deadline
+	ReasonStartFailed                         // the job failed to start
+	ReasonStoppedByUser                       // the job was stopped by a user
+	ReasonNodeShutdown                        // the job was stopped, or interrupted, by the worker shutting down
+	ReasonPreempted                           // the job was stopped to make room for a higher priority job
+	ReasonStartDeadlineExceeded               // the job was queued, but couldn't be started by its start-by time
+)
+
+// reason determines why j is no longer running. cgStats are the stats of the
+// job's cgroup and may be nil.
+func reason(j *job.Job, cgStats *CGroupStats) Reason {
+	switch j.Status() {
+	case job.StatusStartError:
+		if errors.Is(j.Error(), job.ErrStartDeadlineExceeded) {
+			return ReasonStartDeadlineExceeded
+		}
+		return ReasonStartFailed
+	case job.StatusInterrupted:
+		return ReasonNodeShutdown
+	case job.StatusCompleted, job.StatusStopped:
+	default:
+		return ReasonNone
+	}
+
+	switch j.StopReason() {
+	case job.StopReasonRequested:
+		return ReasonStoppedByUser
+	case job.StopReasonTimeout, job.StopReasonIdleTimeout:
+		return ReasonTimedOut
+	case job.StopReasonShutdown:
+		return ReasonNodeShutdown
+	case job.StopReasonPreempted:
+		return ReasonPreempted
+	}
+
+	switch signalSource(j, cgStats) {
+	case SignalSourceOOM:
+		return ReasonOOMKilled
+	case SignalSourceExternal:
+		return ReasonSignalKilled
+	}
+
+	if ec, ok := j.ExitCode(); !ok || ec != 0 {
+		return ReasonNonZeroExit
+	}
+
+	return ReasonCompletedOK
+}
@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// ErrReexecChecksumMismatch is returned by New if the ReexecCommand binary
+// doesn't match Config.ReexecChecksum
+var ErrReexecChecksumMismatch = errors.New("reexec command checksum mismatch")
+
+// verifyReexecCommand returns ErrReexecChecksumMismatch if checksum is set and
+// the binary that command runs doesn't match it
+func verifyReexecCommand(command, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return fmt.Errorf("error finding reexec command: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening reexec command: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return fmt.Errorf("error reading reexec command: %w", err)
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != strings.ToLower(checksum) {
+		return fmt.Errorf("%w: %s is %s", ErrReexecChecksumMismatch, path, sum)
+	}
+
+	return nil
+}
+
+// StartChild is called when the ReexecCommand binary is executed with the new
+// namespaces applied. It should be the only function called by the binary in
+// that circumstance and should never be called in any other situation. It
+// doesn't need a Worker, everything it needs, including the job's command and
+// args, is read from job.SpecFD, so that it can be called by a dedicated
+// helper binary. It is started by the parent inside the job's cgroup, which
+// already has cpu, memory and io limits applied. It will remount /proc, apply
+// the job's priority and any rlimits, start a new session and set
+// no_new_privs, unless the spec says otherwise, install a seccomp filter and
+// drop capabilities, if the job's IsolationProfile says to, and finally it
+// will execute the command, or start it under a minimal init, see
+// Config.Init. On darwin, the command is executed with sandbox-exec(1) if
+// Config.SandboxProfile is set. If it returns, the error has already been
+// reported to the parent, which records it as the job's error, and the caller
+// should exit with a non-zero status without writing anything to the job's
+// output.
+func StartChild() error {
+	// the child's own logs must never end up in the job's output
+	redirectChildLog()
+
+	spec, err := readChildSpec()
+	if err != nil {
+		err = fmt.Errorf("error reading child spec: %w", err)
+		slog.Error("error starting child process", "err", err)
+		return err
+	}
+
+	setup := openSetupPipe(spec)
+
+	// mounts would leak into the host without a new mount namespace
+	if runtime.GOOS == linuxOS && spec.Namespaces&job.NamespaceMount != 0 {
+		if err = mountProc(&spec.Proc); err != nil {
+			return childError(setup, fmt.Errorf("error mounting /proc: %w", err))
+		}
+
+		for _, m := range spec.BindMounts {
+			if err = bindMount(m.Source, m.Target); err != nil {
+				return childError(setup, fmt.Errorf("error bind mounting %q on %q: %w", m.Source, m.Target, err))
+			}
+		}
+
+		// the devices of the gpus that the job wasn't granted
+		for _, dev := range spec.MaskedDevices {
+			if err = maskPath(dev); err != nil {
+				return childError(setup, fmt.Errorf("error masking %q: %w", dev, err))
+			}
+		}
+	}
+
+	// the parent has already added the rctl rules that limit the jail
+	if spec.Jail != "" {
+		if err = createJail(spec.Jail); err != nil {
+			return childError(setup, fmt.Errorf("error creating jail: %w", err))
+		}
+	}
+
+	if err = setPriority(&spec.Priority); err != nil {
+		return childError(setup, err)
+	}
+
+	// rlimits are applied last so that they restrict the job, not the setup
+	// done on its behalf
+	if err = setRlimits(spec.Rlimits); err != nil {
+		return childError(setup, fmt.Errorf("error setting rlimits: %w", err))
+	}
+
+	// a new session has no controlling terminal, so terminal signals meant
+	// for the worker never reach the job. either way, the job's process group
+	// is killed along with it where there are no pid namespaces.
+	if spec.Setsid {
+		if err = setsid(); err != nil {
+			return childError(setup, fmt.Errorf("error creating session: %w", err))
+		}
+	} else if err = setpgid(); err != nil {
+		return childError(setup, fmt.Errorf("error creating process group: %w", err))
+	}
+
+	// no_new_privs is inherited across exec, so setuid binaries, and files
+	// with capabilities, can't be used by the job to gain privileges
+	if spec.NoNewPrivs {
+		if err = setNoNewPrivs(); err != nil {
+			return childError(setup, fmt.Errorf("error setting no_new_privs: %w", err))
+		}
+	}
+
+	// the filter is installed while the child still has CAP_SYS_ADMIN, which
+	// it needs if no_new_privs isn't set
+	if spec.Seccomp {
+		if err = setSeccomp(); err != nil {
+			return childError(setup, fmt.Errorf("error installing seccomp filter: %w", err))
+		}
+	}
+
+	if spec.DropCapabilities {
+		if err = dropCapabilities(spec.Capabilities); err != nil {
+			return childError(setup, err)
+		}
+	}
+
+	spec.Args = sandboxArgs(spec.Sandbox, spec.Args)
+
+	if spec.Init {
+		return runInit(setup, spec)
+	}
+
+	// the command was resolved by the parent with the job's PATH
+	if err = syscall.Exec(spec.Args[0], spec.Args, spec.Env); err != nil {
+		return childError(setup, fmt.Errorf("syscall.Exec error: %w", err))
+	}
+
+	return nil
+}
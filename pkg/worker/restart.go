@@ -0,0 +1,277 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// RestartMode decides which jobs are restarted by their RestartPolicy
+type RestartMode int
+
+const (
+	RestartNever     RestartMode = iota // jobs are never restarted
+	RestartOnFailure                    // jobs that fail, e.g. exit with a non-zero exit code, are restarted
+	RestartAlways                       // jobs are restarted whenever they exit
+)
+
+const (
+	// DefaultRestartBackoff is how long to wait before the first restart if
+	// RestartPolicy.Backoff isn't set
+	DefaultRestartBackoff = time.Second
+
+	// DefaultMaxRestartBackoff is the longest to wait before a restart if
+	// RestartPolicy.MaxBackoff isn't set
+	DefaultMaxRestartBackoff = 5 * time.Minute
+)
+
+// RestartPolicy relaunches long-lived service jobs when they exit. Jobs that
+// are stopped, e.g. by StopJob, a timeout or the Worker shutting down, are
+// never restarted, nor are jobs once the Worker is cordoned. Each attempt
+// keeps the job's id, but has its own output and status.
+// StatusResponse.Restarts and LastExit describe the attempts before it.
+type RestartPolicy struct {
+	Mode RestartMode
+
+	// MaxRestarts is the maximum number of times the job is restarted, 0
+	// means no limit
+	MaxRestarts int
+
+	// Backoff is how long to wait before the first restart. It is doubled
+	// for each restart after that, up to MaxBackoff. If 0,
+	// DefaultRestartBackoff and DefaultMaxRestartBackoff are used.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// ErrInvalidRestartPolicy is returned by StartJob if JobOptions.Restart is
+// invalid
+var ErrInvalidRestartPolicy = errors.New("invalid restart policy")
+
+// validate ensures that p is valid for a job that may be durable
+func (p *RestartPolicy) validate(durable bool) error {
+	switch {
+	case p.Mode < RestartNever || p.Mode > RestartAlways:
+		return fmt.Errorf("%w: invalid mode %d", ErrInvalidRestartPolicy, p.Mode)
+	case p.MaxRestarts < 0, p.Backoff < 0, p.MaxBackoff < 0:
+		return fmt.Errorf("%w: negative value", ErrInvalidRestartPolicy)
+	case p.Mode != RestartNever && durable:
+		// the write-ahead log of the job would be replaced by each attempt
+		return fmt.Errorf("%w: durable jobs can't be restarted", ErrInvalidRestartPolicy)
+	}
+	return nil
+}
+
+// restarts returns true if j, which is done, should be restarted
+func (p *RestartPolicy) restarts(j *job.Job) bool {
+	if p.Mode == RestartNever || (p.MaxRestarts > 0 && j.Restarts() >= p.MaxRestarts) {
+		return false
+	}
+
+	if j.Status() == job.StatusStopped {
+		return false
+	}
+
+	ec, ok := j.ExitCode()
+	failed := j.Error() != nil || !ok || ec.Int() != 0
+
+	return failed || p.Mode == RestartAlways
+}
+
+// backoff returns how long to wait before the restart after the first n
+func (p *RestartPolicy) backoff(n int) time.Duration {
+	d, maxBackoff := p.Backoff, p.MaxBackoff
+	if d == 0 {
+		d = DefaultRestartBackoff
+	}
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxRestartBackoff
+	}
+
+	for range n {
+		if d >= maxBackoff {
+			break
+		}
+		d *= 2
+	}
+
+	return min(d, maxBackoff)
+}
+
+// pendingRestart is the next attempt of a job that is waiting for its backoff
+// to pass before it is started
+type pendingRestart struct {
+	slot     *scheduled
+	next     *job.Job
+	cg       string
+	timer    clock.Timer
+	notified chan struct{} // closed once the restarting event was delivered
+}
+
+// restart schedules the next attempt of j, which is done, was admitted with
+// slot and ran in the cgroup at path cg, if its RestartPolicy says to. The
+// next attempt replaces j immediately, so that its status shows that it is
+// waiting to be restarted, and it is started once its backoff passes. Once
+// users, the goroutines still reading its output or cgroup, are done, the
+// cgroup is removed and j is closed. It returns false if j isn't restarted.
+func (w *Worker) restart(j *job.Job, slot *scheduled, cg string, users *sync.WaitGroup) bool {
+	if slot == nil || !slot.restart.restarts(j) {
+		return false
+	}
+
+	next := j.Restart()
+
+	// a cgroup can only be created once, so each attempt has its own
+	prev := cg
+	if cg != "" {
+		var err error
+		if cg, err = w.newJobCGroupPath(); err != nil {
+			slog.Error("error restarting job", "job_id", j.ID(), "err", err)
+			return false
+		}
+	}
+
+	backoff := slot.restart.backoff(j.Restarts())
+	p := pendingRestart{
+		// the room of the job was freed, it is admitted again before the
+		// next attempt is started
+		slot: &scheduled{
+			tenant:   slot.tenant,
+			queue:    slot.queue,
+			priority: slot.priority,
+			requests: slot.requests,
+			restart:  slot.restart,
+		},
+		next:     next,
+		cg:       cg,
+		notified: make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	if w.acceptingLocked(nil) != nil {
+		w.mu.Unlock()
+		return false
+	}
+	w.jobs.store(next, "")
+	w.restarting[j.ID()] = &p
+	w.wg.Add(1)
+	p.timer = w.clock.AfterFunc(backoff, func() { w.startRestart(&p) })
+	w.mu.Unlock()
+
+	// j has been replaced, so its cgroup and output are released, or a job
+	// that keeps being restarted would leak them
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		users.Wait()
+
+		// it can only be removed once every process in it has exited
+		if prev != "" {
+			_ = os.Remove(prev)
+		}
+		if err := j.Close(); err != nil {
+			slog.Error("error closing restarted job", "job_id", j.ID(), "err", err)
+		}
+	}()
+
+	slog.Info("restarting job", "job_id", j.ID(), "restarts", next.Restarts(), "backoff", backoff)
+	w.notify(event.Alert(j, event.TypeRestarting, fmt.Sprintf("restart %d in %s", next.Restarts(), backoff)))
+	close(p.notified)
+
+	return true
+}
+
+// startRestart starts the next attempt of p, unless it was canceled, once the
+// restarting event was delivered. it fails the attempt if there is no room
+// for it.
+func (w *Worker) startRestart(p *pendingRestart) {
+	defer w.wg.Done()
+	<-p.notified
+
+	w.mu.Lock()
+	pending := w.restarting[p.next.ID()] == p
+	delete(w.restarting, p.next.ID())
+	w.mu.Unlock()
+
+	if !pending {
+		return
+	}
+
+	err := w.sched.admit(p.slot)
+	if err == nil {
+		err = w.sched.start(p.slot, p.next, p.cg)
+	}
+	if err == nil {
+		return
+	}
+
+	slog.Error("error restarting job", "job_id", p.next.ID(), "err", err)
+
+	select {
+	case <-p.next.Done():
+	default:
+		reason := job.StopReasonNone
+		if errors.Is(err, ErrWorkerClosed) || errors.Is(err, ErrWorkerCordoned) {
+			reason = job.StopReasonShutdown
+		}
+		p.next.Cancel(reason, err)
+	}
+	w.finished(p.next)
+}
+
+// cancelRestart stops the job identified by jobID, with reason, if it is
+// waiting to be restarted. it returns false if it isn't.
+func (w *Worker) cancelRestart(jobID job.ID, reason job.StopReason) bool {
+	w.mu.Lock()
+	p, ok := w.restarting[jobID]
+	if ok {
+		delete(w.restarting, jobID)
+	}
+	w.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	w.finishRestart(p, reason)
+	return true
+}
+
+// cancelRestarts stops all of the jobs that are waiting to be restarted with
+// reason
+func (w *Worker) cancelRestarts(reason job.StopReason) {
+	w.mu.Lock()
+	pending := w.restarting
+	w.restarting = map[job.ID]*pendingRestart{}
+	w.mu.Unlock()
+
+	for _, p := range pending {
+		w.finishRestart(p, reason)
+	}
+}
+
+// finishRestart completes the next attempt of p, which was canceled and will
+// never be started, with reason and delivers the event describing it
+func (w *Worker) finishRestart(p *pendingRestart, reason job.StopReason) {
+	// the timer holds w.wg, which is handed over to delivering the event,
+	// unless startRestart is already running. it will find that p was
+	// canceled and release its own.
+	if !p.timer.Stop() {
+		w.wg.Add(1)
+	}
+
+	p.next.Cancel(reason, nil)
+
+	go func() {
+		defer w.wg.Done()
+		<-p.notified
+		w.finished(p.next)
+	}()
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RlimitResource is the enum representing the resource that an Rlimit
+// applies to
+type RlimitResource int
+
+// NOTE: keep this synced with jobworker.proto:RlimitResource
+const (
+	RlimitUnspecified RlimitResource = iota
+	RlimitCPU                        // maximum cpu time in seconds (RLIMIT_CPU)
+	RlimitFsize                      // maximum size of files the job may create (RLIMIT_FSIZE)
+	RlimitData                       // maximum size of the data segment (RLIMIT_DATA)
+	RlimitStack                      // maximum size of the stack (RLIMIT_STACK)
+	RlimitCore                       // maximum size of core files (RLIMIT_CORE)
+	RlimitNofile                     // maximum number of open file descriptors (RLIMIT_NOFILE)
+	RlimitAS                         // maximum size of the virtual memory (RLIMIT_AS)
+)
+
+// Rlimit is a resource limit that is applied, with setrlimit(2), to the job
+// process before it is executed
+type Rlimit struct {
+	Resource RlimitResource
+	Soft     uint64 // the soft limit (rlim_cur)
+	Hard     uint64 // the hard limit (rlim_max), must be >= Soft
+}
+
+// ErrInvalidRlimit is returned when an Rlimit is malformed or when a per-job
+// Rlimit would exceed the hard limit configured on the Worker for the same
+// resource
+var ErrInvalidRlimit = errors.New("invalid rlimit")
+
+// validate ensures the rlimit is well formed
+func (r Rlimit) validate() error {
+	if r.Resource <= RlimitUnspecified || r.Resource > RlimitAS {
+		return fmt.Errorf("%w: unknown resource %d", ErrInvalidRlimit, r.Resource)
+	}
+	if r.Soft > r.Hard {
+		return fmt.Errorf("%w: soft limit %d is greater than hard limit %d", ErrInvalidRlimit, r.Soft, r.Hard)
+	}
+	return nil
+}
+
+// validateRlimits ensures that all rlimits are well formed and that each
+// resource is only listed once
+func validateRlimits(rlimits []Rlimit) error {
+	seen := map[RlimitResource]bool{}
+	for _, r := range rlimits {
+		if err := r.validate(); err != nil {
+			return err
+		}
+		if seen[r.Resource] {
+			return fmt.Errorf("%w: resource %d listed more than once", ErrInvalidRlimit, r.Resource)
+		}
+		seen[r.Resource] = true
+	}
+	return nil
+}
+
+// mergeRlimits returns the rlimits in defaults overridden by any of the same
+// resource in overrides. an override may lower, but never raise, the hard limit
+// of a default.
+func mergeRlimits(defaults, overrides []Rlimit) ([]Rlimit, error) {
+	if err := validateRlimits(overrides); err != nil {
+		return nil, err
+	}
+
+	ret := make([]Rlimit, len(defaults))
+	copy(ret, defaults)
+
+	for _, o := range overrides {
+		found := false
+		for i, d := range ret {
+			if d.Resource != o.Resource {
+				continue
+			}
+			if o.Hard > d.Hard {
+				return nil, fmt.Errorf("%w: hard limit %d for resource %d exceeds the maximum of %d", ErrInvalidRlimit, o.Hard, o.Resource, d.Hard)
+			}
+			ret[i] = o
+			found = true
+			break
+		}
+		if !found {
+			ret = append(ret, o)
+		}
+	}
+
+	return ret, nil
+}
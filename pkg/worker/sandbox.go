@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// sandboxExec is the path of sandbox-exec(1), which is only on darwin
+const sandboxExec = "/usr/bin/sandbox-exec"
+
+// ErrInvalidSandboxProfile is returned by New if Config.SandboxProfile is not
+// a regular file
+var ErrInvalidSandboxProfile = errors.New("sandbox profile must be a regular file")
+
+// validateSandboxProfile ensures that profile, if it is set and will be used,
+// is a regular file
+func validateSandboxProfile(profile string) error {
+	if profile == "" || runtime.GOOS != darwinOS {
+		return nil
+	}
+
+	fi, err := os.Stat(profile)
+	if err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s", ErrInvalidSandboxProfile, profile)
+	}
+
+	return nil
+}
+
+// sandboxArgs returns args, the job's command and its arguments, wrapped with
+// sandbox-exec(1) so that the command runs with profile. args is returned
+// unchanged if profile isn't set or the platform isn't darwin.
+func sandboxArgs(profile string, args []string) []string {
+	if profile == "" || runtime.GOOS != darwinOS {
+		return args
+	}
+	return append([]string{sandboxExec, "-f", profile}, args...)
+}
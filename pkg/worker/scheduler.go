@@ -0,0 +1,667 @@
+package worker
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// DefaultPreemptionGracePeriod is used if PreemptionPolicy.GracePeriod is <= 0
+const DefaultPreemptionGracePeriod = 10 * time.Second
+
+var (
+	// ErrWorkerSaturated is returned when starting a job while the Worker is
+	// already running Config.MaxRunningJobs, or the requests of the running
+	// jobs leave no room for it in Config.Capacity, and no job could be
+	// preempted to make room for it
+	ErrWorkerSaturated = errors.New("worker is running the maximum number of jobs")
+
+	// ErrTenantSaturated is returned when starting a job while its tenant is
+	// already running Config.MaxRunningJobsPerTenant
+	ErrTenantSaturated = errors.New("tenant is running the maximum number of jobs")
+
+	// ErrInvalidRequests is returned when starting a job with negative
+	// resource requests, or with requests that exceed Config.Capacity and so
+	// could never be admitted
+	ErrInvalidRequests = errors.New("invalid resource requests")
+)
+
+// Resources are amounts of cpu and memory. They are used for the resources
+// that jobs request and for the capacity of the node they run on.
+type Resources struct {
+	CPU    float64 // in cpus, e.g. 0.5 is half of one cpu
+	Memory uint64  // in bytes
+}
+
+// add adds o to r
+func (r *Resources) add(o *Resources) {
+	r.CPU += o.CPU
+	r.Memory += o.Memory
+}
+
+// sub subtracts o from r
+func (r *Resources) sub(o *Resources) {
+	r.CPU -= o.CPU
+	r.Memory -= o.Memory
+}
+
+// fits returns true if r fits within capacity. zero capacities are
+// unlimited.
+func (r *Resources) fits(capacity *Resources) bool {
+	// allow for the rounding errors of adding and subtracting cpus
+	const epsilon = 1e-9
+
+	return (capacity.CPU == 0 || r.CPU <= capacity.CPU+epsilon) &&
+		(capacity.Memory == 0 || r.Memory <= capacity.Memory)
+}
+
+// validateRequests ensures that requests are positive and could be admitted
+// on a node with capacity, in a queue with queueCapacity
+func validateRequests(requests, capacity, queueCapacity *Resources) error {
+	switch {
+	case requests.CPU < 0:
+		return fmt.Errorf("%w: cpu %v is negative", ErrInvalidRequests, requests.CPU)
+	case !requests.fits(capacity):
+		return fmt.Errorf("%w: %+v exceeds the capacity %+v", ErrInvalidRequests, *requests, *capacity)
+	case !requests.fits(queueCapacity):
+		return fmt.Errorf("%w: %+v exceeds the capacity of the queue %+v", ErrInvalidRequests, *requests, *queueCapacity)
+	}
+	return nil
+}
+
+// PreemptionPolicy determines whether jobs may preempt running jobs with a
+// lower JobOptions.SchedulingPriority when the Worker is saturated. The zero
+// value disables preemption.
+type PreemptionPolicy struct {
+	Enabled bool
+
+	// GracePeriod is how long preempted jobs are given to exit after SIGTERM
+	// before they are killed
+	GracePeriod time.Duration
+
+	// Requeue starts preempted jobs again, as new jobs with the same command
+	// and options, once there is room for them. They keep their description,
+	// annotations and correlation id so that they can be found with ListJobs.
+	Requeue bool
+}
+
+// saturated returns true if err is why a job wasn't admitted: the Worker, its
+// queue or its tenant had no room for it
+func saturated(err error) bool {
+	return errors.Is(err, ErrWorkerSaturated) || errors.Is(err, ErrTenantSaturated)
+}
+
+// startRequest is everything needed to start a job again
+type startRequest struct {
+	tenant  job.TenantID
+	userID  job.UserID
+	opts    JobOptions
+	command string
+	args    []string
+}
+
+// scheduled is a job that was admitted by the scheduler
+type scheduled struct {
+	tenant   job.TenantID
+	queue    string
+	priority int
+	requests Resources
+	seq      uint64        // the order jobs were admitted in
+	job      *job.Job      // nil until the job has started
+	req      *startRequest // nil unless preempted jobs are requeued
+	restart  RestartPolicy
+}
+
+// queued is a job that is waiting for room to start by its
+// JobOptions.StartBy
+type queued struct {
+	slot     *scheduled
+	job      *job.Job
+	cg       string
+	timer    clock.Timer   // fails the job once its start-by time has passed
+	notified chan struct{} // closed once the queued event was delivered
+}
+
+// scheduler admits jobs while the Worker, their queue and their tenant are
+// below their limits and picks the jobs to preempt when they aren't. the lock of the
+// Worker may be taken while mu is held, but not the reverse.
+type scheduler struct {
+	w *Worker
+
+	mu       sync.Mutex
+	seq      uint64
+	running  map[job.ID]*scheduled // admitted and started
+	total    usage                 // the room used by all admitted jobs
+	queues   map[string]*usage     // the room used by the admitted jobs of each queue
+	tenants  map[job.TenantID]int  // the number of admitted jobs of each tenant
+	requeued []*startRequest       // preempted jobs waiting to be started again
+	waiting  []*queued             // jobs waiting to start, by priority and then in order
+}
+
+// admit reserves room for slot, a job that is about to be started. If the
+// Worker, or the job's queue, is saturated, running jobs with a lower priority
+// are preempted, and waited for, if the PreemptionPolicy permits, otherwise
+// ErrWorkerSaturated is returned. If the job's tenant is saturated,
+// ErrTenantSaturated is returned, since preempting the jobs of other tenants
+// wouldn't make room for it. Once admitted, slot must be passed to start.
+func (s *scheduler) admit(slot *scheduled) error {
+	qc := s.w.cfg.Queues[slot.queue]
+	if err := validateRequests(&slot.requests, &s.w.cfg.Capacity, &qc.Capacity); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+
+	if !s.fitsTenant(slot) {
+		s.mu.Unlock()
+		return ErrTenantSaturated
+	}
+
+	s.seq++
+	slot.seq = s.seq
+
+	if s.fits(s.total, s.queueUsage(slot.queue), slot) {
+		s.reserve(slot)
+		s.mu.Unlock()
+		return nil
+	}
+
+	victims := s.victims(slot)
+	if victims == nil {
+		s.mu.Unlock()
+		return ErrWorkerSaturated
+	}
+
+	// the victims' room is handed over to the new job
+	for _, victim := range victims {
+		delete(s.running, victim.job.ID())
+		s.free(victim)
+	}
+	s.reserve(slot)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, victim := range victims {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.preempt(victim)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// fitsTotal returns true if slot fits within the Worker's limits alongside
+// the jobs using total. s.mu must be held.
+func (s *scheduler) fitsTotal(total usage, slot *scheduled) bool {
+	return total.fits(&slot.requests, s.w.cfg.MaxRunningJobs, &s.w.cfg.Capacity)
+}
+
+// fitsQueue returns true if slot fits within the limits of its queue
+// alongside the jobs using queue. s.mu must be held.
+func (s *scheduler) fitsQueue(queue usage, slot *scheduled) bool {
+	qc := s.w.cfg.Queues[slot.queue]
+	return queue.fits(&slot.requests, qc.MaxRunningJobs, &qc.Capacity)
+}
+
+// fitsTenant returns true if slot fits within the limit on the number of jobs
+// of its tenant. s.mu must be held.
+func (s *scheduler) fitsTenant(slot *scheduled) bool {
+	limit := s.w.cfg.MaxRunningJobsPerTenant
+	return limit <= 0 || s.tenants[slot.tenant] < limit
+}
+
+// fits returns true if slot fits within the limits of both the Worker and its
+// queue alongside the jobs using total and queue. s.mu must be held.
+func (s *scheduler) fits(total, queue usage, slot *scheduled) bool {
+	return s.fitsTotal(total, slot) && s.fitsQueue(queue, slot)
+}
+
+// queueUsage returns the room used by the admitted jobs of the named queue.
+// s.mu must be held.
+func (s *scheduler) queueUsage(name string) usage {
+	if u := s.queues[name]; u != nil {
+		return *u
+	}
+	return usage{}
+}
+
+// reserve reserves room for slot. s.mu must be held.
+func (s *scheduler) reserve(slot *scheduled) {
+	s.total.add(&slot.requests)
+
+	u := s.queues[slot.queue]
+	if u == nil {
+		u = &usage{}
+		s.queues[slot.queue] = u
+	}
+	u.add(&slot.requests)
+
+	s.tenants[slot.tenant]++
+}
+
+// free frees the room used by slot. s.mu must be held.
+func (s *scheduler) free(slot *scheduled) {
+	s.total.sub(&slot.requests)
+
+	u := s.queues[slot.queue]
+	u.sub(&slot.requests)
+	if u.jobs == 0 {
+		delete(s.queues, slot.queue)
+	}
+
+	if s.tenants[slot.tenant]--; s.tenants[slot.tenant] == 0 {
+		delete(s.tenants, slot.tenant)
+	}
+}
+
+// victims returns the jobs to preempt to make room for slot, or nil if there
+// are none. they are the running jobs with the lowest priorities below that of
+// slot, and of those the ones admitted last, since they have likely done the
+// least work. if only the queue of slot is saturated, they are from that
+// queue. s.mu must be held.
+func (s *scheduler) victims(slot *scheduled) []*scheduled {
+	if !s.w.cfg.Preemption.Enabled {
+		return nil
+	}
+
+	var candidates []*scheduled
+	for _, c := range s.running {
+		if c.priority < slot.priority {
+			candidates = append(candidates, c)
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b *scheduled) int {
+		if a.priority != b.priority {
+			return cmp.Compare(a.priority, b.priority)
+		}
+		return cmp.Compare(b.seq, a.seq)
+	})
+
+	// preempting jobs of other queues only helps if the Worker is saturated
+	var victims []*scheduled
+	total, queue := s.total, s.queueUsage(slot.queue)
+	for _, c := range candidates {
+		if c.queue != slot.queue && s.fitsTotal(total, slot) {
+			continue
+		}
+
+		victims = append(victims, c)
+		total.sub(&c.requests)
+		if c.queue == slot.queue {
+			queue.sub(&c.requests)
+		}
+
+		if s.fits(total, queue, slot) {
+			return victims
+		}
+	}
+
+	return nil
+}
+
+// preempt stops victim and waits for it to be done. if preempted jobs are
+// requeued, it is queued to start again.
+func (s *scheduler) preempt(victim *scheduled) {
+	grace := s.w.cfg.Preemption.GracePeriod
+	if grace <= 0 {
+		grace = DefaultPreemptionGracePeriod
+	}
+
+	slog.Info("preempting job", "job_id", victim.job.ID(), "priority", victim.priority)
+	if err := victim.job.Preempt(grace); err != nil {
+		slog.Error("error preempting job", "job_id", victim.job.ID(), "err", err)
+	}
+
+	if victim.req != nil {
+		s.mu.Lock()
+		s.requeued = append(s.requeued, victim.req)
+		s.mu.Unlock()
+	}
+}
+
+// start starts j, which was admitted with slot, in the cgroup at path cg. if
+// it fails, the room reserved for j is freed.
+func (s *scheduler) start(slot *scheduled, j *job.Job, cg string) error {
+	err := s.w.launch(j, cg, nil, func() { s.run(slot, j) })
+	if err != nil {
+		s.mu.Lock()
+		s.free(slot)
+		s.mu.Unlock()
+	}
+
+	return err
+}
+
+// run records that the job admitted with slot has started as j
+func (s *scheduler) run(slot *scheduled, j *job.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot.job = j
+	s.running[j.ID()] = slot
+}
+
+// enqueue queues j, which was refused by admit with slot, to be started in
+// the cgroup at path cg once there is room for it. if there is no room by
+// startBy, it fails with job.ErrStartDeadlineExceeded. if room was freed since
+// j was refused, it is reserved for j instead, true is returned and slot must
+// be passed to start, as with admit.
+func (s *scheduler) enqueue(slot *scheduled, j *job.Job, cg string, startBy time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fitsTenant(slot) && s.fits(s.total, s.queueUsage(slot.queue), slot) {
+		s.reserve(slot)
+		return true, nil
+	}
+
+	w := s.w
+
+	w.mu.Lock()
+	if err := w.acceptingLocked(nil); err != nil {
+		w.mu.Unlock()
+		return false, err
+	}
+	w.jobs.store(j, "")
+	w.wg.Add(1)
+	w.mu.Unlock()
+
+	q := queued{slot: slot, job: j, cg: cg, notified: make(chan struct{})}
+
+	go func() {
+		defer w.wg.Done()
+		defer close(q.notified)
+		w.notify(event.ForJob(j))
+	}()
+
+	// after the jobs with the same or a higher priority
+	i := slices.IndexFunc(s.waiting, func(o *queued) bool {
+		return o.slot.priority < slot.priority
+	})
+	if i < 0 {
+		i = len(s.waiting)
+	}
+	s.waiting = slices.Insert(s.waiting, i, &q)
+
+	// s.mu is held, so the timer can't fire before it is set
+	q.timer = s.w.clock.AfterFunc(clock.Until(s.w.clock, startBy), func() { s.expire(&q) })
+
+	slog.Info("queued job", "job_id", j.ID(), "queue", slot.queue, "priority", slot.priority, "start_by", startBy)
+
+	return false, nil
+}
+
+// dequeue removes q from the queue. it returns false if q is no longer
+// queued. s.mu must be held.
+func (s *scheduler) dequeue(q *queued) bool {
+	i := slices.Index(s.waiting, q)
+	if i < 0 {
+		return false
+	}
+
+	s.waiting = slices.Delete(s.waiting, i, i+1)
+	q.timer.Stop()
+
+	return true
+}
+
+// expire fails q, if it is still queued, since its start-by time has passed
+func (s *scheduler) expire(q *queued) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dequeue(q) {
+		s.finish(q, job.StopReasonNone, job.ErrStartDeadlineExceeded)
+	}
+}
+
+// cancel stops the job identified by jobID, with reason, if it is queued. it
+// returns false if it isn't queued.
+func (s *scheduler) cancel(jobID job.ID, reason job.StopReason) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.waiting, func(q *queued) bool {
+		return q.job.ID() == jobID
+	})
+	if i < 0 {
+		return false
+	}
+
+	q := s.waiting[i]
+	s.dequeue(q)
+	s.finish(q, reason, nil)
+
+	return true
+}
+
+// cancelAll stops all of the queued jobs with reason
+func (s *scheduler) cancelAll(reason job.StopReason) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, q := range s.waiting {
+		q.timer.Stop()
+		s.finish(q, reason, nil)
+	}
+	s.waiting = nil
+}
+
+// finish completes the job of q, which was dequeued and never started, with
+// reason and err and delivers the event describing it. s.mu must be held so
+// that Shutdown can't miss the event.
+func (s *scheduler) finish(q *queued, reason job.StopReason, err error) {
+	q.job.Cancel(reason, err)
+	s.notifyQueued(q)
+}
+
+// notifyQueued delivers the event describing the job of q, which has been
+// dequeued, once the queued event was delivered. s.mu must be held.
+func (s *scheduler) notifyQueued(q *queued) {
+	w := s.w
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		<-q.notified
+		w.finished(q.job)
+	}()
+}
+
+// dequeueReady dequeues queued jobs, in order, and reserves room for them
+// until there is no room in the Worker for the next one. jobs are skipped while
+// there is no room in their queue, but no later job of the same queue is
+// dequeued before them. jobs are also skipped while there is no room in their
+// tenant. the dequeued jobs must be passed to startQueued. s.mu must be held.
+func (s *scheduler) dequeueReady() []*queued {
+	var ready []*queued
+	blocked := map[string]bool{}
+	for _, q := range slices.Clone(s.waiting) {
+		if blocked[q.slot.queue] || !s.fitsTenant(q.slot) {
+			continue
+		}
+
+		if !s.fitsTotal(s.total, q.slot) {
+			break
+		}
+
+		if !s.fitsQueue(s.queueUsage(q.slot.queue), q.slot) {
+			blocked[q.slot.queue] = true
+			continue
+		}
+
+		s.dequeue(q)
+		s.reserve(q.slot)
+		ready = append(ready, q)
+	}
+	return ready
+}
+
+// startQueued starts the jobs dequeued by dequeueReady, in order. s.mu must not
+// be held.
+func (s *scheduler) startQueued(ready []*queued) {
+	for _, q := range ready {
+		err := s.w.launch(q.job, q.cg, q.notified, func() { s.run(q.slot, q.job) })
+		if err == nil {
+			slog.Info("started queued job", "job_id", q.job.ID())
+			continue
+		}
+
+		s.mu.Lock()
+		s.free(q.slot)
+		if errors.Is(err, ErrWorkerClosed) || errors.Is(err, ErrWorkerCordoned) {
+			s.finish(q, job.StopReasonShutdown, nil)
+		} else {
+			// the job has already failed with err
+			s.notifyQueued(q)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// done frees the room used by j, which is done, and starts any queued, and
+// then requeued, jobs that now fit. it returns the slot j was admitted with,
+// or nil if j was preempted.
+func (s *scheduler) done(j *job.Job) *scheduled {
+	s.mu.Lock()
+	// preempted jobs have already handed their room over
+	slot, ok := s.running[j.ID()]
+	if ok {
+		delete(s.running, j.ID())
+		s.free(slot)
+	}
+	ready := s.dequeueReady()
+	s.mu.Unlock()
+
+	s.startQueued(ready)
+	s.startRequeued()
+
+	return slot
+}
+
+// startRequeued starts requeued jobs, in the order they were preempted, until
+// there is no more room for them
+func (s *scheduler) startRequeued() {
+	for {
+		s.mu.Lock()
+		if len(s.requeued) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		req := s.requeued[0]
+		s.requeued = s.requeued[1:]
+		s.mu.Unlock()
+
+		jobID, err := s.w.StartJobWithOptions(req.tenant, req.userID, &req.opts, req.command, req.args...)
+		switch {
+		case saturated(err):
+			// try again when the next job is done
+			s.mu.Lock()
+			s.requeued = append([]*startRequest{req}, s.requeued...)
+			s.mu.Unlock()
+			return
+		case errors.Is(err, ErrWorkerClosed), errors.Is(err, ErrWorkerCordoned):
+			return
+		case err != nil:
+			slog.Error("error starting requeued job", "user_id", req.userID, "err", err)
+		default:
+			slog.Info("started requeued job", "job_id", jobID)
+		}
+	}
+}
+
+// Allocation describes how much of the Worker's capacity, or that of one of
+// its queues, is in use
+type Allocation struct {
+	Jobs      int       // the number of running jobs, including those being started
+	Requested Resources // the sum of the requests of those jobs
+	Capacity  Resources // Config.Capacity, or QueueConfig.Capacity
+	Queued    int       // the number of jobs waiting for room to start
+
+	// Queues is the allocation of each queue that is configured or in use.
+	// It is nil for the allocations of queues.
+	Queues map[string]*Allocation
+}
+
+// Allocation returns how much of the Worker's capacity is in use. It must
+// only be exposed to administrators.
+func (w *Worker) Allocation() *Allocation {
+	w.sched.mu.Lock()
+	defer w.sched.mu.Unlock()
+
+	ret := Allocation{
+		Jobs:      w.sched.total.jobs,
+		Requested: w.sched.total.requested,
+		Capacity:  w.cfg.Capacity,
+		Queued:    len(w.sched.waiting),
+		Queues:    map[string]*Allocation{},
+	}
+
+	queue := func(name string) *Allocation {
+		a := ret.Queues[name]
+		if a == nil {
+			a = &Allocation{Capacity: w.cfg.Queues[name].Capacity}
+			ret.Queues[name] = a
+		}
+		return a
+	}
+
+	for name := range w.cfg.Queues {
+		queue(name)
+	}
+
+	for name, u := range w.sched.queues {
+		a := queue(name)
+		a.Jobs = u.jobs
+		a.Requested = u.requested
+	}
+
+	for _, q := range w.sched.waiting {
+		queue(q.slot.queue).Queued++
+	}
+
+	return &ret
+}
+
+// newStartRequest returns a startRequest that doesn't share any memory with
+// the caller's arguments
+func newStartRequest(tenant job.TenantID, userID job.UserID, opts *JobOptions, command string, args []string) *startRequest {
+	req := startRequest{
+		tenant:  tenant,
+		userID:  userID,
+		opts:    *opts,
+		command: command,
+		args:    slices.Clone(args),
+	}
+
+	req.opts.Rlimits = slices.Clone(opts.Rlimits)
+	req.opts.Env = slices.Clone(opts.Env)
+	req.opts.Annotations = maps.Clone(opts.Annotations)
+	req.opts.GPUs = slices.Clone(opts.GPUs)
+	if opts.Priority != nil {
+		p := *opts.Priority
+		req.opts.Priority = &p
+	}
+	if opts.Init != nil {
+		v := *opts.Init
+		req.opts.Init = &v
+	}
+	if opts.Readiness != nil {
+		p := *opts.Readiness
+		req.opts.Readiness = &p
+	}
+
+	return &req
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// SelfTestUserID is the user that owns the job run by SelfTest
+const SelfTestUserID job.UserID = "job-worker-self-test"
+
+// ErrSelfTestFailed is returned by SelfTest if the job didn't complete
+// successfully
+var ErrSelfTestFailed = errors.New("self test failed")
+
+// SelfTest runs "true" as a job of the default tenant, with the strict
+// isolation profile, and waits for it to complete. It catches environments
+// where jobs can't be run, e.g. because cgroups, namespaces, seccomp or
+// dropping capabilities are unavailable, so that servers can run it at startup
+// and only report that they are serving once it has passed. If the strict
+// profile is overridden in Config.IsolationProfiles, SelfTestUserID must be
+// permitted to use it. The job is removed once it is done. If it doesn't
+// complete successfully before ctx is done, ErrSelfTestFailed is returned.
+func (w *Worker) SelfTest(ctx context.Context) error {
+	jobID, err := w.StartJobWithOptions("", SelfTestUserID, &JobOptions{
+		Description:      "self test",
+		IsolationProfile: IsolationStrict,
+	}, "true")
+	if err != nil {
+		return fmt.Errorf("%w: error starting job: %w", ErrSelfTestFailed, err)
+	}
+
+	st, err := w.WaitJobStatus(ctx, "", SelfTestUserID, jobID, job.StatusRunning)
+	if err == nil && st.Status == job.StatusRunning {
+		err = w.StopJob("", SelfTestUserID, jobID)
+		if err == nil {
+			err = context.Cause(ctx)
+		}
+	}
+
+	if rerr := w.RemoveJob("", SelfTestUserID, jobID); rerr != nil && err == nil {
+		err = rerr
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSelfTestFailed, err)
+	}
+
+	if st.Reason != ReasonCompletedOK {
+		err = fmt.Errorf("%w: job completed with reason %s", ErrSelfTestFailed, st.Reason)
+		if st.Error != nil {
+			err = fmt.Errorf("%w: %w", err, st.Error)
+		}
+		return err
+	}
+
+	return nil
+}
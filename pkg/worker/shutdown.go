@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// ShutdownPolicy determines what happens to running jobs when the Worker is
+// shut down
+type ShutdownPolicy int
+
+const (
+	ShutdownStopJobs     ShutdownPolicy = iota // running jobs are stopped
+	ShutdownWaitForJobs                        // running jobs are allowed to complete until the shutdown deadline, then they are stopped
+	ShutdownLeaveRunning                       // running jobs are left running, only their output readers are closed
+)
+
+// Shutdown stops the Worker from starting new jobs, handles running jobs
+// according to Config.ShutdownPolicy, closes all open output readers and
+// waits for any pending events to be delivered before closing the channels
+// returned by WatchJobs. ctx bounds how long ShutdownWaitForJobs waits for
+// jobs to complete. The cgroups of the jobs, and the Worker's root cgroup,
+// are removed, unless jobs are left running. The status of existing jobs can
+// still be queried afterwards, but without their CGroupStats. The lock on
+// Config.WALDir is released so that a standby Worker can take over.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+
+	// no more jobs can be added once those being started have been
+	w.waitForLaunches()
+	jobs := w.jobs.all()
+
+	// queued jobs will never have room to start
+	w.sched.cancelAll(job.StopReasonShutdown)
+	w.cancelRestarts(job.StopReasonShutdown)
+	w.stopExpiring()
+
+	var errs []error
+
+	if w.cfg.ShutdownPolicy == ShutdownLeaveRunning {
+		// jobs can keep writing output, but nobody is reading it anymore.
+		// events for jobs that are left running will never be delivered so
+		// there is nothing to wait for.
+		for _, j := range jobs {
+			j.CloseOutputReaders()
+		}
+		w.watchers.close()
+		return errors.Join(w.usage.close(), w.unlockWALDir())
+	}
+
+	if w.cfg.ShutdownPolicy == ShutdownWaitForJobs {
+		if err := waitForJobs(ctx, jobs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, j := range jobs {
+		select {
+		case <-j.Done():
+		default:
+			if err := j.StopForShutdown(); err != nil {
+				errs = append(errs, fmt.Errorf("error stopping job %s: %w", j.ID(), err))
+			}
+		}
+
+		if err := j.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	w.wg.Wait()
+	w.watchers.close()
+
+	// all of the jobs are done, and nothing reads their cgroups anymore
+	w.removeCGroups()
+
+	if err := w.usage.close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := w.unlockWALDir(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// removeCGroups removes the leaf cgroups of all jobs, which are done, and then
+// the root cgroup. a cgroup can only be removed once every process in it has
+// exited.
+func (w *Worker) removeCGroups() {
+	if w.rootCGroupName == "" {
+		return
+	}
+
+	for _, cg := range w.jobs.cgroups() {
+		if err := os.Remove(cg); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("error removing cgroup", "cgroup", cg, "err", err)
+		}
+	}
+
+	if err := os.Remove(w.rootCGroupName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("error removing root cgroup", "cgroup", w.rootCGroupName, "err", err)
+	}
+}
+
+// waitForJobs waits for all jobs to be done or for ctx to be done
+func waitForJobs(ctx context.Context, jobs []*job.Job) error {
+	for _, j := range jobs {
+		select {
+		case <-j.Done():
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting for jobs to complete: %w", context.Cause(ctx))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"syscall"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// SignalSource describes where the signal that terminated a job came from
+type SignalSource int
+
+// NOTE: keep this synced with jobworker.proto:SignalSource
+const (
+	SignalSourceNone     SignalSource = iota // the job was not terminated by a signal
+	SignalSourceStop                         // the job was stopped by StopJob, a timeout or the worker shutting down
+	SignalSourceOOM                          // the job was killed by the oom killer after exceeding its memory limit
+	SignalSourceExternal                     // the signal was sent by something other than the worker
+)
+
+// signalSource determines where the signal that terminated j came from.
+// cgStats are the stats of the job's cgroup and may be nil.
+func signalSource(j *job.Job, cgStats *CGroupStats) SignalSource {
+	switch {
+	case j.Signal() == 0:
+		return SignalSourceNone
+	case j.StopReason() != job.StopReasonNone:
+		return SignalSourceStop
+	case j.Signal() == syscall.SIGKILL && cgStats != nil && cgStats.MemoryOOMKill > 0:
+		return SignalSourceOOM
+	default:
+		return SignalSourceExternal
+	}
+}
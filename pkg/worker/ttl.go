@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// finished delivers the event describing j, which is done and will not be
+// restarted, and schedules its removal once its JobOptions.TTLAfterFinished
+// has passed. jobs aren't removed once the worker is closing.
+func (w *Worker) finished(j *job.Job) {
+	w.notify(event.ForJob(j))
+
+	ttl := j.TTLAfterFinished()
+	if ttl <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.closed {
+		w.expiring[j.ID()] = w.clock.AfterFunc(ttl, func() { w.expire(j) })
+	}
+}
+
+// expire removes j, its output and its cgroup once its TTL has passed. unlike
+// RemoveJob, its record isn't kept in the history. it does nothing if j was
+// already removed, or if the worker is closing.
+func (w *Worker) expire(j *job.Job) {
+	w.mu.Lock()
+	delete(w.expiring, j.ID())
+	closed := w.closed
+	w.mu.Unlock()
+
+	if closed {
+		return
+	}
+
+	e, ok := w.jobs.removeJob(j)
+	if !ok {
+		return
+	}
+
+	// it can only be removed once every process in it has exited
+	if e.cg != "" {
+		_ = os.Remove(e.cg)
+	}
+
+	if err := w.removeJobWAL(j); err != nil {
+		slog.Error("error removing expired job", "job_id", j.ID(), "err", err)
+	}
+
+	slog.Info("removed expired job", "job_id", j.ID())
+}
+
+// stopExpiring stops the ttl timers of all of the finished jobs
+func (w *Worker) stopExpiring() {
+	w.mu.Lock()
+	expiring := w.expiring
+	w.expiring = map[job.ID]clock.Timer{}
+	w.mu.Unlock()
+
+	for _, t := range expiring {
+		t.Stop()
+	}
+}
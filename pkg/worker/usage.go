@@ -0,0 +1,319 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+const (
+	// UsageBucket is the granularity that usage is accounted at. The time
+	// windows of UsageReport are rounded to it.
+	UsageBucket = time.Hour
+
+	// usageFileName is the name of the file in WALDir that usage is persisted
+	// to
+	usageFileName = "usage.jsonl"
+)
+
+// Usage is the resources used by jobs, as measured by their cgroups
+type Usage struct {
+	CPUTime           time.Duration // the cpu time used, from cpu.stat
+	MemoryByteSeconds float64       // memory.current integrated over time
+	IOReadBytes       uint64        // bytes read from block devices, from io.stat
+	IOWriteBytes      uint64        // bytes written to block devices, from io.stat
+}
+
+// add adds o to u
+func (u *Usage) add(o *Usage) {
+	u.CPUTime += o.CPUTime
+	u.MemoryByteSeconds += o.MemoryByteSeconds
+	u.IOReadBytes += o.IOReadBytes
+	u.IOWriteBytes += o.IOWriteBytes
+}
+
+// usageSample is a point in time reading of the cumulative usage of a cgroup
+type usageSample struct {
+	time    time.Time
+	cpu     time.Duration
+	memory  uint64 // memory.current, it is not cumulative
+	ioRead  uint64
+	ioWrite uint64
+}
+
+// readUsageSample reads cpu.stat, memory.current and io.stat from the cgroup at
+// path, at now
+func readUsageSample(path string, now time.Time) (*usageSample, error) {
+	cpu, err := os.ReadFile(filepath.Join(path, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := os.ReadFile(filepath.Join(path, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+
+	io, err := os.ReadFile(filepath.Join(path, "io.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := usageSample{
+		time: now,
+		cpu:  time.Duration(parseFlatKeyed(cpu)["usage_usec"]) * time.Microsecond,
+	}
+
+	if s.memory, err = strconv.ParseUint(string(bytes.TrimSpace(memory)), 10, 64); err != nil {
+		return nil, fmt.Errorf("error parsing memory.current: %w", err)
+	}
+
+	s.ioRead, s.ioWrite = parseIOStat(io)
+
+	return &s, nil
+}
+
+// parseIOStat returns the total bytes read and written, across all devices,
+// from the contents of io.stat. each line is a device followed by "key=value"
+// pairs.
+func parseIOStat(data []byte) (read, write uint64) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, kv := range fields[1:] {
+			key, value, _ := strings.Cut(kv, "=")
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			switch key {
+			case "rbytes":
+				read += v
+			case "wbytes":
+				write += v
+			}
+		}
+	}
+	return read, write
+}
+
+// usageSince returns the usage between prev and s. memory is integrated with
+// the trapezoidal rule since only its current value is known at each sample.
+func (s *usageSample) usageSince(prev *usageSample) *Usage {
+	if prev == nil {
+		prev = &usageSample{time: s.time}
+	}
+
+	elapsed := s.time.Sub(prev.time).Seconds()
+
+	return &Usage{
+		CPUTime:           s.cpu - prev.cpu,
+		MemoryByteSeconds: float64(s.memory+prev.memory) / 2 * elapsed,
+		IOReadBytes:       s.ioRead - prev.ioRead,
+		IOWriteBytes:      s.ioWrite - prev.ioWrite,
+	}
+}
+
+// usageKey identifies a bucket of usage
+type usageKey struct {
+	Bucket time.Time    `json:"bucket"`
+	UserID job.UserID   `json:"user_id"`
+	Tenant job.TenantID `json:"tenant,omitempty"`
+}
+
+// usageEntry is a line of the usage file
+type usageEntry struct {
+	usageKey
+	Usage
+}
+
+// usageLedger accumulates usage per user per UsageBucket. if it has a file,
+// each addition is appended to it so that usage survives restarts.
+type usageLedger struct {
+	mu      sync.Mutex
+	buckets map[usageKey]*Usage
+	file    *os.File
+}
+
+// add accounts u to userID in tenant for the bucket containing t
+func (l *usageLedger) add(userID job.UserID, tenant job.TenantID, t time.Time, u *Usage) {
+	key := usageKey{
+		Bucket: t.Truncate(UsageBucket).UTC(),
+		UserID: userID,
+		Tenant: tenant,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.addLocked(key, u)
+
+	if l.file == nil {
+		return
+	}
+
+	line, err := json.Marshal(&usageEntry{usageKey: key, Usage: *u})
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		slog.Error("error persisting usage", "user_id", userID, "err", err)
+	}
+}
+
+// addLocked adds u to the bucket for key. l.mu must be held.
+func (l *usageLedger) addLocked(key usageKey, u *Usage) {
+	if l.buckets == nil {
+		l.buckets = map[usageKey]*Usage{}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &Usage{}
+		l.buckets[key] = b
+	}
+	b.add(u)
+}
+
+// open loads the usage persisted to path, compacts the file to a single entry
+// per bucket and keeps it open to persist further usage
+func (l *usageLedger) open(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading usage: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var e usageEntry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a partially written final line from a crash
+			slog.Warn("skipping invalid usage entry", "path", path, "err", err)
+			continue
+		}
+		l.addLocked(e.usageKey, &e.Usage)
+	}
+
+	var compacted bytes.Buffer
+	enc := json.NewEncoder(&compacted)
+	for key, u := range l.buckets {
+		if err = enc.Encode(&usageEntry{usageKey: key, Usage: *u}); err != nil {
+			return err
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, compacted.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("error compacting usage: %w", err)
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error compacting usage: %w", err)
+	}
+
+	if l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return fmt.Errorf("error opening usage: %w", err)
+	}
+
+	return nil
+}
+
+// close closes the file usage is persisted to, if any
+func (l *usageLedger) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// report returns the usage of each user in tenant, or all tenants if it is
+// empty, in the buckets that start at or after since and before until. the
+// zero time leaves that end of the window open.
+func (l *usageLedger) report(tenant job.TenantID, since, until time.Time) map[job.UserID]Usage {
+	since = since.Truncate(UsageBucket)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ret := map[job.UserID]Usage{}
+	for key, u := range l.buckets {
+		switch {
+		case tenant != "" && key.Tenant != tenant,
+			!since.IsZero() && key.Bucket.Before(since),
+			!until.IsZero() && !key.Bucket.Before(until):
+			continue
+		}
+
+		total := ret[key.UserID]
+		total.add(u)
+		ret[key.UserID] = total
+	}
+
+	return ret
+}
+
+// meterUsage samples the usage of the cgroup at path each Config.UsageInterval
+// until j is done, and once more after, and accounts it to the owner of j
+func (w *Worker) meterUsage(j *job.Job, path string) {
+	defer w.wg.Done()
+
+	ticker := clock.NewTicker(w.clock, w.cfg.UsageInterval)
+	defer ticker.Stop()
+
+	var prev *usageSample
+	sample := func() {
+		s, err := readUsageSample(path, w.clock.Now())
+		if err != nil {
+			// the child may not have created the cgroup yet
+			return
+		}
+		w.usage.add(j.UserID(), j.TenantID(), s.time, s.usageSince(prev))
+		prev = s
+	}
+
+	for {
+		select {
+		case <-j.Done():
+			sample()
+			return
+		case <-ticker.C:
+			sample()
+		}
+	}
+}
+
+// UsageReport returns the resources used by each user's jobs in tenant,
+// regardless of user, between since and until. The window is rounded to
+// UsageBucket and the zero time leaves that end of it open. If tenant is empty,
+// jobs from all tenants are included. Usage is only accounted when
+// Config.UsageInterval is set, and is persisted across restarts when
+// Config.WALDir is set. It must only be exposed to administrators of tenant.
+func (w *Worker) UsageReport(tenant job.TenantID, since, until time.Time) map[job.UserID]Usage {
+	return w.usage.report(tenant, since, until)
+}
@@ -0,0 +1,189 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/wal"
+)
+
+const (
+	// walExt is the file extension of job write-ahead logs
+	walExt = ".wal"
+
+	// walLockName is the name of the file in WALDir that is locked by the
+	// Worker using it
+	walLockName = "lock"
+
+	// standbyInterval is how often NewStandby tries to take the lock on
+	// WALDir
+	standbyInterval = 100 * time.Millisecond
+)
+
+// NewStandby is like New, but if another Worker, in this or another process,
+// holds the lock on config.WALDir it waits until that Worker is shut down, or
+// its process dies, and then takes over its jobs. This allows a standby to
+// share the WALDir with an active Worker without both of them starting jobs.
+// Jobs that were still running are recovered with job.StatusInterrupted and
+// are never started again. It returns the cause of ctx if ctx is done first.
+func NewStandby(ctx context.Context, config *Config) (*Worker, error) {
+	t := time.NewTicker(standbyInterval)
+	defer t.Stop()
+
+	for {
+		w, err := New(config)
+		if !errors.Is(err, ErrWALDirLocked) {
+			return w, err
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+// lockWALDir takes an exclusive lock on dir and returns the locked file. The
+// lock is held until the file is closed or the process exits.
+func lockWALDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, walLockName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening wal dir lock: %w", err)
+	}
+
+	if err = tryLock(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// unlockWALDir releases the lock on WALDir, if it is held
+func (w *Worker) unlockWALDir() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.walLock == nil {
+		return nil
+	}
+
+	err := w.walLock.Close()
+	w.walLock = nil
+	return err
+}
+
+// walPath returns the path of the write-ahead log for jobID
+func (w *Worker) walPath(jobID job.ID) string {
+	return filepath.Join(w.cfg.WALDir, jobID.String()+walExt)
+}
+
+// createWAL creates the write-ahead log for j and makes its output durable
+func (w *Worker) createWAL(j *job.Job) error {
+	if w.cfg.WALDir == "" {
+		return ErrWALDirRequired
+	}
+
+	meta, err := json.Marshal(j.Metadata())
+	if err != nil {
+		return err
+	}
+
+	path := w.walPath(j.ID())
+
+	l, err := wal.Create(path, meta)
+	if err != nil {
+		return fmt.Errorf("error creating wal: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		_ = l.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("error creating wal: %w", err)
+	}
+
+	w.disk.add(j, info.Size())
+	l.SetQuota(walQuota{budget: &w.disk, jobID: j.ID()})
+
+	j.SetWAL(l)
+	return nil
+}
+
+// removeJobWAL closes j and removes its write-ahead log, if it has one
+func (w *Worker) removeJobWAL(j *job.Job) error {
+	err := j.Close()
+	w.disk.remove(j.ID())
+
+	if w.cfg.WALDir != "" {
+		if rerr := os.Remove(w.walPath(j.ID())); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			err = errors.Join(err, rerr)
+		}
+	}
+
+	return err
+}
+
+// recoverJobs restores the jobs with write-ahead logs in WALDir. jobs that
+// were still running when the worker stopped have job.StatusInterrupted. logs
+// that can't be recovered are skipped.
+func (w *Worker) recoverJobs() error {
+	entries, err := os.ReadDir(w.cfg.WALDir)
+	if err != nil {
+		return fmt.Errorf("error reading wal dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), walExt) {
+			continue
+		}
+
+		path := filepath.Join(w.cfg.WALDir, entry.Name())
+
+		j, err := recoverJob(path)
+		if err != nil {
+			slog.Warn("error recovering job", "path", path, "err", err)
+			continue
+		}
+
+		w.jobs.store(j, "")
+
+		if info, err := entry.Info(); err == nil {
+			w.disk.add(j, info.Size())
+		}
+	}
+
+	return nil
+}
+
+// recoverJob restores a single job from the write-ahead log at path
+func recoverJob(path string) (*job.Job, error) {
+	contents, err := wal.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta job.Metadata
+	if err = json.Unmarshal(contents.Metadata, &meta); err != nil {
+		return nil, err
+	}
+
+	var outcome *job.Outcome
+	if contents.Final != nil {
+		outcome = &job.Outcome{}
+		if err = json.Unmarshal(contents.Final, outcome); err != nil {
+			return nil, err
+		}
+	}
+
+	return job.Recover(&meta, contents.Data, outcome), nil
+}
@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
+	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+)
+
+// watchBuffer is the number of events that are buffered for a watcher before
+// it is considered to be too slow and is closed
+const watchBuffer = 64
+
+// watcher receives the events of the jobs of tenant that userID has access to
+type watcher struct {
+	tenant job.TenantID
+	userID job.UserID
+	ch     chan *event.Event
+}
+
+// watchers is an event.Sink that delivers events to the callers of WatchJobs
+type watchers struct {
+	w *Worker
+
+	mu     sync.Mutex
+	list   map[*watcher]struct{}
+	closed bool
+}
+
+// ensure watchers implements the event.Sink interface
+var _ event.Sink = (*watchers)(nil)
+
+// Notify delivers e to each watcher that has access to the job. It never
+// blocks, watchers that have fallen too far behind are closed instead.
+func (ws *watchers) Notify(_ context.Context, e *event.Event) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for wt := range ws.list {
+		if !ws.w.canWatch(wt.tenant, wt.userID, e) {
+			continue
+		}
+
+		select {
+		case wt.ch <- e:
+		default:
+			ws.remove(wt)
+		}
+	}
+
+	return nil
+}
+
+// add registers a new watcher for userID, of tenant. It returns nil if the
+// watchers have been closed.
+func (ws *watchers) add(tenant job.TenantID, userID job.UserID) *watcher {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.closed {
+		return nil
+	}
+
+	wt := watcher{
+		tenant: tenant,
+		userID: userID,
+		ch:     make(chan *event.Event, watchBuffer),
+	}
+
+	if ws.list == nil {
+		ws.list = map[*watcher]struct{}{}
+	}
+	ws.list[&wt] = struct{}{}
+
+	return &wt
+}
+
+// remove closes wt, if it hasn't been already. ws.mu must be held.
+func (ws *watchers) remove(wt *watcher) {
+	if _, ok := ws.list[wt]; ok {
+		delete(ws.list, wt)
+		close(wt.ch)
+	}
+}
+
+// unwatch closes wt, if it hasn't been already
+func (ws *watchers) unwatch(wt *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.remove(wt)
+}
+
+// close closes all of the watchers and prevents new ones from being added
+func (ws *watchers) close() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.closed = true
+	for wt := range ws.list {
+		ws.remove(wt)
+	}
+}
+
+// canWatch returns true if userID, of tenant, may receive e. Users receive the
+// events of the jobs of their tenant that they own, including those that
+// failed to start, and of the jobs they have been granted access to.
+func (w *Worker) canWatch(tenant job.TenantID, userID job.UserID, e *event.Event) bool {
+	if e.Tenant != tenant.String() {
+		return false
+	}
+
+	if e.UserID == userID.String() {
+		return true
+	}
+
+	jobID, err := job.ParseID(e.JobID)
+	if err != nil {
+		return false
+	}
+
+	_, err = w.getJob(tenant, userID, jobID, job.AccessRead)
+	return err == nil
+}
+
+// WatchJobs streams the events of all of the jobs of tenant that userID has
+// access to. It begins with an event describing the current state of each
+// existing job, followed by events as jobs change state. Since an event may be
+// sent for the same change more than once, when watching begins, consumers
+// should keep the latest event, by Time, for each job. The channel is closed
+// when ctx is done, when the Worker is shut down, or if the consumer falls too
+// far behind, in which case it should watch again. If the Worker has already
+// been shut down, ErrWorkerClosed is returned.
+func (w *Worker) WatchJobs(ctx context.Context, tenant job.TenantID, userID job.UserID) (<-chan *event.Event, error) {
+	// the watcher is added before the current state is read so that no
+	// changes are missed
+	wt := w.watchers.add(tenant, userID)
+	if wt == nil {
+		return nil, ErrWorkerClosed
+	}
+
+	var current []*event.Event
+	for _, j := range w.jobs.all() {
+		if j.TenantID() == tenant && j.Access(userID) != job.AccessNone {
+			current = append(current, event.ForJob(j))
+		}
+	}
+
+	ch := make(chan *event.Event)
+	go func() {
+		defer close(ch)
+		defer w.watchers.unwatch(wt)
+
+		for _, e := range current {
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for {
+			select {
+			case e, ok := <-wt.ch:
+				if !ok {
+					return
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
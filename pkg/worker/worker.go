@@ -1,46 +1,279 @@
 package worker
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strconv"
-	"strings"
+	"slices"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/joshuarubin/teleport-job-worker/pkg/audit"
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
 	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
+	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer"
+	"github.com/joshuarubin/teleport-job-worker/pkg/webhook"
 )
 
 // ReexecCommand contains the necessary configuration for the JobWorker to be
-// able to call exec.Command() and expect that the current binary will be
-// reexecuted, the JobWorker will be reinstantiated, and
-// JobWorker.StartJobChild() will be called with the remaining arguments passed
-// in as command and args
+// able to call exec.Command() and expect that the current binary, or a
+// dedicated helper binary like cmd/job-worker-child, will be executed and that
+// StartChild will be called. The job's command and args are not passed in
+// argv, StartChild reads them from job.SpecFD.
 type Config struct {
-	ReexecCommand string   // often "/proc/self/exe"
-	ReexecArgs    []string // usually a command that puts this binary in a different "mode", e.g. "child" or "helper"
-	ReexecEnv     []string // additional env variables, in the form of "key=value", to be added to the current os.Environ()
+	ReexecCommand string   // often "/proc/self/exe", or the path of a helper binary
+	ReexecArgs    []string // usually a command that puts this binary in a different "mode", e.g. "child" or "helper", helper binaries don't need any
+	ReexecEnv     []string // additional env variables, in the form of "key=value", to be added to the current os.Environ() of the reexecuted binary, they are not passed on to jobs
 	CPUMax        float32  // the maximum cpu usage as a decimal, 0 < value <= 1, 0 indicates no max
 	MemoryMax     uint32   // the maximum memory usage in bytes, 0 indicates no max
 	RIOPSMax      uint32   // the maximum read io operations per second, 0 indicates no max
 	WIOPSMax      uint32   // the maximum write io operations per second, 0 indicates no max
+	Rlimits       []Rlimit // default resource limits applied to each job, may be lowered per job
+
+	// ReexecChecksum is the hex encoded SHA-256 of the ReexecCommand binary.
+	// If set, New returns ErrReexecChecksumMismatch unless the binary matches
+	// it, so that a helper binary that was replaced isn't run with the
+	// privileges needed to create namespaces.
+	ReexecChecksum string
+
+	// IODevices are the block devices that RIOPSMax and WIOPSMax apply to.
+	// Each may be given as MAJOR:MINOR, the path of a block device or any
+	// other path to use the device backing it. If empty, the device backing
+	// the working directory is used or, if that can't be detected, all
+	// non-loop block devices. Devices that reject io limits are skipped.
+	IODevices []string
+
+	// ResolvConfPath and HostsPath are optional files that are bind mounted,
+	// read only, over /etc/resolv.conf and /etc/hosts in each job's mount
+	// namespace so that name resolution in the job's network namespace is
+	// predictable and can be restricted. Jobs see the host's files if they
+	// are not set.
+	ResolvConfPath string
+	HostsPath      string
+
+	// Proc determines how /proc is mounted in each job's mount namespace, by
+	// default it is writable and all processes in the job are visible
+	Proc ProcPolicy
+
+	// AllowNewPrivileges keeps jobs from being started with no_new_privs set.
+	// By default it is set so that jobs can't gain privileges, e.g. by
+	// executing setuid binaries.
+	AllowNewPrivileges bool
+
+	// InheritSession starts jobs in the session of the Worker. By default
+	// each job is started in a new session, without a controlling terminal,
+	// so that terminal signals aren't delivered to jobs.
+	InheritSession bool
+
+	// Init starts each job under a minimal init, which runs as pid 1 of the
+	// job's pid namespace instead of the job's command. It reaps processes
+	// that are orphaned in the namespace, which would otherwise remain as
+	// zombies, counting against the job's limits, if the command doesn't
+	// reap them itself. It forwards signals to the command and exits with its
+	// exit code or, if the command is terminated by a signal, 128 plus the
+	// number of the signal.
+	Init bool
+
+	// IsolationProfiles are the isolation profiles that jobs may select with
+	// JobOptions.IsolationProfile. They override DefaultIsolationProfiles of
+	// the same name.
+	IsolationProfiles map[string]IsolationProfile
+
+	// IsolationProfile is the name of the profile used by jobs that don't
+	// select one, if empty, IsolationBasic is used
+	IsolationProfile string
+
+	// GPUs are the gpus, by name, that jobs may be granted access to with
+	// JobOptions.GPUs. The devices of the others are masked in each job's
+	// mount namespace.
+	GPUs map[string]GPU
+
+	// AllowedDevices are device nodes, e.g. /dev/fuse, that every job may
+	// access in addition to the defaults, like /dev/null and /dev/pts. Jobs
+	// may also access the devices of the gpus they are granted. Access to
+	// all other devices is denied with a device program attached to each
+	// job's cgroup.
+	AllowedDevices []string
+
+	// AllowAllDevices keeps the device program from being attached, so that
+	// jobs may access every device
+	AllowAllDevices bool
+
+	// SandboxProfile is the path of a sandbox-exec(1) profile that jobs are
+	// run with on darwin, which has no namespaces or cgroups, so that they
+	// can at least be kept from e.g. writing outside of certain directories.
+	// It is ignored on other platforms.
+	SandboxProfile string
+
+	// Priority is the default scheduling priority of jobs, it may be
+	// overridden per job. It must be permitted by PriorityPolicy.
+	Priority Priority
+
+	// PriorityPolicy limits how favorably jobs may be scheduled, by default
+	// they may only lower their priority
+	PriorityPolicy PriorityPolicy
+
+	// Environment sets the standard variables, like PATH and HOME, that jobs
+	// run with. Fields that are empty use DefaultEnvironment.
+	Environment Environment
+
+	// EnvAllowlist are the names of the variables from the Worker's
+	// environment that are passed on to jobs, no others are. If nil,
+	// DefaultEnvAllowlist is used.
+	EnvAllowlist []string
+
+	// SlowReaderPolicy determines how output readers that stop reading are
+	// handled, by default they are left alone
+	SlowReaderPolicy safebuffer.SlowReaderPolicy
+
+	// AuditLogSize is the number of audit events retained in memory, if 0,
+	// audit.DefaultMaxEvents is used
+	AuditLogSize int
+
+	// HistorySize is the number of removed jobs whose records are retained
+	// for ListJobs, if 0, DefaultHistorySize is used
+	HistorySize int
+
+	// MaxResultSize is the maximum size of the result a job may write to
+	// job.ResultFD, if 0, job.DefaultMaxResultSize is used
+	MaxResultSize int
+
+	// WALDir is the directory that the write-ahead logs of durable jobs are
+	// stored in. It is required to start durable jobs. Jobs with logs in the
+	// directory are recovered by New. Only one Worker may use the directory at
+	// a time, see NewStandby.
+	WALDir string
+
+	// WALDiskBudget is the maximum total size, in bytes, of the write-ahead
+	// logs in WALDir. When it is reached, the logs of completed jobs are
+	// removed, least recently written first. If that isn't enough, durable
+	// jobs fail with ErrDiskBudgetExceeded. If 0, there is no limit.
+	WALDiskBudget int64
+
+	// Webhook is optional and, if set, is notified when jobs complete, fail,
+	// are stopped or fail to start
+	Webhook *webhook.Config
+
+	// EventSinks are notified whenever a job starts, or fails to start, and
+	// when it completes, fails or is stopped
+	EventSinks []event.Sink
+
+	// LogShippers, if any, are sent the output of every job a line at a time,
+	// e.g. to forward it to syslog or journald. Output is still buffered and
+	// streamed to clients as usual.
+	LogShippers []logship.Shipper
+
+	// CGroupAlerts determines when event sinks are notified about jobs that
+	// are heavily throttled or hit their memory limit, by default they are
+	// not
+	CGroupAlerts CGroupAlertPolicy
+
+	// ShutdownPolicy determines what happens to running jobs when the Worker
+	// is shut down, by default they are stopped
+	ShutdownPolicy ShutdownPolicy
+
+	// MaxRunningJobs is the maximum number of jobs that may run at once. Once
+	// it is reached, jobs fail to start with ErrWorkerSaturated unless
+	// Preemption permits them to stop a lower priority job. If 0, there is no
+	// limit.
+	MaxRunningJobs int
+
+	// MaxRunningJobsPerTenant is the maximum number of jobs that each tenant
+	// may run at once. Once it is reached, jobs of that tenant fail to start
+	// with ErrTenantSaturated, they never preempt the jobs of other tenants.
+	// If 0, there is no limit.
+	MaxRunningJobsPerTenant int
+
+	// Capacity is the cpu and memory of the node that is available to jobs.
+	// Jobs are only admitted while the sum of their JobOptions.Requests fits
+	// within it, otherwise they fail to start with ErrWorkerSaturated unless
+	// Preemption permits them to stop lower priority jobs. Zero values are
+	// unlimited.
+	Capacity Resources
+
+	// Preemption determines whether jobs may preempt lower priority jobs when
+	// MaxRunningJobs or Capacity is reached, by default they may not
+	Preemption PreemptionPolicy
+
+	// Queues are the named queues that jobs may select with JobOptions.Queue,
+	// each with its own limits. Jobs that don't select one are in
+	// DefaultQueue, which may also be configured here.
+	Queues map[string]QueueConfig
+
+	// OnStateChange, if set, is called whenever the Worker is cordoned,
+	// drained or uncordoned, e.g. to update the health status of a server
+	// so that it is taken out of rotation while it isn't StateServing. It
+	// must not call the Worker.
+	OnStateChange func(State)
+
+	// UsageInterval is how often the cgroups of running jobs are sampled to
+	// account their resource usage to their users for UsageReport. If 0,
+	// usage is not accounted. If WALDir is set, usage is persisted there.
+	UsageInterval time.Duration
+
+	// Clock measures the timeouts, deadlines, restart backoffs and ttls of
+	// jobs, the start-by times of queued jobs, how long output readers have
+	// stalled, and the intervals of readiness probes, cgroup alerts and usage
+	// samples, by default it is clock.Real. Tests may set it to a
+	// workertest.Clock so that they don't have to wait for real time to pass.
+	// The times of events, audit events and shipped output, and the polling
+	// of a standby for the lock on WALDir, always use the real clock.
+	Clock clock.Clock
+
+	// IDRand, if set, is read for the random bits of job ids instead of
+	// crypto/rand, so that tests can generate the same ids each time. It
+	// needn't be safe for concurrent use.
+	IDRand io.Reader
 }
 
 // copy returns a deep copy of Config
 func (c *Config) copy() *Config {
 	ret := Config{
-		ReexecCommand: c.ReexecCommand,
-		CPUMax:        c.CPUMax,
-		MemoryMax:     c.MemoryMax,
-		RIOPSMax:      c.RIOPSMax,
-		WIOPSMax:      c.WIOPSMax,
+		ReexecCommand:           c.ReexecCommand,
+		ReexecChecksum:          c.ReexecChecksum,
+		CPUMax:                  c.CPUMax,
+		MemoryMax:               c.MemoryMax,
+		RIOPSMax:                c.RIOPSMax,
+		WIOPSMax:                c.WIOPSMax,
+		SlowReaderPolicy:        c.SlowReaderPolicy,
+		AuditLogSize:            c.AuditLogSize,
+		HistorySize:             c.HistorySize,
+		MaxResultSize:           c.MaxResultSize,
+		WALDir:                  c.WALDir,
+		WALDiskBudget:           c.WALDiskBudget,
+		CGroupAlerts:            c.CGroupAlerts,
+		ResolvConfPath:          c.ResolvConfPath,
+		HostsPath:               c.HostsPath,
+		Proc:                    c.Proc,
+		AllowNewPrivileges:      c.AllowNewPrivileges,
+		InheritSession:          c.InheritSession,
+		Init:                    c.Init,
+		SandboxProfile:          c.SandboxProfile,
+		IsolationProfile:        c.IsolationProfile,
+		AllowAllDevices:         c.AllowAllDevices,
+		ShutdownPolicy:          c.ShutdownPolicy,
+		UsageInterval:           c.UsageInterval,
+		MaxRunningJobs:          c.MaxRunningJobs,
+		MaxRunningJobsPerTenant: c.MaxRunningJobsPerTenant,
+		Capacity:                c.Capacity,
+		Preemption:              c.Preemption,
+		Queues:                  maps.Clone(c.Queues),
+		OnStateChange:           c.OnStateChange,
+		Environment:             c.Environment,
+		Priority:                c.Priority,
+		PriorityPolicy:          c.PriorityPolicy,
+		Clock:                   c.Clock,
+		IDRand:                  c.IDRand,
 	}
 
 	ret.ReexecArgs = make([]string, len(c.ReexecArgs))
@@ -49,6 +282,43 @@ func (c *Config) copy() *Config {
 	ret.ReexecEnv = make([]string, len(c.ReexecEnv))
 	copy(ret.ReexecEnv, c.ReexecEnv)
 
+	ret.Rlimits = make([]Rlimit, len(c.Rlimits))
+	copy(ret.Rlimits, c.Rlimits)
+
+	ret.IODevices = slices.Clone(c.IODevices)
+
+	ret.EnvAllowlist = slices.Clone(c.EnvAllowlist)
+
+	ret.AllowedDevices = slices.Clone(c.AllowedDevices)
+
+	if c.GPUs != nil {
+		ret.GPUs = make(map[string]GPU, len(c.GPUs))
+		for name, gpu := range c.GPUs {
+			gpu.Devices = slices.Clone(gpu.Devices)
+			gpu.Users = slices.Clone(gpu.Users)
+			ret.GPUs[name] = gpu
+		}
+	}
+
+	if c.IsolationProfiles != nil {
+		ret.IsolationProfiles = make(map[string]IsolationProfile, len(c.IsolationProfiles))
+		for name, p := range c.IsolationProfiles {
+			p.Capabilities = slices.Clone(p.Capabilities)
+			p.Users = slices.Clone(p.Users)
+			ret.IsolationProfiles[name] = p
+		}
+	}
+
+	ret.EventSinks = slices.Clone(c.EventSinks)
+
+	ret.LogShippers = slices.Clone(c.LogShippers)
+
+	if c.Webhook != nil {
+		wh := *c.Webhook
+		wh.Secret = slices.Clone(c.Webhook.Secret)
+		ret.Webhook = &wh
+	}
+
 	return &ret
 }
 
@@ -57,9 +327,32 @@ type Worker struct {
 	cfg            *Config
 	rootCGroupName string
 	blockDevices   []string
+	audit          *audit.Log
+	history        history     // the records of removed jobs
+	usage          usageLedger // the resources used by each user's jobs
+	disk           diskBudget  // the disk space used by write-ahead logs
+	sched          scheduler   // admits jobs while below Config.MaxRunningJobs
+	sinks          []event.Sink
+	watchers       watchers // the callers of WatchJobs, also one of the sinks
+	clock          clock.Clock
+
+	idMu sync.Mutex // guards reading cfg.IDRand
+
+	wg sync.WaitGroup // tracks the goroutines delivering events
+
+	// jobs are added by launch, which is counted in launching while mu is
+	// held so that Close, and Drain, can wait for it and not miss them.
+	// they are looked up without mu.
+	jobs jobMap
 
-	mu   sync.RWMutex
-	jobs map[job.ID]*job.Job
+	mu         sync.RWMutex
+	launching  int                        // the number of jobs being started by launch
+	launched   sync.Cond                  // signaled when launching is decremented, uses mu
+	restarting map[job.ID]*pendingRestart // the jobs waiting to be restarted
+	expiring   map[job.ID]clock.Timer     // the ttl timers of finished jobs
+	closed     bool
+	state      State
+	walLock    *os.File // holds the lock on WALDir, if it is set
 }
 
 var (
@@ -73,19 +366,96 @@ var (
 	// ErrJobNotFound is returned when trying to stop, get status or get output
 	// of a job that doesn't exist or that the user is not authorized for.
 	ErrJobNotFound = errors.New("job not found")
+
+	// ErrPermissionDenied is returned when a user has been granted access to a
+	// job, but not enough access for the requested operation.
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrJobRunning is returned when trying to remove a job that has not
+	// completed yet
+	ErrJobRunning = errors.New("job is still running")
+
+	// ErrJobNotRunning is returned when trying to update the deadline of a job
+	// that is no longer running
+	ErrJobNotRunning = errors.New("job is not running")
+
+	// ErrCGroupsNotSupported is returned when trying to update the limits of
+	// a job on a platform without cgroups
+	ErrCGroupsNotSupported = errors.New("cgroups are not supported on this platform")
+
+	// ErrWALDirRequired is returned when starting a durable job on a Worker
+	// without a WALDir
+	ErrWALDirRequired = errors.New("wal dir is required for durable jobs")
+
+	// ErrWALDirLocked is returned by New if another Worker is using the
+	// WALDir
+	ErrWALDirLocked = errors.New("wal dir is locked by another worker")
+
+	// ErrWorkerClosed is returned when trying to start a job after the Worker
+	// has been closed
+	ErrWorkerClosed = errors.New("worker is closed")
+
+	// ErrInvalidOffset is returned by JobOutputAt if the offset is negative
+	ErrInvalidOffset = errors.New("invalid output offset")
 )
 
 // New creates a new JobWorker
 func New(config *Config) (*Worker, error) {
-	if config.ReexecCommand == "" {
+	// the child's own logs must never end up in the job's output
+	_, isChild := os.LookupEnv(childEnv)
+	if isChild {
+		redirectChildLog()
+	}
+
+	// jobs aren't started by a child on windows, see setJobObject
+	if config.ReexecCommand == "" && runtime.GOOS != windowsOS {
 		return nil, ErrReexecCommandRequired
 	}
 
+	if !isChild && config.ReexecCommand != "" {
+		if err := verifyReexecCommand(config.ReexecCommand, config.ReexecChecksum); err != nil {
+			return nil, err
+		}
+	}
+
 	if config.CPUMax < 0 || config.CPUMax > 1 {
 		return nil, ErrInvalidCPUMax
 	}
 
-	blockDevices, err := getBlockDevices()
+	if err := validateRlimits(config.Rlimits); err != nil {
+		return nil, err
+	}
+
+	if err := validateBindMounts(config.bindMounts()); err != nil {
+		return nil, err
+	}
+
+	if err := validateSandboxProfile(config.SandboxProfile); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateIsolationProfiles(); err != nil {
+		return nil, err
+	}
+
+	if err := validateGPUs(config.GPUs); err != nil {
+		return nil, err
+	}
+
+	if err := validateAllowedDevices(config.AllowedDevices); err != nil {
+		return nil, err
+	}
+
+	// darwin is only supported so that the Worker can be developed there
+	if runtime.GOOS == darwinOS && !isChild {
+		slog.Warn("jobs are not isolated on darwin and cpu, memory and io limits are ignored")
+	}
+
+	if err := config.Priority.validate(&config.PriorityPolicy); err != nil {
+		return nil, err
+	}
+
+	blockDevices, err := resolveBlockDevices(config.IODevices)
 	if err != nil {
 		return nil, err
 	}
@@ -93,246 +463,927 @@ func New(config *Config) (*Worker, error) {
 	w := Worker{
 		// make a copy to ensure config is externally immutable
 		cfg:          config.copy(),
-		jobs:         map[job.ID]*job.Job{},
+		restarting:   map[job.ID]*pendingRestart{},
+		expiring:     map[job.ID]clock.Timer{},
 		blockDevices: blockDevices,
+		audit:        audit.New(config.AuditLogSize),
+		history:      history{maxRecords: config.HistorySize},
+		sched:        scheduler{running: map[job.ID]*scheduled{}, queues: map[string]*usage{}, tenants: map[job.TenantID]int{}},
+		sinks:        slices.Clone(config.EventSinks),
+		clock:        config.Clock,
 	}
 
-	if runtime.GOOS == linuxOS {
+	w.launched.L = &w.mu
+
+	if w.clock == nil {
+		w.clock = clock.Real
+	}
+
+	if config.Webhook != nil {
+		wh, err := webhook.New(config.Webhook)
+		if err != nil {
+			return nil, err
+		}
+		w.sinks = append(w.sinks, wh)
+	}
+
+	w.watchers.w = &w
+	w.disk.w = &w
+	w.sched.w = &w
+	w.sinks = append(w.sinks, &w.watchers)
+
+	// the reexecuted child uses the root cgroup created by its parent, which
+	// is passed to it in the child spec
+	if runtime.GOOS == linuxOS && !isChild {
 		if err = w.createRootCGroup(); err != nil {
 			return nil, err
 		}
 	}
 
+	if config.WALDir != "" && !isChild {
+		if w.walLock, err = lockWALDir(config.WALDir); err != nil {
+			return nil, err
+		}
+
+		if err = w.recoverJobs(); err != nil {
+			_ = w.walLock.Close()
+			return nil, err
+		}
+
+		if err = w.usage.open(filepath.Join(config.WALDir, usageFileName)); err != nil {
+			_ = w.walLock.Close()
+			return nil, err
+		}
+	}
+
 	return &w, nil
 }
 
-// getBlockDevices returns a list of MAJOR:MINOR block devices that can be used
-// for setting io limits in io.max for cgroups
-func getBlockDevices() ([]string, error) {
-	if runtime.GOOS != linuxOS {
-		return nil, nil
+// JobOptions contains optional, per-job settings for StartJobWithOptions
+type JobOptions struct {
+	// Rlimits override Config.Rlimits for the same resource. They may lower,
+	// but never raise, the hard limits configured on the Worker.
+	Rlimits []Rlimit
+
+	// Timeout is the maximum amount of time the job may run for before it is
+	// stopped with job.StopReasonTimeout, 0 means no timeout
+	Timeout time.Duration
+
+	// IdleTimeout is the maximum amount of time the job may run without
+	// producing any output before it is stopped with
+	// job.StopReasonIdleTimeout, 0 means no timeout
+	IdleTimeout time.Duration
+
+	// Deadline is the time at which the job will be stopped with
+	// job.StopReasonTimeout. If Timeout is also set, whichever comes first is
+	// used. The zero time means no deadline.
+	Deadline time.Time
+
+	// Durable jobs have their output written to a write-ahead log, and
+	// fsync'd, before it is made available to readers so that it can be
+	// recovered if the worker crashes. It requires Config.WALDir.
+	Durable bool
+
+	// Priority overrides Config.Priority, if set. It must be permitted by
+	// Config.PriorityPolicy.
+	Priority *Priority
+
+	// Environment overrides Config.Environment for the fields that are set
+	Environment Environment
+
+	// Env are additional variables, in the form "key=value", that the job
+	// runs with. They take precedence over Environment and those passed on
+	// from the Worker's environment by Config.EnvAllowlist.
+	Env []string
+
+	// Description and Annotations are free-form, so that people can later
+	// tell what the job was for. They are returned with the job's status and
+	// are limited in size, see MaxDescriptionLength and MaxAnnotations.
+	Description string
+	Annotations map[string]string
+
+	// CorrelationID ties the job to an external system, e.g. a trace id or
+	// ticket number. It is returned with the job's status, can be searched
+	// for with ListJobs and is recorded in the audit log.
+	CorrelationID string
+
+	// SchedulingPriority ranks the job against others when
+	// Config.MaxRunningJobs is reached. If Config.Preemption is enabled, the
+	// running job with the lowest SchedulingPriority, if it is lower than this
+	// one, is preempted to make room. It is unrelated to Priority, which
+	// controls how the operating system schedules the job once it is running.
+	SchedulingPriority int
+
+	// Requests are the cpu and memory the job is expected to use. They are
+	// only used to decide whether the job fits within Config.Capacity
+	// alongside the other running jobs, unlike limits they are not enforced.
+	Requests Resources
+
+	// StartBy is the latest time the job may start at. If it is set and the
+	// Worker, or its tenant, is saturated, the job is queued until there is
+	// room for it, rather than rejected with ErrWorkerSaturated or
+	// ErrTenantSaturated. If there is no room by StartBy, the job fails with
+	// job.ErrStartDeadlineExceeded. Queued jobs are started in order of
+	// SchedulingPriority, then in the order they were queued. The zero time
+	// means the job is never queued.
+	StartBy time.Time
+
+	// Queue is the name of the queue, configured in Config.Queues, that the
+	// job runs in. The job is only admitted while it fits within the limits
+	// of both the Worker and its queue. If empty, DefaultQueue is used.
+	Queue string
+
+	// Init overrides Config.Init, if set, so that only the jobs whose
+	// commands don't handle signals, or reap their children, as pid 1 need
+	// to run under the minimal init
+	Init *bool
+
+	// IsolationProfile is the name of the profile, configured in
+	// Config.IsolationProfiles, that the job is isolated with. The user must
+	// be listed in the profile's Users. If empty, Config.IsolationProfile is
+	// used.
+	IsolationProfile string
+
+	// GPUs are the names of the gpus, configured in Config.GPUs, that the job
+	// is granted access to. The user must be listed in each gpu's Users.
+	GPUs []string
+
+	// Readiness, if set, decides when the job is ready. It is reported as
+	// StatusResponse.ReadyTime and by a job.ready event.
+	Readiness *ReadinessProbe
+
+	// Restart decides whether the job is restarted when it exits. Jobs are
+	// never restarted by default.
+	Restart RestartPolicy
+
+	// TTLAfterFinished, if set, is how long the job is kept once it is done,
+	// and won't be restarted, before it, its output and its cgroup are
+	// removed, as if by RemoveJob. Unlike RemoveJob, its record is not kept
+	// in the history.
+	TTLAfterFinished time.Duration
+}
+
+// StartJob executes command, with optional args, in a new pid, mount and
+// network namespace, unless Config.IsolationProfile says otherwise. It also
+// creates a new cgroup and applies cpu.max, memory.max and io.max limits. The
+// tenant and userID are opaque values, of the authenticated user, that are
+// used for authorization of later requests. Only matching userIDs, of the same
+// tenant, will be able to Stop or get the Status or Output of a job. Returns the opaque job.ID that is required for subsequent operations
+// with the job. The command is resolved using the PATH the job will run with,
+// if it can't be found ErrCommandNotFound is returned.
+func (w *Worker) StartJob(tenant job.TenantID, userID job.UserID, command string, args ...string) (job.ID, error) {
+	return w.StartJobWithOptions(tenant, userID, nil, command, args...)
+}
+
+// StartJobWithOptions is like StartJob but also applies the per-job settings
+// in opts, which may be nil.
+func (w *Worker) StartJobWithOptions(tenant job.TenantID, userID job.UserID, opts *JobOptions, command string, args ...string) (jobID job.ID, err error) {
+	defer func() { w.record(audit.ActionStartJob, tenant, userID, jobID, err) }()
+
+	if opts == nil {
+		opts = &JobOptions{}
+	}
+
+	rlimits, err := mergeRlimits(w.cfg.Rlimits, opts.Rlimits)
+	if err != nil {
+		return job.ID{}, err
 	}
 
-	var names []string //nolint:prealloc
+	if err = validateEnv(opts.Env); err != nil {
+		return job.ID{}, err
+	}
 
-	// first list all available block devices
-	dir, err := os.ReadDir("/sys/block")
+	if err = validateAnnotations(opts.Description, opts.Annotations); err != nil {
+		return job.ID{}, err
+	}
+
+	if err = validateCorrelationID(opts.CorrelationID); err != nil {
+		return job.ID{}, err
+	}
+
+	if opts.Readiness != nil {
+		if err = opts.Readiness.validate(); err != nil {
+			return job.ID{}, err
+		}
+	}
+
+	if err = opts.Restart.validate(opts.Durable); err != nil {
+		return job.ID{}, err
+	}
+
+	priority := w.cfg.Priority
+	if opts.Priority != nil {
+		if err = opts.Priority.validate(&w.cfg.PriorityPolicy); err != nil {
+			return job.ID{}, err
+		}
+		priority = *opts.Priority
+	}
+
+	if err = w.accepting(nil); err != nil {
+		return job.ID{}, err
+	}
+
+	if !opts.StartBy.IsZero() && !w.clock.Now().Before(opts.StartBy) {
+		return job.ID{}, job.ErrStartDeadlineExceeded
+	}
+
+	var req *startRequest
+	if w.cfg.Preemption.Requeue {
+		req = newStartRequest(tenant, userID, opts, command, args)
+	}
+
+	queue, err := w.cfg.queueName(opts)
 	if err != nil {
-		return nil, err
+		return job.ID{}, err
 	}
 
-	// filter out loop devices
-	for _, f := range dir {
-		if strings.HasPrefix(f.Name(), "loop") {
-			continue
+	j, cg, err := w.newJob(tenant, userID, opts, queue, priority, rlimits, command, args)
+	if err != nil {
+		return job.ID{}, err
+	}
+
+	slot := &scheduled{
+		tenant:   tenant,
+		queue:    queue,
+		priority: opts.SchedulingPriority,
+		requests: opts.Requests,
+		req:      req,
+		restart:  opts.Restart,
+	}
+
+	err = w.sched.admit(slot)
+	if saturated(err) && !opts.StartBy.IsZero() {
+		var reserved bool
+		if reserved, err = w.sched.enqueue(slot, j, cg, opts.StartBy); err == nil && !reserved {
+			return j.ID(), nil
 		}
-		names = append(names, f.Name())
 	}
+	if err != nil {
+		w.removeJobWAL(j)
+		return job.ID{}, err
+	}
+
+	switch err = w.sched.start(slot, j, cg); {
+	case errors.Is(err, ErrWorkerClosed), errors.Is(err, ErrWorkerCordoned):
+		w.removeJobWAL(j)
+		return job.ID{}, err
+	case err != nil:
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.notify(event.ForJob(j))
+		}()
+		return job.ID{}, err
+	}
+
+	return j.ID(), nil
+}
 
-	// /proc/partitions lists the major and minor device numbers of all
-	// partitions including root devices
-	f, err := os.Open("/proc/partitions")
+// newJob creates, but does not start, the job described by the arguments of
+// StartJobWithOptions, in queue. It returns the job and the path of the cgroup
+// that it will run in.
+func (w *Worker) newJob(tenant job.TenantID, userID job.UserID, opts *JobOptions, queue string, priority Priority, rlimits []Rlimit, command string, args []string) (*job.Job, string, error) {
+	env := w.jobEnv(opts)
+
+	// the command is resolved with the job's PATH, not the worker's, and
+	// before the child is started so that a missing command is reported as
+	// such rather than as a failure of the child
+	command, err := lookPath(command, env)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	defer f.Close()
-
-	ret := make([]string, 0, len(names))
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		// the lines we're looking for look like:
-		//  253        0  104857600 vda
-		for _, name := range names {
-			// so we look for those that end with one of the device names we
-			// pulled out earlier
-			if strings.HasSuffix(line, name) {
-				// then we extract the first 2 fields as MAJOR:MINOR
-				if fields := strings.Fields(line); len(fields) >= 2 { //nolint:mnd
-					ret = append(ret, fmt.Sprintf("%s:%s", fields[0], fields[1]))
-				}
-				break
-			}
+
+	isolation, err := w.cfg.isolationProfile(userID, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	maskedDevices, err := w.cfg.gpuDevices(userID, opts.GPUs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var j *job.Job
+	var cg string
+	if runtime.GOOS == windowsOS {
+		// there is no child on windows, the command is run directly in a job
+		// object, see setJobObject
+		if j, err = w.createJob(userID, command, args, nil); err != nil {
+			return nil, "", err
 		}
+		j.SetEnv(env)
+	} else if j, cg, err = w.newChildJob(userID, opts, isolation, maskedDevices, priority, rlimits, env, command, args); err != nil {
+		return nil, "", err
 	}
 
-	return ret, nil
+	j.SetTenantID(tenant)
+	j.SetDescription(opts.Description, opts.Annotations)
+	j.SetCorrelationID(opts.CorrelationID)
+	j.SetQueue(queue)
+	j.SetGPUs(opts.GPUs)
+	if opts.Readiness != nil {
+		j.SetReadinessCheck(opts.Readiness.check(w.clock))
+	}
+	j.SetSlowReaderPolicy(w.cfg.SlowReaderPolicy)
+	j.SetMaxResultSize(w.cfg.MaxResultSize)
+	j.SetTimeouts(opts.Timeout, opts.IdleTimeout)
+	j.SetDeadline(opts.Deadline)
+	j.SetTTLAfterFinished(opts.TTLAfterFinished)
+
+	if opts.Durable {
+		if err = w.createWAL(j); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return j, cg, nil
 }
 
-// StartJob executes command, with optional args, in a new pid, mount and
-// network namespace. It also creates a new cgroup and applies cpu.max,
-// memory.max and io.max limits. The userID is an opaque value that is used for
-// authorization of later requests. Only matching userIDs will be able to Stop
-// or get the Status or Output of a job. Returns the opaque job.ID that is
-// required for subsequent operations with the job.
-func (w *Worker) StartJob(userID job.UserID, command string, args ...string) (job.ID, error) {
-	cmdArgs := append(w.cfg.ReexecArgs, command) //nolint:gocritic
-	cmdArgs = append(cmdArgs, args...)
-
-	j, err := job.New(
+// createJob is job.New, but the job's id is generated from Config.IDRand, if it
+// is set, and the job uses the Worker's clock
+func (w *Worker) createJob(userID job.UserID, command string, args, env []string) (*job.Job, error) {
+	var id job.ID
+	var err error
+	if w.cfg.IDRand == nil {
+		id, err = job.NewID()
+	} else {
+		w.idMu.Lock()
+		id, err = job.NewIDFrom(w.clock.Now(), w.cfg.IDRand)
+		w.idMu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := job.NewWithID(id, userID, command, args, env)
+	if err != nil {
+		return nil, err
+	}
+
+	j.SetClock(w.clock)
+	return j, nil
+}
+
+// newChildJob creates, but does not start, a job that runs the ReexecCommand
+// child, which sets up the job's environment and then executes command. It
+// returns the job and the path of the cgroup that it will run in.
+func (w *Worker) newChildJob(userID job.UserID, opts *JobOptions, isolation *IsolationProfile, maskedDevices []string, priority Priority, rlimits []Rlimit, env []string, command string, args []string) (*job.Job, string, error) {
+	spec := childSpec{
+		Args:       append([]string{command}, args...),
+		Env:        env,
+		Priority:   priority,
+		Rlimits:    rlimits,
+		BindMounts: w.cfg.bindMounts(),
+		Proc:       w.cfg.Proc.merge(isolation.Proc),
+		Namespaces: isolation.Namespaces,
+		Seccomp:    isolation.Seccomp,
+		Setsid:     !w.cfg.InheritSession,
+		NoNewPrivs: !w.cfg.AllowNewPrivileges,
+		Init:       w.cfg.Init,
+		Sandbox:    w.cfg.SandboxProfile,
+		Setup:      runtime.GOOS == linuxOS,
+
+		MaskedDevices: maskedDevices,
+	}
+
+	if opts.Init != nil {
+		spec.Init = *opts.Init
+	}
+
+	if isolation.Capabilities != nil {
+		spec.DropCapabilities = true
+		spec.Capabilities = capabilityNumbers(isolation.Capabilities)
+	}
+
+	var cg string
+	if runtime.GOOS == linuxOS {
+		var err error
+		if cg, err = w.newJobCGroupPath(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	j, err := w.createJob(
 		userID,
 		w.cfg.ReexecCommand,
-		cmdArgs,
-		w.cfg.ReexecEnv,
+		slices.Clone(w.cfg.ReexecArgs),
+		append(slices.Clone(w.cfg.ReexecEnv), childEnv+"=1"),
 	)
 	if err != nil {
-		return job.ID{}, err
+		return nil, "", err
 	}
 
-	if err = j.Start(); err != nil {
-		return job.ID{}, err
+	// the jail is named after the job, so it is only known once the job has
+	// been created
+	spec.Jail = jailName(j)
+
+	data, err := spec.marshal()
+	if err != nil {
+		return nil, "", err
 	}
 
+	j.SetSpec(data)
+	j.SetNamespaces(isolation.Namespaces)
+
+	if spec.Setup {
+		j.EnableSetup(childLog{jobID: j.ID()})
+	}
+
+	return j, cg, nil
+}
+
+// accepting returns ErrWorkerClosed or ErrWorkerCordoned if the Worker isn't
+// accepting jobs. queued is not nil for jobs that were queued, they are still
+// accepted while the Worker is cordoned, but not once it is draining.
+func (w *Worker) accepting(queued <-chan struct{}) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.acceptingLocked(queued)
+}
+
+// acceptingLocked is accepting with w.mu held
+func (w *Worker) acceptingLocked(queued <-chan struct{}) error {
+	switch {
+	case w.closed:
+		return ErrWorkerClosed
+	case w.state == StateServing,
+		w.state == StateCordoned && queued != nil:
+		return nil
+	default:
+		return ErrWorkerCordoned
+	}
+}
+
+// launch starts j in the cgroup at path cg. started is called once j has
+// started, before it is added to the Worker and its events are delivered. if
+// queued is not nil, the started event is not delivered until it is closed. it
+// must only be called by the scheduler, without its lock held.
+func (w *Worker) launch(j *job.Job, cg string, queued <-chan struct{}, started func()) error {
+	// only the check, and counting the launch, is done with the lock held so
+	// that jobs are started concurrently, but Close, and Drain, can't miss
+	// them
+	w.mu.Lock()
+	if err := w.acceptingLocked(queued); err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	w.launching++
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		w.launching--
+		w.launched.Broadcast()
+		w.mu.Unlock()
+	}()
+
+	if err := w.spawn(j, cg); err != nil {
+		return err
+	}
+
+	started()
+
+	w.jobs.store(j, cg)
+
+	w.wg.Add(1)
+	go w.watch(j, queued, event.ForJob(j), cg)
+
+	return nil
+}
+
+// waitForLaunches waits for the jobs that are being started by launch to be
+// added. it must only be called once the Worker no longer accepts jobs.
+func (w *Worker) waitForLaunches() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.jobs[j.ID()] = j
 
-	return j.ID(), nil
+	for w.launching > 0 {
+		w.launched.Wait()
+	}
+}
+
+// spawn creates the cgroup at path cg, and any other limits, for j and starts
+// it in them
+func (w *Worker) spawn(j *job.Job, cg string) error {
+	if cg != "" {
+		// the job is born inside its cgroup so that it is never unlimited and
+		// its stats can be read even if the child fails to start the command
+		dir, err := createJobCGroup(cg, w.cfg.limits(), w.blockDevices)
+		if err != nil {
+			return fmt.Errorf("error creating cgroup: %w", err)
+		}
+		defer func() { _ = dir.Close() }()
+		j.SetCGroup(cg)
+
+		// cgroups that aren't on a cgroup v2 filesystem, e.g. when it is
+		// faked, can't be used with clone3 or have programs attached
+		if isCGroup2(dir) {
+			if err = w.setDevicePolicy(j, dir); err != nil {
+				_ = os.Remove(cg)
+				return err
+			}
+			j.SetCGroupFD(int(dir.Fd()))
+		}
+	}
+
+	// on windows, the job is limited by a job object instead
+	if err := setJobObject(j, w.cfg.limits()); err != nil {
+		return fmt.Errorf("error creating job object: %w", err)
+	}
+
+	// and on freebsd by rctl rules for its jail, which the child creates
+	jail := jailName(j)
+	if jail != "" {
+		if err := addJailRules(jail, w.cfg.limits()); err != nil {
+			_ = removeJailRules(jail)
+			return fmt.Errorf("error adding rctl rules: %w", err)
+		}
+	}
+
+	if err := j.Start(); err != nil {
+		if cg != "" {
+			_ = os.Remove(cg)
+		}
+		if jail != "" {
+			_ = removeJailRules(jail)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// setDevicePolicy restricts the devices that j may access, unless
+// Config.AllowAllDevices is set, by attaching a device program to its cgroup,
+// which is open as dir
+func (w *Worker) setDevicePolicy(j *job.Job, dir *os.File) error {
+	if w.cfg.AllowAllDevices {
+		return nil
+	}
+
+	rules, err := w.cfg.deviceRules(j.GPUs())
+	if err != nil {
+		return err
+	}
+
+	if err = setDevicePolicy(dir, rules); err != nil {
+		return fmt.Errorf("error setting device policy: %w", err)
+	}
+
+	return nil
+}
+
+// watch delivers the started event for j, once the queued event, if any, has
+// been delivered, ships its output, meters the usage of its cgroup, cg,
+// monitors it for alerts, waits for j to complete, frees its room in the
+// scheduler and then delivers the event describing its final state. this
+// ensures that sinks receive the events for a job in order.
+func (w *Worker) watch(j *job.Job, queued <-chan struct{}, started *event.Event, cg string) {
+	defer w.wg.Done()
+	var users sync.WaitGroup // the goroutines reading the output or cgroup of j
+	if len(w.cfg.LogShippers) > 0 {
+		w.wg.Add(1)
+		users.Add(1)
+		go func() {
+			defer users.Done()
+			w.shipOutput(j)
+		}()
+	}
+	if cg != "" && w.cfg.UsageInterval > 0 {
+		w.wg.Add(1)
+		users.Add(1)
+		go func() {
+			defer users.Done()
+			w.meterUsage(j, cg)
+		}()
+	}
+	if queued != nil {
+		<-queued
+	}
+	w.notify(started)
+	var ready chan struct{}
+	if j.Ready() != nil {
+		// the ready event must be delivered before the final one
+		ready = make(chan struct{})
+		go func() {
+			defer close(ready)
+			w.notifyReady(j)
+		}()
+	}
+	if cg != "" && w.cfg.CGroupAlerts.Interval > 0 {
+		w.monitorCGroup(j, cg)
+	}
+	<-j.Done()
+	if ready != nil {
+		<-ready
+	}
+	if jail := jailName(j); jail != "" {
+		if err := removeJailRules(jail); err != nil {
+			slog.Error("error removing rctl rules", "job_id", j.ID(), "err", err)
+		}
+	}
+	if slot := w.sched.done(j); w.restart(j, slot, cg, &users) {
+		return
+	}
+	w.finished(j)
+}
+
+// notify delivers e to all event sinks concurrently and waits for all of the
+// deliveries to complete
+func (w *Worker) notify(e *event.Event) {
+	var wg sync.WaitGroup
+	for _, sink := range w.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sink.Notify(context.Background(), e); err != nil {
+				slog.Error("error delivering event", "job_id", e.JobID, "type", e.Type, "err", err)
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 // cgroupFilePerm is the file permission that is used when creating the files
 // inside the cgroup
 const cgroupFilePerm = 0o400
 
-// createRootCGroup creates the root cgroup. this is only done once. sets the
-// proper values on cgroup.subtree_control so that cpu, memory and io can be
-// managed on leaf cgroups.
+// createRootCGroup creates the root cgroup. this is only done once.
 func (w *Worker) createRootCGroup() error {
-	// Requires cgroup v2.
-	const prefix = "/sys/fs/cgroup"
-
-	cg, err := os.MkdirTemp(prefix, "job-worker-")
+	cg, err := newRootCGroup(cgroupMountPoint, procSelfCGroup)
 	if err != nil {
-		return fmt.Errorf("error creating root cgroup: %w", err)
+		return err
 	}
 
 	w.rootCGroupName = cg
+	return nil
+}
+
+// newRootCGroup creates a root cgroup in the hierarchy mounted at mountPoint,
+// and returns its path. it is created beneath the cgroup listed in
+// procCGroup, which is the one the server is running in and may not be the
+// root of the hierarchy (e.g. when running in a container). sets the proper
+// values on cgroup.subtree_control so that cpu, memory and io can be managed
+// on leaf cgroups.
+func newRootCGroup(mountPoint, procCGroup string) (string, error) {
+	// Requires cgroup v2.
+	parent, err := currentCGroup(mountPoint, procCGroup)
+	if err != nil {
+		return "", fmt.Errorf("error detecting current cgroup: %w", err)
+	}
 
-	err = os.WriteFile(filepath.Join(cg, "cgroup.subtree_control"), []byte("+cpu +memory +io"), cgroupFilePerm)
+	// the controllers must be delegated even at the root of the hierarchy,
+	// which is where the server is in a container with its own cgroup
+	// namespace
+	if err = enableSubtreeControllers(parent); err != nil {
+		return "", fmt.Errorf("error enabling controllers in cgroup %q: %w", parent, err)
+	}
+
+	cg, err := os.MkdirTemp(parent, "job-worker-")
 	if err != nil {
-		return fmt.Errorf("error writing cgroup.subtree_control: %w", err)
+		return "", fmt.Errorf("error creating root cgroup: %w", err)
 	}
 
-	return nil
+	err = os.WriteFile(filepath.Join(cg, "cgroup.subtree_control"), []byte(subtreeControllers), cgroupFilePerm)
+	if err != nil {
+		return "", fmt.Errorf("error writing cgroup.subtree_control: %w", err)
+	}
+
+	return cg, nil
+}
+
+const (
+	linuxOS   = "linux"   // the value expected by runtime.GOOS on linux
+	windowsOS = "windows" // the value expected by runtime.GOOS on windows
+	darwinOS  = "darwin"  // the value expected by runtime.GOOS on darwin
+)
+
+// StartJobChild calls StartChild, for binaries that create a Worker when they
+// are reexecuted. It should be the only method called by the binary in that
+// circumstance and should never be called in any other situation.
+func (w *Worker) StartJobChild() error {
+	return StartChild()
+}
+
+// getJob returns the job identified by jobID if userID, of tenant, has at
+// least access to it. Users without any access, and users of other tenants,
+// get ErrJobNotFound so that the existence of the job isn't revealed to them.
+func (w *Worker) getJob(tenant job.TenantID, userID job.UserID, jobID job.ID, access job.Access) (*job.Job, error) {
+	e, ok := w.jobs.get(jobID)
+	if !ok || e.job.TenantID() != tenant {
+		return nil, ErrJobNotFound
+	}
+	j := e.job
+
+	switch has := j.Access(userID); {
+	case has == job.AccessNone:
+		return nil, ErrJobNotFound
+	case has < access:
+		return nil, ErrPermissionDenied
+	}
+
+	return j, nil
 }
 
-// createCGroup creates a cgroup and sets the values for cpu.max, memory.max
-// and io.max. it also adds the current process's pid to cgroup.procs.
-func (w *Worker) createCGroup() error {
-	subCGroup, err := os.MkdirTemp(w.rootCGroupName, "job-")
+// StopJob kills the job identified by jobID. Queued jobs are removed from the
+// queue and never started. If the job does not exist, or if the user is not
+// authorized, ErrJobNotFound will be returned. Users that have only been
+// granted read access get ErrPermissionDenied.
+func (w *Worker) StopJob(tenant job.TenantID, userID job.UserID, jobID job.ID) (err error) {
+	defer func() { w.record(audit.ActionStopJob, tenant, userID, jobID, err) }()
+
+	j, err := w.getJob(tenant, userID, jobID, job.AccessFull)
 	if err != nil {
 		return err
 	}
 
-	type cgroupValue struct {
-		file  string
-		value string
+	if w.sched.cancel(jobID, job.StopReasonRequested) || w.cancelRestart(jobID, job.StopReasonRequested) {
+		return nil
 	}
 
-	cgroupData := []cgroupValue{{
-		file: "cgroup.procs", value: strconv.Itoa(os.Getpid()),
-	}}
+	return j.Stop()
+}
 
-	if v := w.cfg.CPUMax; v != 0 {
-		const maxCPU = 100000
-		d := int64(v * float32(maxCPU))
-		cgroupData = append(cgroupData, cgroupValue{
-			file: "cpu.max", value: fmt.Sprintf("%d %d", d, maxCPU),
-		})
+// StopJobs stops each of jobIDs, as StopJob does, in a single call. It returns
+// the error, if any, for each job that could not be stopped. Jobs that were
+// stopped are not included, so an empty map means that all of them were.
+func (w *Worker) StopJobs(tenant job.TenantID, userID job.UserID, jobIDs ...job.ID) map[job.ID]error {
+	errs := map[job.ID]error{}
+	for _, jobID := range jobIDs {
+		if err := w.StopJob(tenant, userID, jobID); err != nil {
+			errs[jobID] = err
+		}
 	}
+	return errs
+}
 
-	if v := w.cfg.MemoryMax; v != 0 {
-		cgroupData = append(cgroupData, cgroupValue{
-			file: "memory.max", value: strconv.Itoa(int(v)),
-		})
-	}
+// StopAllJobs stops all of the running, and queued, jobs owned by userID, of
+// tenant. Jobs that userID has only been granted access to are not stopped. It
+// returns the ids of the jobs that were stopped and, like StopJobs, the errors
+// of those that could not be.
+func (w *Worker) StopAllJobs(tenant job.TenantID, userID job.UserID) ([]job.ID, map[job.ID]error) {
+	var jobIDs []job.ID
 
-	if w.cfg.RIOPSMax > 0 || w.cfg.WIOPSMax > 0 {
-		for _, v := range w.blockDevices {
-			s := v
-			if w.cfg.RIOPSMax > 0 {
-				s += fmt.Sprintf(" riops=%d", w.cfg.RIOPSMax)
-			}
-			if w.cfg.WIOPSMax > 0 {
-				s += fmt.Sprintf(" wiops=%d", w.cfg.WIOPSMax)
-			}
-			cgroupData = append(cgroupData, cgroupValue{
-				file: "io.max", value: s,
-			})
+	for _, j := range w.jobs.all() {
+		if j.TenantID() == tenant && j.UserID() == userID && (j.Status() == job.StatusRunning || j.Status() == job.StatusNotStarted) {
+			jobIDs = append(jobIDs, j.ID())
 		}
 	}
 
-	for _, v := range cgroupData {
-		file := filepath.Join(subCGroup, v.file)
-		if err = os.WriteFile(file, []byte(v.value), cgroupFilePerm); err != nil {
-			return fmt.Errorf("error writing to cgroup file %q (value: %q): %w", file, v.value, err)
+	errs := w.StopJobs(tenant, userID, jobIDs...)
+
+	stopped := jobIDs[:0]
+	for _, id := range jobIDs {
+		if _, ok := errs[id]; !ok {
+			stopped = append(stopped, id)
 		}
 	}
 
-	return nil
+	return stopped, errs
 }
 
-// linuxOS is the value expected by runtime.GOOS on linux
-const linuxOS = "linux"
+// UpdateJobDeadline replaces the time at which a running job will be stopped,
+// extending or shortening its allowed lifetime. The zero time removes the
+// deadline. If the job does not exist, or if the user is not authorized,
+// ErrJobNotFound will be returned. Users that have only been granted read
+// access get ErrPermissionDenied. If the job has already completed,
+// ErrJobNotRunning is returned.
+func (w *Worker) UpdateJobDeadline(tenant job.TenantID, userID job.UserID, jobID job.ID, deadline time.Time) (err error) {
+	defer func() { w.record(audit.ActionUpdateJobDeadline, tenant, userID, jobID, err) }()
 
-// StartJobChild is called when this binary is reexecuted with the new
-// namespaces applied. It should be the only method called by the binary in that
-// circumstance and should never be called in any other situation. It will
-// create a new cgroup with cpu, memory and io limits applied, then it will
-// remount /proc and finally it will execute the command, with optional args.
-func (w *Worker) StartJobChild(command string, args ...string) error {
-	cmd, err := exec.LookPath(command)
+	j, err := w.getJob(tenant, userID, jobID, job.AccessFull)
 	if err != nil {
-		err = fmt.Errorf("lookpath error: %w", err)
-		slog.Error("error starting child process", "err", err)
 		return err
 	}
 
-	if runtime.GOOS == linuxOS {
-		if err = w.createCGroup(); err != nil {
-			err = fmt.Errorf("error creating cgroup: %w", err)
-			slog.Error("error starting child process", "err", err)
-			return err
-		}
+	select {
+	case <-j.Done():
+		return ErrJobNotRunning
+	default:
+	}
 
-		if err = mountProc(); err != nil {
-			err = fmt.Errorf("error mounting /proc: %w", err)
-			slog.Error("error starting child process", "err", err)
-			return err
-		}
+	j.UpdateDeadline(deadline)
+	return nil
+}
+
+// UpdateJobLimits rewrites the cgroup limits, or on freebsd the rctl rules, of
+// a running job, throttling or boosting it without restarting it. Zero values
+// in limits leave the current limit unchanged. Limits may not exceed those
+// configured on the Worker, in which case ErrLimitExceedsCeiling is returned.
+// If the job does not exist, or if the user is not authorized, ErrJobNotFound
+// will be returned. Users that have only been granted read access get
+// ErrPermissionDenied. If the job has already completed, ErrJobNotRunning is
+// returned.
+func (w *Worker) UpdateJobLimits(tenant job.TenantID, userID job.UserID, jobID job.ID, limits *Limits) (err error) {
+	defer func() { w.record(audit.ActionUpdateJobLimits, tenant, userID, jobID, err) }()
+
+	j, err := w.getJob(tenant, userID, jobID, job.AccessFull)
+	if err != nil {
+		return err
 	}
 
-	args = append([]string{cmd}, args...)
-	if err = syscall.Exec(cmd, args, os.Environ()); err != nil {
-		err = fmt.Errorf("syscall.Exec error: %w", err)
-		slog.Error("error starting child process", "err", err)
+	select {
+	case <-j.Done():
+		return ErrJobNotRunning
+	default:
+	}
+
+	if err = limits.validate(w.cfg.limits()); err != nil {
 		return err
 	}
 
-	return nil
+	cg := w.jobCGroup(j)
+
+	if jail := jailName(j); jail != "" {
+		return addJailRules(jail, limits)
+	}
+
+	if cg == "" {
+		return ErrCGroupsNotSupported
+	}
+
+	return writeCGroupValues(cg, limits.cgroupValues(w.blockDevices))
 }
 
-func (w *Worker) getJob(userID job.UserID, jobID job.ID) (*job.Job, error) {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
+// RemoveJob removes a job that is no longer running. Any open output readers
+// are closed, and its output and cgroup are freed. Its record is kept in the
+// history listed by ListJobs. If the job does not exist, or if the user is not
+// authorized, ErrJobNotFound will be returned. If the job is still running,
+// ErrJobRunning is returned.
+func (w *Worker) RemoveJob(tenant job.TenantID, userID job.UserID, jobID job.ID) (err error) {
+	defer func() { w.record(audit.ActionRemoveJob, tenant, userID, jobID, err) }()
 
-	j, ok := w.jobs[jobID]
-	if !ok || j.UserID() != userID {
-		return nil, ErrJobNotFound
+	j, err := w.getJob(tenant, userID, jobID, job.AccessFull)
+	if err != nil {
+		return err
 	}
 
-	return j, nil
+	select {
+	case <-j.Done():
+	default:
+		return ErrJobRunning
+	}
+
+	// the record is taken before the cgroup is forgotten, so that the reason
+	// the job stopped can still be determined
+	record := w.jobRecord(j)
+	record.Historical = true
+
+	e, ok := w.jobs.removeJob(j)
+	if !ok {
+		// it was removed, or expired, concurrently
+		return ErrJobNotFound
+	}
+
+	// it can only be removed once every process in it has exited
+	if e.cg != "" {
+		_ = os.Remove(e.cg)
+	}
+
+	w.history.add(record)
+
+	return w.removeJobWAL(j)
+}
+
+// Close shuts down the Worker according to Config.ShutdownPolicy. It is the
+// same as calling Shutdown without a deadline.
+func (w *Worker) Close() error {
+	return w.Shutdown(context.Background())
+}
+
+// GrantJobAccess gives grantee access to the job identified by jobID. Only the
+// owner of the job may grant access. job.AccessRead permits getting the status
+// and output of the job, job.AccessFull also permits stopping it. If the job
+// does not exist, or if the user is not authorized, ErrJobNotFound will be
+// returned. Users that have been granted access, but are not the owner, get
+// ErrPermissionDenied.
+func (w *Worker) GrantJobAccess(tenant job.TenantID, userID job.UserID, jobID job.ID, grantee job.UserID, access job.Access) (err error) {
+	defer func() { w.record(audit.ActionGrantJobAccess, tenant, userID, jobID, err) }()
+	return w.grantJobAccess(tenant, userID, jobID, grantee, access)
+}
+
+// RevokeJobAccess removes any access previously granted to grantee. Only the
+// owner of the job may revoke access. It returns the same errors as
+// GrantJobAccess.
+func (w *Worker) RevokeJobAccess(tenant job.TenantID, userID job.UserID, jobID job.ID, grantee job.UserID) (err error) {
+	defer func() { w.record(audit.ActionRevokeJobAccess, tenant, userID, jobID, err) }()
+	return w.grantJobAccess(tenant, userID, jobID, grantee, job.AccessNone)
 }
 
-// StopJob kills the job identified by jobID. If the job does not exist, or if
-// the user is not authorized, ErrJobNotFound will be returned.
-func (w *Worker) StopJob(userID job.UserID, jobID job.ID) error {
-	j, err := w.getJob(userID, jobID)
+// grantJobAccess implements GrantJobAccess and RevokeJobAccess
+func (w *Worker) grantJobAccess(tenant job.TenantID, userID job.UserID, jobID job.ID, grantee job.UserID, access job.Access) error {
+	j, err := w.getJob(tenant, userID, jobID, job.AccessFull)
 	if err != nil {
 		return err
 	}
 
-	return j.Stop()
+	if j.UserID() != userID {
+		return ErrPermissionDenied
+	}
+
+	j.Grant(grantee, access)
+	return nil
 }
 
 // StatusResponse is returned by Worker.JobStatus to group the status, exit
@@ -340,41 +1391,271 @@ func (w *Worker) StopJob(userID job.UserID, jobID job.ID) error {
 type StatusResponse struct {
 	Status job.Status
 
-	// ExitCode is optional since, in the case the job is still running or was
-	// killed, it may not have one
+	// ExitCode is optional since, in the case the job is still running, failed
+	// to start or was terminated by a signal, it doesn't have one
 	ExitCode *job.ExitCode
 
 	// Error is optional and should only be checked for complete or stopped
 	// jobs. Sometimes a job may complete with an error that does not have an
 	// exit code, so this may be able to provide more insight.
 	Error error
+
+	// OutputDigest is the SHA-256 digest of the job's complete output. It is
+	// only set once the job is no longer running.
+	OutputDigest []byte
+
+	// Result is the JSON value the job wrote to job.ResultFD, if any. It is
+	// only set once the job is no longer running.
+	Result json.RawMessage
+
+	// ResultError is set if the job wrote a result that was too large or
+	// that was not valid JSON
+	ResultError error
+
+	// Progress is the most recent progress the job reported on
+	// job.ProgressFD, if any
+	Progress *job.Progress
+
+	// StopReason describes why the job was stopped when Status is
+	// job.StatusStopped
+	StopReason job.StopReason
+
+	// Deadline is the time at which the job will be stopped, the zero time if
+	// it has none
+	Deadline time.Time
+
+	// CGroupStats are the cpu throttling and memory limit statistics of the
+	// job, if available
+	CGroupStats *CGroupStats
+
+	// StartTime and EndTime are the wall clock times the job started and
+	// completed at. EndTime is zero while the job is running.
+	StartTime time.Time
+	EndTime   time.Time
+
+	// Runtime is how long the job has run for, measured with the monotonic
+	// clock so that it is correct even if the wall clock jumps
+	Runtime time.Duration
+
+	// Signal is the signal that terminated the job, if any, and SignalName is
+	// its name, e.g. "SIGKILL". SignalSource describes where it came from.
+	Signal       syscall.Signal
+	SignalName   string
+	SignalSource SignalSource
+
+	// Reason describes why the job is no longer running
+	Reason Reason
+
+	// Description, Annotations and CorrelationID are those the job was
+	// started with
+	Description   string
+	Annotations   map[string]string
+	CorrelationID string
+
+	// Queue is the queue the job was scheduled in
+	Queue string
+
+	// GPUs are the gpus the job was granted access to
+	GPUs []string
+
+	// ReadyTime is the wall clock time the job passed the readiness probe it
+	// was started with, the zero time if it hasn't
+	ReadyTime time.Time
+
+	// Restarts is the number of times the job was restarted by its
+	// RestartPolicy. LastExit describes how the attempt before the current
+	// one ended, it is nil if the job hasn't been restarted.
+	Restarts int
+	LastExit *job.Exit
 }
 
 // JobStatus will return the JobStatus and optional ExitCode from the job. The
 // ExitCode will not exist if the job is still running. If the job does not
-// exist, or if the user is not authorized, ErrJobNotFound will be returned.
-func (w *Worker) JobStatus(userID job.UserID, jobID job.ID) (*StatusResponse, error) {
-	j, err := w.getJob(userID, jobID)
+// exist, or if the user has not been granted at least read access,
+// ErrJobNotFound will be returned.
+func (w *Worker) JobStatus(tenant job.TenantID, userID job.UserID, jobID job.ID) (_ *StatusResponse, err error) {
+	defer func() { w.record(audit.ActionJobStatus, tenant, userID, jobID, err) }()
+
+	j, err := w.getJob(tenant, userID, jobID, job.AccessRead)
 	if err != nil {
 		return nil, err
 	}
 
+	return w.jobStatus(j), nil
+}
+
+// WaitJobStatus is like JobStatus, but if the job's status is last it blocks
+// until the status changes or ctx is done, whichever comes first. It then
+// returns the job's current status, which is still last if ctx was done first.
+// This gives clients change notifications by long polling.
+func (w *Worker) WaitJobStatus(ctx context.Context, tenant job.TenantID, userID job.UserID, jobID job.ID, last job.Status) (_ *StatusResponse, err error) {
+	defer func() { w.record(audit.ActionJobStatus, tenant, userID, jobID, err) }()
+
+	j, err := w.getJob(tenant, userID, jobID, job.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+
+	// jobs are started before they are added to the worker, unless they are
+	// queued, so the only status changes that remain are a queued job
+	// starting and a job finishing
+	if j.Status() == last {
+		var started <-chan struct{}
+		if last == job.StatusNotStarted {
+			started = j.Started()
+		}
+
+		select {
+		case <-started:
+		case <-j.Done():
+		case <-ctx.Done():
+		}
+	}
+
+	return w.jobStatus(j), nil
+}
+
+// jobStatus returns the current status of j
+func (w *Worker) jobStatus(j *job.Job) *StatusResponse {
+	result, resultErr := j.Result()
+
+	cg := w.jobCGroup(j)
+
+	var cgStats *CGroupStats
+	if cg != "" {
+		// the stats are only informational, so errors are ignored
+		cgStats, _ = readCGroupStats(cg)
+	}
+
+	var exitCode *job.ExitCode
+	if ec, ok := j.ExitCode(); ok {
+		exitCode = &ec
+	}
+
 	return &StatusResponse{
-		Status:   j.Status(),
-		Error:    j.Error(),
-		ExitCode: j.ExitCode(),
-	}, nil
+		Status:        j.Status(),
+		Error:         j.Error(),
+		ExitCode:      exitCode,
+		OutputDigest:  j.OutputDigest(),
+		Result:        result,
+		ResultError:   resultErr,
+		Progress:      j.Progress(),
+		StopReason:    j.StopReason(),
+		Deadline:      j.Deadline(),
+		CGroupStats:   cgStats,
+		StartTime:     j.StartTime(),
+		EndTime:       j.EndTime(),
+		Runtime:       j.Runtime(),
+		Signal:        j.Signal(),
+		SignalName:    signalName(j.Signal()),
+		SignalSource:  signalSource(j, cgStats),
+		Reason:        reason(j, cgStats),
+		Description:   j.Description(),
+		Annotations:   j.Annotations(),
+		CorrelationID: j.CorrelationID(),
+		Queue:         j.Queue(),
+		GPUs:          j.GPUs(),
+		ReadyTime:     j.ReadyTime(),
+		Restarts:      j.Restarts(),
+		LastExit:      j.LastExit(),
+	}
 }
 
 // JobOutput returns an io.ReadCloser that can be used to stream the output of a
 // job. Creating multiple readers for a single job is safe. It is the
 // responsibility of the caller to close the reader when done to free resources.
-// If the job does not exist, or if the user is not authorized, ErrJobNotFound
-// will be returned.
-func (w *Worker) JobOutput(userID job.UserID, jobID job.ID) (io.ReadCloser, error) {
-	j, err := w.getJob(userID, jobID)
+// If the job does not exist, or if the user has not been granted at least read
+// access, ErrJobNotFound will be returned.
+func (w *Worker) JobOutput(tenant job.TenantID, userID job.UserID, jobID job.ID) (_ io.ReadCloser, err error) {
+	defer func() { w.record(audit.ActionJobOutput, tenant, userID, jobID, err) }()
+
+	j, err := w.getJob(tenant, userID, jobID, job.AccessRead)
 	if err != nil {
 		return nil, err
 	}
 	return j.NewOutputReader(), nil
 }
+
+// JobOutputAt is like JobOutput, but the output begins at offset. It lets
+// clients whose stream was interrupted, e.g. because the server was drained,
+// resume without receiving output they already have again.
+func (w *Worker) JobOutputAt(tenant job.TenantID, userID job.UserID, jobID job.ID, offset int) (_ io.ReadCloser, err error) {
+	defer func() { w.record(audit.ActionJobOutput, tenant, userID, jobID, err) }()
+
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidOffset, offset)
+	}
+
+	j, err := w.getJob(tenant, userID, jobID, job.AccessRead)
+	if err != nil {
+		return nil, err
+	}
+	return j.NewOutputReaderAt(offset), nil
+}
+
+// Stats contains point in time statistics about the output of all of the
+// Worker's jobs. It is intended to help diagnose memory growth and to report
+// the Worker's load to a scheduler.
+type Stats struct {
+	Jobs           map[job.ID]safebuffer.Stats // per job output statistics
+	BufferedBytes  int                         // the total size of all output buffers
+	Readers        int                         // the total number of open output readers
+	EvictedReaders int                         // the total number of output readers evicted for reading too slowly
+	Running        int                         // the number of jobs that are running
+}
+
+// Stats returns output statistics for all jobs in tenant, regardless of user.
+// If tenant is empty, jobs from all tenants are included. It must only be
+// exposed to administrators of tenant.
+func (w *Worker) Stats(tenant job.TenantID) *Stats {
+	ret := Stats{
+		Jobs: map[job.ID]safebuffer.Stats{},
+	}
+
+	for _, j := range w.jobs.all() {
+		if tenant != "" && j.TenantID() != tenant {
+			continue
+		}
+
+		st := j.OutputStats()
+		ret.Jobs[j.ID()] = st
+		ret.BufferedBytes += st.Size
+		ret.Readers += st.Readers
+		ret.EvictedReaders += st.Evicted
+
+		if j.Status() == job.StatusRunning {
+			ret.Running++
+		}
+	}
+
+	return &ret
+}
+
+// record adds an event to the audit log for action having been performed by
+// userID, of tenant, on jobID. err is the error, if any, that was returned to
+// the user.
+func (w *Worker) record(action audit.Action, tenant job.TenantID, userID job.UserID, jobID job.ID, err error) {
+	e := audit.Event{
+		UserID: userID,
+		Tenant: tenant,
+		JobID:  jobID,
+		Action: action,
+	}
+
+	if je, ok := w.jobs.get(jobID); ok && je.job.TenantID() == tenant {
+		e.CorrelationID = je.job.CorrelationID()
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	w.audit.Record(e)
+}
+
+// QueryAuditLog returns the audit events matching q. It must only be exposed to
+// administrators, and q.Tenant should be set to the administrator's tenant
+// unless they may see the events of all tenants.
+func (w *Worker) QueryAuditLog(q *audit.Query) ([]audit.Event, string, error) {
+	return w.audit.Query(q)
+}
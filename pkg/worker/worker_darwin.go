@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// darwin has no namespaces or cgroups, so jobs aren't isolated. they are run
+// with rlimits, their priority, in their own process group, so that they can
+// be killed along with their descendants, and, optionally, with a
+// sandbox-exec(1) profile, see Config.SandboxProfile.
+
+// mountProc does nothing on darwin, which has no /proc
+func mountProc(*ProcPolicy) error {
+	return nil
+}
+
+// bindMount does nothing on darwin, which has no mount namespaces
+func bindMount(string, string) error {
+	return nil
+}
+
+// maskPath does nothing on darwin, which has no mount namespaces
+func maskPath(string) error {
+	return nil
+}
+
+// rlimitResources maps RlimitResource to the values used by setrlimit(2)
+var rlimitResources = map[RlimitResource]int{
+	RlimitCPU:    syscall.RLIMIT_CPU,
+	RlimitFsize:  syscall.RLIMIT_FSIZE,
+	RlimitData:   syscall.RLIMIT_DATA,
+	RlimitStack:  syscall.RLIMIT_STACK,
+	RlimitCore:   syscall.RLIMIT_CORE,
+	RlimitNofile: syscall.RLIMIT_NOFILE,
+	RlimitAS:     syscall.RLIMIT_AS,
+}
+
+// setRlimits applies rlimits to the current process so that they are inherited
+// by the job after syscall.Exec
+func setRlimits(rlimits []Rlimit) error {
+	for _, r := range rlimits {
+		resource, ok := rlimitResources[r.Resource]
+		if !ok {
+			return ErrInvalidRlimit
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: r.Soft, Max: r.Hard}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceOf always fails on darwin since io limits are not supported
+func deviceOf(string) (string, error) {
+	return "", ErrNoBlockDevice
+}
+
+// tryLock takes an exclusive flock(2) on f without blocking. it returns
+// ErrWALDirLocked if the lock is held by another open file.
+func tryLock(f *os.File) error {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return ErrWALDirLocked
+	}
+	return err
+}
+
+// isCGroup2 always returns false on darwin, which has no cgroups
+func isCGroup2(*os.File) bool {
+	return false
+}
+
+// closeOnExec marks fd to be closed when the process calls exec
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
+
+// setPriority applies p.Nice to the current process so that it is inherited by
+// the job after syscall.Exec. io priorities and oom scores aren't supported on
+// darwin and are ignored.
+func setPriority(p *Priority) error {
+	if p.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, p.Nice); err != nil {
+			return fmt.Errorf("error setting nice: %w", err)
+		}
+	}
+	return nil
+}
+
+// setsid starts a new session, and process group, without a controlling
+// terminal, for the current process so that it is inherited by the job after
+// syscall.Exec
+func setsid() error {
+	_, err := unix.Setsid()
+	return err
+}
+
+// setpgid starts a new process group for the current process, in the same
+// session, so that the job and its descendants can be killed together
+func setpgid() error {
+	return unix.Setpgid(0, 0)
+}
+
+// setNoNewPrivs does nothing on darwin, which doesn't support it
+func setNoNewPrivs() error {
+	return nil
+}
+
+// signalName returns the name of sig, e.g. "SIGKILL", or "" if sig is 0
+func signalName(sig syscall.Signal) string {
+	if sig == 0 {
+		return ""
+	}
+	return unix.SignalName(sig)
+}
@@ -1,12 +1,203 @@
 package worker
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
-// mountProc mounts the /proc filesystem. it is in a separate linux file
-// because syscall.Mount does not exist on all GOOS
-func mountProc() error {
+// mountProc mounts the /proc filesystem according to policy and then masks,
+// or makes read only, the files that jobs shouldn't have access to. it is in a
+// separate linux file because syscall.Mount does not exist on all GOOS
+func mountProc(policy *ProcPolicy) error {
 	// TODO(jrubin) does this need to be unmounted? is that possible after Exec?
-	return syscall.Mount("proc", "/proc", "proc", 0, "")
+	flags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC)
+	if policy.ReadOnly {
+		flags |= syscall.MS_RDONLY
+	}
+
+	var err error
+	if !policy.HidePID {
+		err = syscall.Mount("proc", "/proc", "proc", flags, "")
+	} else if err = syscall.Mount("proc", "/proc", "proc", flags, "hidepid=invisible"); errors.Is(err, syscall.EINVAL) {
+		// kernels before 5.8 only support the numeric value
+		err = syscall.Mount("proc", "/proc", "proc", flags, "hidepid=2")
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, path := range maskedProcPaths {
+		if err = maskPath(path); err != nil {
+			return fmt.Errorf("error masking %q: %w", path, err)
+		}
+	}
+
+	for _, path := range readOnlyProcPaths {
+		if _, err = os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err = bindMount(path, path); err != nil {
+			return fmt.Errorf("error making %q read only: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// maskPath hides path by mounting /dev/null, or an empty read only tmpfs if
+// path is a directory, over it. it does nothing if path doesn't exist.
+func maskPath(path string) error {
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		return syscall.Mount("tmpfs", path, "tmpfs", syscall.MS_RDONLY, "")
+	}
+
+	return syscall.Mount("/dev/null", path, "", syscall.MS_BIND, "")
+}
+
+// bindMount mounts source on target, read only. the mount is only visible in
+// the job's mount namespace.
+func bindMount(source, target string) error {
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return err
+	}
+
+	// the read only flag is ignored when creating a bind mount, so it must be
+	// remounted
+	return syscall.Mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, "")
+}
+
+// rlimitResources maps RlimitResource to the values used by setrlimit(2)
+var rlimitResources = map[RlimitResource]int{
+	RlimitCPU:    syscall.RLIMIT_CPU,
+	RlimitFsize:  syscall.RLIMIT_FSIZE,
+	RlimitData:   syscall.RLIMIT_DATA,
+	RlimitStack:  syscall.RLIMIT_STACK,
+	RlimitCore:   syscall.RLIMIT_CORE,
+	RlimitNofile: syscall.RLIMIT_NOFILE,
+	RlimitAS:     syscall.RLIMIT_AS,
+}
+
+// setRlimits applies rlimits to the current process so that they are inherited
+// by the job after syscall.Exec
+func setRlimits(rlimits []Rlimit) error {
+	for _, r := range rlimits {
+		resource, ok := rlimitResources[r.Resource]
+		if !ok {
+			return ErrInvalidRlimit
+		}
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: r.Soft, Max: r.Hard}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceOf returns the MAJOR:MINOR of the block device at path or, if path is
+// not a block device, of the device containing it
+func deviceOf(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ErrNoBlockDevice
+	}
+
+	dev := st.Dev
+	if fi.Mode()&os.ModeDevice != 0 && fi.Mode()&os.ModeCharDevice == 0 {
+		dev = st.Rdev
+	}
+
+	return fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev)), nil
+}
+
+// tryLock takes an exclusive flock(2) on f without blocking. it returns
+// ErrWALDirLocked if the lock is held by another open file.
+func tryLock(f *os.File) error {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return ErrWALDirLocked
+	}
+	return err
+}
+
+// isCGroup2 returns whether f is on a cgroup v2 filesystem
+func isCGroup2(f *os.File) bool {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(f.Fd()), &st); err != nil {
+		return false
+	}
+	return st.Type == unix.CGROUP2_SUPER_MAGIC
+}
+
+// closeOnExec marks fd to be closed when the process calls exec
+func closeOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
+
+// setPriority applies p to the current process so that it is inherited by the
+// job after syscall.Exec
+func setPriority(p *Priority) error {
+	if p.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, p.Nice); err != nil {
+			return fmt.Errorf("error setting nice: %w", err)
+		}
+	}
+
+	if p.IOClass != IOClassNone {
+		const whoProcess = 1 // IOPRIO_WHO_PROCESS
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, whoProcess, 0, uintptr(p.ioprio())); errno != 0 {
+			return fmt.Errorf("error setting io priority: %w", errno)
+		}
+	}
+
+	if p.OOMScoreAdj != 0 {
+		if err := os.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(p.OOMScoreAdj)), 0); err != nil {
+			return fmt.Errorf("error setting oom score adj: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// setsid starts a new session, without a controlling terminal, for the
+// current process so that it is inherited by the job after syscall.Exec
+func setsid() error {
+	_, err := unix.Setsid()
+	return err
+}
+
+// setpgid does nothing on linux, the job's descendants are killed along with
+// its pid namespace and cgroup instead of its process group
+func setpgid() error {
+	return nil
+}
+
+// setNoNewPrivs sets no_new_privs on the current process so that the job, and
+// all of its descendants, can't gain privileges after syscall.Exec
+func setNoNewPrivs() error {
+	return unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+}
+
+// signalName returns the name of sig, e.g. "SIGKILL", or "" if sig is 0
+func signalName(sig syscall.Signal) string {
+	if sig == 0 {
+		return ""
+	}
+	return unix.SignalName(sig)
 }
@@ -1,9 +1,87 @@
-//go:build !linux
+//go:build !linux && !darwin
 
 package worker
 
-// mountProc is here for all non-linux builds but does nothing and exists only
+import (
+	"os"
+	"syscall"
+)
+
+// mountProc is here for all other builds but does nothing and exists only to
+// make builds work
+func mountProc(*ProcPolicy) error {
+	return nil
+}
+
+// bindMount is here for all other builds but does nothing and exists only to
+// make builds work
+func bindMount(string, string) error {
+	return nil
+}
+
+// maskPath is here for all other builds but does nothing and exists only to
+// make builds work
+func maskPath(string) error {
+	return nil
+}
+
+// setRlimits is here for all other builds but does nothing and exists only to
+// make builds work
+func setRlimits([]Rlimit) error {
+	return nil
+}
+
+// deviceOf is here for all other builds but always fails and exists only to
+// make builds work
+func deviceOf(string) (string, error) {
+	return "", ErrNoBlockDevice
+}
+
+// tryLock is here for all other builds but does nothing and exists only to
+// make builds work
+func tryLock(*os.File) error {
+	return nil
+}
+
+// isCGroup2 is here for all other builds but always returns false and exists
+// only to make builds work
+func isCGroup2(*os.File) bool {
+	return false
+}
+
+// closeOnExec is here for all other builds but does nothing and exists only to
+// make builds work
+func closeOnExec(int) {}
+
+// setPriority is here for all other builds but does nothing and exists only to
+// make builds work
+func setPriority(*Priority) error {
+	return nil
+}
+
+// setsid is here for all other builds but does nothing and exists only to make
+// builds work
+func setsid() error {
+	return nil
+}
+
+// setpgid is here for all other builds but does nothing and exists only to
+// make builds work
+func setpgid() error {
+	return nil
+}
+
+// setNoNewPrivs is here for all other builds but does nothing and exists only
 // to make builds work
-func mountProc() error {
+func setNoNewPrivs() error {
 	return nil
 }
+
+// signalName is here for all other builds and returns the description of sig
+// since signal names are not available on all platforms
+func signalName(sig syscall.Signal) string {
+	if sig == 0 {
+		return ""
+	}
+	return sig.String()
+}
@@ -2,48 +2,58 @@ package worker
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
 
+	"github.com/joshuarubin/teleport-job-worker/pkg/audit"
+	"github.com/joshuarubin/teleport-job-worker/pkg/event"
 	"github.com/joshuarubin/teleport-job-worker/pkg/job"
+	"github.com/joshuarubin/teleport-job-worker/pkg/logship"
 	"github.com/joshuarubin/teleport-job-worker/pkg/safebuffer/safereader"
+	"github.com/joshuarubin/teleport-job-worker/pkg/wal"
+	"github.com/joshuarubin/teleport-job-worker/pkg/webhook"
+	"github.com/joshuarubin/teleport-job-worker/pkg/worker/workertest"
 )
 
 func TestMain(m *testing.M) {
 	switch os.Getenv("GO_TEST_MODE") {
 	case "":
 		// Normal test mode
-		os.Exit(m.Run())
+		goleak.VerifyTestMain(m)
 	case "child":
-		if len(os.Args) < 2 {
-			fmt.Fprintf(os.Stderr, "command is required")
-			os.Exit(1)
-		}
-		child(os.Args[1], os.Args[2:]...)
+		child()
 	}
 }
 
 func newJobWorker() (*Worker, error) {
-	memoryMax := uint32(134217728)
-	if v := os.Getenv("GO_TEST_JOB_WORKER_MEMORY_MAX"); v != "" {
-		m, err := strconv.Atoi(v)
-		if err != nil {
-			return nil, err
-		}
-		memoryMax = uint32(m)
-	}
-
 	cfg := Config{
 		CPUMax:        .25,
-		MemoryMax:     memoryMax,
+		MemoryMax:     134217728,
 		RIOPSMax:      100,
 		WIOPSMax:      10,
 		ReexecCommand: os.Args[0], // /proc/self/exe doesn't work on mac
@@ -52,14 +62,11 @@ func newJobWorker() (*Worker, error) {
 	return New(&cfg)
 }
 
-func child(command string, args ...string) {
-	w, err := newJobWorker()
-	if err != nil {
-		panic(err)
-	}
-	err = w.StartJobChild(command, args...)
-	if err != nil {
-		panic(err)
+func child() {
+	// like a helper binary, the child doesn't create a Worker
+	if err := StartChild(); err != nil {
+		// the error has already been reported to the parent
+		os.Exit(1)
 	}
 }
 
@@ -75,18 +82,18 @@ func TestJobWorker(t *testing.T) {
 		w, err := newJobWorker()
 		require.NoError(err)
 
-		jobID, err := w.StartJob(userID, "sh", "-c", "while true; do echo y && sleep .1; done")
+		jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do echo y && sleep .1; done")
 		require.NoError(err)
 
-		st, err := w.JobStatus(userID, jobID)
+		st, err := w.JobStatus("", userID, jobID)
 		require.NoError(err)
 		assert.Equal(job.StatusRunning, st.Status)
 
 		badUserID := job.UserID("foo")
-		_, err = w.JobStatus(badUserID, jobID)
+		_, err = w.JobStatus("", badUserID, jobID)
 		require.ErrorIs(ErrJobNotFound, err)
 
-		r, err := w.JobOutput(userID, jobID)
+		r, err := w.JobOutput("", userID, jobID)
 		require.NoError(err)
 
 		p := make([]byte, 2)
@@ -104,15 +111,18 @@ func TestJobWorker(t *testing.T) {
 		_, err = io.ReadAll(r)
 		require.ErrorIs(err, safereader.ErrReaderClosed)
 
-		err = w.StopJob(userID, jobID)
+		err = w.StopJob("", userID, jobID)
 		require.NoError(err)
 
-		st, err = w.JobStatus(userID, jobID)
+		st, err = w.JobStatus("", userID, jobID)
 		require.NoError(err)
 		assert.Equal(job.StatusStopped, st.Status)
-		require.NotNil(st.ExitCode)
-		assert.Equal(-1, st.ExitCode.Int())
+		assert.Equal(job.StopReasonRequested, st.StopReason)
+		assert.Equal(ReasonStoppedByUser, st.Reason)
+		assert.Nil(st.ExitCode)
 		assert.Error(st.Error)
+		assert.Equal(syscall.SIGKILL, st.Signal)
+		assert.Equal(SignalSourceStop, st.SignalSource)
 	})
 
 	t.Run("short-job", func(t *testing.T) {
@@ -124,18 +134,43 @@ func TestJobWorker(t *testing.T) {
 		w, err := newJobWorker()
 		require.NoError(err)
 
-		jobID, err := w.StartJob(userID, "sh", "-c", "true")
+		jobID, err := w.StartJob("", userID, "sh", "-c", "echo foo")
 		require.NoError(err)
 
-		r, err := w.JobOutput(userID, jobID)
+		r, err := w.JobOutput("", userID, jobID)
 		require.NoError(err)
 
-		_, err = io.ReadAll(r)
+		data, err := io.ReadAll(r)
 		require.NoError(err)
 
-		st, err := w.JobStatus(userID, jobID)
+		st, err := w.JobStatus("", userID, jobID)
 		require.NoError(err)
 		assert.Equal(job.StatusCompleted, st.Status)
+
+		digest := sha256.Sum256(data)
+		assert.Equal(digest[:], st.OutputDigest)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+
+		jobID, err := w.StartJob("", userID, "sh", "-c", "echo hello")
+		require.NoError(err)
+
+		r, err := w.JobOutputAt("", userID, jobID, 2)
+		require.NoError(err)
+
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		require.Equal("llo\n", string(data))
+
+		_, err = w.JobOutputAt("", userID, jobID, -1)
+		require.ErrorIs(err, ErrInvalidOffset)
 	})
 
 	t.Run("multiple-readers", func(t *testing.T) {
@@ -146,12 +181,12 @@ func TestJobWorker(t *testing.T) {
 		w, err := newJobWorker()
 		require.NoError(err)
 
-		jobID, err := w.StartJob(userID, "sh", "-c", "while true; do echo y && sleep .1; done")
+		jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do echo y && sleep .1; done")
 		require.NoError(err)
 
-		r0, err := w.JobOutput(userID, jobID)
+		r0, err := w.JobOutput("", userID, jobID)
 		require.NoError(err)
-		r1, err := w.JobOutput(userID, jobID)
+		r1, err := w.JobOutput("", userID, jobID)
 		require.NoError(err)
 
 		errCh0 := make(chan error)
@@ -175,7 +210,7 @@ func TestJobWorker(t *testing.T) {
 
 		time.Sleep(1 * time.Second)
 
-		err = w.StopJob(userID, jobID)
+		err = w.StopJob("", userID, jobID)
 		require.NoError(err)
 
 		err = <-errCh0
@@ -198,10 +233,10 @@ func TestJobWorker(t *testing.T) {
 		w, err := newJobWorker()
 		require.NoError(err)
 
-		jobID, err := w.StartJob(userID, "sh", "-c", "echo $$")
+		jobID, err := w.StartJob("", userID, "sh", "-c", "echo $$")
 		require.NoError(err)
 
-		r, err := w.JobOutput(userID, jobID)
+		r, err := w.JobOutput("", userID, jobID)
 		require.NoError(err)
 
 		data, err := io.ReadAll(r)
@@ -210,6 +245,317 @@ func TestJobWorker(t *testing.T) {
 		assert.Equal("1", string(data))
 	})
 
+	t.Run("dns", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		require := require.New(t)
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		resolvConf := filepath.Join(dir, "resolv.conf")
+		require.NoError(os.WriteFile(resolvConf, []byte("nameserver 192.0.2.1\n"), 0o644))
+		hosts := filepath.Join(dir, "hosts")
+		require.NoError(os.WriteFile(hosts, []byte("127.0.0.1 localhost\n"), 0o644))
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+		w.cfg.ResolvConfPath = resolvConf
+		w.cfg.HostsPath = hosts
+
+		jobID, err := w.StartJob("", userID, "sh", "-c", "cat /etc/resolv.conf /etc/hosts && echo foo > /etc/hosts")
+		require.NoError(err)
+
+		r, err := w.JobOutput("", userID, jobID)
+		require.NoError(err)
+
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		assert.Contains(string(data), "nameserver 192.0.2.1\n127.0.0.1 localhost\n")
+
+		// the files are mounted read only
+		st, err := w.JobStatus("", userID, jobID)
+		require.NoError(err)
+		require.NotNil(st.ExitCode)
+		assert.NotEqual(0, st.ExitCode.Int())
+	})
+
+	t.Run("proc", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		require := require.New(t)
+		assert := assert.New(t)
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+		w.cfg.Proc = ProcPolicy{ReadOnly: true, HidePID: true}
+
+		jobID, err := w.StartJob("", userID, "sh", "-c", "grep '^proc /proc ' /proc/mounts; for f in /proc/keys /proc/sysrq-trigger /proc/timer_list; do test ! -e $f || test -c $f || echo unmasked $f; done")
+		require.NoError(err)
+
+		r, err := w.JobOutput("", userID, jobID)
+		require.NoError(err)
+
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		assert.Regexp(`proc /proc proc ro,nosuid,nodev,noexec,.*hidepid=`, string(data))
+
+		// the files that exist, which depends on the kernel, are masked with
+		// /dev/null
+		assert.NotContains(string(data), "unmasked")
+	})
+
+	t.Run("privileges", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		for _, tc := range []struct {
+			name   string
+			allow  bool
+			output string
+		}{
+			// the job is the leader of a new session, which is pid 1 in the
+			// job's pid namespace
+			{name: "default", output: "NoNewPrivs:\t1\n1\n"},
+			// the session is outside of the job's pid namespace
+			{name: "escape-hatch", allow: true, output: "NoNewPrivs:\t0\n0\n"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+				require := require.New(t)
+
+				userID := job.UserID("userID")
+				w, err := newJobWorker()
+				require.NoError(err)
+				w.cfg.AllowNewPrivileges = tc.allow
+				w.cfg.InheritSession = tc.allow
+
+				jobID, err := w.StartJob("", userID, "sh", "-c", "grep NoNewPrivs /proc/self/status && cut -d ' ' -f 6 /proc/1/stat")
+				require.NoError(err)
+
+				r, err := w.JobOutput("", userID, jobID)
+				require.NoError(err)
+
+				data, err := io.ReadAll(r)
+				require.NoError(err)
+				assert.Equal(t, tc.output, string(data))
+			})
+		}
+	})
+
+	t.Run("init", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		require := require.New(t)
+		assert := assert.New(t)
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+		w.cfg.Init = true
+
+		// the job isn't pid 1 and the orphaned true is reaped by init, not by
+		// the job's shell, so it doesn't remain a zombie
+		jobID, err := w.StartJob("", userID, "sh", "-c", "test $$ -ne 1 && sh -c 'true &' && sleep .2 && cat /proc/[0-9]*/stat | grep -c ') Z ' ; exit 3")
+		require.NoError(err)
+
+		r, err := w.JobOutput("", userID, jobID)
+		require.NoError(err)
+
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		assert.Equal("0\n", string(data))
+
+		st, err := w.JobStatus("", userID, jobID)
+		require.NoError(err)
+		require.NotNil(st.ExitCode)
+		assert.Equal(3, st.ExitCode.Int())
+
+		// the Worker's default can be overridden per job
+		noInit := false
+		jobID, err = w.StartJobWithOptions("", userID, &JobOptions{Init: &noInit}, "sh", "-c", "test $$ -eq 1")
+		require.NoError(err)
+
+		r, err = w.JobOutput("", userID, jobID)
+		require.NoError(err)
+		_, err = io.ReadAll(r)
+		require.NoError(err)
+
+		st, err = w.JobStatus("", userID, jobID)
+		require.NoError(err)
+		require.NotNil(st.ExitCode)
+		assert.Equal(0, st.ExitCode.Int())
+	})
+
+	t.Run("isolation", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		require := require.New(t)
+		assert := assert.New(t)
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+		w.cfg.IsolationProfiles = map[string]IsolationProfile{
+			IsolationNone: {Users: []job.UserID{userID}},
+		}
+
+		output := func(profile string, command string) string {
+			jobID, err := w.StartJobWithOptions("", userID, &JobOptions{IsolationProfile: profile}, "sh", "-c", command)
+			require.NoError(err)
+
+			r, err := w.JobOutput("", userID, jobID)
+			require.NoError(err)
+
+			data, err := io.ReadAll(r)
+			require.NoError(err)
+			return string(data)
+		}
+
+		// the strict profile installs a seccomp filter and only keeps
+		// DefaultCapabilities
+		assert.Equal("CapBnd:\t00000000a80405fb\nSeccomp:\t2\n", output(IsolationStrict, "grep -E '^(CapBnd|Seccomp):' /proc/self/status"))
+
+		// the none profile runs the job in the host's namespaces
+		hostNet, err := os.Readlink("/proc/self/ns/net")
+		require.NoError(err)
+		assert.Equal(hostNet+"\n", output(IsolationNone, "readlink /proc/self/ns/net"))
+
+		_, err = w.StartJobWithOptions("", userID, &JobOptions{IsolationProfile: "missing"}, "true")
+		require.ErrorIs(err, ErrIsolationProfileNotFound)
+
+		_, err = w.StartJobWithOptions("", "other", &JobOptions{IsolationProfile: IsolationNone}, "true")
+		require.ErrorIs(err, ErrIsolationProfileNotPermitted)
+	})
+
+	t.Run("gpus", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		require := require.New(t)
+		assert := assert.New(t)
+
+		// character devices in a temporary directory stand in for the gpus
+		dir := t.TempDir()
+		for _, dev := range []struct{ name, minor string }{{"gpu0", "0"}, {"gpu1", "1"}, {"ctl", "255"}} {
+			require.NoError(exec.Command("mknod", filepath.Join(dir, dev.name), "c", "195", dev.minor).Run())
+		}
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+		w.cfg.GPUs = map[string]GPU{
+			"0": {Devices: []string{filepath.Join(dir, "gpu0"), filepath.Join(dir, "ctl")}, Users: []job.UserID{userID}},
+			"1": {Devices: []string{filepath.Join(dir, "gpu1"), filepath.Join(dir, "ctl")}, Users: []job.UserID{userID}},
+		}
+		require.NoError(validateGPUs(w.cfg.GPUs))
+
+		jobID, err := w.StartJobWithOptions("", userID, &JobOptions{GPUs: []string{"0"}}, "stat", "-c", "%t:%T", filepath.Join(dir, "gpu0"), filepath.Join(dir, "gpu1"), filepath.Join(dir, "ctl"))
+		require.NoError(err)
+
+		r, err := w.JobOutput("", userID, jobID)
+		require.NoError(err)
+
+		// gpu1 is masked with /dev/null, the shared ctl device is not
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		assert.Equal("c3:0\n1:3\nc3:ff\n", string(data))
+
+		st, err := w.JobStatus("", userID, jobID)
+		require.NoError(err)
+		assert.Equal([]string{"0"}, st.GPUs)
+
+		_, err = w.StartJobWithOptions("", userID, &JobOptions{GPUs: []string{"2"}}, "true")
+		require.ErrorIs(err, ErrGPUNotFound)
+
+		_, err = w.StartJobWithOptions("", "other", &JobOptions{GPUs: []string{"1"}}, "true")
+		require.ErrorIs(err, ErrGPUNotPermitted)
+	})
+
+	t.Run("readiness", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		require := require.New(t)
+		assert := assert.New(t)
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+
+		sink := make(chanSink, 16)
+		w.sinks = append(w.sinks, sink)
+
+		ready := func(probe *ReadinessProbe, command string) {
+			jobID, err := w.StartJobWithOptions("", userID, &JobOptions{Readiness: probe}, "sh", "-c", command)
+			require.NoError(err)
+
+			st, err := w.JobStatus("", userID, jobID)
+			require.NoError(err)
+			assert.True(st.ReadyTime.IsZero())
+
+			for e := range sink {
+				if e.JobID == jobID.String() && e.Type == event.TypeReady {
+					break
+				}
+			}
+
+			st, err = w.JobStatus("", userID, jobID)
+			require.NoError(err)
+			assert.Equal(job.StatusRunning, st.Status)
+			assert.False(st.ReadyTime.IsZero())
+
+			require.NoError(w.StopJob("", userID, jobID))
+		}
+
+		ready(&ReadinessProbe{OutputRegexp: "^listening on [0-9]+$"}, "echo starting; sleep 0.1; echo listening on 8080; sleep 10")
+
+		// the file is checked for as the job sees it
+		file := filepath.Join(t.TempDir(), "ready")
+		ready(&ReadinessProbe{File: file, Interval: 10 * time.Millisecond}, "sleep 0.1; touch "+file+"; sleep 10")
+
+		// jobs that complete without becoming ready are never ready
+		jobID, err := w.StartJobWithOptions("", userID, &JobOptions{Readiness: &ReadinessProbe{OutputRegexp: "ready"}}, "echo", "starting")
+		require.NoError(err)
+
+		st, err := w.WaitJobStatus(context.Background(), "", userID, jobID, job.StatusRunning)
+		require.NoError(err)
+		assert.Equal(job.StatusCompleted, st.Status)
+		assert.True(st.ReadyTime.IsZero())
+
+		_, err = w.StartJobWithOptions("", userID, &JobOptions{Readiness: &ReadinessProbe{TCPPort: 8080, File: file}}, "true")
+		require.ErrorIs(err, ErrInvalidReadinessProbe)
+
+		_, err = w.StartJobWithOptions("", userID, &JobOptions{Readiness: &ReadinessProbe{File: "ready"}}, "true")
+		require.ErrorIs(err, ErrInvalidReadinessProbe)
+	})
+
 	t.Run("cgroup", func(t *testing.T) {
 		t.Parallel()
 
@@ -223,26 +569,3082 @@ func TestJobWorker(t *testing.T) {
 		userID := job.UserID("userID")
 		w, err := newJobWorker()
 		require.NoError(err)
-		w.cfg.ReexecEnv = append(w.cfg.ReexecEnv,
-			// anything should need more than 1B of memory, right?
-			"GO_TEST_JOB_WORKER_MEMORY_MAX=1",
-		)
+		// anything should need more than 1B of memory, right?
+		w.cfg.MemoryMax = 1
 
-		jobID, err := w.StartJob(userID, "yes")
+		jobID, err := w.StartJob("", userID, "yes")
 		require.NoError(err)
 
-		r, err := w.JobOutput(userID, jobID)
+		r, err := w.JobOutput("", userID, jobID)
 		require.NoError(err)
 
 		data, err := io.ReadAll(r)
 		require.NoError(err)
 		assert.Empty(data)
 
-		st, err := w.JobStatus(userID, jobID)
+		st, err := w.JobStatus("", userID, jobID)
 		require.NoError(err)
 		assert.Equal(job.StatusCompleted, st.Status)
-		require.NotNil(st.ExitCode)
-		assert.Equal(-1, st.ExitCode.Int())
+		assert.Nil(st.ExitCode)
 		assert.Error(st.Error)
+		assert.Equal(syscall.SIGKILL, st.Signal)
+		assert.Equal(SignalSourceOOM, st.SignalSource)
+		assert.Equal(ReasonOOMKilled, st.Reason)
+	})
+
+	t.Run("rlimit", func(t *testing.T) {
+		t.Parallel()
+
+		if runtime.GOOS != linuxOS {
+			t.Skip()
+		}
+
+		require := require.New(t)
+		assert := assert.New(t)
+
+		userID := job.UserID("userID")
+		w, err := newJobWorker()
+		require.NoError(err)
+
+		opts := JobOptions{
+			Rlimits: []Rlimit{{Resource: RlimitNofile, Soft: 64, Hard: 128}},
+		}
+
+		jobID, err := w.StartJobWithOptions("", userID, &opts, "sh", "-c", "ulimit -Sn && ulimit -Hn")
+		require.NoError(err)
+
+		r, err := w.JobOutput("", userID, jobID)
+		require.NoError(err)
+
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+		assert.Equal("64\n128\n", string(data))
 	})
 }
+
+func TestJobAccess(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	owner := job.UserID("owner")
+	other := job.UserID("other")
+
+	jobID, err := w.StartJob("", owner, "sh", "-c", "while true; do echo y && sleep .1; done")
+	require.NoError(err)
+
+	_, err = w.JobStatus("", other, jobID)
+	require.ErrorIs(err, ErrJobNotFound)
+
+	// only the owner can grant access
+	err = w.GrantJobAccess("", other, jobID, other, job.AccessFull)
+	require.ErrorIs(err, ErrJobNotFound)
+
+	require.NoError(w.GrantJobAccess("", owner, jobID, other, job.AccessRead))
+
+	st, err := w.JobStatus("", other, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	r, err := w.JobOutput("", other, jobID)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	err = w.StopJob("", other, jobID)
+	require.ErrorIs(err, ErrPermissionDenied)
+
+	err = w.GrantJobAccess("", other, jobID, other, job.AccessFull)
+	require.ErrorIs(err, ErrPermissionDenied)
+
+	require.NoError(w.RevokeJobAccess("", owner, jobID, other))
+
+	_, err = w.JobStatus("", other, jobID)
+	require.ErrorIs(err, ErrJobNotFound)
+
+	require.NoError(w.GrantJobAccess("", owner, jobID, other, job.AccessFull))
+	require.NoError(w.StopJob("", other, jobID))
+
+	events, _, err := w.QueryAuditLog(&audit.Query{UserID: other, Action: audit.ActionStopJob})
+	require.NoError(err)
+	require.Len(events, 2)
+	assert.Equal(ErrPermissionDenied.Error(), events[0].Error)
+	assert.Empty(events[1].Error)
+}
+
+func TestJobAnnotations(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	annotations := map[string]string{"ticket": "OPS-123", "owner": "data"}
+
+	jobID, err := w.StartJobWithOptions("", userID, &JobOptions{
+		Description: "backfill of last week's events",
+		Annotations: annotations,
+	}, "true")
+	require.NoError(err)
+
+	// the job keeps its own copy
+	annotations["ticket"] = "changed"
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal("backfill of last week's events", st.Description)
+	assert.Equal(map[string]string{"ticket": "OPS-123", "owner": "data"}, st.Annotations)
+
+	for _, opts := range []*JobOptions{
+		{Description: strings.Repeat("x", MaxDescriptionLength+1)},
+		{Annotations: map[string]string{"": "foo"}},
+		{Annotations: map[string]string{strings.Repeat("x", MaxAnnotationKeyLength+1): "foo"}},
+		{Annotations: map[string]string{"foo": strings.Repeat("x", MaxAnnotationValueLength+1)}},
+	} {
+		_, err = w.StartJobWithOptions("", userID, opts, "true")
+		require.ErrorIs(err, ErrInvalidAnnotations)
+	}
+
+	tooMany := map[string]string{}
+	for i := range MaxAnnotations + 1 {
+		tooMany[strconv.Itoa(i)] = ""
+	}
+	_, err = w.StartJobWithOptions("", userID, &JobOptions{Annotations: tooMany}, "true")
+	require.ErrorIs(err, ErrInvalidAnnotations)
+}
+
+func TestCorrelationID(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+
+	jobID, err := w.StartJobWithOptions("", userID, &JobOptions{CorrelationID: "ci-run-42"}, "true")
+	require.NoError(err)
+
+	_, err = w.StartJob("", userID, "true")
+	require.NoError(err)
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal("ci-run-42", st.CorrelationID)
+
+	records := w.ListJobs("", userID, &ListJobsQuery{CorrelationID: "ci-run-42"})
+	require.Len(records, 1)
+	assert.Equal(jobID, records[0].ID)
+
+	events, _, err := w.QueryAuditLog(&audit.Query{CorrelationID: "ci-run-42"})
+	require.NoError(err)
+	require.NotEmpty(events)
+	for _, e := range events {
+		assert.Equal(jobID, e.JobID)
+	}
+
+	for _, id := range []string{
+		"has space",
+		"tab\there",
+		"ünicode",
+		strings.Repeat("x", MaxCorrelationIDLength+1),
+	} {
+		_, err = w.StartJobWithOptions("", userID, &JobOptions{CorrelationID: id}, "true")
+		require.ErrorIs(err, ErrInvalidCorrelationID)
+	}
+}
+
+func TestStopJobs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	owner, other := job.UserID("owner"), job.UserID("other")
+
+	var jobIDs []job.ID
+	for range 3 {
+		jobID, err := w.StartJob("", owner, "sleep", "10")
+		require.NoError(err)
+		jobIDs = append(jobIDs, jobID)
+	}
+
+	otherID, err := w.StartJob("", other, "sleep", "10")
+	require.NoError(err)
+	require.NoError(w.GrantJobAccess("", other, otherID, owner, job.AccessRead))
+
+	errs := w.StopJobs("", owner, jobIDs[0], otherID)
+	assert.Equal(map[job.ID]error{otherID: ErrPermissionDenied}, errs)
+
+	st, err := w.JobStatus("", owner, jobIDs[0])
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+
+	// jobs shared with the user aren't stopped, nor are those already stopped
+	stopped, errs := w.StopAllJobs("", owner)
+	assert.Empty(errs)
+	assert.ElementsMatch(jobIDs[1:], stopped)
+
+	st, err = w.JobStatus("", other, otherID)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	stopped, errs = w.StopAllJobs("", other)
+	assert.Empty(errs)
+	assert.Equal([]job.ID{otherID}, stopped)
+}
+
+func TestStopFinishedJob(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "true")
+	require.NoError(err)
+
+	_, err = w.WaitJob(context.Background(), "", userID, jobID)
+	require.NoError(err)
+
+	before, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, before.Status)
+
+	// stopping a job that is done doesn't change how it ended
+	require.NoError(w.StopJob("", userID, jobID))
+
+	after, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(before.Status, after.Status)
+	assert.Equal(before.StopReason, after.StopReason)
+	assert.Equal(ReasonCompletedOK, after.Reason)
+	assert.Equal(SignalSourceNone, after.SignalSource)
+}
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	received := make(chan *event.Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var e event.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err == nil {
+			received <- &e
+		}
+	}))
+	defer srv.Close()
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	wh, err := webhook.New(&webhook.Config{URL: srv.URL})
+	require.NoError(err)
+	w.sinks = append(w.sinks, wh)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "exit 3")
+	require.NoError(err)
+
+	// the job.started event is not delivered to webhooks
+	e := <-received
+	assert.Equal(event.TypeFailed, e.Type)
+	assert.Equal(jobID.String(), e.JobID)
+	assert.Equal(userID.String(), e.UserID)
+	assert.Equal(job.StatusCompleted.String(), e.Status)
+	require.NotNil(e.ExitCode)
+	assert.Equal(3, *e.ExitCode)
+}
+
+func TestWatchJobs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	owner, other := job.UserID("owner"), job.UserID("other")
+
+	existing, err := w.StartJob("", owner, "sleep", "10")
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := w.WatchJobs(ctx, "", owner)
+	require.NoError(err)
+
+	otherCh, err := w.WatchJobs(ctx, "", other)
+	require.NoError(err)
+
+	// the current state of existing jobs is sent first
+	e := <-ch
+	assert.Equal(existing.String(), e.JobID)
+	assert.Equal(job.StatusRunning.String(), e.Status)
+
+	require.NoError(w.StopJob("", owner, existing))
+
+	// the started event of existing may be repeated
+	for e = <-ch; e.Type == event.TypeStarted; e = <-ch {
+		assert.Equal(existing.String(), e.JobID)
+	}
+	assert.Equal(event.TypeStopped, e.Type)
+	assert.Equal(existing.String(), e.JobID)
+
+	shared, err := w.StartJob("", owner, "sleep", "10")
+	require.NoError(err)
+	require.NoError(w.GrantJobAccess("", owner, shared, other, job.AccessRead))
+
+	e = <-ch
+	assert.Equal(event.TypeStarted, e.Type)
+	assert.Equal(shared.String(), e.JobID)
+
+	require.NoError(w.StopJob("", owner, shared))
+
+	e = <-ch
+	assert.Equal(event.TypeStopped, e.Type)
+	assert.Equal(shared.String(), e.JobID)
+
+	// other only has access once the job has been shared with them, which
+	// may be after it started
+	for e = <-otherCh; e.Type == event.TypeStarted; e = <-otherCh {
+		assert.Equal(shared.String(), e.JobID)
+	}
+	assert.Equal(event.TypeStopped, e.Type)
+	assert.Equal(shared.String(), e.JobID)
+
+	require.NoError(w.Close())
+
+	_, ok := <-ch
+	assert.False(ok)
+
+	_, err = w.WatchJobs(ctx, "", owner)
+	require.ErrorIs(err, ErrWorkerClosed)
+}
+
+// chanSink is an event.Sink that sends events to a channel
+type chanSink chan *event.Event
+
+func (s chanSink) Notify(_ context.Context, e *event.Event) error {
+	s <- e
+	return nil
+}
+
+func TestEventSinks(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	sink := make(chanSink, 2)
+	w.sinks = append(w.sinks, sink)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do sleep .1; done")
+	require.NoError(err)
+
+	e := <-sink
+	assert.Equal(event.TypeStarted, e.Type)
+	assert.Equal(jobID.String(), e.JobID)
+
+	require.NoError(w.StopJob("", userID, jobID))
+
+	e = <-sink
+	assert.Equal(event.TypeStopped, e.Type)
+	assert.Equal(job.StatusStopped.String(), e.Status)
+}
+
+func TestWaitJobStatus(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sleep", "10")
+	require.NoError(err)
+
+	// the status already differs
+	st, err := w.WaitJobStatus(context.Background(), "", userID, jobID, job.StatusNotStarted)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	// the wait times out
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	st, err = w.WaitJobStatus(ctx, "", userID, jobID, job.StatusRunning)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	// the status changes while waiting
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(w.StopJob("", userID, jobID))
+	}()
+	st, err = w.WaitJobStatus(context.Background(), "", userID, jobID, job.StatusRunning)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+
+	_, err = w.WaitJobStatus(context.Background(), "", "other", jobID, job.StatusRunning)
+	require.ErrorIs(err, ErrJobNotFound)
+}
+
+func TestWaitJob(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "sleep .1; echo foo; exit 3")
+	require.NoError(err)
+
+	h, err := w.Job("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(jobID, h.ID())
+	require.NoError(h.Err())
+	_, ok := h.ExitCode()
+	assert.False(ok)
+
+	// the wait times out
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = w.WaitJob(ctx, "", userID, jobID)
+	require.ErrorIs(err, context.DeadlineExceeded)
+
+	h, err = w.WaitJob(context.Background(), "", userID, jobID)
+	require.NoError(err)
+
+	select {
+	case <-h.Done():
+	default:
+		require.Fail("job isn't done")
+	}
+
+	var eerr *exec.ExitError
+	require.ErrorAs(h.Err(), &eerr)
+	ec, ok := h.ExitCode()
+	require.True(ok)
+	assert.Equal(3, ec.Int())
+	assert.Equal(job.StatusCompleted, h.Status().Status)
+
+	r, err := h.OutputReader()
+	require.NoError(err)
+	data, err := io.ReadAll(r)
+	require.NoError(err)
+	require.NoError(r.Close())
+	assert.Equal("foo\n", string(data))
+
+	_, err = w.WaitJob(context.Background(), "", "other", jobID)
+	require.ErrorIs(err, ErrJobNotFound)
+}
+
+func TestJobTimes(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sleep", "0.1")
+	require.NoError(err)
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.False(st.StartTime.IsZero())
+	assert.True(st.EndTime.IsZero())
+
+	st, err = w.WaitJobStatus(context.Background(), "", userID, jobID, job.StatusRunning)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+	assert.False(st.EndTime.Before(st.StartTime))
+	assert.GreaterOrEqual(st.Runtime, 100*time.Millisecond)
+
+	// the runtime no longer changes once the job has completed
+	again, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(st.Runtime, again.Runtime)
+}
+
+func TestLookPath(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+
+	require := require.New(t)
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	cmd := filepath.Join(dir, "cmd")
+	require.NoError(os.WriteFile(cmd, []byte("#!/bin/sh\n"), 0o755))        //nolint:gosec
+	require.NoError(os.WriteFile(filepath.Join(dir, "noexec"), nil, 0o644)) //nolint:gosec
+
+	// the last PATH wins, like it does for the job
+	env := []string{"PATH=/nonexistent", "PATH=relative" + string(filepath.ListSeparator) + dir}
+
+	path, err := lookPath("cmd", env)
+	require.NoError(err)
+	assert.Equal(cmd, path)
+
+	path, err = lookPath(cmd, nil)
+	require.NoError(err)
+	assert.Equal(cmd, path)
+
+	_, err = lookPath("cmd", []string{"PATH=/nonexistent"})
+	require.ErrorIs(err, ErrCommandNotFound)
+
+	_, err = lookPath("noexec", env)
+	require.ErrorIs(err, ErrCommandNotFound)
+
+	// relative PATH entries are ignored
+	wd, err := os.Getwd()
+	require.NoError(err)
+	rel, err := filepath.Rel(wd, dir)
+	require.NoError(err)
+	_, err = lookPath("cmd", []string{"PATH=" + rel})
+	require.ErrorIs(err, ErrCommandNotFound)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	_, err = w.StartJob("", "userID", "job-worker-nonexistent-command")
+	require.ErrorIs(err, ErrCommandNotFound)
+}
+
+func TestSetupError(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	// executable, so it is found, but the child can't exec it
+	cmd := filepath.Join(t.TempDir(), "cmd")
+	require.NoError(os.WriteFile(cmd, []byte("not a binary\n"), 0o755)) //nolint:gosec
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, cmd)
+	require.NoError(err)
+
+	st, err := w.WaitJobStatus(context.Background(), "", userID, jobID, job.StatusRunning)
+	require.NoError(err)
+	assert.Equal(job.StatusStartError, st.Status)
+	require.ErrorIs(st.Error, job.ErrSetupFailed)
+	assert.Contains(st.Error.Error(), "exec format error")
+	assert.Nil(st.ExitCode)
+
+	// the error is not mixed in with the job's output
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+	data, err := io.ReadAll(r)
+	require.NoError(err)
+	assert.Empty(data)
+}
+
+func TestParseChildLog(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, nil))
+	l.Warn("skipping device", "value", "8:0 rbps=1", "err", "invalid argument")
+
+	level, msg, attrs := parseChildLog(bytes.TrimSpace(buf.Bytes()))
+	assert.Equal(t, slog.LevelWarn, level)
+	assert.Equal(t, "skipping device", msg)
+	assert.Equal(t, []any{"err", "invalid argument", "value", "8:0 rbps=1"}, attrs)
+
+	level, msg, attrs = parseChildLog([]byte("not json"))
+	assert.Equal(t, slog.LevelWarn, level)
+	assert.Equal(t, "not json", msg)
+	assert.Empty(t, attrs)
+}
+
+func TestChildSpec(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	spec := childSpec{
+		Args:       []string{"/bin/sh", "-c", "echo 'a  b' \"$HOME\""},
+		Env:        []string{"HOME=/tmp", "EMPTY="},
+		Rlimits:    []Rlimit{{Resource: RlimitNofile, Soft: 64, Hard: 128}},
+		BindMounts: []mountSpec{{Source: "/tmp/resolv.conf", Target: "/etc/resolv.conf"}},
+		Priority:   Priority{Nice: 10, IOClass: IOClassIdle, OOMScoreAdj: 500},
+		Proc:       ProcPolicy{HidePID: true},
+		NoNewPrivs: true,
+		Init:       true,
+		Sandbox:    "/etc/job.sb",
+		Jail:       "job_01h2xcejqtf2nbrexx3vqjhp41",
+		Setup:      true,
+	}
+
+	data, err := spec.marshal()
+	require.NoError(err)
+
+	got, err := unmarshalChildSpec(data)
+	require.NoError(err)
+	require.Equal(&spec, got)
+
+	// a spec without a command is rejected
+	data, err = (&childSpec{}).marshal()
+	require.NoError(err)
+	_, err = unmarshalChildSpec(data)
+	require.ErrorIs(err, job.ErrCommandRequired)
+}
+
+func TestReadinessListening(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(err)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	assert.True(listening(os.Getpid(), port))
+
+	require.NoError(l.Close())
+	assert.False(listening(os.Getpid(), port))
+}
+
+func TestRctlRules(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	l := Limits{CPUMax: .25, MemoryMax: 1 << 20, WIOPSMax: 100}
+	assert.Equal([]string{
+		"jail:job:pcpu:deny=25",
+		"jail:job:memoryuse:deny=1048576",
+		"jail:job:writeiops:throttle=100",
+	}, l.rctlRules("job"))
+
+	assert.Empty((&Limits{}).rctlRules("job"))
+}
+
+func TestDeviceProgram(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+
+	cfg := Config{AllowedDevices: []string{"/dev/null"}}
+	rules, err := cfg.deviceRules(nil)
+	require.NoError(err)
+	require.Len(rules, len(defaultDeviceRules)+1)
+
+	// the kernel's verifier accepts the program
+	fd, err := loadDeviceProgram(rules)
+	require.NoError(err)
+	require.NoError(os.NewFile(uintptr(fd), "device-program").Close())
+
+	_, err = deviceRuleOf(t.TempDir())
+	require.ErrorIs(err, ErrNotDevice)
+}
+
+func TestCreateJobCGroup(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// a plain directory stands in for the cgroup filesystem
+	cg := filepath.Join(t.TempDir(), "job")
+	dir, err := createJobCGroup(cg, &Limits{CPUMax: .5, MemoryMax: 1 << 20}, nil)
+	require.NoError(err)
+	defer func() { _ = dir.Close() }()
+
+	fi, err := dir.Stat()
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	data, err := os.ReadFile(filepath.Join(cg, "cpu.max"))
+	require.NoError(err)
+	assert.Equal("50000 100000", string(data))
+
+	data, err = os.ReadFile(filepath.Join(cg, "memory.max"))
+	require.NoError(err)
+	assert.Equal("1048576", string(data))
+
+	// the cgroup of each job is unique
+	_, err = createJobCGroup(cg, nil, nil)
+	require.ErrorIs(err, os.ErrExist)
+}
+
+func TestJobEnv(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+
+	_, err = w.StartJobWithOptions("", userID, &JobOptions{Env: []string{"FOO"}}, "env")
+	require.ErrorIs(err, ErrInvalidEnv)
+
+	env := func(opts *JobOptions) []string {
+		jobID, err := w.StartJobWithOptions("", userID, opts, "env")
+		require.NoError(err)
+
+		r, err := w.JobOutput("", userID, jobID)
+		require.NoError(err)
+
+		data, err := io.ReadAll(r)
+		require.NoError(err)
+
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	// the reexec env is only for the child, not the job
+	vars := env(&JobOptions{Env: []string{"FOO=bar"}})
+	assert.ElementsMatch(append(DefaultEnvironment.vars(), "FOO=bar"), vars)
+
+	w.cfg.Environment = Environment{Home: "/home/job", Lang: "en_US.UTF-8"}
+	vars = env(&JobOptions{Environment: Environment{TmpDir: "/scratch"}})
+	assert.ElementsMatch([]string{
+		"PATH=" + DefaultEnvironment.Path,
+		"HOME=/home/job",
+		"TMPDIR=/scratch",
+		"LANG=en_US.UTF-8",
+	}, vars)
+
+	// allowlisted variables are inherited from the worker and replace the
+	// standard ones
+	w.cfg.EnvAllowlist = []string{"PATH"}
+	vars = env(&JobOptions{Env: []string{"HOME=/override"}})
+	assert.ElementsMatch([]string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=/override",
+		"TMPDIR=/tmp",
+		"LANG=en_US.UTF-8",
+	}, vars)
+}
+
+func TestJobPriority(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	start := func(p *Priority) (job.ID, error) {
+		return w.StartJobWithOptions("", userID, &JobOptions{Priority: p}, "sh", "-c", "nice; cat /proc/self/oom_score_adj; ionice")
+	}
+
+	_, err = start(&Priority{Nice: 20})
+	require.ErrorIs(err, ErrInvalidPriority)
+
+	_, err = start(&Priority{Nice: -1})
+	require.ErrorIs(err, ErrPriorityExceedsPolicy)
+
+	_, err = start(&Priority{OOMScoreAdj: -1})
+	require.ErrorIs(err, ErrPriorityExceedsPolicy)
+
+	_, err = start(&Priority{IOClass: IOClassRealtime})
+	require.ErrorIs(err, ErrPriorityExceedsPolicy)
+
+	jobID, err := start(&Priority{Nice: 5, IOClass: IOClassBestEffort, IOLevel: 7, OOMScoreAdj: 500})
+	require.NoError(err)
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+	data, err := io.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("5\n500\nbest-effort: prio 7\n", string(data))
+}
+
+func TestSignalSource(t *testing.T) {
+	t.Parallel()
+
+	recovered := func(sig syscall.Signal, reason job.StopReason) *job.Job {
+		return job.Recover(&job.Metadata{}, nil, &job.Outcome{
+			Status:     job.StatusCompleted,
+			StopReason: reason,
+			Signal:     sig,
+		})
+	}
+
+	oom := &CGroupStats{MemoryOOMKill: 1}
+
+	assert.Equal(t, SignalSourceNone, signalSource(recovered(0, job.StopReasonNone), oom))
+	assert.Equal(t, SignalSourceStop, signalSource(recovered(syscall.SIGKILL, job.StopReasonTimeout), oom))
+	assert.Equal(t, SignalSourceOOM, signalSource(recovered(syscall.SIGKILL, job.StopReasonNone), oom))
+	assert.Equal(t, SignalSourceExternal, signalSource(recovered(syscall.SIGKILL, job.StopReasonNone), nil))
+	assert.Equal(t, SignalSourceExternal, signalSource(recovered(syscall.SIGTERM, job.StopReasonNone), oom))
+}
+
+func TestReason(t *testing.T) {
+	t.Parallel()
+
+	exitCode := func(c int) *job.ExitCode {
+		ec := job.ExitCode(c)
+		return &ec
+	}
+
+	oom := &CGroupStats{MemoryOOMKill: 1}
+
+	for _, tc := range []struct {
+		name    string
+		outcome *job.Outcome
+		cgStats *CGroupStats
+		want    Reason
+	}{
+		{"completed", &job.Outcome{Status: job.StatusCompleted, ExitCode: exitCode(0)}, nil, ReasonCompletedOK},
+		{"non-zero", &job.Outcome{Status: job.StatusCompleted, ExitCode: exitCode(3)}, nil, ReasonNonZeroExit},
+		{"signal", &job.Outcome{Status: job.StatusCompleted, Signal: syscall.SIGSEGV}, oom, ReasonSignalKilled},
+		{"oom", &job.Outcome{Status: job.StatusCompleted, Signal: syscall.SIGKILL}, oom, ReasonOOMKilled},
+		{"timeout", &job.Outcome{Status: job.StatusStopped, StopReason: job.StopReasonIdleTimeout, Signal: syscall.SIGKILL}, nil, ReasonTimedOut},
+		{"start-error", &job.Outcome{Status: job.StatusStartError}, nil, ReasonStartFailed},
+		{"stopped", &job.Outcome{Status: job.StatusStopped, StopReason: job.StopReasonRequested, Signal: syscall.SIGKILL}, oom, ReasonStoppedByUser},
+		{"shutdown", &job.Outcome{Status: job.StatusStopped, StopReason: job.StopReasonShutdown, Signal: syscall.SIGKILL}, nil, ReasonNodeShutdown},
+		{"interrupted", nil, nil, ReasonNodeShutdown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			j := job.Recover(&job.Metadata{}, nil, tc.outcome)
+			assert.Equal(t, tc.want, reason(j, tc.cgStats))
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "sleep .1; exit 3")
+	require.NoError(err)
+
+	// nothing about how the job exited is known while it is running
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+	assert.Nil(st.ExitCode)
+	require.NoError(st.Error)
+	assert.Nil(st.OutputDigest)
+	assert.True(st.EndTime.IsZero())
+
+	st, err = w.WaitJobStatus(context.Background(), "", userID, jobID, job.StatusRunning)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+	require.NotNil(st.ExitCode)
+	assert.Equal(3, st.ExitCode.Int())
+	require.Error(st.Error)
+	assert.NotNil(st.OutputDigest)
+	assert.False(st.EndTime.IsZero())
+	assert.Equal(syscall.Signal(0), st.Signal)
+	assert.Equal(ReasonNonZeroExit, st.Reason)
+}
+
+func TestOutputOrder(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	// output written to stdout and stderr is interleaved in the order it was
+	// written
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "for i in 1 2 3 4 5; do echo out$i; echo err$i >&2; done")
+	require.NoError(err)
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(r)
+	require.NoError(err)
+
+	var want strings.Builder
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&want, "out%d\nerr%d\n", i, i)
+	}
+	assert.Equal(want.String(), string(data))
+}
+
+// TestConcurrentStatus is most useful with -race
+func TestConcurrentStatus(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do echo y; sleep .01; done")
+	require.NoError(err)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				_, err := w.JobStatus("", userID, jobID)
+				assert.NoError(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := w.WaitJobStatus(context.Background(), "", userID, jobID, job.StatusRunning)
+			assert.NoError(err)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(w.StopJob("", userID, jobID))
+		}()
+	}
+
+	wg.Wait()
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+	assert.Equal(job.StopReasonRequested, st.StopReason)
+}
+
+// newBenchmarkJobs adds n jobs, that are never started, to w and returns their
+// ids
+func newBenchmarkJobs(b *testing.B, w *Worker, userID job.UserID, n int) []job.ID {
+	b.Helper()
+
+	ids := make([]job.ID, n)
+	for i := range ids {
+		j, err := job.New(userID, "true", nil, nil)
+		require.NoError(b, err)
+		w.jobs.store(j, "")
+		ids[i] = j.ID()
+	}
+
+	// every call is audited, and once the log is full each event replaces
+	// the oldest, as on a long-lived Worker
+	for i := range audit.DefaultMaxEvents {
+		w.record(audit.ActionJobStatus, "", userID, ids[i%len(ids)], nil)
+	}
+
+	return ids
+}
+
+func BenchmarkStartJob(b *testing.B) {
+	w, err := newJobWorker()
+	require.NoError(b, err)
+	defer func() { _ = w.Close() }()
+
+	userID := job.UserID("userID")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := w.StartJob("", userID, "true"); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkJobStatus(b *testing.B) {
+	w, err := newJobWorker()
+	require.NoError(b, err)
+	defer func() { _ = w.Close() }()
+
+	userID := job.UserID("userID")
+	ids := newBenchmarkJobs(b, w, userID, 10000)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := w.JobStatus("", userID, ids[i%len(ids)]); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func BenchmarkJobStatusWhileAdding(b *testing.B) {
+	w, err := newJobWorker()
+	require.NoError(b, err)
+	defer func() { _ = w.Close() }()
+
+	userID := job.UserID("userID")
+	ids := newBenchmarkJobs(b, w, userID, 10000)
+
+	// jobs are added, and removed, as fast as possible while the status of
+	// the others is queried
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			j, err := job.New(userID, "true", nil, nil)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			w.mu.Lock()
+			w.jobs.store(j, "")
+			w.mu.Unlock()
+			w.jobs.remove(j.ID())
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := w.JobStatus("", userID, ids[i%len(ids)]); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+
+	jobA, err := w.StartJob("a", userID, "sh", "-c", "true")
+	require.NoError(err)
+
+	jobB, err := w.StartJob("b", userID, "sh", "-c", "true")
+	require.NoError(err)
+
+	st := w.Stats("a")
+	assert.Len(st.Jobs, 1)
+	assert.Contains(st.Jobs, jobA)
+
+	st = w.Stats("")
+	assert.Len(st.Jobs, 2)
+	assert.Contains(st.Jobs, jobA)
+	assert.Contains(st.Jobs, jobB)
+
+	jobC, err := w.StartJob("c", userID, "sleep", "10")
+	require.NoError(err)
+
+	st = w.Stats("c")
+	assert.Equal(1, st.Running)
+
+	require.NoError(w.StopJob("c", userID, jobC))
+	require.Eventually(func() bool {
+		return w.Stats("c").Running == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTenants(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	// the same user id may be used in both tenants
+	userID, grantee := job.UserID("userID"), job.UserID("grantee")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := w.WatchJobs(ctx, "b", userID)
+	require.NoError(err)
+
+	jobID, err := w.StartJob("a", userID, "sleep", "10")
+	require.NoError(err)
+	require.NoError(w.GrantJobAccess("a", userID, jobID, grantee, job.AccessFull))
+
+	// tenant b can't see, or act on, the job of tenant a
+	for _, id := range []job.UserID{userID, grantee} {
+		_, err = w.JobStatus("b", id, jobID)
+		require.ErrorIs(err, ErrJobNotFound)
+
+		_, err = w.Job("b", id, jobID)
+		require.ErrorIs(err, ErrJobNotFound)
+
+		_, err = w.JobOutput("b", id, jobID)
+		require.ErrorIs(err, ErrJobNotFound)
+
+		require.ErrorIs(w.StopJob("b", id, jobID), ErrJobNotFound)
+		require.ErrorIs(w.UpdateJobDeadline("b", id, jobID, time.Time{}), ErrJobNotFound)
+		require.ErrorIs(w.UpdateJobLimits("b", id, jobID, &Limits{}), ErrJobNotFound)
+		require.ErrorIs(w.RemoveJob("b", id, jobID), ErrJobNotFound)
+		require.ErrorIs(w.GrantJobAccess("b", id, jobID, id, job.AccessFull), ErrJobNotFound)
+		require.ErrorIs(w.RevokeJobAccess("b", id, jobID, grantee), ErrJobNotFound)
+
+		assert.Empty(w.ListJobs("b", id, nil))
+
+		stopped, errs := w.StopAllJobs("b", id)
+		assert.Empty(stopped)
+		assert.Empty(errs)
+	}
+
+	st, err := w.JobStatus("a", grantee, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	require.Len(w.ListJobs("a", userID, nil), 1)
+
+	// nor does it receive its events
+	require.NoError(w.StopJob("a", userID, jobID))
+	_, err = w.WaitJob(ctx, "a", userID, jobID)
+	require.NoError(err)
+
+	own, err := w.StartJob("b", userID, "true")
+	require.NoError(err)
+
+	e := <-events
+	assert.Equal(own.String(), e.JobID)
+	assert.Equal("b", e.Tenant)
+
+	// its history is also kept from tenant b
+	require.NoError(w.RemoveJob("a", userID, jobID))
+	assert.Empty(w.ListJobs("b", userID, &ListJobsQuery{State: JobStateHistory}))
+	assert.Len(w.ListJobs("a", userID, &ListJobsQuery{State: JobStateHistory}), 1)
+}
+
+func TestMaxRunningJobsPerTenant(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	w.cfg.MaxRunningJobsPerTenant = 1
+
+	userID := job.UserID("userID")
+
+	running, err := w.StartJob("a", userID, "sleep", "10")
+	require.NoError(err)
+
+	_, err = w.StartJob("a", userID, "true")
+	require.ErrorIs(err, ErrTenantSaturated)
+
+	// other tenants aren't affected
+	other, err := w.StartJob("b", userID, "sleep", "10")
+	require.NoError(err)
+
+	// jobs with a start-by time are queued until their tenant has room
+	queued, err := w.StartJobWithOptions("a", userID, &JobOptions{StartBy: time.Now().Add(time.Minute)}, "true")
+	require.NoError(err)
+
+	st, err := w.JobStatus("a", userID, queued)
+	require.NoError(err)
+	assert.Equal(job.StatusNotStarted, st.Status)
+
+	// and not started when another tenant has room
+	require.NoError(w.StopJob("b", userID, other))
+	_, err = w.WaitJob(context.Background(), "b", userID, other)
+	require.NoError(err)
+
+	st, err = w.JobStatus("a", userID, queued)
+	require.NoError(err)
+	assert.Equal(job.StatusNotStarted, st.Status)
+
+	require.NoError(w.StopJob("a", userID, running))
+
+	st, err = w.WaitJobStatus(context.Background(), "a", userID, queued, job.StatusNotStarted)
+	require.NoError(err)
+	assert.NotEqual(job.StatusStartError, st.Status)
+
+	h, err := w.WaitJob(context.Background(), "a", userID, queued)
+	require.NoError(err)
+	st, err = w.JobStatus("a", userID, h.ID())
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+}
+
+func TestRemoveJob(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do echo y && sleep .1; done")
+	require.NoError(err)
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+
+	err = w.RemoveJob("", userID, jobID)
+	require.ErrorIs(err, ErrJobRunning)
+
+	err = w.RemoveJob("", job.UserID("foo"), jobID)
+	require.ErrorIs(err, ErrJobNotFound)
+
+	require.NoError(w.StopJob("", userID, jobID))
+	require.NoError(w.RemoveJob("", userID, jobID))
+
+	// open readers are closed when the job is removed
+	_, err = io.ReadAll(r)
+	require.ErrorIs(err, safereader.ErrReaderClosed)
+
+	_, err = w.JobStatus("", userID, jobID)
+	require.ErrorIs(err, ErrJobNotFound)
+
+	events, _, err := w.QueryAuditLog(&audit.Query{JobID: jobID, Action: audit.ActionRemoveJob})
+	require.NoError(err)
+	assert.Len(events, 3)
+}
+
+func TestRemoveCGroups(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	dir, err := os.Open(w.rootCGroupName)
+	require.NoError(err)
+	v2 := isCGroup2(dir)
+	require.NoError(dir.Close())
+	if !v2 {
+		_ = w.Close()
+		t.Skip("cgroups are faked, their directories can't be removed")
+	}
+
+	userID := job.UserID("userID")
+	start := func() (job.ID, string) {
+		jobID, err := w.StartJob("", userID, "true")
+		require.NoError(err)
+
+		h, err := w.WaitJob(context.Background(), "", userID, jobID)
+		require.NoError(err)
+
+		cg := w.jobCGroup(h.job)
+		require.DirExists(cg)
+		return jobID, cg
+	}
+
+	removed, removedCG := start()
+	_, keptCG := start()
+
+	// the cgroup of a job is removed with it
+	require.NoError(w.RemoveJob("", userID, removed))
+	require.NoDirExists(removedCG)
+	require.DirExists(keptCG)
+
+	// and those of the remaining jobs, and the root cgroup, on shutdown
+	require.NoError(w.Close())
+	require.NoDirExists(keptCG)
+	require.NoDirExists(w.rootCGroupName)
+}
+
+func TestTTLAfterFinished(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	expiring, err := w.StartJobWithOptions("", userID, &JobOptions{TTLAfterFinished: 100 * time.Millisecond}, "true")
+	require.NoError(err)
+
+	kept, err := w.StartJob("", userID, "true")
+	require.NoError(err)
+
+	// the job is kept until its ttl has passed once it is done
+	st, err := w.WaitJobStatus(context.Background(), "", userID, expiring, job.StatusRunning)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+
+	require.Eventually(func() bool {
+		_, err = w.JobStatus("", userID, expiring)
+		return errors.Is(err, ErrJobNotFound)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// its record isn't kept either
+	records := w.ListJobs("", userID, nil)
+	require.Len(records, 1)
+	assert.Equal(kept, records[0].ID)
+
+	_, err = w.JobStatus("", userID, kept)
+	require.NoError(err)
+}
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := workertest.NewClock(start)
+
+	cfg := Config{
+		ReexecCommand: os.Args[0],
+		ReexecEnv:     []string{"GO_TEST_MODE=child"},
+		Clock:         clk,
+		IDRand:        workertest.NewRand(1),
+	}
+	w, err := New(&cfg)
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJobWithOptions("", userID, &JobOptions{
+		Timeout:          time.Hour,
+		TTLAfterFinished: time.Minute,
+	}, "sleep", "60")
+	require.NoError(err)
+
+	// the id is generated from the clock and the seeded randomness
+	want, err := job.NewIDFrom(start, workertest.NewRand(1))
+	require.NoError(err)
+	assert.Equal(want, jobID)
+
+	clk.Advance(time.Hour - time.Second)
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+	assert.Equal(start, st.StartTime)
+	assert.Equal(time.Hour-time.Second, st.Runtime)
+
+	// the timeout has passed once the clock has been advanced
+	clk.Advance(time.Second)
+	st, err = w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+	assert.Equal(job.StopReasonTimeout, st.StopReason)
+	assert.Equal(start.Add(time.Hour), st.EndTime)
+	assert.Equal(time.Hour, st.Runtime)
+
+	// the job is removed once its ttl has passed
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	_, err = w.JobStatus("", userID, jobID)
+	require.ErrorIs(err, ErrJobNotFound)
+}
+
+func TestTTLAfterClose(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	clk := workertest.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	cfg := Config{
+		ReexecCommand: os.Args[0],
+		ReexecEnv:     []string{"GO_TEST_MODE=child"},
+		Clock:         clk,
+	}
+	w, err := New(&cfg)
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJobWithOptions("", userID, &JobOptions{TTLAfterFinished: time.Minute}, "true")
+	require.NoError(err)
+
+	_, err = w.WaitJob(context.Background(), "", userID, jobID)
+	require.NoError(err)
+
+	// the ttl is pending when the worker is closed
+	clk.BlockUntil(1)
+	require.NoError(w.Close())
+	assert.Zero(clk.Timers())
+
+	// and the job isn't removed once it would have passed
+	clk.Advance(time.Minute)
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+}
+
+func TestListJobs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+	w.history.maxRecords = 2
+
+	owner, other := job.UserID("owner"), job.UserID("other")
+
+	start := time.Now()
+
+	var removed []job.ID
+	for range 3 {
+		jobID, err := w.StartJobWithOptions("", owner, &JobOptions{Description: "old"}, "true")
+		require.NoError(err)
+		j, err := w.getJob("", owner, jobID, job.AccessRead)
+		require.NoError(err)
+		<-j.Done()
+		require.NoError(w.RemoveJob("", owner, jobID))
+		removed = append(removed, jobID)
+	}
+
+	running, err := w.StartJob("", owner, "sleep", "10")
+	require.NoError(err)
+	require.NoError(w.GrantJobAccess("", owner, running, other, job.AccessRead))
+
+	ids := func(records []JobRecord) []job.ID {
+		var ret []job.ID
+		for _, r := range records {
+			ret = append(ret, r.ID)
+		}
+		return ret
+	}
+
+	// only the 2 most recently removed jobs are retained
+	hist := w.ListJobs("", owner, &ListJobsQuery{State: JobStateHistory})
+	assert.Equal(removed[1:], ids(hist))
+	require.Len(hist, 2)
+	assert.True(hist[0].Historical)
+	assert.Equal(job.StatusCompleted, hist[0].Status)
+	assert.Equal(ReasonCompletedOK, hist[0].Reason)
+	assert.Equal("old", hist[0].Description)
+
+	active := w.ListJobs("", owner, &ListJobsQuery{State: JobStateActive})
+	assert.Equal([]job.ID{running}, ids(active))
+	assert.False(active[0].Historical)
+	assert.Equal(job.StatusRunning, active[0].Status)
+
+	assert.Equal(append(removed[1:], running), ids(w.ListJobs("", owner, nil)))
+
+	// the history is only listed for the owner
+	assert.Equal([]job.ID{running}, ids(w.ListJobs("", other, nil)))
+
+	// a time range selects the jobs that were running during it
+	assert.Empty(w.ListJobs("", owner, &ListJobsQuery{Until: start}))
+	assert.Equal([]job.ID{running}, ids(w.ListJobs("", owner, &ListJobsQuery{Since: time.Now()})))
+
+	require.NoError(w.StopJob("", owner, running))
+}
+
+func TestExportJobs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+
+	jobID, err := w.StartJobWithOptions("acme", userID, &JobOptions{
+		Description: "report, nightly",
+	}, "sh", "-c", "exit 3")
+	require.NoError(err)
+	j, err := w.getJob("acme", userID, jobID, job.AccessRead)
+	require.NoError(err)
+	<-j.Done()
+	require.NoError(w.RemoveJob("acme", userID, jobID))
+
+	otherID, err := w.StartJob("other", job.UserID("other"), "true")
+	require.NoError(err)
+
+	var buf bytes.Buffer
+	require.NoError(w.ExportJobs(&buf, "acme", ExportFormatJSONL, nil))
+
+	var rec map[string]any
+	require.NoError(json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(jobID.String(), rec["job_id"])
+	assert.Equal("userID", rec["user_id"])
+	assert.Equal("acme", rec["tenant"])
+	assert.Equal(true, rec["historical"])
+	assert.Equal("Completed", rec["status"])
+	assert.Equal("NonZeroExit", rec["reason"])
+	assert.InDelta(3, rec["exit_code"], 0)
+
+	buf.Reset()
+	require.NoError(w.ExportJobs(&buf, "", ExportFormatCSV, nil))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(err)
+	require.Len(rows, 3)
+	assert.Equal(exportHeader, rows[0])
+	assert.ElementsMatch([]string{jobID.String(), otherID.String()}, []string{rows[1][0], rows[2][0]})
+	for _, row := range rows[1:] {
+		if row[0] == jobID.String() {
+			assert.Equal("3", row[7])
+			assert.Equal("report, nightly", row[12])
+		}
+	}
+
+	err = w.ExportJobs(&buf, "", ExportFormat(-1), nil)
+	require.ErrorIs(err, ErrInvalidExportFormat)
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	sink := make(chanSink, 2)
+	w.sinks = append(w.sinks, sink)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do sleep .1; done")
+	require.NoError(err)
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+
+	require.NoError(w.Close())
+
+	// all events have been delivered by the time Close returns
+	require.Len(sink, 2)
+	assert.Equal(event.TypeStarted, (<-sink).Type)
+	assert.Equal(event.TypeStopped, (<-sink).Type)
+
+	_, err = io.ReadAll(r)
+	require.ErrorIs(err, safereader.ErrReaderClosed)
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+
+	_, err = w.StartJob("", userID, "true")
+	require.ErrorIs(err, ErrWorkerClosed)
+}
+
+func TestJobResult(t *testing.T) {
+	t.Parallel()
+
+	w, err := newJobWorker()
+	require.NoError(t, err)
+	w.cfg.MaxResultSize = 16
+
+	for _, tc := range []struct {
+		name   string
+		script string
+		result string
+		err    error
+	}{
+		{name: "none", script: "echo foo"},
+		{name: "fd", script: `echo '{"ok": true}' >&3`, result: `{"ok": true}`},
+		{name: "file", script: "echo 42 > /dev/fd/3", result: "42"},
+		{name: "too-large", script: `echo '"0123456789abcdef"' >&3`, err: job.ErrResultTooLarge},
+		{name: "invalid", script: "echo foo >&3", err: job.ErrInvalidResult},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require := require.New(t)
+			assert := assert.New(t)
+
+			userID := job.UserID("userID")
+			jobID, err := w.StartJob("", userID, "sh", "-c", tc.script)
+			require.NoError(err)
+
+			r, err := w.JobOutput("", userID, jobID)
+			require.NoError(err)
+
+			// wait for the job to complete
+			_, err = io.ReadAll(r)
+			require.NoError(err)
+
+			st, err := w.JobStatus("", userID, jobID)
+			require.NoError(err)
+			assert.Equal(job.StatusCompleted, st.Status)
+
+			if tc.err != nil {
+				require.ErrorIs(st.ResultError, tc.err)
+				assert.Nil(st.Result)
+				return
+			}
+
+			require.NoError(st.ResultError)
+			assert.Equal(tc.result, string(st.Result))
+		})
+	}
+}
+
+func TestJobProgress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", `
+		echo "@@progress 10" >&4
+		echo "not progress" >&4
+		echo "@@progress 150 invalid" >&4
+		echo "@@progress 42.5 compiling things" >&4
+		echo done
+		while true; do sleep .1; done
+	`)
+	require.NoError(err)
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+
+	p := make([]byte, 5)
+	_, err = io.ReadFull(r, p)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	// the progress is read asynchronously
+	require.Eventually(func() bool {
+		st, err := w.JobStatus("", userID, jobID)
+		return err == nil && st.Progress != nil && st.Progress.Percent == 42.5
+	}, time.Second, 10*time.Millisecond)
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+	assert.Equal(&job.Progress{Percent: 42.5, Message: "compiling things"}, st.Progress)
+
+	require.NoError(w.StopJob("", userID, jobID))
+}
+
+func TestTimeouts(t *testing.T) {
+	t.Parallel()
+
+	w, err := newJobWorker()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		script string
+		opts   JobOptions
+		reason job.StopReason
+	}{{
+		name:   "timeout",
+		script: "while true; do sleep .1; done",
+		opts:   JobOptions{Timeout: 200 * time.Millisecond},
+		reason: job.StopReasonTimeout,
+	}, {
+		name:   "idle-timeout",
+		script: "echo foo; while true; do sleep .1; done",
+		opts:   JobOptions{IdleTimeout: 200 * time.Millisecond},
+		reason: job.StopReasonIdleTimeout,
+	}, {
+		// output keeps resetting the idle timeout so the hard timeout is hit
+		name:   "active",
+		script: "while true; do echo y && sleep .05; done",
+		opts:   JobOptions{Timeout: 500 * time.Millisecond, IdleTimeout: 200 * time.Millisecond},
+		reason: job.StopReasonTimeout,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require := require.New(t)
+			assert := assert.New(t)
+
+			userID := job.UserID("userID")
+			jobID, err := w.StartJobWithOptions("", userID, &tc.opts, "sh", "-c", tc.script)
+			require.NoError(err)
+
+			r, err := w.JobOutput("", userID, jobID)
+			require.NoError(err)
+
+			// wait for the job to be stopped
+			_, err = io.ReadAll(r)
+			require.NoError(err)
+
+			st, err := w.JobStatus("", userID, jobID)
+			require.NoError(err)
+			assert.Equal(job.StatusStopped, st.Status)
+			assert.Equal(tc.reason, st.StopReason)
+		})
+	}
+}
+
+func TestUpdateJobDeadline(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	deadline := time.Now().Add(time.Hour)
+	jobID, err := w.StartJobWithOptions("", userID, &JobOptions{
+		Timeout:  2 * time.Hour,
+		Deadline: deadline,
+	}, "sh", "-c", "while true; do sleep .1; done")
+	require.NoError(err)
+
+	// the deadline comes before the timeout
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.True(deadline.Equal(st.Deadline))
+
+	other := job.UserID("other")
+	require.NoError(w.GrantJobAccess("", userID, jobID, other, job.AccessRead))
+	err = w.UpdateJobDeadline("", other, jobID, time.Now())
+	require.ErrorIs(err, ErrPermissionDenied)
+
+	// shorten the deadline so that the job is stopped
+	deadline = time.Now().Add(100 * time.Millisecond)
+	require.NoError(w.UpdateJobDeadline("", userID, jobID, deadline))
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+	_, err = io.ReadAll(r)
+	require.NoError(err)
+
+	st, err = w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+	assert.Equal(job.StopReasonTimeout, st.StopReason)
+	assert.True(deadline.Equal(st.Deadline))
+
+	err = w.UpdateJobDeadline("", userID, jobID, time.Time{})
+	require.ErrorIs(err, ErrJobNotRunning)
+}
+
+func TestDurableJob(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	_, err = w.StartJobWithOptions("", userID, &JobOptions{Durable: true}, "true")
+	require.ErrorIs(err, ErrWALDirRequired)
+
+	w.cfg.WALDir = t.TempDir()
+
+	jobID, err := w.StartJobWithOptions("", userID, &JobOptions{
+		Durable:     true,
+		Description: "nightly report",
+		Annotations: map[string]string{"team": "billing"},
+	}, "sh", "-c", "echo foo; exit 3")
+	require.NoError(err)
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+	_, err = io.ReadAll(r)
+	require.NoError(err)
+
+	// a job that was running when the worker crashed
+	interrupted, err := job.NewID()
+	require.NoError(err)
+	meta, err := json.Marshal(&job.Metadata{ID: interrupted, UserID: userID})
+	require.NoError(err)
+	l, err := wal.Create(w.walPath(interrupted), meta)
+	require.NoError(err)
+	_, err = l.Write([]byte("bar\n"))
+	require.NoError(err)
+	require.NoError(l.Close())
+
+	before, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+
+	// simulate a restart
+	w, err = New(w.cfg)
+	require.NoError(err)
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+	assert.Equal(before.Runtime, st.Runtime)
+	assert.True(before.StartTime.Equal(st.StartTime))
+	assert.True(before.EndTime.Equal(st.EndTime))
+	require.NotNil(st.ExitCode)
+	assert.Equal(3, st.ExitCode.Int())
+	assert.Equal("nightly report", st.Description)
+	assert.Equal(map[string]string{"team": "billing"}, st.Annotations)
+
+	r, err = w.JobOutput("", userID, jobID)
+	require.NoError(err)
+	data, err := io.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("foo\n", string(data))
+
+	st, err = w.JobStatus("", userID, interrupted)
+	require.NoError(err)
+	assert.Equal(job.StatusInterrupted, st.Status)
+	require.ErrorIs(st.Error, job.ErrInterrupted)
+
+	r, err = w.JobOutput("", userID, interrupted)
+	require.NoError(err)
+	data, err = io.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("bar\n", string(data))
+
+	require.NoError(w.RemoveJob("", userID, jobID))
+	_, err = os.Stat(w.walPath(jobID))
+	require.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestDiskBudget(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	w.cfg.WALDir = t.TempDir()
+	w.cfg.WALDiskBudget = 4 << 10
+
+	userID := job.UserID("userID")
+	opts := &JobOptions{Durable: true}
+
+	wait := func(jobID job.ID) {
+		j, err := w.getJob("", userID, jobID, job.AccessRead)
+		require.NoError(err)
+		<-j.Done()
+	}
+
+	small, err := w.StartJobWithOptions("", userID, opts, "echo", "foo")
+	require.NoError(err)
+	wait(small)
+
+	usage := w.DiskUsage()
+	assert.Equal(1, usage.Logs)
+	assert.Equal(int64(4<<10), usage.Budget)
+	info, err := os.Stat(w.walPath(small))
+	require.NoError(err)
+	assert.Equal(info.Size(), usage.Used)
+
+	// the log of the completed job is removed to make room, but that isn't
+	// enough for all of this output
+	large, err := w.StartJobWithOptions("", userID, opts, "head", "-c", "65536", "/dev/zero")
+	require.NoError(err)
+	wait(large)
+
+	st, err := w.JobStatus("", userID, large)
+	require.NoError(err)
+	require.ErrorIs(st.Error, ErrDiskBudgetExceeded)
+
+	_, err = os.Stat(w.walPath(small))
+	require.ErrorIs(err, os.ErrNotExist)
+
+	// the output of the removed log is still available until a restart
+	st, err = w.JobStatus("", userID, small)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+
+	usage = w.DiskUsage()
+	assert.Equal(1, usage.Logs)
+	info, err = os.Stat(w.walPath(large))
+	require.NoError(err)
+	assert.Equal(info.Size(), usage.Used)
+
+	require.NoError(w.RemoveJob("", userID, large))
+	assert.Equal(DiskUsage{Budget: 4 << 10}, w.DiskUsage())
+}
+
+func TestWALDirLock(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	cfg := w.cfg
+	cfg.WALDir = t.TempDir()
+
+	active, err := New(cfg)
+	require.NoError(err)
+
+	_, err = New(cfg)
+	require.ErrorIs(err, ErrWALDirLocked)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NewStandby(ctx, cfg)
+	require.ErrorIs(err, context.DeadlineExceeded)
+
+	standby := make(chan *Worker, 1)
+	go func() {
+		w, err := NewStandby(context.Background(), cfg)
+		assert.NoError(err)
+		standby <- w
+	}()
+
+	require.NoError(active.Close())
+
+	select {
+	case w = <-standby:
+		require.NotNil(w)
+		require.NoError(w.Close())
+	case <-time.After(5 * time.Second):
+		require.Fail("standby did not take over")
+	}
+}
+
+func TestStopJobKillsCGroup(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "sleep 60 & wait")
+	require.NoError(err)
+
+	// cgroup.kill only exists on a real cgroup v2 filesystem, so it is faked
+	// when it doesn't
+	e, ok := w.jobs.get(jobID)
+	require.True(ok)
+	kill := filepath.Join(e.cg, "cgroup.kill")
+	if _, err = os.Stat(kill); errors.Is(err, os.ErrNotExist) {
+		require.NoError(os.WriteFile(kill, nil, 0o600))
+	}
+
+	require.NoError(w.StopJob("", userID, jobID))
+
+	if data, err := os.ReadFile(kill); err == nil {
+		// reading cgroup.kill fails on a real cgroup v2 filesystem
+		require.Equal("1", string(data))
+	}
+
+	st, err := w.JobStatus("", userID, jobID)
+	require.NoError(err)
+	require.Equal(job.StatusStopped, st.Status)
+}
+
+func TestUpdateJobLimits(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do sleep .1; done")
+	require.NoError(err)
+
+	// the leaf cgroup, with the worker's limits, exists before the job starts
+	e, ok := w.jobs.get(jobID)
+	require.True(ok)
+	cg := e.cg
+	data, err := os.ReadFile(filepath.Join(cg, "cpu.max"))
+	require.NoError(err)
+	assert.Equal("25000 100000", string(data))
+
+	require.NoError(w.UpdateJobLimits("", userID, jobID, &Limits{CPUMax: .1}))
+
+	data, err = os.ReadFile(filepath.Join(cg, "cpu.max"))
+	require.NoError(err)
+	assert.Equal("10000 100000", string(data))
+
+	err = w.UpdateJobLimits("", userID, jobID, &Limits{MemoryMax: w.cfg.MemoryMax + 1})
+	require.ErrorIs(err, ErrLimitExceedsCeiling)
+
+	err = w.UpdateJobLimits("", userID, jobID, &Limits{CPUMax: 2})
+	require.ErrorIs(err, ErrInvalidCPUMax)
+
+	require.NoError(w.StopJob("", userID, jobID))
+
+	err = w.UpdateJobLimits("", userID, jobID, &Limits{CPUMax: .1})
+	require.ErrorIs(err, ErrJobNotRunning)
+}
+
+func TestCGroupAlerts(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+	w.cfg.CGroupAlerts.Interval = 10 * time.Millisecond
+
+	sink := make(chanSink, 3)
+	w.sinks = append(w.sinks, sink)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do sleep .1; done")
+	require.NoError(err)
+	assert.Equal(event.TypeStarted, (<-sink).Type)
+
+	// fake the cgroup files so that the stats can be controlled
+	cg := t.TempDir()
+	writeStats := func(periods, throttled, memoryMax int) {
+		cpu := fmt.Sprintf("usage_usec 0\nnr_periods %d\nnr_throttled %d\nthrottled_usec 0\n", periods, throttled)
+		memory := fmt.Sprintf("low 0\nhigh 0\nmax %d\noom 0\noom_kill 0\n", memoryMax)
+		require.NoError(os.WriteFile(filepath.Join(cg, "cpu.stat"), []byte(cpu), 0o600))
+		require.NoError(os.WriteFile(filepath.Join(cg, "memory.events"), []byte(memory), 0o600))
+	}
+	writeStats(0, 0, 0)
+
+	e, ok := w.jobs.get(jobID)
+	require.True(ok)
+	j := e.job
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.monitorCGroup(j, cg)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	writeStats(10, 8, 1)
+
+	alerts := map[event.Type]string{}
+	for range 2 {
+		e := <-sink
+		alerts[e.Type] = e.Message
+	}
+	assert.Equal(map[event.Type]string{
+		event.TypeThrottled:   "throttled in 80% of cpu periods",
+		event.TypeMemoryLimit: "memory limit hit 1 times",
+	}, alerts)
+
+	require.NoError(w.StopJob("", userID, jobID))
+	<-done
+	assert.Equal(event.TypeStopped, (<-sink).Type)
+}
+
+func TestParseFlatKeyed(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("nr_periods 10\nnr_throttled 8\nthrottled_usec 1500\ninvalid\n")
+	stats := newCGroupStats(parseFlatKeyed(data), parseFlatKeyed([]byte("max 2\noom 1\noom_kill 1\n")))
+	assert.Equal(t, &CGroupStats{
+		NrPeriods:     10,
+		NrThrottled:   8,
+		ThrottledTime: 1500 * time.Microsecond,
+		MemoryMax:     2,
+		MemoryOOM:     1,
+		MemoryOOMKill: 1,
+	}, stats)
+}
+
+func TestParseIOStat(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("8:0 rbytes=100 wbytes=20 rios=3 wios=1\n\n8:16 rbytes=5 wbytes=invalid\n")
+	read, write := parseIOStat(data)
+	assert.Equal(t, uint64(105), read)
+	assert.Equal(t, uint64(20), write)
+}
+
+func TestUsageReport(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// usage between samples
+	now := time.Now()
+	prev := &usageSample{time: now, cpu: time.Second, memory: 100, ioRead: 10, ioWrite: 1}
+	s := &usageSample{time: now.Add(2 * time.Second), cpu: 3 * time.Second, memory: 300, ioRead: 15, ioWrite: 4}
+	assert.Equal(&Usage{
+		CPUTime:           2 * time.Second,
+		MemoryByteSeconds: 400,
+		IOReadBytes:       5,
+		IOWriteBytes:      3,
+	}, s.usageSince(prev))
+
+	path := filepath.Join(t.TempDir(), usageFileName)
+
+	var l usageLedger
+	require.NoError(l.open(path))
+
+	hour := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	u := &Usage{CPUTime: time.Second, MemoryByteSeconds: 10, IOReadBytes: 1, IOWriteBytes: 2}
+	l.add("alice", "acme", hour.Add(time.Minute), u)
+	l.add("alice", "acme", hour.Add(59*time.Minute), u)
+	l.add("alice", "acme", hour.Add(time.Hour), u)
+	l.add("bob", "other", hour, u)
+	require.NoError(l.close())
+
+	// usage is persisted across restarts
+	var reopened usageLedger
+	require.NoError(reopened.open(path))
+	defer reopened.close()
+
+	double := Usage{CPUTime: 2 * time.Second, MemoryByteSeconds: 20, IOReadBytes: 2, IOWriteBytes: 4}
+	triple := Usage{CPUTime: 3 * time.Second, MemoryByteSeconds: 30, IOReadBytes: 3, IOWriteBytes: 6}
+
+	assert.Equal(map[job.UserID]Usage{"alice": triple, "bob": *u}, reopened.report("", time.Time{}, time.Time{}))
+	assert.Equal(map[job.UserID]Usage{"alice": triple}, reopened.report("acme", time.Time{}, time.Time{}))
+
+	// the window is rounded to the bucket
+	assert.Equal(map[job.UserID]Usage{"alice": double}, reopened.report("acme", hour.Add(30*time.Minute), hour.Add(time.Hour)))
+	assert.Equal(map[job.UserID]Usage{"alice": *u}, reopened.report("acme", hour.Add(time.Hour), time.Time{}))
+
+	// the file was compacted to one entry per bucket
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	assert.Equal(3, bytes.Count(data, []byte("\n")))
+}
+
+func TestResolveBlockDevices(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != linuxOS {
+		t.Skip()
+	}
+
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// the device backing a path is a whole disk that exists in sysfs
+	devices, err := resolveBlockDevices([]string{t.TempDir()})
+	if errors.Is(err, ErrNoBlockDevice) {
+		t.Skip("temp dir is not backed by a block device")
+	}
+	require.NoError(err)
+	require.Len(devices, 1)
+	assert.Regexp(majMinRE, devices[0])
+	_, err = os.Stat(filepath.Join("/sys/dev/block", devices[0]))
+	require.NoError(err)
+
+	// the same disk given as MAJOR:MINOR is deduplicated
+	same, err := resolveBlockDevices([]string{devices[0], t.TempDir()})
+	require.NoError(err)
+	assert.Equal(devices, same)
+
+	_, err = resolveBlockDevices([]string{"0:0"})
+	require.ErrorIs(err, ErrNoBlockDevice)
+
+	_, err = resolveBlockDevices([]string{filepath.Join(t.TempDir(), "missing")})
+	require.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestListBlockDevices(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// a fake /sys/block with an lvm volume on a partition of sda, and an md
+	// raid of nvme1n1 and nvme2n1
+	dir := t.TempDir()
+	for path, dev := range map[string]string{
+		"dm-0":                  "253:0",
+		"loop0":                 "7:0",
+		"md0":                   "9:0",
+		"nvme0n1":               "259:0",
+		"nvme0n1/nvme0n1p1":     "259:1",
+		"nvme1n1":               "259:2",
+		"nvme1n1/holders/md0":   "",
+		"nvme2n1":               "259:3",
+		"nvme2n1/holders/md0":   "",
+		"sda":                   "8:0",
+		"sda/sda1":              "8:1",
+		"sda/sda2":              "8:2",
+		"sda/sda2/holders/dm-0": "",
+		"sda/queue/rotational":  "",
+	} {
+		require.NoError(os.MkdirAll(filepath.Join(dir, path), 0o755))
+		if dev != "" {
+			require.NoError(os.WriteFile(filepath.Join(dir, path, "dev"), []byte(dev+"\n"), 0o600))
+		}
+	}
+
+	devices, err := listBlockDevices(dir)
+	require.NoError(err)
+	assert.Equal([]string{"253:0", "9:0", "259:0"}, devices)
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("wait-for-jobs", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		w, err := newJobWorker()
+		require.NoError(err)
+		w.cfg.ShutdownPolicy = ShutdownWaitForJobs
+
+		userID := job.UserID("userID")
+		short, err := w.StartJob("", userID, "sh", "-c", "sleep .1")
+		require.NoError(err)
+		long, err := w.StartJob("", userID, "sh", "-c", "while true; do sleep .1; done")
+		require.NoError(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		err = w.Shutdown(ctx)
+		require.ErrorIs(err, context.DeadlineExceeded)
+
+		st, err := w.JobStatus("", userID, short)
+		require.NoError(err)
+		assert.Equal(job.StatusCompleted, st.Status)
+
+		assert.Equal(ReasonCompletedOK, st.Reason)
+
+		st, err = w.JobStatus("", userID, long)
+		require.NoError(err)
+		assert.Equal(job.StatusStopped, st.Status)
+		assert.Equal(job.StopReasonShutdown, st.StopReason)
+		assert.Equal(ReasonNodeShutdown, st.Reason)
+	})
+
+	t.Run("leave-running", func(t *testing.T) {
+		t.Parallel()
+		require := require.New(t)
+		assert := assert.New(t)
+
+		w, err := newJobWorker()
+		require.NoError(err)
+		w.cfg.ShutdownPolicy = ShutdownLeaveRunning
+
+		userID := job.UserID("userID")
+		jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do echo y && sleep .1; done")
+		require.NoError(err)
+
+		r, err := w.JobOutput("", userID, jobID)
+		require.NoError(err)
+
+		require.NoError(w.Shutdown(context.Background()))
+
+		_, err = io.ReadAll(r)
+		require.ErrorIs(err, safereader.ErrReaderClosed)
+
+		st, err := w.JobStatus("", userID, jobID)
+		require.NoError(err)
+		assert.Equal(job.StatusRunning, st.Status)
+
+		_, err = w.StartJob("", userID, "true")
+		require.ErrorIs(err, ErrWorkerClosed)
+
+		require.NoError(w.StopJob("", userID, jobID))
+	})
+}
+
+func TestMergeRlimits(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	defaults := []Rlimit{
+		{Resource: RlimitNofile, Soft: 1024, Hard: 4096},
+		{Resource: RlimitCore, Soft: 0, Hard: 0},
+	}
+
+	rlimits, err := mergeRlimits(defaults, []Rlimit{
+		{Resource: RlimitNofile, Soft: 64, Hard: 64},
+		{Resource: RlimitCPU, Soft: 10, Hard: 20},
+	})
+	require.NoError(err)
+	assert.Equal([]Rlimit{
+		{Resource: RlimitNofile, Soft: 64, Hard: 64},
+		{Resource: RlimitCore, Soft: 0, Hard: 0},
+		{Resource: RlimitCPU, Soft: 10, Hard: 20},
+	}, rlimits)
+
+	// raising a configured hard limit is not permitted
+	_, err = mergeRlimits(defaults, []Rlimit{{Resource: RlimitCore, Soft: 0, Hard: 1}})
+	require.ErrorIs(err, ErrInvalidRlimit)
+
+	// soft can't be greater than hard
+	_, err = mergeRlimits(defaults, []Rlimit{{Resource: RlimitCPU, Soft: 2, Hard: 1}})
+	require.ErrorIs(err, ErrInvalidRlimit)
+
+	// each resource may only be listed once
+	_, err = mergeRlimits(nil, []Rlimit{
+		{Resource: RlimitCPU, Soft: 1, Hard: 1},
+		{Resource: RlimitCPU, Soft: 1, Hard: 1},
+	})
+	require.ErrorIs(err, ErrInvalidRlimit)
+}
+
+func TestParseCGroupV2Path(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	path, err := parseCGroupV2Path([]byte("0::/\n"))
+	require.NoError(err)
+	assert.Equal("/", path)
+
+	// hybrid hierarchies list the v1 controllers as well
+	path, err = parseCGroupV2Path([]byte("12:memory:/docker/abc\n1:name=systemd:/docker/abc\n0::/docker/abc\n"))
+	require.NoError(err)
+	assert.Equal("/docker/abc", path)
+
+	_, err = parseCGroupV2Path([]byte("4:memory:/docker/abc\n"))
+	require.ErrorIs(err, ErrCGroupV2Required)
+}
+
+func TestNewRootCGroup(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	// a fake cgroup2 mount, with the server at the root of the hierarchy like
+	// in a container with its own cgroup namespace
+	mount := t.TempDir()
+	for name, data := range map[string]string{
+		"cgroup.procs":           "1\n",
+		"cgroup.subtree_control": "",
+	} {
+		require.NoError(os.WriteFile(filepath.Join(mount, name), []byte(data), 0o600))
+	}
+
+	procCGroup := filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(os.WriteFile(procCGroup, []byte("0::/\n"), 0o600))
+
+	cg, err := newRootCGroup(mount, procCGroup)
+	require.NoError(err)
+	assert.Equal(mount, filepath.Dir(cg))
+	assert.True(strings.HasPrefix(filepath.Base(cg), "job-worker-"))
+
+	// the controllers are delegated to the root cgroup, and by it
+	for _, dir := range []string{mount, cg} {
+		data, err := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+		require.NoError(err)
+		assert.Equal(subtreeControllers, string(data))
+	}
+}
+
+// recordingShipper is a logship.Shipper that records the lines it is sent
+type recordingShipper struct {
+	mu    sync.Mutex
+	lines []logship.Line
+}
+
+func (s *recordingShipper) Ship(_ context.Context, lines []logship.Line) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, lines...)
+	return nil
+}
+
+func (s *recordingShipper) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ret []string
+	for _, l := range s.lines {
+		ret = append(ret, l.Message)
+	}
+	return ret
+}
+
+func TestLogShippers(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	var a, b recordingShipper
+	w.cfg.LogShippers = []logship.Shipper{&a, &b}
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("acme", userID, "printf", `foo\nbar\nbaz`)
+	require.NoError(err)
+
+	want := []string{"foo", "bar", "baz"}
+	require.Eventually(func() bool {
+		return slices.Equal(want, a.messages()) && slices.Equal(want, b.messages())
+	}, 5*time.Second, 10*time.Millisecond)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, l := range a.lines {
+		assert.Equal(jobID, l.JobID)
+		assert.Equal(userID, l.UserID)
+		assert.Equal(job.TenantID("acme"), l.Tenant)
+		assert.False(l.Truncated)
+	}
+}
+
+func TestPreemption(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	w.cfg.MaxRunningJobs = 1
+	w.cfg.Preemption = PreemptionPolicy{
+		GracePeriod: 100 * time.Millisecond,
+		Requeue:     true,
+	}
+
+	userID := job.UserID("userID")
+
+	low, err := w.StartJobWithOptions("", userID, &JobOptions{CorrelationID: "low"}, "sleep", "10")
+	require.NoError(err)
+
+	// without preemption, jobs are rejected once the worker is saturated
+	_, err = w.StartJobWithOptions("", userID, &JobOptions{SchedulingPriority: 1}, "true")
+	require.ErrorIs(err, ErrWorkerSaturated)
+
+	w.cfg.Preemption.Enabled = true
+
+	high, err := w.StartJobWithOptions("", userID, &JobOptions{SchedulingPriority: 1}, "sleep", ".5")
+	require.NoError(err)
+
+	st, err := w.JobStatus("", userID, low)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+	assert.Equal(job.StopReasonPreempted, st.StopReason)
+	assert.Equal(ReasonPreempted, st.Reason)
+
+	// jobs with the same priority are not preempted
+	_, err = w.StartJobWithOptions("", userID, &JobOptions{SchedulingPriority: 1}, "true")
+	require.ErrorIs(err, ErrWorkerSaturated)
+
+	// the preempted job is started again once the high priority job is done
+	var records []JobRecord
+	require.Eventually(func() bool {
+		records = w.ListJobs("", userID, &ListJobsQuery{CorrelationID: "low", State: JobStateActive})
+		return len(records) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	st, err = w.JobStatus("", userID, high)
+	require.NoError(err)
+	assert.Equal(job.StatusCompleted, st.Status)
+
+	for _, r := range records {
+		if r.ID != low {
+			assert.Equal(job.StatusRunning, r.Status)
+			require.NoError(w.StopJob("", userID, r.ID))
+		}
+	}
+}
+
+func TestResourceRequests(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	w.cfg.Capacity = Resources{CPU: 2, Memory: 1 << 30}
+	w.cfg.Preemption.GracePeriod = 100 * time.Millisecond
+
+	userID := job.UserID("userID")
+	start := func(priority int, requests Resources) (job.ID, error) {
+		return w.StartJobWithOptions("", userID, &JobOptions{
+			SchedulingPriority: priority,
+			Requests:           requests,
+		}, "sleep", "10")
+	}
+
+	for _, requests := range []Resources{{CPU: -1}, {CPU: 3}, {Memory: 2 << 30}} {
+		_, err = start(0, requests)
+		require.ErrorIs(err, ErrInvalidRequests)
+	}
+
+	a, err := start(0, Resources{CPU: 1})
+	require.NoError(err)
+
+	b, err := start(0, Resources{CPU: .5, Memory: 256 << 20})
+	require.NoError(err)
+
+	// only half a cpu is left
+	_, err = start(0, Resources{CPU: 1})
+	require.ErrorIs(err, ErrWorkerSaturated)
+
+	c, err := start(0, Resources{CPU: .5})
+	require.NoError(err)
+
+	assert.Equal(&Allocation{
+		Jobs:      3,
+		Requested: Resources{CPU: 2, Memory: 256 << 20},
+		Capacity:  w.cfg.Capacity,
+		Queues: map[string]*Allocation{DefaultQueue: {
+			Jobs:      3,
+			Requested: Resources{CPU: 2, Memory: 256 << 20},
+		}},
+	}, w.Allocation())
+
+	// room is freed once a job is done
+	require.NoError(w.StopJob("", userID, a))
+	require.Eventually(func() bool {
+		return w.Allocation().Requested.CPU == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the lowest priority jobs are preempted, most recent first, until there
+	// is enough room
+	w.cfg.Preemption.Enabled = true
+
+	e, err := start(1, Resources{CPU: .5})
+	require.NoError(err)
+
+	d, err := start(2, Resources{CPU: 1.5})
+	require.NoError(err)
+
+	for _, id := range []job.ID{b, c} {
+		st, err := w.JobStatus("", userID, id)
+		require.NoError(err)
+		assert.Equal(job.StopReasonPreempted, st.StopReason)
+	}
+
+	st, err := w.JobStatus("", userID, e)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	assert.Equal(2, w.Allocation().Jobs)
+
+	for _, r := range w.ListJobs("", userID, &ListJobsQuery{}) {
+		if r.Status == job.StatusRunning {
+			require.NoError(w.StopJob("", userID, r.ID))
+		}
+	}
+
+	st, err = w.JobStatus("", userID, d)
+	require.NoError(err)
+	assert.Equal(job.StopReasonRequested, st.StopReason)
+}
+
+func TestStartBy(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	w.cfg.MaxRunningJobs = 1
+
+	userID := job.UserID("userID")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := w.WatchJobs(ctx, "", userID)
+	require.NoError(err)
+
+	running, err := w.StartJob("", userID, "sleep", "10")
+	require.NoError(err)
+
+	_, err = w.StartJobWithOptions("", userID, &JobOptions{StartBy: time.Now().Add(-time.Second)}, "true")
+	require.ErrorIs(err, job.ErrStartDeadlineExceeded)
+
+	// jobs that can't start by their start-by time fail
+	expired, err := w.StartJobWithOptions("", userID, &JobOptions{StartBy: time.Now().Add(100 * time.Millisecond)}, "true")
+	require.NoError(err)
+
+	st, err := w.JobStatus("", userID, expired)
+	require.NoError(err)
+	assert.Equal(job.StatusNotStarted, st.Status)
+	assert.Equal(1, w.Allocation().Queued)
+
+	st, err = w.WaitJobStatus(ctx, "", userID, expired, job.StatusNotStarted)
+	require.NoError(err)
+	assert.Equal(job.StatusStartError, st.Status)
+	assert.Equal(ReasonStartDeadlineExceeded, st.Reason)
+
+	var types []event.Type
+	for e := range events {
+		if e.JobID == expired.String() {
+			types = append(types, e.Type)
+			if e.Type.Terminal() {
+				break
+			}
+		}
+	}
+	assert.Equal([]event.Type{event.TypeQueued, event.TypeStartDeadlineExceeded}, types)
+
+	// queued jobs can be stopped
+	stopped, err := w.StartJobWithOptions("", userID, &JobOptions{StartBy: time.Now().Add(time.Minute)}, "true")
+	require.NoError(err)
+	require.NoError(w.StopJob("", userID, stopped))
+
+	st, err = w.JobStatus("", userID, stopped)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+	assert.Equal(ReasonStoppedByUser, st.Reason)
+
+	// queued jobs start, by priority, once there is room for them
+	low, err := w.StartJobWithOptions("", userID, &JobOptions{StartBy: time.Now().Add(time.Minute)}, "true")
+	require.NoError(err)
+
+	high, err := w.StartJobWithOptions("", userID, &JobOptions{
+		StartBy:            time.Now().Add(time.Minute),
+		SchedulingPriority: 1,
+	}, "sleep", "10")
+	require.NoError(err)
+	assert.Equal(2, w.Allocation().Queued)
+
+	require.NoError(w.StopJob("", userID, running))
+
+	st, err = w.WaitJobStatus(ctx, "", userID, high, job.StatusNotStarted)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	st, err = w.JobStatus("", userID, low)
+	require.NoError(err)
+	assert.Equal(job.StatusNotStarted, st.Status)
+
+	require.NoError(w.StopJob("", userID, high))
+
+	st, err = w.WaitJobStatus(ctx, "", userID, low, job.StatusNotStarted)
+	require.NoError(err)
+	assert.NotEqual(job.StatusNotStarted, st.Status)
+	assert.Equal(0, w.Allocation().Queued)
+}
+
+func TestRestartPolicy(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := w.WatchJobs(ctx, "", userID)
+	require.NoError(err)
+
+	typesOf := func(jobID job.ID) []event.Type {
+		var types []event.Type
+		for e := range events {
+			if e.JobID == jobID.String() {
+				types = append(types, e.Type)
+				if e.Type.Terminal() {
+					return types
+				}
+			}
+		}
+		return types
+	}
+
+	// failed jobs are restarted until they reach their maximum restarts
+	failing, err := w.StartJobWithOptions("", userID, &JobOptions{Restart: RestartPolicy{
+		Mode:        RestartOnFailure,
+		MaxRestarts: 2,
+		Backoff:     10 * time.Millisecond,
+	}}, "sh", "-c", "exit 3")
+	require.NoError(err)
+
+	assert.Equal([]event.Type{
+		event.TypeStarted, event.TypeRestarting,
+		event.TypeStarted, event.TypeRestarting,
+		event.TypeStarted, event.TypeFailed,
+	}, typesOf(failing))
+
+	st, err := w.JobStatus("", userID, failing)
+	require.NoError(err)
+	require.NotNil(st.ExitCode)
+	assert.Equal(3, st.ExitCode.Int())
+	assert.Equal(2, st.Restarts)
+	require.NotNil(st.LastExit)
+	require.NotNil(st.LastExit.ExitCode)
+	assert.Equal(3, st.LastExit.ExitCode.Int())
+
+	// but not if they succeed
+	ok, err := w.StartJobWithOptions("", userID, &JobOptions{Restart: RestartPolicy{Mode: RestartOnFailure}}, "true")
+	require.NoError(err)
+	assert.Equal([]event.Type{event.TypeStarted, event.TypeCompleted}, typesOf(ok))
+
+	// jobs that are waiting to be restarted can be stopped
+	always, err := w.StartJobWithOptions("", userID, &JobOptions{Restart: RestartPolicy{Mode: RestartAlways, Backoff: time.Hour}}, "true")
+	require.NoError(err)
+
+	for e := range events {
+		if e.JobID == always.String() && e.Type == event.TypeRestarting {
+			break
+		}
+	}
+
+	st, err = w.JobStatus("", userID, always)
+	require.NoError(err)
+	assert.Equal(job.StatusNotStarted, st.Status)
+	assert.Equal(1, st.Restarts)
+
+	require.NoError(w.StopJob("", userID, always))
+	assert.Equal([]event.Type{event.TypeStopped}, typesOf(always))
+
+	st, err = w.JobStatus("", userID, always)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+	assert.Equal(ReasonStoppedByUser, st.Reason)
+
+	_, err = w.StartJobWithOptions("", userID, &JobOptions{Restart: RestartPolicy{Mode: RestartAlways}, Durable: true}, "true")
+	require.ErrorIs(err, ErrInvalidRestartPolicy)
+
+	p := RestartPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for n, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		assert.Equal(want, p.backoff(n))
+	}
+}
+
+func TestQueues(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	w.cfg.MaxRunningJobs = 3
+	w.cfg.Queues = map[string]QueueConfig{
+		"ci":    {MaxRunningJobs: 1},
+		"batch": {Capacity: Resources{CPU: 1}},
+	}
+
+	userID := job.UserID("userID")
+	start := func(opts *JobOptions) (job.ID, error) {
+		return w.StartJobWithOptions("", userID, opts, "sleep", "10")
+	}
+
+	_, err = start(&JobOptions{Queue: "interactive"})
+	require.ErrorIs(err, ErrQueueNotFound)
+
+	_, err = start(&JobOptions{Queue: "batch", Requests: Resources{CPU: 2}})
+	require.ErrorIs(err, ErrInvalidRequests)
+
+	ci, err := start(&JobOptions{Queue: "ci"})
+	require.NoError(err)
+
+	// each queue is limited independently of the others
+	_, err = start(&JobOptions{Queue: "ci"})
+	require.ErrorIs(err, ErrWorkerSaturated)
+
+	batch, err := start(&JobOptions{Queue: "batch", Requests: Resources{CPU: 1}})
+	require.NoError(err)
+
+	_, err = start(&JobOptions{Queue: "batch", Requests: Resources{CPU: .5}})
+	require.ErrorIs(err, ErrWorkerSaturated)
+
+	def, err := start(nil)
+	require.NoError(err)
+
+	st, err := w.JobStatus("", userID, def)
+	require.NoError(err)
+	assert.Equal(DefaultQueue, st.Queue)
+
+	// as is the worker
+	_, err = start(&JobOptions{Queue: DefaultQueue})
+	require.ErrorIs(err, ErrWorkerSaturated)
+
+	alloc := w.Allocation()
+	assert.Equal(3, alloc.Jobs)
+	assert.Equal(&Allocation{Jobs: 1}, alloc.Queues["ci"])
+	assert.Equal(&Allocation{
+		Jobs:      1,
+		Requested: Resources{CPU: 1},
+		Capacity:  Resources{CPU: 1},
+	}, alloc.Queues["batch"])
+	assert.Equal(&Allocation{Jobs: 1}, alloc.Queues[DefaultQueue])
+
+	records := w.ListJobs("", userID, &ListJobsQuery{Queue: "ci"})
+	require.Len(records, 1)
+	assert.Equal(ci, records[0].ID)
+	assert.Equal("ci", records[0].Queue)
+
+	// queued jobs aren't held up by those of other queues
+	w.cfg.MaxRunningJobs = 0
+
+	ci2, err := start(&JobOptions{Queue: "ci", StartBy: time.Now().Add(time.Minute)})
+	require.NoError(err)
+
+	batch2, err := start(&JobOptions{Queue: "batch", StartBy: time.Now().Add(time.Minute)})
+	require.NoError(err)
+
+	require.NoError(w.StopJob("", userID, batch))
+
+	st, err = w.WaitJobStatus(context.Background(), "", userID, batch2, job.StatusNotStarted)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	st, err = w.JobStatus("", userID, ci2)
+	require.NoError(err)
+	assert.Equal(job.StatusNotStarted, st.Status)
+
+	for _, id := range []job.ID{ci, ci2, def, batch2} {
+		require.NoError(w.StopJob("", userID, id))
+	}
+}
+
+func TestCordonAndDrain(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	var (
+		mu     sync.Mutex
+		states []State
+	)
+	w.cfg.OnStateChange = func(st State) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, st)
+	}
+
+	userID := job.UserID("userID")
+
+	running, err := w.StartJob("", userID, "sleep", "10")
+	require.NoError(err)
+
+	// cordoning rejects new jobs, but leaves running jobs alone
+	w.Cordon()
+	assert.Equal(StateCordoned, w.State())
+
+	_, err = w.StartJob("", userID, "true")
+	require.ErrorIs(err, ErrWorkerCordoned)
+
+	st, err := w.JobStatus("", userID, running)
+	require.NoError(err)
+	assert.Equal(job.StatusRunning, st.Status)
+
+	w.Uncordon()
+	assert.Equal(StateServing, w.State())
+
+	// jobs that don't complete in time are stopped
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(w.Drain(ctx, DrainWaitForJobs), context.DeadlineExceeded)
+	assert.Equal(StateDrained, w.State())
+
+	st, err = w.JobStatus("", userID, running)
+	require.NoError(err)
+	assert.Equal(job.StopReasonShutdown, st.StopReason)
+
+	_, err = w.StartJob("", userID, "true")
+	require.ErrorIs(err, ErrWorkerCordoned)
+
+	// jobs are sent SIGTERM, and killed if they are still running once ctx is
+	// done
+	w.Uncordon()
+
+	running, err = w.StartJob("", userID, "sleep", "10")
+	require.NoError(err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	require.NoError(w.Drain(ctx, DrainStopJobs))
+
+	st, err = w.JobStatus("", userID, running)
+	require.NoError(err)
+	assert.Equal(job.StatusStopped, st.Status)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal([]State{
+		StateCordoned, StateServing,
+		StateDraining, StateDrained, StateServing,
+		StateDraining, StateDrained,
+	}, states)
+}
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	assert := assert.New(t)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(w.SelfTest(ctx))
+	assert.Empty(w.ListJobs("", SelfTestUserID, &ListJobsQuery{State: JobStateActive}))
+
+	w.Cordon()
+	err = w.SelfTest(ctx)
+	require.ErrorIs(err, ErrSelfTestFailed)
+	require.ErrorIs(err, ErrWorkerCordoned)
+
+	// the job uses the strict profile, not the default one
+	w, err = newJobWorker()
+	require.NoError(err)
+	w.cfg.IsolationProfiles = map[string]IsolationProfile{
+		IsolationStrict: {Namespaces: job.DefaultNamespaces},
+	}
+
+	err = w.SelfTest(ctx)
+	require.ErrorIs(err, ErrSelfTestFailed)
+	require.ErrorIs(err, ErrIsolationProfileNotPermitted)
+}
+
+// TestSoak continuously starts and stops jobs, and opens and closes readers of
+// their output, checking that the number of goroutines and the memory used
+// stay bounded. It is for verifying the stability of releases and only runs if
+// GO_TEST_SOAK is set to how long it should run for, e.g.
+// `GO_TEST_SOAK=2h go test -run TestSoak -timeout 0 ./pkg/worker`.
+func TestSoak(t *testing.T) {
+	// not parallel so that other tests don't affect the counts
+
+	v := os.Getenv("GO_TEST_SOAK")
+	if v == "" {
+		t.Skip("GO_TEST_SOAK is not set")
+	}
+
+	require := require.New(t)
+
+	d, err := time.ParseDuration(v)
+	require.NoError(err)
+
+	w, err := newJobWorker()
+	require.NoError(err)
+
+	// the history is bounded, but would otherwise take many iterations to fill
+	w.history.maxRecords = 10
+
+	userID := job.UserID("soak")
+
+	iteration := func() {
+		jobID, err := w.StartJob("", userID, "sh", "-c", "while true; do echo y; sleep .01; done")
+		require.NoError(err)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			r, err := w.JobOutput("", userID, jobID)
+			require.NoError(err)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer r.Close()
+				_, _ = io.ReadFull(r, make([]byte, 4))
+			}()
+		}
+		wg.Wait()
+
+		require.NoError(w.StopJob("", userID, jobID))
+		require.NoError(w.RemoveJob("", userID, jobID))
+	}
+
+	// sample returns the number of goroutines and the bytes of allocated heap
+	// objects
+	sample := func() (int, uint64) {
+		runtime.GC()
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		return runtime.NumGoroutine(), ms.HeapAlloc
+	}
+
+	// warm up so that caches and pools are filled before the baseline
+	for range 10 {
+		iteration()
+	}
+	baseGoroutines, baseHeap := sample()
+
+	deadline := time.Now().Add(d)
+	for i := 1; time.Now().Before(deadline); i++ {
+		iteration()
+
+		if i%100 != 0 {
+			continue
+		}
+
+		goroutines, heap := sample()
+		t.Logf("iterations: %d, goroutines: %d, heap: %d", i, goroutines, heap)
+		require.LessOrEqual(goroutines, baseGoroutines+10, "goroutines are leaking")
+		require.LessOrEqual(heap, 2*baseHeap+16<<20, "memory is leaking")
+	}
+
+	require.Empty(w.ListJobs("", userID, &ListJobsQuery{State: JobStateActive}))
+}
+
+func TestReexecChecksum(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	data, err := os.ReadFile(os.Args[0])
+	require.NoError(err)
+	sum := sha256.Sum256(data)
+
+	cfg := Config{
+		ReexecCommand:  os.Args[0],
+		ReexecEnv:      []string{"GO_TEST_MODE=child"},
+		ReexecChecksum: hex.EncodeToString(sum[:]),
+	}
+
+	w, err := New(&cfg)
+	require.NoError(err)
+
+	userID := job.UserID("userID")
+	jobID, err := w.StartJob("", userID, "echo", "foo")
+	require.NoError(err)
+
+	r, err := w.JobOutput("", userID, jobID)
+	require.NoError(err)
+	defer r.Close()
+
+	data, err = io.ReadAll(r)
+	require.NoError(err)
+	require.Equal("foo\n", string(data))
+
+	cfg.ReexecChecksum = strings.Repeat("0", sha256.Size*2)
+	_, err = New(&cfg)
+	require.ErrorIs(err, ErrReexecChecksumMismatch)
+}
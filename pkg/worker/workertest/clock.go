@@ -0,0 +1,167 @@
+// Package workertest provides helpers for testing programs that use a
+// worker.Worker, or the Worker itself, deterministically
+package workertest
+
+import (
+	"io"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+)
+
+// Clock is a fake clock.Clock whose time only changes when it is advanced. Set
+// it as worker.Config.Clock to test timeouts, ttls, restart backoffs and
+// start-by times without waiting for real time to pass.
+type Clock struct {
+	mu      sync.Mutex
+	cond    sync.Cond // signaled when a timer is added
+	now     time.Time
+	timers  []*timer
+	nextSeq int
+}
+
+// ensure Clock implements the clock.Clock interface
+var _ clock.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock whose time is now
+func NewClock(now time.Time) *Clock {
+	c := Clock{now: now}
+	c.cond.L = &c.mu
+	return &c
+}
+
+// Now returns the current time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f once the clock has been advanced by d
+func (c *Clock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := timer{c: c, f: f}
+	c.scheduleLocked(&t, d)
+	return &t
+}
+
+// Advance moves the clock forward by d and calls the functions of the timers
+// that fire, in the order they fire, with the clock set to the time each
+// fires at. Unlike time.AfterFunc, they are called synchronously, so their
+// effects, e.g. a job having been stopped by its timeout, are visible once
+// Advance returns. Timers that are set by the functions fire too if they are
+// due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+
+	for {
+		i := c.nextLocked()
+		if i < 0 || c.timers[i].when.After(end) {
+			break
+		}
+
+		t := c.timers[i]
+		c.timers = slices.Delete(c.timers, i, i+1)
+		c.now = t.when
+
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Timers returns the number of timers that haven't fired or been stopped
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers haven't fired or been stopped. It
+// is used to wait for the Worker to set a timer, e.g. a job's ttl once it is
+// done, before advancing the clock.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// nextLocked returns the index of the timer that fires next, timers that fire
+// at the same time fire in the order they were set. it returns -1 if there
+// are no timers. c.mu must be held.
+func (c *Clock) nextLocked() int {
+	next := -1
+	for i, t := range c.timers {
+		if next < 0 || t.when.Before(c.timers[next].when) ||
+			(t.when.Equal(c.timers[next].when) && t.seq < c.timers[next].seq) {
+			next = i
+		}
+	}
+	return next
+}
+
+// scheduleLocked sets t to fire after d. c.mu must be held.
+func (c *Clock) scheduleLocked(t *timer, d time.Duration) {
+	t.when = c.now.Add(d)
+	t.seq = c.nextSeq
+	c.nextSeq++
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+}
+
+// removeLocked stops t. it returns false if t already fired or was stopped.
+// c.mu must be held.
+func (c *Clock) removeLocked(t *timer) bool {
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	return true
+}
+
+// timer is a clock.Timer of a Clock
+type timer struct {
+	c    *Clock
+	f    func()
+	when time.Time
+	seq  int
+}
+
+// Stop prevents the timer from firing. It returns false if it already fired
+// or was stopped.
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	return t.c.removeLocked(t)
+}
+
+// Reset changes the timer to fire once the clock has been advanced by d. It
+// returns false if it had already fired or been stopped.
+func (t *timer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	active := t.c.removeLocked(t)
+	t.c.scheduleLocked(t, d)
+	return active
+}
+
+// NewRand returns a source of randomness that always produces the same bytes
+// for the same seed. Set it as worker.Config.IDRand so that job ids are the
+// same each time a test is run.
+func NewRand(seed int64) io.Reader {
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,114 @@
+package workertest
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/joshuarubin/teleport-job-worker/pkg/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+
+	var fired []string
+	record := func(name string) func() {
+		return func() {
+			fired = append(fired, name+"@"+c.Now().Sub(start).String())
+		}
+	}
+
+	c.AfterFunc(2*time.Second, record("b"))
+	c.AfterFunc(time.Second, record("a"))
+	stopped := c.AfterFunc(time.Second, record("stopped"))
+	reset := c.AfterFunc(time.Second, record("reset"))
+	c.AfterFunc(time.Second, func() {
+		// timers set while advancing fire if they are due
+		c.AfterFunc(time.Second, record("nested"))
+	})
+	assert.Equal(5, c.Timers())
+
+	assert.True(stopped.Stop())
+	assert.False(stopped.Stop())
+	assert.True(reset.Reset(3 * time.Second))
+
+	c.Advance(2 * time.Second)
+	assert.Equal([]string{"a@1s", "b@2s", "nested@2s"}, fired)
+	assert.Equal(start.Add(2*time.Second), c.Now())
+	assert.Equal(1, c.Timers())
+
+	c.Advance(time.Hour)
+	assert.Equal([]string{"a@1s", "b@2s", "nested@2s", "reset@3s"}, fired)
+	assert.Equal(start.Add(2*time.Second+time.Hour), c.Now())
+	assert.Equal(0, c.Timers())
+	assert.False(reset.Stop())
+}
+
+func TestClockBlockUntil(t *testing.T) {
+	t.Parallel()
+
+	c := NewClock(time.Time{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.BlockUntil(1)
+	}()
+
+	c.AfterFunc(time.Second, func() {})
+	<-done
+}
+
+func TestTicker(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+
+	ticker := clock.NewTicker(c, time.Second)
+
+	select {
+	case <-ticker.C:
+		assert.Fail("ticked before the clock was advanced")
+	default:
+	}
+
+	c.Advance(time.Second)
+	assert.Equal(start.Add(time.Second), <-ticker.C)
+
+	// ticks that aren't received are dropped
+	c.Advance(3 * time.Second)
+	assert.Equal(start.Add(2*time.Second), <-ticker.C)
+
+	ticker.Stop()
+	assert.Equal(0, c.Timers())
+
+	c.Advance(time.Hour)
+	select {
+	case <-ticker.C:
+		assert.Fail("ticked after it was stopped")
+	default:
+	}
+}
+
+func TestNewRand(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	a := make([]byte, 16)
+	_, err := io.ReadFull(NewRand(1), a)
+	require.NoError(err)
+
+	b := make([]byte, 16)
+	_, err = io.ReadFull(NewRand(1), b)
+	require.NoError(err)
+
+	require.Equal(a, b)
+}